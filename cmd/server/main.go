@@ -8,6 +8,7 @@ import (
 	"github.com/sejo412/ya-metrics/internal/app/server"
 	"github.com/sejo412/ya-metrics/internal/config"
 	"github.com/sejo412/ya-metrics/internal/logger"
+	"github.com/sejo412/ya-metrics/internal/models"
 	"github.com/sejo412/ya-metrics/internal/storage"
 )
 
@@ -35,12 +36,31 @@ func run() error {
 	if err != nil {
 		return fmt.Errorf("parse database DSN: %w", err)
 	}
+	dsn = cfg.DatabaseOptions(dsn)
+	ttlPolicy, err := cfg.TTLPolicy()
+	if err != nil {
+		return fmt.Errorf("parse metric ttl: %w", err)
+	}
+	tenants, err := cfg.Tenants()
+	if err != nil {
+		return fmt.Errorf("parse tenant keys: %w", err)
+	}
+	graphiteMapping, err := cfg.GraphiteMapping()
+	if err != nil {
+		return fmt.Errorf("parse graphite rules: %w", err)
+	}
+	influxCounters, err := models.NewInfluxCounters(cfg.InfluxCounters)
+	if err != nil {
+		return fmt.Errorf("parse influx counters: %w", err)
+	}
 
 	switch dsn.Scheme {
 	case "memory":
 		store = storage.NewMemoryStorage()
 	case "postgres", "postgresql":
 		store = storage.NewPostgresStorage()
+	case storage.FileScheme:
+		store = storage.NewFileStorage()
 	default:
 		return fmt.Errorf("database \"%s\" not supported", cfg.DatabaseDSN)
 	}
@@ -54,30 +74,44 @@ func run() error {
 		return fmt.Errorf("error init database: %w", err)
 	}
 
-	// try restore metrics
-	skipRestore := false
-	if *cfg.Restore {
-		f, err := os.Open(cfg.StoreFile)
-		if err != nil {
-			log.Errorw("error open file",
-				"file", cfg.StoreFile)
-			skipRestore = true
+	// try restore metrics (only memory storage keeps its state in store file,
+	// another storages keep it themselves and restore would add counters twice)
+	if *cfg.Restore && storage.UsesStoreFile(dsn.Scheme) {
+		if err = loadFile(store, cfg.StoreFile); err != nil {
+			log.Errorw("error restore metrics",
+				"file", cfg.StoreFile,
+				"error", err)
 		}
-		if !skipRestore {
-			if err = store.Load(context.TODO(), f); err != nil {
-				log.Errorw("error load file",
-					"file", cfg.StoreFile)
-			}
-			if err := f.Close(); err != nil {
-				log.Errorw("error close file",
-					"file", cfg.StoreFile)
-			}
+	}
+	// one-off import of metrics requested explicitly
+	if cfg.ImportFile != "" {
+		if err = loadFile(store, cfg.ImportFile); err != nil {
+			return fmt.Errorf("error import metrics: %w", err)
 		}
 	}
 	ctx := context.Background()
 	return server.StartServer(ctx, &config.Options{
-		Config:  *cfg,
-		Storage: store,
-		Logger:  logger.Logger{Logger: log},
+		Config:          *cfg,
+		Storage:         store,
+		Logger:          logger.Logger{Logger: log},
+		TTLPolicy:       ttlPolicy,
+		Tenants:         tenants,
+		GraphiteMapping: graphiteMapping,
+		InfluxCounters:  influxCounters,
 	})
 }
+
+// loadFile loads saved metrics from file into store.
+func loadFile(store config.Storage, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return fmt.Errorf("error open file: %w", err)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	if err = store.Load(context.TODO(), f); err != nil {
+		return fmt.Errorf("error load file: %w", err)
+	}
+	return nil
+}
@@ -13,7 +13,6 @@ import (
 	"os/signal"
 	"runtime"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 
@@ -35,6 +34,11 @@ const (
 	baseInt = 10
 )
 
+// histogramUnits - units of reported histograms.
+var histogramUnits = map[string]string{
+	models.MetricNameGCPauseDuration: "seconds",
+}
+
 // NewAgent returns new Agent object.
 func NewAgent(cfg *config.AgentConfig) *Agent {
 	m := &metrics{
@@ -145,9 +149,26 @@ func (a *Agent) Poll() {
 	a.Metrics.gauge.memStats = m
 	a.Metrics.gauge.randomValue = float64(cryptoRand.Uint64())
 	a.Metrics.counter.pollCount = 1
+	if a.Metrics.histogram.gcPause == nil {
+		a.Metrics.histogram.gcPause = models.NewHistogram(a.Config.GCPauseBounds)
+	}
+	observeGCPauses(a.Metrics.histogram.gcPause, m, a.Metrics.histogram.numGC)
+	a.Metrics.histogram.numGC = m.NumGC
 	a.Metrics.mutex.Unlock()
 }
 
+// observeGCPauses adds durations of GC pauses happened after numGC cycles to histogram.
+// Only last pauses kept by runtime are observed if there were too many cycles since numGC.
+func observeGCPauses(h *models.Histogram, m *runtime.MemStats, numGC uint32) {
+	kept := uint32(len(m.PauseNs))
+	if m.NumGC-numGC > kept {
+		numGC = m.NumGC - kept
+	}
+	for i := numGC; i < m.NumGC; i++ {
+		h.Observe(float64(m.PauseNs[i%kept]) / float64(time.Second))
+	}
+}
+
 // PollPS collects ps metrics.
 func (a *Agent) PollPS() {
 	log := a.Config.Logger.Logger
@@ -176,6 +197,7 @@ func (a *Agent) Report(ctx context.Context) {
 	report.mutex.Lock()
 	report.gauge = make(map[string]float64)
 	report.counter = make(map[string]int64)
+	report.histogram = make(map[string]*models.Histogram)
 	a.Metrics.mutex.Lock()
 	runtimeMetrics := models.RuntimeMetricsMap(a.Metrics.gauge.memStats)
 	a.Metrics.mutex.Unlock()
@@ -197,6 +219,11 @@ func (a *Agent) Report(ctx context.Context) {
 	for core, value := range a.Metrics.gauge.psStats.cpuUtilization {
 		report.gauge[core] = value
 	}
+	// histograms are reported as delta since previous report
+	if h := a.Metrics.histogram.gcPause; h != nil {
+		report.histogram[models.MetricNameGCPauseDuration] = h
+		a.Metrics.histogram.gcPause = models.NewHistogram(h.Bounds)
+	}
 	a.Metrics.mutex.Unlock()
 	report.mutex.Unlock()
 
@@ -228,15 +255,19 @@ func (a *Agent) Report(ctx context.Context) {
 
 	// Try post without batch
 	report.mutex.Lock()
-	lenMetrics := len(report.gauge) + len(report.counter)
-	metricsChan := make(chan string, lenMetrics)
+	lenMetrics := len(report.gauge) + len(report.counter) + len(report.histogram)
+	metricsChan := make(chan models.Metric, lenMetrics)
 	for name, value := range report.gauge {
-		mpath := models.MetricPathPostPrefix + "/" + models.MetricKindGauge + "/" + name
-		metricsChan <- fmt.Sprintf("%s/%v", mpath, value)
+		metricsChan <- models.Metric{Kind: models.MetricKindGauge, Name: name, Value: value}
 	}
 	for name, value := range report.counter {
-		mpath := models.MetricPathPostPrefix + "/" + models.MetricKindCounter + "/" + name
-		metricsChan <- fmt.Sprintf("%s/%v", mpath, value)
+		metricsChan <- models.Metric{Kind: models.MetricKindCounter, Name: name, Delta: value}
+	}
+	// histogram can't be posted by path
+	if !a.Config.PathStyle {
+		for name, value := range report.histogram {
+			metricsChan <- histogramMetric(name, value)
+		}
 	}
 	report.mutex.Unlock()
 	close(metricsChan)
@@ -245,7 +276,7 @@ func (a *Agent) Report(ctx context.Context) {
 	var wg sync.WaitGroup
 	for w := 0; w < a.Config.RateLimit; w++ {
 		wg.Add(1)
-		go func(ch <-chan string, wg *sync.WaitGroup) {
+		go func(ch <-chan models.Metric, wg *sync.WaitGroup) {
 			defer wg.Done()
 			var err error
 			for metric := range ch {
@@ -293,11 +324,29 @@ func (a *Agent) Sign(body *[]byte, r *http.Request) {
 	r.Header.Set(models.HTTPHeaderSign, hash)
 }
 
+// metricPath returns path for posting metric in plain text.
+func metricPath(metric models.Metric) (string, error) {
+	var value string
+	switch metric.Kind {
+	case models.MetricKindGauge:
+		value = strconv.FormatFloat(metric.Value, 'f', -1, 64)
+	case models.MetricKindCounter:
+		value = strconv.FormatInt(metric.Delta, baseInt)
+	default:
+		return "", fmt.Errorf("unknown metric type: %s", metric.Kind)
+	}
+	return models.MetricPathPostPrefix + "/" + metric.Kind + "/" + metric.Name + "/" + value, nil
+}
+
 // postMetricByPath push metrics to server.
-func (a *Agent) postMetricByPath(ctx context.Context, metric string) error {
+func (a *Agent) postMetricByPath(ctx context.Context, metric models.Metric) error {
 	address := fmt.Sprintf("%s://%s", config.ServerScheme, a.Config.Address)
 	log := a.Config.Logger.Logger
-	uri := address + "/" + metric
+	mpath, err := metricPath(metric)
+	if err != nil {
+		return err
+	}
+	uri := address + "/" + mpath
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, http.NoBody)
 	if err != nil {
@@ -310,33 +359,20 @@ func (a *Agent) postMetricByPath(ctx context.Context, metric string) error {
 	defer func() {
 		_ = resp.Body.Close()
 	}()
-	log.Infof("Sent %s: %d", metric, resp.StatusCode)
+	log.Infof("Sent %s: %d", mpath, resp.StatusCode)
 	return nil
 }
 
-func (a *Agent) postMetric(ctx context.Context, metric string) error {
+func (a *Agent) postMetric(ctx context.Context, metric models.Metric) error {
 	address := fmt.Sprintf("%s://%s", config.ServerScheme, a.Config.Address)
 	log := a.Config.Logger.Logger
-	splitedMetric := strings.Split(metric, "/")
-	m := models.MetricV2{
-		ID:    splitedMetric[2],
-		MType: splitedMetric[1],
-	}
-	switch m.MType {
-	case "gauge":
-		v, err := strconv.ParseFloat(splitedMetric[3], 64)
-		if err != nil {
-			return fmt.Errorf("failed parse gauge value: %w", err)
-		}
-		m.Value = &v
-	case "counter":
-		v, err := strconv.ParseInt(splitedMetric[3], baseInt, 64)
-		if err != nil {
-			return fmt.Errorf("failed parse counter value: %w", err)
-		}
-		m.Delta = &v
-	default:
-		return fmt.Errorf("unknown metric type: %s", m.MType)
+	if metric.Kind != models.MetricKindGauge && metric.Kind != models.MetricKindCounter &&
+		metric.Kind != models.MetricKindHistogram {
+		return fmt.Errorf("unknown metric type: %s", metric.Kind)
+	}
+	m, err := models.ConvertV1ToV2(&metric)
+	if err != nil {
+		return fmt.Errorf("failed convert metric: %w", err)
 	}
 
 	body, err := json.Marshal(m)
@@ -408,7 +444,7 @@ func (a *Agent) postMetricsBatch(ctx context.Context, report *report) error {
 func reportToMetricsV2(report *report) []models.MetricV2 {
 	report.mutex.Lock()
 	defer report.mutex.Unlock()
-	metrics := make([]models.MetricV2, 0, len(report.gauge)+len(report.counter))
+	metrics := make([]models.MetricV2, 0, len(report.gauge)+len(report.counter)+len(report.histogram))
 	for name, value := range report.gauge {
 		metric := models.MetricV2{
 			ID:    name,
@@ -425,13 +461,28 @@ func reportToMetricsV2(report *report) []models.MetricV2 {
 		}
 		metrics = append(metrics, metric)
 	}
+	for name, value := range report.histogram {
+		metric := histogramMetric(name, value)
+		m, _ := models.ConvertV1ToV2(&metric)
+		metrics = append(metrics, *m)
+	}
 	return metrics
 }
 
+// histogramMetric returns histogram metric with unit of reported histogram.
+func histogramMetric(name string, h *models.Histogram) models.Metric {
+	return models.Metric{
+		Kind:      models.MetricKindHistogram,
+		Name:      name,
+		Histogram: h,
+		Unit:      histogramUnits[name],
+	}
+}
+
 func reportToPbMetrics(report *report) []*pb.Metric {
 	report.mutex.Lock()
 	defer report.mutex.Unlock()
-	m := make([]*pb.Metric, 0, len(report.gauge)+len(report.counter))
+	m := make([]*pb.Metric, 0, len(report.gauge)+len(report.counter)+len(report.histogram))
 	for name, value := range report.gauge {
 		kind := pb.MType_GAUGE
 		m = append(m, &pb.Metric{
@@ -448,6 +499,10 @@ func reportToPbMetrics(report *report) []*pb.Metric {
 			Delta: &value,
 		})
 	}
+	for name, value := range report.histogram {
+		metric, _ := models.ConvertV1ToPb(histogramMetric(name, value))
+		m = append(m, metric)
+	}
 	return m
 }
 
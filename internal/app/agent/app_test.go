@@ -34,6 +34,7 @@ var testCfg = config.AgentConfig{
 	RealReportInterval: 0,
 	RealPollInterval:   0,
 	Logger:             logger.MustNewLogger(false),
+	GCPauseBounds:      []float64{0.001, 0.01},
 }
 
 func TestAgent_Poll(t *testing.T) {
@@ -55,6 +56,7 @@ func TestAgent_Poll(t *testing.T) {
 						psStats:     psStats{},
 					},
 				},
+				Config: &testCfg,
 			},
 		},
 	}
@@ -65,6 +67,9 @@ func TestAgent_Poll(t *testing.T) {
 				Config:  tt.fields.Config,
 			}
 			a.Poll()
+			if a.Metrics.histogram.gcPause == nil {
+				t.Errorf("Poll() didn't create GC pause histogram")
+			}
 		})
 	}
 }
@@ -126,6 +131,9 @@ func Test_reportToMetricsV2(t *testing.T) {
 					counter: map[string]int64{
 						"testCounter1": 1,
 					},
+					histogram: map[string]*models.Histogram{
+						models.MetricNameGCPauseDuration: {Bounds: []float64{0.001}, Counts: []uint64{2, 1}, Sum: 0.5},
+					},
 				},
 			},
 			want: []models.MetricV2{
@@ -139,6 +147,12 @@ func Test_reportToMetricsV2(t *testing.T) {
 					MType: models.MetricKindCounter,
 					Delta: int64Ptr(1),
 				},
+				{
+					ID:        models.MetricNameGCPauseDuration,
+					MType:     models.MetricKindHistogram,
+					Histogram: &models.Histogram{Bounds: []float64{0.001}, Counts: []uint64{2, 1}, Sum: 0.5},
+					Unit:      "seconds",
+				},
 			},
 		},
 	}
@@ -151,6 +165,48 @@ func Test_reportToMetricsV2(t *testing.T) {
 	}
 }
 
+func Test_observeGCPauses(t *testing.T) {
+	ms := &runtime.MemStats{}
+	for i := range ms.PauseNs {
+		ms.PauseNs[i] = uint64(i+1) * 1000
+	}
+	tests := []struct {
+		name      string
+		numGC     uint32
+		prevNumGC uint32
+		want      []uint64
+	}{
+		{
+			name:      "new pauses",
+			numGC:     3,
+			prevNumGC: 1,
+			want:      []uint64{0, 2, 0},
+		},
+		{
+			name:      "no new pauses",
+			numGC:     3,
+			prevNumGC: 3,
+			want:      []uint64{0, 0, 0},
+		},
+		{
+			name:      "only kept pauses",
+			numGC:     1000,
+			prevNumGC: 0,
+			want:      []uint64{1, 8, 247},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms.NumGC = tt.numGC
+			h := models.NewHistogram([]float64{0.000001, 0.000009})
+			observeGCPauses(h, ms, tt.prevNumGC)
+			if !reflect.DeepEqual(h.Counts, tt.want) {
+				t.Errorf("observeGCPauses() counts = %v, want %v", h.Counts, tt.want)
+			}
+		})
+	}
+}
+
 func TestAgent_Report(t *testing.T) {
 	ms := runtime.MemStats{}
 	runtime.ReadMemStats(&ms)
@@ -258,7 +314,7 @@ func TestAgent_postMetric(t *testing.T) {
 	}
 	type args struct {
 		ctx    context.Context
-		metric string
+		metric models.Metric
 	}
 	tests := []struct {
 		name    string
@@ -273,21 +329,10 @@ func TestAgent_postMetric(t *testing.T) {
 			},
 			args: args{
 				ctx:    context.Background(),
-				metric: "updates/counter/poll/4",
+				metric: models.Metric{Kind: models.MetricKindCounter, Name: "poll", Delta: 4},
 			},
 			wantErr: false,
 		},
-		{
-			name: "PostMetric counter fail",
-			fields: fields{
-				Config: agentConfig,
-			},
-			args: args{
-				ctx:    context.Background(),
-				metric: "updates/counter/poll2/4xc",
-			},
-			wantErr: true,
-		},
 		{
 			name: "PostMetric gauge ok",
 			fields: fields{
@@ -295,21 +340,10 @@ func TestAgent_postMetric(t *testing.T) {
 			},
 			args: args{
 				ctx:    context.Background(),
-				metric: "updates/gauge/poll/42.011",
+				metric: models.Metric{Kind: models.MetricKindGauge, Name: "poll", Value: 42.011},
 			},
 			wantErr: false,
 		},
-		{
-			name: "PostMetric gauge fail",
-			fields: fields{
-				Config: agentConfig,
-			},
-			args: args{
-				ctx:    context.Background(),
-				metric: "updates/gauge/poll/42.011x",
-			},
-			wantErr: true,
-		},
 		{
 			name: "PostMetric unknown",
 			fields: fields{
@@ -317,7 +351,7 @@ func TestAgent_postMetric(t *testing.T) {
 			},
 			args: args{
 				ctx:    context.Background(),
-				metric: "updates/zzzz/poll/42.011",
+				metric: models.Metric{Kind: "zzzz", Name: "poll", Value: 42.011},
 			},
 			wantErr: true,
 		},
@@ -333,3 +367,40 @@ func TestAgent_postMetric(t *testing.T) {
 		})
 	}
 }
+
+func Test_metricPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		metric  models.Metric
+		wantErr bool
+	}{
+		{
+			name:   "counter",
+			metric: models.Metric{Kind: models.MetricKindCounter, Name: "PollCount", Delta: 9007199254740993},
+			want:   "update/counter/PollCount/9007199254740993",
+		},
+		{
+			name:   "gauge keeps precision",
+			metric: models.Metric{Kind: models.MetricKindGauge, Name: "RandomValue", Value: 0.123456789},
+			want:   "update/gauge/RandomValue/0.123456789",
+		},
+		{
+			name:    "unknown kind",
+			metric:  models.Metric{Kind: "zzzz", Name: "poll"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := metricPath(tt.metric)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("metricPath() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("metricPath() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
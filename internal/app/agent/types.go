@@ -6,6 +6,7 @@ import (
 	"sync"
 
 	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
 )
 
 type Agent struct {
@@ -15,9 +16,10 @@ type Agent struct {
 }
 
 type metrics struct {
-	gauge   gauge
-	counter counter
-	mutex   sync.Mutex
+	gauge     gauge
+	counter   counter
+	histogram histogram
+	mutex     sync.Mutex
 }
 
 type gauge struct {
@@ -30,10 +32,18 @@ type counter struct {
 	pollCount int64
 }
 
+type histogram struct {
+	// gcPause - durations of GC pauses in seconds since last report, nil before first poll
+	gcPause *models.Histogram
+	// numGC - count of GC cycles already observed
+	numGC uint32
+}
+
 type report struct {
-	gauge   map[string]float64
-	counter map[string]int64
-	mutex   sync.Mutex
+	gauge     map[string]float64
+	counter   map[string]int64
+	histogram map[string]*models.Histogram
+	mutex     sync.Mutex
 }
 
 type psStats struct {
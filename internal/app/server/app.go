@@ -4,19 +4,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"iter"
 	"log"
 	"net"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/sejo412/ya-metrics/internal/config"
 	"github.com/sejo412/ya-metrics/internal/models"
+	"github.com/sejo412/ya-metrics/internal/storage"
+	"github.com/sejo412/ya-metrics/pkg/utils"
 )
 
-// CheckMetricKind returns error if metric value does not match kind.
-func CheckMetricKind(metric models.Metric) error {
-	_, err := models.GetMetricValueString(metric)
+// ParseMetric returns metric with value parsed from string according to kind.
+func ParseMetric(kind, name, value string) (models.Metric, error) {
+	metric, err := models.ParseMetric(kind, name, value)
+	if err != nil {
+		return models.Metric{}, metricValueError(err)
+	}
+	return metric, nil
+}
+
+// metricValueError converts error of metric value to bad request error.
+func metricValueError(err error) error {
 	switch {
 	case errors.Is(err, models.ErrNotFloat):
 		return fmt.Errorf("%w: %s", models.ErrHTTPBadRequest, models.MessageNotFloat)
@@ -25,35 +37,117 @@ func CheckMetricKind(metric models.Metric) error {
 	case errors.Is(err, models.ErrNotSupported):
 		return fmt.Errorf("%w: %s", models.ErrHTTPBadRequest, models.MessageNotSupported)
 	}
-	return nil
+	return err
 }
 
-// GetMetricValue returns metric value by name.
-func GetMetricValue(st config.Storage, name string) (string, error) {
-	metric, err := st.Get(context.TODO(), "", name)
+// GetMetricValue returns metric value by kind and name.
+func GetMetricValue(ctx context.Context, st config.Storage, kind, name string) (string, error) {
+	metric, err := st.Get(ctx, kind, name)
 	if err != nil {
 		return "", err
 	}
 	return models.GetMetricValueString(metric)
 }
 
-// GetAllMetricValues returns all metrics.
-func GetAllMetricValues(st config.Storage) map[string]string {
-	ctx := context.Background()
-	result := make(map[string]string)
-	metrics, err := st.GetAll(ctx)
+// GetMetric returns metric by kind, name and exactly matched labels.
+func GetMetric(ctx context.Context, st config.Storage, kind, name string, labels models.Labels) (models.Metric,
+	error) {
+	if len(labels) == 0 {
+		return st.Get(ctx, kind, name)
+	}
+	metrics, err := st.Select(ctx, kind, name, models.EqualMatchers(labels)...)
 	if err != nil {
-		return nil
+		return models.Metric{}, err
 	}
 	for _, metric := range metrics {
+		if metric.Labels.Equal(labels) {
+			return metric, nil
+		}
+	}
+	return models.Metric{}, models.ErrHTTPNotFound
+}
+
+// DeleteMetric removes metric of all label sets by kind and name.
+func DeleteMetric(ctx context.Context, st config.Storage, kind, name string) error {
+	return st.Delete(ctx, kind, name)
+}
+
+// DeleteMetrics removes metrics with name prefix and returns count of removed metrics.
+func DeleteMetrics(ctx context.Context, st config.Storage, prefix string) (int, error) {
+	if prefix == "" {
+		return 0, fmt.Errorf("%w: empty prefix", models.ErrHTTPBadRequest)
+	}
+	return st.DeleteByPrefix(ctx, prefix)
+}
+
+// ResetCounter sets counter value of all label sets to zero.
+func ResetCounter(ctx context.Context, st config.Storage, name string) error {
+	return st.ResetCounter(ctx, name)
+}
+
+// GetSchemaVersion returns schema version of storage. Returns ErrNotSupported if storage schema is not versioned.
+func GetSchemaVersion(ctx context.Context, st config.Storage) (int, error) {
+	versioner, ok := st.(config.SchemaVersioner)
+	if !ok {
+		return 0, fmt.Errorf("%w: schema version", models.ErrNotSupported)
+	}
+	return versioner.SchemaVersion(ctx)
+}
+
+// GetAllMetrics returns sorted metrics.
+func GetAllMetrics(ctx context.Context, st config.Storage) ([]models.Metric, error) {
+	return collectMetrics(st.All(ctx))
+}
+
+// GetFreshMetrics returns sorted metrics which are not stale at now according to policy.
+func GetFreshMetrics(ctx context.Context, st config.Storage, policy storage.TTLPolicy, now time.Time) (
+	[]models.Metric, error) {
+	return collectMetrics(st.AllFresh(ctx, policy, now))
+}
+
+// collectMetrics returns sorted metrics of iterator, stops on first error.
+func collectMetrics(all iter.Seq2[models.Metric, error]) ([]models.Metric, error) {
+	metrics := make([]models.Metric, 0)
+	for metric, err := range all {
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	slices.SortFunc(metrics, models.CompareMetrics)
+	return metrics, nil
+}
+
+// GetAllMetricValues returns all metrics keyed by kind, name and labels like "gauge/name{labels}".
+// Metrics stale according to policy are hidden.
+func GetAllMetricValues(ctx context.Context, st config.Storage, policy storage.TTLPolicy) map[string]string {
+	result := make(map[string]string)
+	for metric, err := range st.AllFresh(ctx, policy, time.Now()) {
+		if err != nil {
+			return nil
+		}
 		value, err := models.GetMetricValueString(metric)
 		if err == nil {
-			result[metric.Name] = value
+			result[metric.Kind+"/"+metric.Name+metric.Labels.String()] = value
 		}
 	}
 	return result
 }
 
+// signedTenant returns tenant whose key signs data with hash, default tenant for main key.
+// Returns false if data is not signed by any key.
+func signedTenant(key string, tenants map[string]string, data []byte, hash string) (string, bool) {
+	if key != "" && utils.Hash(data, key) == hash {
+		return models.DefaultTenant, true
+	}
+	for k, tenant := range tenants {
+		if utils.Hash(data, k) == hash {
+			return tenant, true
+		}
+	}
+	return "", false
+}
+
 // FlushingMetrics saves metrics to file.
 func FlushingMetrics(ctx context.Context, st config.Storage, file string, interval int) {
 	timer := time.NewTimer(time.Duration(interval) * time.Second)
@@ -74,6 +168,38 @@ func FlushingMetrics(ctx context.Context, st config.Storage, file string, interv
 	}
 }
 
+// PruningHistory removes metrics history older than retention on timer.
+func PruningHistory(ctx context.Context, st config.Storage, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := st.PruneHistory(ctx, time.Now().Add(-retention)); err != nil {
+				log.Printf("failed prune history: %v", err)
+			}
+		}
+	}
+}
+
+// ExpiringMetrics removes stale metrics on timer.
+func ExpiringMetrics(ctx context.Context, st config.Storage, policy storage.TTLPolicy, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := st.DeleteStale(ctx, policy, time.Now()); err != nil {
+				log.Printf("failed delete stale metrics: %v", err)
+			}
+		}
+	}
+}
+
 func flushToFile(ctx context.Context, st config.Storage, file string) error {
 	f, err := os.Create(file)
 	if err != nil {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/sejo412/ya-metrics/internal/storage"
 	"github.com/stretchr/testify/assert"
@@ -52,16 +53,47 @@ func TestParsePostUpdateRequestJSON(t *testing.T) {
 	}
 }
 
+func TestGetFreshMetrics(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	_ = store.MassUpsert(context.Background(), []models.Metric{
+		{Kind: "gauge", Name: "test2", Value: 15},
+		{Kind: "gauge", Name: "test1", Value: 12},
+		{Kind: "counter", Name: "test1", Delta: 3},
+	})
+	all, err := GetAllMetrics(context.Background(), store)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Metric{
+		{Kind: "counter", Name: "test1", Delta: 3},
+		{Kind: "gauge", Name: "test1", Value: 12},
+		{Kind: "gauge", Name: "test2", Value: 15},
+	}, all)
+	policy := storage.TTLPolicy{Rules: []storage.TTLRule{{Pattern: "test1", TTL: time.Hour}}}
+	fresh, err := GetFreshMetrics(context.Background(), store, policy, time.Now().Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Metric{{Kind: "gauge", Name: "test2", Value: 15}}, fresh)
+}
+
 func TestGetAllMetricValues(t *testing.T) {
 	tests := []struct {
-		want map[string]string
-		name string
+		want   map[string]string
+		name   string
+		policy storage.TTLPolicy
 	}{
 		{
 			name: "Get all metric values",
 			want: map[string]string{
-				"test1": "12",
-				"test2": "15",
+				"gauge/test1": "12",
+				"gauge/test2": "15",
+			},
+		},
+		{
+			name: "Hide stale metrics",
+			want: map[string]string{
+				"gauge/test1": "12",
+			},
+			policy: storage.TTLPolicy{
+				Rules: []storage.TTLRule{{Pattern: "test2", TTL: time.Nanosecond}},
+				TTL:   time.Hour,
 			},
 		},
 	}
@@ -69,16 +101,17 @@ func TestGetAllMetricValues(t *testing.T) {
 	_ = store.Upsert(context.Background(), models.Metric{
 		Kind:  "gauge",
 		Name:  "test1",
-		Value: "12",
+		Value: 12,
 	})
 	_ = store.Upsert(context.Background(), models.Metric{
 		Kind:  "gauge",
 		Name:  "test2",
-		Value: "15",
+		Value: 15,
 	})
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equalf(t, tt.want, GetAllMetricValues(store), "GetAllMetricValues(%v)", store)
+			got := GetAllMetricValues(context.Background(), store, tt.policy)
+			assert.Equalf(t, tt.want, got, "GetAllMetricValues(%v)", store)
 		})
 	}
 }
@@ -196,3 +229,33 @@ func Test_isNetsContainsIP(t *testing.T) {
 		})
 	}
 }
+
+func TestPruningHistory(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	_ = store.Upsert(context.Background(), models.Metric{
+		Kind:  "gauge",
+		Name:  "test1",
+		Value: 12,
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	PruningHistory(ctx, store, 0, 10*time.Millisecond)
+	samples, err := store.GetRange(context.Background(), "gauge", "test1", time.Time{}, time.Now())
+	assert.NoError(t, err)
+	assert.Empty(t, samples)
+}
+
+func TestExpiringMetrics(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	_ = store.MassUpsert(context.Background(), []models.Metric{
+		{Kind: "gauge", Name: "test1", Value: 12},
+		{Kind: "gauge", Name: "test2", Value: 15},
+	})
+	policy := storage.TTLPolicy{Rules: []storage.TTLRule{{Pattern: "test1", TTL: time.Nanosecond}}}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ExpiringMetrics(ctx, store, policy, 10*time.Millisecond)
+	metrics, err := GetAllMetrics(context.Background(), store)
+	assert.NoError(t, err)
+	assert.Equal(t, []models.Metric{{Kind: "gauge", Name: "test2", Value: 15}}, metrics)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
+)
+
+// graphiteBatchSize - max count of metrics written to storage at once.
+const graphiteBatchSize = 1000
+
+// GraphiteServer receives Graphite plaintext metrics over TCP and writes them to storage as gauges.
+// Lines received together are written in one batch.
+type GraphiteServer struct {
+	opts  config.Options
+	conns map[net.Conn]struct{}
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+}
+
+func NewGraphiteServerWithOptions(opts *config.Options) *GraphiteServer {
+	return &GraphiteServer{opts: *opts, conns: make(map[net.Conn]struct{})}
+}
+
+// Serve accepts connections from listener until ctx is done or listener fails.
+// Connections are closed then and metrics received from them are written before return.
+func (s *GraphiteServer) Serve(ctx context.Context, ln net.Listener) error {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+		case <-done:
+		}
+		// unblocks Accept and reading of connections
+		_ = ln.Close()
+		s.mu.Lock()
+		for conn := range s.conns {
+			_ = conn.Close()
+		}
+		s.mu.Unlock()
+	}()
+	err := s.accept(ctx, ln)
+	close(done)
+	<-stopped
+	s.wg.Wait()
+	if ctx.Err() != nil && errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// accept handles connections from listener until accepting fails.
+func (s *GraphiteServer) accept(ctx context.Context, ln net.Listener) error {
+	log := s.opts.Logger.Logger
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		host, _, _ := net.SplitHostPort(conn.RemoteAddr().String())
+		if len(s.opts.TrustedSubnets) > 0 && !isNetsContainsIP(host, s.opts.TrustedSubnets) {
+			log.Debugw("reject graphite connection from untrusted address", "address", conn.RemoteAddr().String())
+			_ = conn.Close()
+			continue
+		}
+		s.mu.Lock()
+		if ctx.Err() != nil {
+			// connections are already closed by Serve
+			s.mu.Unlock()
+			_ = conn.Close()
+			continue
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+		go func() {
+			defer s.wg.Done()
+			s.handle(models.WithSource(context.WithoutCancel(ctx), host), conn)
+		}()
+	}
+}
+
+// handle writes metrics received from connection until it is closed. Invalid lines are skipped.
+func (s *GraphiteServer) handle(ctx context.Context, conn net.Conn) {
+	log := s.opts.Logger.Logger
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		_ = conn.Close()
+	}()
+	reader := bufio.NewReader(conn)
+	batch := make([]models.Metric, 0, graphiteBatchSize)
+	for {
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			metric, er := models.ParseGraphiteLine(line, s.opts.GraphiteMapping)
+			if er != nil {
+				log.Debugw("skip graphite line", "address", conn.RemoteAddr().String(), "error", er)
+			} else {
+				batch = append(batch, metric)
+			}
+		}
+		// batch is written when all received lines are read
+		if len(batch) > 0 && (len(batch) == graphiteBatchSize || reader.Buffered() == 0 || err != nil) {
+			if er := s.opts.Storage.MassUpsert(ctx, batch); er != nil {
+				log.Errorw("write graphite metrics", "count", len(batch), "error", er)
+			}
+			batch = batch[:0]
+		}
+		if err != nil {
+			return
+		}
+	}
+}
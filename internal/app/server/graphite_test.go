@@ -0,0 +1,119 @@
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
+	"github.com/sejo412/ya-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// startGraphiteServer starts Graphite server on random port and returns its address and result of Serve.
+func startGraphiteServer(t *testing.T, ctx context.Context, opts *config.Options) (string, chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error)
+	s := NewGraphiteServerWithOptions(opts)
+	go func() {
+		served <- s.Serve(ctx, ln)
+	}()
+	return ln.Addr().String(), served
+}
+
+func TestGraphiteServer_Serve(t *testing.T) {
+	rule, err := models.NewGraphiteRule("servers.*.cpu", "cpu", models.Labels{"host": "$2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	st := storage.NewMemoryStorage()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, served := startGraphiteServer(t, ctx, &config.Options{
+		Storage:         st,
+		Logger:          *lm,
+		GraphiteMapping: models.GraphiteMapping{Rules: []models.GraphiteRule{rule}},
+	})
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	_, err = conn.Write([]byte("servers.web1.cpu 0.5 1700000000\ninvalid\njobs.backup.duration 42\n"))
+	assert.NoError(t, err)
+	labels := models.Labels{"host": "web1"}
+	// lines are received asynchronously
+	assert.Eventually(t, func() bool {
+		_, err := GetMetric(context.Background(), st, models.MetricKindGauge, "cpu", labels)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+	cpu, err := GetMetric(context.Background(), st, models.MetricKindGauge, "cpu", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.5, cpu.Value)
+	meta, err := st.GetMetadata(context.Background(), cpu)
+	assert.NoError(t, err)
+	assert.Equal(t, "127.0.0.1", meta.Source)
+	duration, err := st.Get(context.Background(), models.MetricKindGauge, "jobs_backup_duration")
+	assert.NoError(t, err)
+	assert.Equal(t, 42.0, duration.Value)
+
+	// line without newline is written when client closes connection
+	_, err = conn.Write([]byte("jobs.backup.size 7"))
+	assert.NoError(t, err)
+	assert.NoError(t, conn.(*net.TCPConn).CloseWrite())
+	assert.Eventually(t, func() bool {
+		size, err := st.Get(context.Background(), models.MetricKindGauge, "jobs_backup_size")
+		return err == nil && size.Value == 7
+	}, time.Second, 10*time.Millisecond)
+
+	// open connections don't block shutdown
+	idle, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = idle.Close()
+	}()
+	cancel()
+	select {
+	case err = <-served:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server not stopped")
+	}
+}
+
+func TestGraphiteServer_ServeUntrusted(t *testing.T) {
+	_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	addr, _ := startGraphiteServer(t, ctx, &config.Options{
+		Storage:        storage.NewMemoryStorage(),
+		Logger:         *lm,
+		TrustedSubnets: []net.IPNet{*trusted},
+	})
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	// connection is closed by server
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, os.ErrDeadlineExceeded)
+}
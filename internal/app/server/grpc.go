@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"net/netip"
 
@@ -11,11 +12,12 @@ import (
 	"github.com/sejo412/ya-metrics/internal/config"
 	"github.com/sejo412/ya-metrics/internal/logger"
 	"github.com/sejo412/ya-metrics/internal/models"
-	"github.com/sejo412/ya-metrics/pkg/utils"
 	pb "github.com/sejo412/ya-metrics/proto"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
@@ -40,6 +42,7 @@ func NewGRPCServerWithOptions(opts *config.Options) *GRPCServer {
 		router.opts.TrustedSubnets = []net.IPNet{}
 	}
 	router.opts.Logger = opts.Logger
+	router.opts.Tenants = opts.Tenants
 	return router
 }
 
@@ -51,18 +54,58 @@ var grpcMsgErr = "error"
 
 func (g *GRPCServer) SendMetrics(ctx context.Context, in *pb.SendMetricsRequest) (*pb.SendMetricsResponse, error) {
 	metrics := models.ConvertPbsToV1s(in.GetMetrics())
+	for _, metric := range metrics {
+		if err := metric.Labels.Validate(); err != nil {
+			return &pb.SendMetricsResponse{Error: &grpcMsgErr}, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+		var err error
+		switch metric.Kind {
+		case models.MetricKindHistogram:
+			err = metric.Histogram.Validate()
+		case models.MetricKindSummary:
+			err = metric.Summary.Validate()
+		}
+		if err != nil {
+			return &pb.SendMetricsResponse{Error: &grpcMsgErr}, status.Errorf(codes.InvalidArgument, "%v", err)
+		}
+	}
 
-	if err := g.opts.Storage.MassUpsert(ctx, metrics); err != nil {
+	err := g.opts.Storage.MassUpsert(ctx, metrics)
+	switch {
+	case errors.Is(err, models.ErrNotSupported):
+		return &pb.SendMetricsResponse{Error: &grpcMsgErr}, status.Errorf(codes.InvalidArgument, "%v", err)
+	case err != nil:
 		return &pb.SendMetricsResponse{Error: &grpcMsgErr}, status.Errorf(codes.Internal, "internal error: %v", err)
 	}
 	return nil, nil
 }
 
+// UpdateMetric updates gauge if condition of request holds for stored one.
+func (g *GRPCServer) UpdateMetric(ctx context.Context, in *pb.UpdateMetricRequest) (*pb.UpdateMetricResponse,
+	error) {
+	log := g.opts.Logger.Logger
+	metric := models.ConvertPbToV1(in.GetMetric())
+	if err := metric.Labels.Validate(); err != nil {
+		return &pb.UpdateMetricResponse{Error: &grpcMsgErr}, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	version, err := g.opts.Storage.UpsertIf(ctx, metric, models.ConvertPbToCondition(in))
+	switch {
+	case errors.Is(err, models.ErrConflict):
+		return &pb.UpdateMetricResponse{Error: &grpcMsgErr}, status.Errorf(codes.Aborted, "%v", err)
+	case errors.Is(err, models.ErrNotSupported), errors.Is(err, models.ErrInvalidCondition):
+		return &pb.UpdateMetricResponse{Error: &grpcMsgErr}, status.Errorf(codes.InvalidArgument, "%v", err)
+	case err != nil:
+		log.Errorw("update metric", "type", metric.Kind, "id", metric.Name, "error", err)
+		return &pb.UpdateMetricResponse{Error: &grpcMsgErr}, status.Errorf(codes.Internal, grpcMsgErr)
+	}
+	return &pb.UpdateMetricResponse{Version: &version}, nil
+}
+
 func (g *GRPCServer) GetMetric(ctx context.Context, in *pb.GetMetricRequest) (*pb.GetMetricResponse, error) {
 	log := g.opts.Logger.Logger
 	mNamePb := in.GetId()
-	mTypePb := in.GetKind().String()
-	metric, err := g.opts.Storage.Get(ctx, mTypePb, mNamePb)
+	mTypePb := models.ConvertPbKindToV1(in.GetKind())
+	metric, err := GetMetric(ctx, g.opts.Storage, mTypePb, mNamePb, in.GetLabels())
 	if err != nil {
 		log.Errorw("get metric", "type", mTypePb, "id", mNamePb, "err", err)
 		return &pb.GetMetricResponse{Error: &grpcMsgErr}, status.Errorf(codes.NotFound, "%v", err)
@@ -72,15 +115,21 @@ func (g *GRPCServer) GetMetric(ctx context.Context, in *pb.GetMetricRequest) (*p
 		log.Errorw("convert metric", "type", mTypePb, "id", mNamePb, "err", err)
 		return &pb.GetMetricResponse{Error: &grpcMsgErr}, status.Errorf(codes.NotFound, "%v", err)
 	}
+	meta, err := g.opts.Storage.GetMetadata(ctx, metric)
+	if err != nil {
+		log.Errorw("get metric metadata", "type", mTypePb, "id", mNamePb, "err", err)
+		return &pb.GetMetricResponse{Error: &grpcMsgErr}, status.Errorf(codes.NotFound, "%v", err)
+	}
 	return &pb.GetMetricResponse{
-		Metric: res,
-		Error:  nil,
+		Metric:   res,
+		Error:    nil,
+		Metadata: models.ConvertMetadataToPb(meta),
 	}, nil
 }
 
 func (g *GRPCServer) GetMetrics(ctx context.Context, _ *emptypb.Empty) (*pb.GetMetricsResponse, error) {
 	log := g.opts.Logger.Logger
-	m, err := g.opts.Storage.GetAll(ctx)
+	m, err := GetAllMetrics(ctx, g.opts.Storage)
 	if err != nil {
 		log.Errorw("get metrics", "error", err)
 		return &pb.GetMetricsResponse{Error: &grpcMsgErr}, status.Errorf(codes.Internal, grpcMsgErr)
@@ -96,6 +145,99 @@ func (g *GRPCServer) GetMetrics(ctx context.Context, _ *emptypb.Empty) (*pb.GetM
 	}, nil
 }
 
+// ListMetrics streams all metrics one by one, so memory doesn't depend on count of metrics.
+func (g *GRPCServer) ListMetrics(_ *emptypb.Empty, stream grpc.ServerStreamingServer[pb.ListMetricsResponse]) error {
+	log := g.opts.Logger.Logger
+	for m, err := range g.opts.Storage.All(stream.Context()) {
+		if err != nil {
+			log.Errorw("list metrics", "error", err)
+			return status.Errorf(codes.Internal, grpcMsgErr)
+		}
+		metric, err := models.ConvertV1ToPb(m)
+		if err != nil {
+			log.Errorw("convert metric", "error", err)
+			return status.Errorf(codes.Internal, grpcMsgErr)
+		}
+		if err = stream.Send(&pb.ListMetricsResponse{Metric: metric}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *GRPCServer) QueryMetrics(ctx context.Context, in *pb.QueryMetricsRequest) (*pb.QueryMetricsResponse,
+	error) {
+	log := g.opts.Logger.Logger
+	kind := ""
+	if in.GetKind() != pb.MType_UNKNOWN {
+		kind = models.ConvertPbKindToV1(in.GetKind())
+	}
+	query, err := models.NewListQuery(kind, in.GetPrefix(), in.GetRegex(), in.GetCursor(), int(in.GetLimit()))
+	if err != nil {
+		return &pb.QueryMetricsResponse{Error: &grpcMsgErr}, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	page, err := g.opts.Storage.List(ctx, query)
+	if err != nil {
+		log.Errorw("list metrics", "error", err)
+		return &pb.QueryMetricsResponse{Error: &grpcMsgErr}, status.Errorf(codes.Internal, grpcMsgErr)
+	}
+	metrics, err := models.ConvertV1sToPbs(page.Metrics)
+	if err != nil {
+		log.Errorw("convert metrics", "error", err)
+		return &pb.QueryMetricsResponse{Error: &grpcMsgErr}, status.Errorf(codes.Internal, grpcMsgErr)
+	}
+	res := &pb.QueryMetricsResponse{Metrics: metrics}
+	if page.Next != "" {
+		res.NextCursor = &page.Next
+	}
+	return res, nil
+}
+
+func (g *GRPCServer) DeleteMetric(ctx context.Context, in *pb.DeleteMetricRequest) (*pb.DeleteMetricResponse, error) {
+	log := g.opts.Logger.Logger
+	mNamePb := in.GetId()
+	mTypePb := models.ConvertPbKindToV1(in.GetKind())
+	if err := g.opts.Storage.Delete(ctx, mTypePb, mNamePb); err != nil {
+		log.Errorw("delete metric", "type", mTypePb, "id", mNamePb, "err", err)
+		return &pb.DeleteMetricResponse{Error: &grpcMsgErr}, grpcModifyError(err)
+	}
+	return &pb.DeleteMetricResponse{}, nil
+}
+
+func (g *GRPCServer) DeleteMetrics(ctx context.Context, in *pb.DeleteMetricsRequest) (*pb.DeleteMetricsResponse,
+	error) {
+	log := g.opts.Logger.Logger
+	prefix := in.GetPrefix()
+	if prefix == "" {
+		return &pb.DeleteMetricsResponse{Error: &grpcMsgErr}, status.Error(codes.InvalidArgument, "empty prefix")
+	}
+	deleted, err := g.opts.Storage.DeleteByPrefix(ctx, prefix)
+	if err != nil {
+		log.Errorw("delete metrics", "prefix", prefix, "err", err)
+		return &pb.DeleteMetricsResponse{Error: &grpcMsgErr}, grpcModifyError(err)
+	}
+	count := int64(deleted)
+	return &pb.DeleteMetricsResponse{Deleted: &count}, nil
+}
+
+func (g *GRPCServer) ResetCounter(ctx context.Context, in *pb.ResetCounterRequest) (*pb.ResetCounterResponse, error) {
+	log := g.opts.Logger.Logger
+	mNamePb := in.GetId()
+	if err := g.opts.Storage.ResetCounter(ctx, mNamePb); err != nil {
+		log.Errorw("reset counter", "id", mNamePb, "err", err)
+		return &pb.ResetCounterResponse{Error: &grpcMsgErr}, grpcModifyError(err)
+	}
+	return &pb.ResetCounterResponse{}, nil
+}
+
+// grpcModifyError converts error of modifying storage to grpc status.
+func grpcModifyError(err error) error {
+	if errors.Is(err, models.ErrHTTPNotFound) || errors.Is(err, models.ErrNotSupported) {
+		return status.Errorf(codes.NotFound, "%v", err)
+	}
+	return status.Errorf(codes.Internal, "internal error: %v", err)
+}
+
 func (g *GRPCServer) PingStorage(ctx context.Context, _ *emptypb.Empty) (*pb.PingStorageResponse, error) {
 	ok := new(bool)
 	if err := g.opts.Storage.Ping(ctx); err != nil {
@@ -110,10 +252,25 @@ func (g *GRPCServer) PingStorage(ctx context.Context, _ *emptypb.Empty) (*pb.Pin
 
 func interceptorLogger(l *logger.Logger) logging.Logger {
 	return logging.LoggerFunc(func(ctx context.Context, lvl logging.Level, msg string, keyvals ...any) {
-		l.Logger.Log(l.IntToLevel(int(lvl)), msg, keyvals)
+		l.Logger.Log(interceptorLevel(lvl), msg, keyvals)
 	})
 }
 
+// interceptorLevel returns zap level of interceptor level. Values of levels differ,
+// so warning of interceptor must not become panic of zap.
+func interceptorLevel(lvl logging.Level) zapcore.Level {
+	switch {
+	case lvl >= logging.LevelError:
+		return zapcore.ErrorLevel
+	case lvl >= logging.LevelWarn:
+		return zapcore.WarnLevel
+	case lvl >= logging.LevelInfo:
+		return zapcore.InfoLevel
+	default:
+		return zapcore.DebugLevel
+	}
+}
+
 func interceptorXRealIPOptions(addrs []net.IPNet) []realip.Option {
 	res := make([]realip.Option, 0)
 	if addrs == nil {
@@ -129,13 +286,93 @@ func interceptorXRealIPOptions(addrs []net.IPNet) []realip.Option {
 	return res
 }
 
+// interceptorSource puts address of client into context: determined by realip interceptor
+// if trusted subnets specified, address of peer otherwise.
+func interceptorSource(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	if addr, ok := realip.FromContext(ctx); ok {
+		ctx = models.WithSource(ctx, addr.String())
+	} else if p, ok := peer.FromContext(ctx); ok {
+		host, _, err := net.SplitHostPort(p.Addr.String())
+		if err != nil {
+			host = p.Addr.String()
+		}
+		ctx = models.WithSource(ctx, host)
+	}
+	return handler(ctx, req)
+}
+
+// interceptorTenant puts tenant from X-Tenant metadata into context.
+// Metadata is trusted only if tenant keys are not configured, e.g. behind proxy which authenticates clients.
+func interceptorTenant(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(withMetadataTenant(ctx), req)
+}
+
+// streamInterceptorTenant puts tenant from X-Tenant metadata into context of stream.
+func streamInterceptorTenant(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: withMetadataTenant(ss.Context())})
+}
+
+// withMetadataTenant returns context with tenant from X-Tenant metadata if it is specified.
+func withMetadataTenant(ctx context.Context) context.Context {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(models.HTTPHeaderTenant); len(values) > 0 && values[0] != "" {
+			return models.WithTenant(ctx, values[0])
+		}
+	}
+	return ctx
+}
+
 func (g *GRPCServer) interceptorCheckHash(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo,
 	handler grpc.UnaryHandler) (interface{}, error) {
-	// skip if key not specified
-	key := g.opts.Config.Key
-	if key == "" {
+	// skip if keys not specified
+	if g.opts.Config.Key == "" && len(g.opts.Tenants) == 0 {
 		return handler(ctx, req)
 	}
+	ctx, err := g.checkHash(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptorCheckHash checks hash of request received by stream.
+func (g *GRPCServer) streamInterceptorCheckHash(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ss.Context(), recv: g.checkHash})
+}
+
+// serverStream is server stream with own context. Context is updated by recv for every received message.
+type serverStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	recv func(ctx context.Context, m any) (context.Context, error)
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+	if s.recv == nil {
+		return nil
+	}
+	ctx, err := s.recv(s.ctx, m)
+	if err != nil {
+		return err
+	}
+	s.ctx = ctx
+	return nil
+}
+
+// checkHash checks hash of request from metadata. If tenant keys are configured,
+// returns context with tenant whose key signs request.
+func (g *GRPCServer) checkHash(ctx context.Context, req any) (context.Context, error) {
 	var hash string
 	if md, ok := metadata.FromIncomingContext(ctx); ok {
 		values := md.Get(models.HTTPHeaderSign)
@@ -146,20 +383,28 @@ func (g *GRPCServer) interceptorCheckHash(ctx context.Context, req interface{},
 	if len(hash) == 0 {
 		return nil, status.Error(codes.Unauthenticated, "missing hash")
 	}
-	r, ok := req.(*pb.SendMetricsRequest)
-	if !ok {
+	var signed any
+	switch r := req.(type) {
+	case *pb.SendMetricsRequest:
+		signed = r.GetMetrics()
+	case *pb.UpdateMetricRequest, *pb.DeleteMetricRequest, *pb.DeleteMetricsRequest, *pb.ResetCounterRequest,
+		*pb.GetMetricRequest, *pb.QueryMetricsRequest, *emptypb.Empty:
+		signed = r
+	default:
 		return nil, status.Error(codes.Unauthenticated, "invalid request")
 	}
-	metrics := r.GetMetrics()
-	metricsBytes, err := json.Marshal(metrics)
+	signedBytes, err := json.Marshal(signed)
 	if err != nil {
 		return nil, status.Error(codes.Internal, err.Error())
 	}
-	want := utils.Hash(metricsBytes, key)
-	if want != hash {
+	tenant, ok := signedTenant(g.opts.Config.Key, g.opts.Tenants, signedBytes, hash)
+	if !ok {
 		return nil, status.Error(codes.Unauthenticated, "invalid hash")
 	}
-	return handler(ctx, req)
+	if len(g.opts.Tenants) > 0 {
+		ctx = models.WithTenant(ctx, tenant)
+	}
+	return ctx, nil
 }
 
 func gRPCServerOptions(server *GRPCServer, key string) []grpc.ServerOption {
@@ -171,9 +416,19 @@ func gRPCServerOptions(server *GRPCServer, key string) []grpc.ServerOption {
 	if realIPOpts != nil {
 		unaryInterceptors = append(unaryInterceptors, realip.UnaryServerInterceptorOpts(realIPOpts...))
 	}
-	if key != "" {
+	unaryInterceptors = append(unaryInterceptors, interceptorSource)
+	streamInterceptors := []grpc.StreamServerInterceptor{
+		logging.StreamServerInterceptor(interceptorLogger(&server.opts.Logger)),
+	}
+	if len(server.opts.Tenants) == 0 {
+		unaryInterceptors = append(unaryInterceptors, interceptorTenant)
+		streamInterceptors = append(streamInterceptors, streamInterceptorTenant)
+	}
+	if key != "" || len(server.opts.Tenants) > 0 {
 		unaryInterceptors = append(unaryInterceptors, server.interceptorCheckHash)
+		streamInterceptors = append(streamInterceptors, server.streamInterceptorCheckHash)
 	}
-	res = append(res, grpc.ChainUnaryInterceptor(unaryInterceptors...))
+	res = append(res, grpc.ChainUnaryInterceptor(unaryInterceptors...),
+		grpc.ChainStreamInterceptor(streamInterceptors...))
 	return res
 }
@@ -2,18 +2,26 @@ package server
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/sejo412/ya-metrics/internal/config"
 	"github.com/sejo412/ya-metrics/internal/logger"
 	"github.com/sejo412/ya-metrics/internal/models"
 	"github.com/sejo412/ya-metrics/internal/storage"
+	"github.com/sejo412/ya-metrics/pkg/utils"
 	"github.com/sejo412/ya-metrics/proto"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/emptypb"
 )
 
@@ -23,12 +31,12 @@ func TestMain(m *testing.M) {
 		{
 			Kind:  "gauge",
 			Name:  "testMetric1",
-			Value: "99.9",
+			Value: 99.9,
 		},
 		{
 			Kind:  "gauge",
 			Name:  "testMetric2",
-			Value: "500",
+			Value: 500,
 		},
 	})
 	logs := logger.MustNewLogger(false)
@@ -88,7 +96,7 @@ func TestGRPCServer_GetMetric(t *testing.T) {
 			want: models.Metric{
 				Kind:  "gauge",
 				Name:  "testMetric1",
-				Value: "99.9",
+				Value: 99.9,
 			},
 			wantErr: false,
 		},
@@ -124,12 +132,12 @@ func TestGRPCServer_GetMetrics(t *testing.T) {
 				{
 					Kind:  "gauge",
 					Name:  "testMetric1",
-					Value: "99.9",
+					Value: 99.9,
 				},
 				{
 					Kind:  "gauge",
 					Name:  "testMetric2",
-					Value: "500",
+					Value: 500,
 				},
 			},
 			args: args{
@@ -152,3 +160,346 @@ func TestGRPCServer_GetMetrics(t *testing.T) {
 		})
 	}
 }
+
+func TestGRPCServer_DeleteAndReset(t *testing.T) {
+	ctx := context.Background()
+	client := testGRPCClient()
+	metrics, err := models.ConvertV1sToPbs([]models.Metric{
+		{Kind: "gauge", Name: "testDelete1", Value: 1.5},
+		{Kind: "gauge", Name: "testDelete2", Value: 2.5},
+		{Kind: "counter", Name: "testDelete3", Delta: 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: metrics})
+	assert.NoError(t, err)
+
+	gauge := proto.MType_GAUGE
+	name := "testDelete1"
+	_, err = client.DeleteMetric(ctx, &proto.DeleteMetricRequest{Id: &name, Kind: &gauge})
+	assert.NoError(t, err)
+	_, err = client.DeleteMetric(ctx, &proto.DeleteMetricRequest{Id: &name, Kind: &gauge})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	name = "testDelete3"
+	_, err = client.ResetCounter(ctx, &proto.ResetCounterRequest{Id: &name})
+	assert.NoError(t, err)
+	counter := proto.MType_COUNTER
+	resp, err := client.GetMetric(ctx, &proto.GetMetricRequest{Id: &name, Kind: &counter})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), resp.GetMetric().GetDelta())
+	name = "testDelete4"
+	_, err = client.ResetCounter(ctx, &proto.ResetCounterRequest{Id: &name})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	prefix := ""
+	_, err = client.DeleteMetrics(ctx, &proto.DeleteMetricsRequest{Prefix: &prefix})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	prefix = "testDelete"
+	deleted, err := client.DeleteMetrics(ctx, &proto.DeleteMetricsRequest{Prefix: &prefix})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), deleted.GetDeleted())
+}
+
+func TestGRPCServer_Labels(t *testing.T) {
+	ctx := context.Background()
+	client := testGRPCClient()
+	metrics, err := models.ConvertV1sToPbs([]models.Metric{
+		{Kind: "gauge", Name: "testLabels1", Value: 1.5, Labels: models.Labels{"host": "web1"}},
+		{Kind: "gauge", Name: "testLabels1", Value: 2.5, Labels: models.Labels{"host": "web2"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: metrics})
+	assert.NoError(t, err)
+
+	gauge := proto.MType_GAUGE
+	name := "testLabels1"
+	resp, err := client.GetMetric(ctx, &proto.GetMetricRequest{
+		Id:     &name,
+		Kind:   &gauge,
+		Labels: map[string]string{"host": "web2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Metric{Kind: "gauge", Name: name, Value: 2.5, Labels: models.Labels{"host": "web2"}},
+		models.ConvertPbToV1(resp.GetMetric()))
+	_, err = client.GetMetric(ctx, &proto.GetMetricRequest{Id: &name, Kind: &gauge})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	invalid, err := models.ConvertV1sToPbs([]models.Metric{
+		{Kind: "gauge", Name: "testLabels2", Value: 1, Labels: models.Labels{"2host": "web1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: invalid})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteMetric(ctx, &proto.DeleteMetricRequest{Id: &name, Kind: &gauge})
+	assert.NoError(t, err)
+}
+
+func TestGRPCServer_Metadata(t *testing.T) {
+	ctx := context.Background()
+	client := testGRPCClient()
+	before := time.Now()
+	metrics, err := models.ConvertV1sToPbs([]models.Metric{
+		{Kind: "gauge", Name: "testMetadata1", Value: 1.5, Unit: "bytes", Description: "heap"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: metrics})
+	assert.NoError(t, err)
+
+	gauge := proto.MType_GAUGE
+	name := "testMetadata1"
+	resp, err := client.GetMetric(ctx, &proto.GetMetricRequest{Id: &name, Kind: &gauge})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Metric{Kind: "gauge", Name: name, Value: 1.5, Unit: "bytes", Description: "heap"},
+		models.ConvertPbToV1(resp.GetMetric()))
+	assert.Equal(t, "127.0.0.1", resp.GetMetadata().GetSource())
+	assert.False(t, resp.GetMetadata().GetUpdatedAt().AsTime().Before(before.Truncate(time.Microsecond)))
+
+	_, err = client.DeleteMetric(ctx, &proto.DeleteMetricRequest{Id: &name, Kind: &gauge})
+	assert.NoError(t, err)
+}
+
+func TestGRPCServer_Histogram(t *testing.T) {
+	ctx := context.Background()
+	client := testGRPCClient()
+	name := "testHistogram1"
+	report := models.Metric{Kind: models.MetricKindHistogram, Name: name,
+		Histogram: &models.Histogram{Bounds: []float64{0.1, 1}, Counts: []uint64{1, 0, 1}, Sum: 2.05}}
+	metrics, err := models.ConvertV1sToPbs([]models.Metric{report, report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: metrics})
+	assert.NoError(t, err)
+
+	histogram := proto.MType_HISTOGRAM
+	resp, err := client.GetMetric(ctx, &proto.GetMetricRequest{Id: &name, Kind: &histogram})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Metric{Kind: models.MetricKindHistogram, Name: name,
+		Histogram: &models.Histogram{Bounds: []float64{0.1, 1}, Counts: []uint64{2, 0, 2}, Sum: 4.1}},
+		models.ConvertPbToV1(resp.GetMetric()))
+
+	invalid := &proto.Metric{Id: &name, Type: &histogram, Histogram: &proto.Histogram{Bounds: []float64{1}}}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: []*proto.Metric{invalid}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	unknown := proto.MType(99)
+	invalid = &proto.Metric{Id: &name, Type: &unknown}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: []*proto.Metric{invalid}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteMetric(ctx, &proto.DeleteMetricRequest{Id: &name, Kind: &histogram})
+	assert.NoError(t, err)
+}
+
+func TestGRPCServer_Summary(t *testing.T) {
+	ctx := context.Background()
+	client := testGRPCClient()
+	name := "testSummary1"
+	report := models.Metric{Kind: models.MetricKindSummary, Name: name,
+		Summary: &models.Summary{Positive: map[int32]uint64{0: 1}, Accuracy: 0.01, Sum: 1, Min: 1, Max: 1}}
+	metrics, err := models.ConvertV1sToPbs([]models.Metric{report, report})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: metrics})
+	assert.NoError(t, err)
+
+	summary := proto.MType_SUMMARY
+	resp, err := client.GetMetric(ctx, &proto.GetMetricRequest{Id: &name, Kind: &summary})
+	assert.NoError(t, err)
+	assert.Equal(t, models.Metric{Kind: models.MetricKindSummary, Name: name,
+		Summary: &models.Summary{Positive: map[int32]uint64{0: 2}, Accuracy: 0.01, Sum: 2, Min: 1, Max: 1}},
+		models.ConvertPbToV1(resp.GetMetric()))
+	assert.Equal(t, map[string]float64{"p50": 1, "p90": 1, "p95": 1, "p99": 1},
+		resp.GetMetric().GetSummary().GetQuantiles())
+
+	invalid := &proto.Metric{Id: &name, Type: &summary, Summary: &proto.Summary{}}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: []*proto.Metric{invalid}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteMetric(ctx, &proto.DeleteMetricRequest{Id: &name, Kind: &summary})
+	assert.NoError(t, err)
+}
+
+func TestGRPCServer_QueryMetrics(t *testing.T) {
+	ctx := context.Background()
+	client := testGRPCClient()
+	metrics, err := models.ConvertV1sToPbs([]models.Metric{
+		{Kind: models.MetricKindGauge, Name: "testQuery2", Value: 2},
+		{Kind: models.MetricKindCounter, Name: "testQuery1", Delta: 1},
+		{Kind: models.MetricKindGauge, Name: "testQuery1", Value: 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: metrics})
+	assert.NoError(t, err)
+
+	prefix := "testQuery"
+	limit := int32(2)
+	resp, err := client.QueryMetrics(ctx, &proto.QueryMetricsRequest{Prefix: &prefix, Limit: &limit})
+	assert.NoError(t, err)
+	var names []string
+	for _, metric := range resp.GetMetrics() {
+		names = append(names, metric.GetType().String()+" "+metric.GetId())
+	}
+	assert.Equal(t, []string{"COUNTER testQuery1", "GAUGE testQuery1"}, names)
+	assert.NotEmpty(t, resp.GetNextCursor())
+
+	cursor := resp.GetNextCursor()
+	resp, err = client.QueryMetrics(ctx, &proto.QueryMetricsRequest{Prefix: &prefix, Limit: &limit,
+		Cursor: &cursor})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetMetrics(), 1)
+	assert.Equal(t, "testQuery2", resp.GetMetrics()[0].GetId())
+	assert.Empty(t, resp.GetNextCursor())
+
+	gauge := proto.MType_GAUGE
+	regex := "testQuery[0-9]"
+	resp, err = client.QueryMetrics(ctx, &proto.QueryMetricsRequest{Kind: &gauge, Regex: &regex})
+	assert.NoError(t, err)
+	assert.Len(t, resp.GetMetrics(), 2)
+
+	invalid := "("
+	_, err = client.QueryMetrics(ctx, &proto.QueryMetricsRequest{Regex: &invalid})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteMetrics(ctx, &proto.DeleteMetricsRequest{Prefix: &prefix})
+	assert.NoError(t, err)
+}
+
+func TestGRPCServer_ListMetrics(t *testing.T) {
+	ctx := context.Background()
+	client := testGRPCClient()
+	stream, err := client.ListMetrics(ctx, &emptypb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]float64)
+	for {
+		resp, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got[resp.GetMetric().GetId()] = resp.GetMetric().GetValue()
+	}
+	assert.Equal(t, 99.9, got["testMetric1"])
+	assert.Equal(t, 500.0, got["testMetric2"])
+}
+
+func TestGRPCServer_UpdateMetric(t *testing.T) {
+	ctx := context.Background()
+	client := testGRPCClient()
+	metric, err := models.ConvertV1ToPb(models.Metric{Kind: models.MetricKindGauge, Name: "testGauge95", Value: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	zero := int64(0)
+	resp, err := client.UpdateMetric(ctx, &proto.UpdateMetricRequest{Metric: metric, IfVersion: &zero})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), resp.GetVersion())
+
+	_, err = client.UpdateMetric(ctx, &proto.UpdateMetricRequest{Metric: metric, IfVersion: &zero})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	min := proto.UpdateMode_MIN
+	_, err = client.UpdateMetric(ctx, &proto.UpdateMetricRequest{Metric: metric, Mode: &min})
+	assert.Equal(t, codes.Aborted, status.Code(err))
+
+	*metric.Value = 4
+	resp, err = client.UpdateMetric(ctx, &proto.UpdateMetricRequest{Metric: metric, Mode: &min})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), resp.GetVersion())
+
+	gauge := proto.MType_GAUGE
+	got, err := client.GetMetric(ctx, &proto.GetMetricRequest{Id: metric.Id, Kind: &gauge})
+	assert.NoError(t, err)
+	assert.Equal(t, 4.0, got.GetMetric().GetValue())
+	assert.Equal(t, int64(2), got.GetMetadata().GetVersion())
+
+	counter, err := models.ConvertV1ToPb(models.Metric{Kind: models.MetricKindCounter, Name: "testCounter95"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.UpdateMetric(ctx, &proto.UpdateMetricRequest{Metric: counter, IfVersion: &zero})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.DeleteMetric(ctx, &proto.DeleteMetricRequest{Id: metric.Id, Kind: &gauge})
+	assert.NoError(t, err)
+}
+
+func TestGRPCServer_Tenants(t *testing.T) {
+	client := testGRPCClient()
+	name := "testGauge96"
+	gauge := proto.MType_GAUGE
+	for i, tenant := range []string{"one", "two"} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), models.HTTPHeaderTenant, tenant)
+		metric, err := models.ConvertV1ToPb(models.Metric{Kind: models.MetricKindGauge, Name: name, Value: float64(i)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = client.SendMetrics(ctx, &proto.SendMetricsRequest{Metrics: []*proto.Metric{metric}})
+		assert.NoError(t, err)
+	}
+	for i, tenant := range []string{"one", "two"} {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), models.HTTPHeaderTenant, tenant)
+		resp, err := client.GetMetric(ctx, &proto.GetMetricRequest{Id: &name, Kind: &gauge})
+		assert.NoError(t, err)
+		assert.Equal(t, float64(i), resp.GetMetric().GetValue())
+
+		all, err := client.GetMetrics(ctx, &emptypb.Empty{})
+		assert.NoError(t, err)
+		assert.Len(t, all.GetMetrics(), 1)
+	}
+	_, err := client.GetMetric(context.Background(), &proto.GetMetricRequest{Id: &name, Kind: &gauge})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCServer_checkHash(t *testing.T) {
+	g := &GRPCServer{opts: config.Options{
+		Config:  config.ServerConfig{Key: "secret"},
+		Tenants: map[string]string{"secretOne": "one"},
+	}}
+	name := "testGauge97"
+	req := &proto.GetMetricRequest{Id: &name}
+	data, err := json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		hash   string
+		tenant string
+		code   codes.Code
+	}{
+		{name: "main key", hash: utils.Hash(data, "secret"), tenant: models.DefaultTenant, code: codes.OK},
+		{name: "tenant key", hash: utils.Hash(data, "secretOne"), tenant: "one", code: codes.OK},
+		{name: "unknown key", hash: utils.Hash(data, "unknown"), code: codes.Unauthenticated},
+		{name: "missing hash", code: codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.hash != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(models.HTTPHeaderSign, tt.hash))
+			}
+			got, err := g.checkHash(ctx, req)
+			assert.Equal(t, tt.code, status.Code(err))
+			if err == nil {
+				assert.Equal(t, tt.tenant, models.TenantFromContext(got))
+			}
+		})
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"html/template"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/sejo412/ya-metrics/internal/models"
@@ -56,11 +61,32 @@ func gzipHandle(next http.Handler) http.Handler {
 	})
 }
 
-func checkHashHandle(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		key := r.Context().Value("key").(string)
-		headerHash := r.Header.Get(models.HTTPHeaderSign)
-		if r.Method == http.MethodPost && key != "" && headerHash != "" {
+// acceptsGzip reports whether client accepts gzip encoded response, like "gzip" or "deflate, gzip;q=0.5".
+func acceptsGzip(req *http.Request) bool {
+	for _, coding := range strings.Split(req.Header.Get(models.HTTPHeaderAcceptEncoding), ",") {
+		name, params, _ := strings.Cut(coding, ";")
+		if !strings.EqualFold(strings.TrimSpace(name), models.HTTPHeaderEncodingGzip) {
+			continue
+		}
+		// zero quality means gzip is not acceptable
+		q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+		if !ok {
+			return true
+		}
+		weight, err := strconv.ParseFloat(q, 64)
+		return err == nil && weight > 0
+	}
+	return false
+}
+
+// checkHashHandle checks sign of request. If tenant keys are configured, request of any method is checked
+// and tenant whose key signs request is put into request context.
+func (r *Router) checkHashHandle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		key := req.Context().Value("key").(string)
+		tenants := r.opts.Tenants
+		headerHash := req.Header.Get(models.HTTPHeaderSign)
+		if headerHash != "" && (req.Method == http.MethodPost && key != "" || len(tenants) > 0) {
 			/* Broken logic in autotests
 			headerHash := r.Header.Get(models.HTTPHeaderSign)
 			if headerHash == "" {
@@ -68,28 +94,54 @@ func checkHashHandle(next http.Handler) http.Handler {
 				return
 			}
 			*/
-			body, err := io.ReadAll(r.Body)
+			body, err := io.ReadAll(req.Body)
 			if err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
 			defer func() {
-				_ = r.Body.Close()
+				_ = req.Body.Close()
 			}()
 
-			if len(body) > 0 {
-				want := utils.Hash(body, key)
-				if want != headerHash {
+			if len(body) > 0 || len(tenants) > 0 {
+				tenant, ok := signedTenant(key, tenants, body, headerHash)
+				if !ok {
 					http.Error(w, "Invalid sign", http.StatusBadRequest)
 					return
 				}
+				if len(tenants) > 0 {
+					req = req.WithContext(models.WithTenant(req.Context(), tenant))
+				}
 			}
-			r.Body = io.NopCloser(bytes.NewReader(body))
+			req.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// tenantHandle puts tenant from X-Tenant header into request context.
+// Header is trusted only if tenant keys are not configured, e.g. behind proxy which authenticates clients.
+func tenantHandle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenant := r.Header.Get(models.HTTPHeaderTenant); tenant != "" {
+			r = r.WithContext(models.WithTenant(r.Context(), tenant))
 		}
 		next.ServeHTTP(w, r)
 	})
 }
 
+// sourceHandle puts address of client into request context.
+// Address is taken from X-Real-IP header or from remote address of connection.
+func sourceHandle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		source := r.Header.Get(models.HTTPHeaderRealIP)
+		if source == "" {
+			source, _, _ = net.SplitHostPort(r.RemoteAddr)
+		}
+		next.ServeHTTP(w, r.WithContext(models.WithSource(r.Context(), source)))
+	})
+}
+
 func (r *Router) decryptHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		body, err := io.ReadAll(req.Body)
@@ -116,13 +168,13 @@ func (r *Router) decryptHandler(next http.Handler) http.Handler {
 func (r *Router) postUpdate(w http.ResponseWriter, req *http.Request) {
 	log := r.opts.Logger.Logger
 	cfg := r.opts.Config
-	metric := models.Metric{
-		Kind:  chi.URLParam(req, "kind"),
-		Name:  chi.URLParam(req, "name"),
-		Value: chi.URLParam(req, "value"),
+	metric, err := ParseMetric(chi.URLParam(req, "kind"), chi.URLParam(req, "name"), chi.URLParam(req, "value"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 	store := r.opts.Storage
-	if err := UpdateMetric(store, metric); err != nil {
+	if err := UpdateMetric(req.Context(), store, metric); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		log.Errorw("add or update metric",
 			"metric", metric.Name,
@@ -148,11 +200,12 @@ func (r *Router) postUpdate(w http.ResponseWriter, req *http.Request) {
 
 func (r *Router) getValue(w http.ResponseWriter, req *http.Request) {
 	log := r.opts.Logger.Logger
+	kind := chi.URLParam(req, "kind")
 	name := chi.URLParam(req, "name")
 	store := r.opts.Storage
-	value, err := GetMetricValue(store, name)
+	value, err := GetMetricValue(req.Context(), store, kind, name)
 	switch {
-	case errors.Is(err, models.ErrHTTPNotFound):
+	case errors.Is(err, models.ErrHTTPNotFound), errors.Is(err, models.ErrNotSupported):
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	case errors.Is(err, models.ErrHTTPInternalServerError):
@@ -168,10 +221,78 @@ func (r *Router) getValue(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func (r *Router) deleteValue(w http.ResponseWriter, req *http.Request) {
+	kind := chi.URLParam(req, "kind")
+	name := chi.URLParam(req, "name")
+	err := DeleteMetric(req.Context(), r.opts.Storage, kind, name)
+	if !r.writeModifyError(w, "delete metric", name, err) {
+		return
+	}
+	r.flushIfSync()
+	w.WriteHeader(http.StatusOK)
+}
+
+func (r *Router) deleteValues(w http.ResponseWriter, req *http.Request) {
+	log := r.opts.Logger.Logger
+	prefix := req.URL.Query().Get(models.MetricQueryPrefix)
+	deleted, err := DeleteMetrics(req.Context(), r.opts.Storage, prefix)
+	if errors.Is(err, models.ErrHTTPBadRequest) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !r.writeModifyError(w, "delete metrics", prefix, err) {
+		return
+	}
+	r.flushIfSync()
+	if _, err = io.WriteString(w, strconv.Itoa(deleted)); err != nil {
+		log.Errorw("write to response writer",
+			"error", err)
+	}
+}
+
+func (r *Router) postResetCounter(w http.ResponseWriter, req *http.Request) {
+	name := chi.URLParam(req, "name")
+	err := ResetCounter(req.Context(), r.opts.Storage, name)
+	if !r.writeModifyError(w, "reset counter", name, err) {
+		return
+	}
+	r.flushIfSync()
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeModifyError writes response for error of modifying storage. Returns true if err is nil.
+func (r *Router) writeModifyError(w http.ResponseWriter, action, name string, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, models.ErrHTTPNotFound), errors.Is(err, models.ErrNotSupported):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		http.Error(w, models.ErrHTTPInternalServerError.Error(), http.StatusInternalServerError)
+		r.opts.Logger.Logger.Errorw(action,
+			"name", name,
+			"error", err)
+	}
+	return false
+}
+
+// flushIfSync saves metrics to file if synchronous storing is enabled.
+func (r *Router) flushIfSync() {
+	cfg := r.opts.Config
+	if cfg.StoreInterval != 0 {
+		return
+	}
+	if err := flushToFile(context.TODO(), r.opts.Storage, cfg.StoreFile); err != nil {
+		r.opts.Logger.Logger.Errorw("flush store",
+			"file", cfg.StoreFile,
+			"error", err)
+	}
+}
+
 func (r *Router) getIndex(w http.ResponseWriter, req *http.Request) {
 	log := r.opts.Logger.Logger
 	store := r.opts.Storage
-	metrics := GetAllMetricValues(store)
+	metrics := GetAllMetricValues(req.Context(), store, r.opts.TTLPolicy)
 	tmpl, err := template.New("index").Parse(index)
 	if err != nil {
 		http.Error(w, "", http.StatusInternalServerError)
@@ -187,7 +308,7 @@ func (r *Router) getIndex(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 	resp := buf.Bytes()
-	if req.Header.Get(models.HTTPHeaderAcceptEncoding) == models.HTTPHeaderEncodingGzip {
+	if acceptsGzip(req) {
 		resp, err = utils.Compress(resp)
 		if err == nil {
 			w.Header().Set(models.HTTPHeaderContentEncoding, models.HTTPHeaderEncodingGzip)
@@ -201,6 +322,28 @@ func (r *Router) getIndex(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// getPrometheusMetrics writes metrics of tenant in Prometheus text exposition format.
+func (r *Router) getPrometheusMetrics(w http.ResponseWriter, req *http.Request) {
+	log := r.opts.Logger.Logger
+	resp, err := PrometheusMetrics(req.Context(), r.opts.Storage, r.opts.TTLPolicy)
+	if err != nil {
+		http.Error(w, models.ErrHTTPInternalServerError.Error(), http.StatusInternalServerError)
+		log.Errorw("get prometheus metrics", "error", err)
+		return
+	}
+	w.Header().Set(models.HTTPHeaderContentType, models.HTTPHeaderContentTypeTextPrometheus)
+	if acceptsGzip(req) {
+		compressed, err := utils.Compress(resp)
+		if err == nil {
+			resp = compressed
+			w.Header().Set(models.HTTPHeaderContentEncoding, models.HTTPHeaderEncodingGzip)
+		}
+	}
+	if _, err = w.Write(resp); err != nil {
+		log.Errorw("write response", "error", err)
+	}
+}
+
 func (r *Router) postUpdateJSON(w http.ResponseWriter, req *http.Request) {
 	log := r.opts.Logger.Logger
 	if req.Header.Get(models.HTTPHeaderContentType) != models.HTTPHeaderContentTypeApplicationJSON {
@@ -220,8 +363,12 @@ func (r *Router) postUpdateJSON(w http.ResponseWriter, req *http.Request) {
 	data := buf.Bytes()
 
 	store := r.opts.Storage
-	resp, err := UpdateMetricFromJSON(store, data)
-	if err != nil {
+	resp, err := UpdateMetricFromJSON(req.Context(), store, data)
+	switch {
+	case errors.Is(err, models.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -268,7 +415,7 @@ func (r *Router) postUpdatesJSON(w http.ResponseWriter, req *http.Request) {
 	data := buf.Bytes()
 
 	store := r.opts.Storage
-	err = UpdateMetricsFromJSON(store, data)
+	err = UpdateMetricsFromJSON(req.Context(), store, data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -276,6 +423,59 @@ func (r *Router) postUpdatesJSON(w http.ResponseWriter, req *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// postInfluxWrite updates metrics from InfluxDB line protocol.
+func (r *Router) postInfluxWrite(w http.ResponseWriter, req *http.Request) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, models.ErrHTTPBadRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		_ = req.Body.Close()
+	}()
+	precision := req.URL.Query().Get(models.InfluxQueryPrecision)
+	err = UpdateMetricsFromInflux(req.Context(), r.opts.Storage, body, precision, r.opts.InfluxCounters)
+	switch {
+	case errors.Is(err, models.ErrInvalidLine):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, models.ErrHTTPInternalServerError.Error(), http.StatusInternalServerError)
+		r.opts.Logger.Logger.Errorw("write influx lines", "error", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// postRemoteWrite updates metrics from Prometheus remote write 1.0 request.
+func (r *Router) postRemoteWrite(w http.ResponseWriter, req *http.Request) {
+	// remote write 2.0 is requested by proto parameter of content type
+	if strings.Contains(req.Header.Get(models.HTTPHeaderContentType), models.HTTPHeaderContentTypeRemoteWriteV2) {
+		http.Error(w, models.ErrNotSupported.Error(), http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, models.ErrHTTPBadRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		_ = req.Body.Close()
+	}()
+	err = UpdateMetricsFromRemoteWrite(req.Context(), r.opts.Storage, body)
+	switch {
+	case errors.Is(err, models.ErrInvalidRemoteWrite), errors.Is(err, models.ErrInvalidLabel):
+		// Prometheus doesn't retry client errors
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		http.Error(w, models.ErrHTTPInternalServerError.Error(), http.StatusInternalServerError)
+		r.opts.Logger.Logger.Errorw("write remote samples", "error", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
 func (r *Router) getMetricJSON(w http.ResponseWriter, req *http.Request) {
 	if req.Header.Get(models.HTTPHeaderContentType) != models.HTTPHeaderContentTypeApplicationJSON {
 		http.Error(w, models.ErrHTTPBadRequest.Error(), http.StatusBadRequest)
@@ -296,12 +496,12 @@ func (r *Router) getMetricJSON(w http.ResponseWriter, req *http.Request) {
 		http.Error(w, models.ErrHTTPBadRequest.Error(), http.StatusBadRequest)
 		return
 	}
-	resp, err := GetMetricJSON(store, metric.MType, metric.ID)
+	resp, err := GetMetricWithMetadataJSON(req.Context(), store, metric.MType, metric.ID, metric.Labels)
 	if err != nil {
 		http.Error(w, models.ErrHTTPNotFound.Error(), http.StatusNotFound)
 		return
 	}
-	if req.Header.Get(models.HTTPHeaderAcceptEncoding) == models.HTTPHeaderEncodingGzip {
+	if acceptsGzip(req) {
 		resp, err = utils.Compress(resp)
 		if err == nil {
 			w.Header().Set(models.HTTPHeaderContentEncoding, models.HTTPHeaderEncodingGzip)
@@ -316,6 +516,75 @@ func (r *Router) getMetricJSON(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func (r *Router) selectMetricsJSON(w http.ResponseWriter, req *http.Request) {
+	log := r.opts.Logger.Logger
+	if req.Header.Get(models.HTTPHeaderContentType) != models.HTTPHeaderContentTypeApplicationJSON {
+		http.Error(w, models.ErrHTTPBadRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	buf := new(bytes.Buffer)
+	_, err := buf.ReadFrom(req.Body)
+	if err != nil {
+		http.Error(w, models.ErrHTTPBadRequest.Error(), http.StatusBadRequest)
+		return
+	}
+	defer func() {
+		_ = req.Body.Close()
+	}()
+	resp, err := SelectMetricsJSON(req.Context(), r.opts.Storage, buf.Bytes())
+	switch {
+	case errors.Is(err, models.ErrHTTPBadRequest), errors.Is(err, models.ErrInvalidLabel):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, models.ErrNotSupported):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, models.ErrHTTPInternalServerError.Error(), http.StatusInternalServerError)
+		log.Errorw("select metrics", "error", err)
+		return
+	}
+	w.Header().Set(models.HTTPHeaderContentType, models.HTTPHeaderContentTypeApplicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(resp); err != nil {
+		log.Errorw("write response", "error", err)
+	}
+}
+
+func (r *Router) listMetricsJSON(w http.ResponseWriter, req *http.Request) {
+	log := r.opts.Logger.Logger
+	query, err := parseListQuery(req.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := ListMetricsJSON(req.Context(), r.opts.Storage, query)
+	if err != nil {
+		http.Error(w, models.ErrHTTPInternalServerError.Error(), http.StatusInternalServerError)
+		log.Errorw("list metrics", "error", err)
+		return
+	}
+	w.Header().Set(models.HTTPHeaderContentType, models.HTTPHeaderContentTypeApplicationJSON)
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(resp); err != nil {
+		log.Errorw("write response", "error", err)
+	}
+}
+
+// parseListQuery returns list query from kind, prefix, regex, limit and cursor URL parameters.
+func parseListQuery(values url.Values) (models.ListQuery, error) {
+	limit := 0
+	if v := values.Get(models.MetricQueryLimit); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return models.ListQuery{}, fmt.Errorf("%w: limit '%s'", models.ErrInvalidQuery, v)
+		}
+		limit = n
+	}
+	return models.NewListQuery(values.Get(models.MetricQueryKind), values.Get(models.MetricQueryPrefix),
+		values.Get(models.MetricQueryRegex), values.Get(models.MetricQueryCursor), limit)
+}
+
 func (r *Router) pingStorage(w http.ResponseWriter, req *http.Request) {
 	log := r.opts.Logger.Logger
 	store := r.opts.Storage
@@ -330,11 +599,30 @@ func (r *Router) pingStorage(w http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func (r *Router) getSchemaVersion(w http.ResponseWriter, req *http.Request) {
+	log := r.opts.Logger.Logger
+	version, err := GetSchemaVersion(req.Context(), r.opts.Storage)
+	switch {
+	case errors.Is(err, models.ErrNotSupported):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case err != nil:
+		http.Error(w, models.ErrHTTPInternalServerError.Error(), http.StatusInternalServerError)
+		log.Errorw("get schema version", "error", err)
+		return
+	}
+	if _, err = io.WriteString(w, strconv.Itoa(version)); err != nil {
+		log.Errorw("write to response writer",
+			"error", err)
+	}
+}
+
 func (r *Router) checkXRealIPHandler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-		// skip checks if not POST method
-		if req.Method != http.MethodPost {
+		// skip checks if method doesn't modify metrics
+		if req.Method != http.MethodPost && req.Method != http.MethodDelete {
 			next.ServeHTTP(w, req)
+			return
 		}
 		xRealIP := req.Header.Get("X-Real-Ip")
 		if !isNetsContainsIP(xRealIP, r.opts.TrustedSubnets) {
@@ -9,13 +9,17 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sejo412/ya-metrics/internal/config"
 	"github.com/sejo412/ya-metrics/internal/logger"
 	m "github.com/sejo412/ya-metrics/internal/models"
 	"github.com/sejo412/ya-metrics/internal/storage"
+	"github.com/sejo412/ya-metrics/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -31,6 +35,14 @@ var lm = logger.MustNewLogger(false)
 
 const notFound = "404 page not found"
 
+// updatedAtRe matches nondeterministic update time of metric in JSON response.
+var updatedAtRe = regexp.MustCompile(`,"updated_at":"[^"]+"`)
+
+// withoutUpdatedAt strips update time from JSON response.
+func withoutUpdatedAt(body string) string {
+	return updatedAtRe.ReplaceAllString(body, "")
+}
+
 func testRequest(t *testing.T, ts *httptest.Server, method, path string, header http.Header,
 	body io.Reader) (*http.Response, string) {
 	ctx := context.TODO()
@@ -204,6 +216,76 @@ func Test_getIndex(t *testing.T) {
 	}
 }
 
+func Test_acceptsGzip(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{name: "no header", header: "", want: false},
+		{name: "gzip only", header: "gzip", want: true},
+		{name: "gzip in list", header: "deflate, gzip, br", want: true},
+		{name: "gzip with quality", header: "br;q=1.0, gzip;q=0.5", want: true},
+		{name: "gzip not acceptable", header: "gzip;q=0, deflate", want: false},
+		{name: "other codings", header: "deflate, br", want: false},
+		{name: "similar name", header: "x-gzip2", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(m.HTTPHeaderAcceptEncoding, tt.header)
+			}
+			assert.Equal(t, tt.want, acceptsGzip(req))
+		})
+	}
+}
+
+func Test_getIndexHidesStale(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	_ = store.MassUpsert(context.Background(), []m.Metric{
+		{Kind: m.MetricKindGauge, Name: "freshGauge", Value: 1},
+		{Kind: m.MetricKindGauge, Name: "staleGauge", Value: 2},
+	})
+	r := NewRouterWithOptions(&config.Options{
+		Config:    cfg,
+		Storage:   store,
+		Logger:    *lm,
+		TTLPolicy: storage.TTLPolicy{Rules: []storage.TTLRule{{Pattern: "stale*", TTL: time.Nanosecond}}},
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	resp, body := testRequest(t, ts, http.MethodGet, "/", nil, nil)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "gauge/freshGauge=1")
+	assert.NotContains(t, body, "staleGauge")
+}
+
+func Test_getIndexSameNameKinds(t *testing.T) {
+	store := storage.NewMemoryStorage()
+	_ = store.MassUpsert(context.Background(), []m.Metric{
+		{Kind: m.MetricKindGauge, Name: "requests", Value: 1.5},
+		{Kind: m.MetricKindCounter, Name: "requests", Delta: 7},
+	})
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: store,
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	resp, body := testRequest(t, ts, http.MethodGet, "/", nil, nil)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, body, "gauge/requests=1.5")
+	assert.Contains(t, body, "counter/requests=7")
+}
+
 func Test_postUpdateJSON(t *testing.T) {
 	gzippedBase64invalid := "H4sICDwDBWgAA3Rlc3QAKyhKLUtN4QIAlOowhwcAAAA="
 	gzippedInvalid, _ := base64.StdEncoding.DecodeString(gzippedBase64invalid)
@@ -418,7 +500,7 @@ func TestRouter_getMetricJSON(t *testing.T) {
 			body: bytes.NewBuffer([]byte(`{"id": "testGauge90", "type": "gauge"}`)),
 			want: want{
 				code:     http.StatusOK,
-				response: `{"value":99.11,"id":"testGauge90","type":"gauge"}`,
+				response: `{"value":99.11,"id":"testGauge90","type":"gauge","version":1}`,
 			},
 		},
 		{
@@ -444,7 +526,7 @@ func TestRouter_getMetricJSON(t *testing.T) {
 	_ = store.Upsert(context.Background(), m.Metric{
 		Kind:  "gauge",
 		Name:  "testGauge90",
-		Value: "99.11",
+		Value: 99.11,
 	})
 
 	for _, tt := range tests {
@@ -454,7 +536,7 @@ func TestRouter_getMetricJSON(t *testing.T) {
 				_ = resp.Body.Close()
 			}()
 			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
-			assert.Equal(t, tt.want.response, body, tt.name)
+			assert.Equal(t, tt.want.response, withoutUpdatedAt(body), tt.name)
 		})
 	}
 }
@@ -485,6 +567,14 @@ func TestRouter_getValue(t *testing.T) {
 				response: "not found",
 			},
 		},
+		{
+			name:    "404 other kind",
+			request: "/value/counter/testGauge90",
+			want: want{
+				code:     http.StatusNotFound,
+				response: "not found",
+			},
+		},
 	}
 	store := storage.NewMemoryStorage()
 	r := NewRouterWithOptions(&config.Options{
@@ -497,7 +587,7 @@ func TestRouter_getValue(t *testing.T) {
 	_ = store.Upsert(context.Background(), m.Metric{
 		Kind:  "gauge",
 		Name:  "testGauge90",
-		Value: "99.11",
+		Value: 99.11,
 	})
 
 	for _, tt := range tests {
@@ -572,3 +662,1256 @@ func TestRouter_checkXRealIPHandler(t *testing.T) {
 		})
 	}
 }
+
+func TestRouter_deleteAndReset(t *testing.T) {
+	type want struct {
+		response string
+		code     int
+	}
+	tests := []struct {
+		name    string
+		method  string
+		request string
+		want    want
+	}{
+		{
+			name:    "delete ok",
+			method:  http.MethodDelete,
+			request: "/value/gauge/testGauge90",
+			want: want{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name:    "deleted not found",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge90",
+			want: want{
+				code:     http.StatusNotFound,
+				response: "not found",
+			},
+		},
+		{
+			name:    "delete not found",
+			method:  http.MethodDelete,
+			request: "/value/gauge/testGauge90",
+			want: want{
+				code:     http.StatusNotFound,
+				response: "not found",
+			},
+		},
+		{
+			name:    "delete unsupported kind",
+			method:  http.MethodDelete,
+			request: "/value/preved/testGauge91",
+			want: want{
+				code:     http.StatusNotFound,
+				response: "not supported: metric kind 'preved'",
+			},
+		},
+		{
+			name:    "reset ok",
+			method:  http.MethodPost,
+			request: "/update/counter/testCounter90/reset",
+			want: want{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name:    "reset value",
+			method:  http.MethodGet,
+			request: "/value/counter/testCounter90",
+			want: want{
+				code:     http.StatusOK,
+				response: "0",
+			},
+		},
+		{
+			name:    "update after reset",
+			method:  http.MethodPost,
+			request: "/update/counter/testCounter90/5",
+			want: want{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name:    "updated value",
+			method:  http.MethodGet,
+			request: "/value/counter/testCounter90",
+			want: want{
+				code:     http.StatusOK,
+				response: "5",
+			},
+		},
+		{
+			name:    "reset not found",
+			method:  http.MethodPost,
+			request: "/update/counter/testCounter91/reset",
+			want: want{
+				code:     http.StatusNotFound,
+				response: "not found",
+			},
+		},
+		{
+			name:    "delete by prefix without prefix",
+			method:  http.MethodDelete,
+			request: "/value/",
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "bad request: empty prefix",
+			},
+		},
+		{
+			name:    "delete by prefix",
+			method:  http.MethodDelete,
+			request: "/value/?prefix=testGauge9",
+			want: want{
+				code:     http.StatusOK,
+				response: "2",
+			},
+		},
+		{
+			name:    "left after delete by prefix",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge1",
+			want: want{
+				code:     http.StatusOK,
+				response: "1.1",
+			},
+		},
+	}
+	store := storage.NewMemoryStorage()
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: store,
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	_ = store.MassUpsert(context.Background(), []m.Metric{
+		{Kind: "gauge", Name: "testGauge90", Value: 99.11},
+		{Kind: "gauge", Name: "testGauge91", Value: 1},
+		{Kind: "gauge", Name: "testGauge92", Value: 2},
+		{Kind: "gauge", Name: "testGauge1", Value: 1.1},
+		{Kind: "counter", Name: "testCounter90", Delta: 10},
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, tt.method, tt.request, nil, nil)
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			assert.Equal(t, tt.want.response, body, tt.name)
+		})
+	}
+}
+
+func TestRouter_labels(t *testing.T) {
+	type want struct {
+		response string
+		code     int
+	}
+	jsonHeader := http.Header{
+		m.HTTPHeaderContentType: []string{"application/json"},
+	}
+	tests := []struct {
+		name    string
+		request string
+		body    string
+		want    want
+	}{
+		{
+			name:    "update with labels",
+			request: "/update/",
+			body:    `{"type": "counter", "delta": 5, "id": "testCounter90", "labels": {"host": "web1"}}`,
+			want: want{
+				code:     http.StatusOK,
+				response: `{"delta":5,"id":"testCounter90","type":"counter","labels":{"host":"web1"}}`,
+			},
+		},
+		{
+			name:    "update with invalid label",
+			request: "/update/",
+			body:    `{"type": "counter", "delta": 5, "id": "testCounter90", "labels": {"host-name": "web1"}}`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid label: label name 'host-name'",
+			},
+		},
+		{
+			name:    "updates with labels",
+			request: "/updates/",
+			body: `[{"type": "counter", "delta": 1, "id": "testCounter90"},
+				{"type": "counter", "delta": 2, "id": "testCounter90", "labels": {"host": "web2"}},
+				{"type": "counter", "delta": 3, "id": "testCounter90", "labels": {"host": "web1"}}]`,
+			want: want{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name:    "value with labels",
+			request: "/value/",
+			body:    `{"type": "counter", "id": "testCounter90", "labels": {"host": "web1"}}`,
+			want: want{
+				code: http.StatusOK,
+				response: `{"delta":8,"id":"testCounter90","type":"counter","labels":{"host":"web1"},` +
+					`"source":"127.0.0.1","version":2}`,
+			},
+		},
+		{
+			name:    "value without labels",
+			request: "/value/",
+			body:    `{"type": "counter", "id": "testCounter90"}`,
+			want: want{
+				code:     http.StatusOK,
+				response: `{"delta":1,"id":"testCounter90","type":"counter","source":"127.0.0.1","version":1}`,
+			},
+		},
+		{
+			name:    "value with labels not found",
+			request: "/value/",
+			body:    `{"type": "counter", "id": "testCounter90", "labels": {"host": "web3"}}`,
+			want: want{
+				code:     http.StatusNotFound,
+				response: "not found",
+			},
+		},
+		{
+			name:    "values by matchers",
+			request: "/values/",
+			body:    `{"type": "counter", "id": "testCounter90", "matchers": ["host=~web.*"]}`,
+			want: want{
+				code: http.StatusOK,
+				response: `[{"delta":8,"id":"testCounter90","type":"counter","labels":{"host":"web1"}},` +
+					`{"delta":2,"id":"testCounter90","type":"counter","labels":{"host":"web2"}}]`,
+			},
+		},
+		{
+			name:    "values by invalid matcher",
+			request: "/values/",
+			body:    `{"type": "counter", "id": "testCounter90", "matchers": ["host"]}`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid label: matcher 'host'",
+			},
+		},
+	}
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: storage.NewMemoryStorage(),
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, http.MethodPost, tt.request, jsonHeader, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			assert.Equal(t, tt.want.response, withoutUpdatedAt(body), tt.name)
+		})
+	}
+}
+
+func TestRouter_metadata(t *testing.T) {
+	type want struct {
+		response string
+		code     int
+	}
+	tests := []struct {
+		name    string
+		request string
+		header  http.Header
+		body    string
+		want    want
+	}{
+		{
+			name:    "update with unit and description",
+			request: "/update/",
+			header: http.Header{
+				m.HTTPHeaderContentType: []string{"application/json"},
+				m.HTTPHeaderRealIP:      []string{"10.0.0.7"},
+			},
+			body: `{"type": "gauge", "value": 1.5, "id": "testGauge91", "unit": "bytes", "description": "heap"}`,
+			want: want{
+				code:     http.StatusOK,
+				response: `{"value":1.5,"id":"testGauge91","type":"gauge","unit":"bytes","description":"heap"}`,
+			},
+		},
+		{
+			name:    "value with source from header",
+			request: "/value/",
+			header: http.Header{
+				m.HTTPHeaderContentType: []string{"application/json"},
+			},
+			body: `{"type": "gauge", "id": "testGauge91"}`,
+			want: want{
+				code: http.StatusOK,
+				response: `{"value":1.5,"id":"testGauge91","type":"gauge","unit":"bytes","description":"heap",` +
+					`"source":"10.0.0.7","version":1}`,
+			},
+		},
+		{
+			name:    "update keeps unit and description",
+			request: "/update/",
+			header: http.Header{
+				m.HTTPHeaderContentType: []string{"application/json"},
+			},
+			body: `{"type": "gauge", "value": 2.5, "id": "testGauge91"}`,
+			want: want{
+				code:     http.StatusOK,
+				response: `{"value":2.5,"id":"testGauge91","type":"gauge","unit":"bytes","description":"heap"}`,
+			},
+		},
+		{
+			name:    "value with source from remote address",
+			request: "/value/",
+			header: http.Header{
+				m.HTTPHeaderContentType: []string{"application/json"},
+			},
+			body: `{"type": "gauge", "id": "testGauge91"}`,
+			want: want{
+				code: http.StatusOK,
+				response: `{"value":2.5,"id":"testGauge91","type":"gauge","unit":"bytes","description":"heap",` +
+					`"source":"127.0.0.1","version":2}`,
+			},
+		},
+	}
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: storage.NewMemoryStorage(),
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, http.MethodPost, tt.request, tt.header, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			if tt.request == "/value/" {
+				assert.Regexp(t, `"updated_at":"[^"]+"`, body, tt.name)
+			}
+			assert.Equal(t, tt.want.response, withoutUpdatedAt(body), tt.name)
+		})
+	}
+}
+
+func TestRouter_histogram(t *testing.T) {
+	type want struct {
+		response string
+		code     int
+	}
+	jsonHeader := http.Header{
+		m.HTTPHeaderContentType: []string{"application/json"},
+	}
+	tests := []struct {
+		name    string
+		method  string
+		request string
+		body    string
+		want    want
+	}{
+		{
+			name:    "update histogram",
+			method:  http.MethodPost,
+			request: "/update/",
+			body: `{"type": "histogram", "id": "testHistogram90",
+				"histogram": {"bounds": [0.1, 1], "counts": [1, 2, 0], "sum": 1.25}}`,
+			want: want{
+				code: http.StatusOK,
+				response: `{"id":"testHistogram90","type":"histogram",` +
+					`"histogram":{"bounds":[0.1,1],"counts":[1,2,0],"sum":1.25}}`,
+			},
+		},
+		{
+			name:    "updates merge histogram",
+			method:  http.MethodPost,
+			request: "/updates/",
+			body: `[{"type": "histogram", "id": "testHistogram90",
+				"histogram": {"bounds": [0.1, 1], "counts": [0, 0, 1], "sum": 2}}]`,
+			want: want{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name:    "value of histogram",
+			method:  http.MethodPost,
+			request: "/value/",
+			body:    `{"type": "histogram", "id": "testHistogram90"}`,
+			want: want{
+				code: http.StatusOK,
+				response: `{"id":"testHistogram90","type":"histogram",` +
+					`"histogram":{"bounds":[0.1,1],"counts":[1,2,1],"sum":3.25},"source":"127.0.0.1","version":2}`,
+			},
+		},
+		{
+			name:    "plain value of histogram",
+			method:  http.MethodGet,
+			request: "/value/histogram/testHistogram90",
+			want: want{
+				code:     http.StatusOK,
+				response: "count=4 sum=3.25 le0.1=1 le1=3 le+Inf=4",
+			},
+		},
+		{
+			name:    "plain update of histogram",
+			method:  http.MethodPost,
+			request: "/update/histogram/testHistogram90/1",
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "bad request: not supported",
+			},
+		},
+		{
+			name:    "update invalid histogram",
+			method:  http.MethodPost,
+			request: "/update/",
+			body: `{"type": "histogram", "id": "testHistogram90",
+				"histogram": {"bounds": [1, 0.1], "counts": [1, 2, 0], "sum": 1.25}}`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid histogram: bounds are not increasing",
+			},
+		},
+		{
+			name:    "update histogram without buckets",
+			method:  http.MethodPost,
+			request: "/update/",
+			body:    `{"type": "histogram", "id": "testHistogram90"}`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid histogram: no buckets",
+			},
+		},
+	}
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: storage.NewMemoryStorage(),
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, tt.method, tt.request, jsonHeader, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			assert.Equal(t, tt.want.response, withoutUpdatedAt(body), tt.name)
+		})
+	}
+}
+
+func TestRouter_summary(t *testing.T) {
+	type want struct {
+		response string
+		code     int
+	}
+	jsonHeader := http.Header{
+		m.HTTPHeaderContentType: []string{"application/json"},
+	}
+	tests := []struct {
+		name    string
+		method  string
+		request string
+		body    string
+		want    want
+	}{
+		{
+			name:    "update summary",
+			method:  http.MethodPost,
+			request: "/update/",
+			body: `{"type": "summary", "id": "testSummary90",
+				"summary": {"accuracy": 0.01, "positive": {"0": 2}, "sum": 2, "min": 1, "max": 1}}`,
+			want: want{
+				code: http.StatusOK,
+				response: `{"id":"testSummary90","type":"summary",` +
+					`"summary":{"positive":{"0":2},"accuracy":0.01,"sum":2,"min":1,"max":1},` +
+					`"quantiles":{"p50":1,"p90":1,"p95":1,"p99":1}}`,
+			},
+		},
+		{
+			name:    "updates merge summary",
+			method:  http.MethodPost,
+			request: "/updates/",
+			body: `[{"type": "summary", "id": "testSummary90",
+				"summary": {"accuracy": 0.01, "positive": {"116": 2}, "sum": 20, "min": 10, "max": 10}}]`,
+			want: want{
+				code: http.StatusOK,
+			},
+		},
+		{
+			name:    "value of summary",
+			method:  http.MethodPost,
+			request: "/value/",
+			body:    `{"type": "summary", "id": "testSummary90"}`,
+			want: want{
+				code: http.StatusOK,
+				response: `{"id":"testSummary90","type":"summary",` +
+					`"summary":{"positive":{"0":2,"116":2},"accuracy":0.01,"sum":22,"min":1,"max":10},` +
+					`"quantiles":{"p50":1,"p90":10,"p95":10,"p99":10},"source":"127.0.0.1","version":2}`,
+			},
+		},
+		{
+			name:    "plain value of summary",
+			method:  http.MethodGet,
+			request: "/value/summary/testSummary90",
+			want: want{
+				code:     http.StatusOK,
+				response: "count=4 sum=22 min=1 max=10 p50=1 p90=10 p95=10 p99=10",
+			},
+		},
+		{
+			name:    "plain update of summary",
+			method:  http.MethodPost,
+			request: "/update/summary/testSummary90/1",
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "bad request: not supported",
+			},
+		},
+		{
+			name:    "update summary with invalid accuracy",
+			method:  http.MethodPost,
+			request: "/update/",
+			body:    `{"type": "summary", "id": "testSummary90", "summary": {"accuracy": 1}}`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid summary: accuracy 1",
+			},
+		},
+		{
+			name:    "update summary without sketch",
+			method:  http.MethodPost,
+			request: "/update/",
+			body:    `{"type": "summary", "id": "testSummary90"}`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid summary: no sketch",
+			},
+		},
+	}
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: storage.NewMemoryStorage(),
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, tt.method, tt.request, jsonHeader, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			assert.Equal(t, tt.want.response, withoutUpdatedAt(body), tt.name)
+		})
+	}
+}
+
+func TestRouter_listMetrics(t *testing.T) {
+	type want struct {
+		response string
+		code     int
+	}
+	st := storage.NewMemoryStorage()
+	_ = st.MassUpsert(context.Background(), []m.Metric{
+		{Kind: m.MetricKindGauge, Name: "testList2", Value: 2},
+		{Kind: m.MetricKindCounter, Name: "testList1", Delta: 1},
+		{Kind: m.MetricKindGauge, Name: "testList1", Value: 1},
+		{Kind: m.MetricKindGauge, Name: "otherList1", Value: 3},
+	})
+	next := m.ListCursor{Kind: m.MetricKindCounter, Name: "testList1"}.String()
+	tests := []struct {
+		name    string
+		request string
+		want    want
+	}{
+		{
+			name:    "first page",
+			request: "/values?prefix=test&limit=2",
+			want: want{
+				code: http.StatusOK,
+				response: `{"metrics":[{"delta":1,"id":"testList1","type":"counter"},` +
+					`{"value":1,"id":"testList1","type":"gauge"}],"next_cursor":"` +
+					m.ListCursor{Kind: m.MetricKindGauge, Name: "testList1"}.String() + `"}`,
+			},
+		},
+		{
+			name:    "next page",
+			request: "/values?prefix=test&limit=1&cursor=" + next,
+			want: want{
+				code: http.StatusOK,
+				response: `{"metrics":[{"value":1,"id":"testList1","type":"gauge"}],"next_cursor":"` +
+					m.ListCursor{Kind: m.MetricKindGauge, Name: "testList1"}.String() + `"}`,
+			},
+		},
+		{
+			name:    "filter by kind and regex",
+			request: "/values?kind=gauge&regex=.*List1",
+			want: want{
+				code: http.StatusOK,
+				response: `{"metrics":[{"value":3,"id":"otherList1","type":"gauge"},` +
+					`{"value":1,"id":"testList1","type":"gauge"}]}`,
+			},
+		},
+		{
+			name:    "nothing found",
+			request: "/values?prefix=preved",
+			want: want{
+				code:     http.StatusOK,
+				response: `{"metrics":[]}`,
+			},
+		},
+		{
+			name:    "invalid limit",
+			request: "/values?limit=preved",
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid query: limit 'preved'",
+			},
+		},
+		{
+			name:    "invalid kind",
+			request: "/values?kind=preved",
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid query: metric kind 'preved'",
+			},
+		},
+		{
+			name:    "invalid regex",
+			request: "/values?regex=(",
+			want: want{
+				code: http.StatusBadRequest,
+			},
+		},
+		{
+			name:    "invalid cursor",
+			request: "/values?cursor=preved",
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid query: cursor 'preved'",
+			},
+		},
+	}
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: st,
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, http.MethodGet, tt.request, http.Header{}, nil)
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			if tt.want.response != "" {
+				assert.Equal(t, tt.want.response, withoutUpdatedAt(body), tt.name)
+			}
+		})
+	}
+}
+
+func TestRouter_conditionalUpdate(t *testing.T) {
+	type want struct {
+		response string
+		code     int
+	}
+	jsonHeader := http.Header{
+		m.HTTPHeaderContentType: []string{"application/json"},
+	}
+	tests := []struct {
+		name    string
+		request string
+		body    string
+		want    want
+	}{
+		{
+			name:    "create gauge",
+			request: "/update/",
+			body:    `{"type": "gauge", "id": "testGauge95", "value": 1, "if_version": 0}`,
+			want: want{
+				code:     http.StatusOK,
+				response: `{"value":1,"id":"testGauge95","type":"gauge","version":1}`,
+			},
+		},
+		{
+			name:    "create existing gauge",
+			request: "/update/",
+			body:    `{"type": "gauge", "id": "testGauge95", "value": 2, "if_version": 0}`,
+			want: want{
+				code:     http.StatusConflict,
+				response: "conflict: version is 1, not 0",
+			},
+		},
+		{
+			name:    "max with greater value",
+			request: "/update/",
+			body:    `{"type": "gauge", "id": "testGauge95", "value": 3, "mode": "max", "if_version": 1}`,
+			want: want{
+				code:     http.StatusOK,
+				response: `{"value":3,"id":"testGauge95","type":"gauge","version":2}`,
+			},
+		},
+		{
+			name:    "max with less value",
+			request: "/update/",
+			body:    `{"type": "gauge", "id": "testGauge95", "value": 2, "mode": "max"}`,
+			want: want{
+				code:     http.StatusConflict,
+				response: "conflict: 2 is not greater than 3",
+			},
+		},
+		{
+			name:    "unknown mode",
+			request: "/update/",
+			body:    `{"type": "gauge", "id": "testGauge95", "value": 2, "mode": "preved"}`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "invalid condition: mode 'preved'",
+			},
+		},
+		{
+			name:    "counter",
+			request: "/update/",
+			body:    `{"type": "counter", "id": "testCounter95", "delta": 2, "if_version": 0}`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "not supported: conditional update of counter",
+			},
+		},
+		{
+			name:    "condition in batch",
+			request: "/updates/",
+			body:    `[{"type": "gauge", "id": "testGauge95", "value": 2, "mode": "min"}]`,
+			want: want{
+				code:     http.StatusBadRequest,
+				response: "not supported: conditional update of testGauge95 in batch",
+			},
+		},
+		{
+			name:    "value with version",
+			request: "/value/",
+			body:    `{"type": "gauge", "id": "testGauge95"}`,
+			want: want{
+				code:     http.StatusOK,
+				response: `{"value":3,"id":"testGauge95","type":"gauge","source":"127.0.0.1","version":2}`,
+			},
+		},
+	}
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: storage.NewMemoryStorage(),
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, http.MethodPost, tt.request, jsonHeader, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			if tt.want.response != "" {
+				assert.Equal(t, tt.want.response, withoutUpdatedAt(body), tt.name)
+			}
+		})
+	}
+}
+
+// versionedStorage is storage with versioned schema.
+type versionedStorage struct {
+	*storage.MemoryStorage
+}
+
+func (s versionedStorage) SchemaVersion(_ context.Context) (int, error) {
+	return 4, nil
+}
+
+func TestRouter_getSchemaVersion(t *testing.T) {
+	tests := []struct {
+		name     string
+		storage  config.Storage
+		code     int
+		response string
+	}{
+		{
+			name:     "versioned",
+			storage:  versionedStorage{storage.NewMemoryStorage()},
+			code:     http.StatusOK,
+			response: "4",
+		},
+		{
+			name:     "not versioned",
+			storage:  storage.NewMemoryStorage(),
+			code:     http.StatusNotFound,
+			response: "not supported: schema version",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRouterWithOptions(&config.Options{
+				Config:  cfg,
+				Storage: tt.storage,
+				Logger:  *lm,
+			})
+			ts := httptest.NewServer(r)
+			defer ts.Close()
+			resp, body := testRequest(t, ts, http.MethodGet, "/schema", nil, nil)
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.code, resp.StatusCode, tt.name)
+			assert.Equal(t, tt.response, body, tt.name)
+		})
+	}
+}
+
+func TestRouter_tenants(t *testing.T) {
+	type want struct {
+		response string
+		code     int
+	}
+	tests := []struct {
+		name    string
+		method  string
+		request string
+		header  http.Header
+		body    string
+		want    want
+	}{
+		{
+			name:    "update in tenant one",
+			method:  http.MethodPost,
+			request: "/update/gauge/testGauge96/1",
+			header:  http.Header{m.HTTPHeaderTenant: []string{"one"}},
+			want:    want{code: http.StatusOK},
+		},
+		{
+			name:    "update in tenant two",
+			method:  http.MethodPost,
+			request: "/update/gauge/testGauge96/2",
+			header:  http.Header{m.HTTPHeaderTenant: []string{"two"}},
+			want:    want{code: http.StatusOK},
+		},
+		{
+			name:    "value in tenant one",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge96",
+			header:  http.Header{m.HTTPHeaderTenant: []string{"one"}},
+			want:    want{code: http.StatusOK, response: "1"},
+		},
+		{
+			name:    "value in tenant two",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge96",
+			header:  http.Header{m.HTTPHeaderTenant: []string{"two"}},
+			want:    want{code: http.StatusOK, response: "2"},
+		},
+		{
+			name:    "value in default tenant",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge96",
+			want:    want{code: http.StatusNotFound},
+		},
+	}
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: storage.NewMemoryStorage(),
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, tt.method, tt.request, tt.header, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			if tt.want.response != "" {
+				assert.Equal(t, tt.want.response, body, tt.name)
+			}
+		})
+	}
+}
+
+func TestRouter_tenantKeys(t *testing.T) {
+	const (
+		keyOne = "secretOne"
+		keyTwo = "secretTwo"
+	)
+	signed := func(key, body string) http.Header {
+		return http.Header{
+			m.HTTPHeaderContentType: []string{"application/json"},
+			m.HTTPHeaderSign:        []string{utils.Hash([]byte(body), key)},
+			// header is ignored when tenant keys are configured
+			m.HTTPHeaderTenant: []string{"two"},
+		}
+	}
+	type want struct {
+		response string
+		code     int
+	}
+	tests := []struct {
+		name    string
+		method  string
+		request string
+		header  http.Header
+		body    string
+		want    want
+	}{
+		{
+			name:    "update in tenant one",
+			method:  http.MethodPost,
+			request: "/update/",
+			header:  signed(keyOne, `{"type": "gauge", "id": "testGauge97", "value": 1}`),
+			body:    `{"type": "gauge", "id": "testGauge97", "value": 1}`,
+			want:    want{code: http.StatusOK},
+		},
+		{
+			name:    "update in tenant two",
+			method:  http.MethodPost,
+			request: "/update/",
+			header:  signed(keyTwo, `{"type": "gauge", "id": "testGauge97", "value": 2}`),
+			body:    `{"type": "gauge", "id": "testGauge97", "value": 2}`,
+			want:    want{code: http.StatusOK},
+		},
+		{
+			name:    "value in tenant one",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge97",
+			header:  signed(keyOne, ""),
+			want:    want{code: http.StatusOK, response: "1"},
+		},
+		{
+			name:    "value in tenant two",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge97",
+			header:  signed(keyTwo, ""),
+			want:    want{code: http.StatusOK, response: "2"},
+		},
+		{
+			name:    "invalid sign",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge97",
+			header:  signed("unknown", ""),
+			want:    want{code: http.StatusBadRequest, response: "Invalid sign"},
+		},
+		{
+			name:    "value in default tenant",
+			method:  http.MethodGet,
+			request: "/value/gauge/testGauge97",
+			want:    want{code: http.StatusNotFound},
+		},
+	}
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: storage.NewMemoryStorage(),
+		Logger:  *lm,
+		Tenants: map[string]string{keyOne: "one", keyTwo: "two"},
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, tt.method, tt.request, tt.header, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			if tt.want.response != "" {
+				assert.Equal(t, tt.want.response, body, tt.name)
+			}
+		})
+	}
+}
+
+func TestRouter_getPrometheusMetrics(t *testing.T) {
+	st := storage.NewMemoryStorage()
+	histogram := m.NewHistogram([]float64{0.1, 1})
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	err := st.MassUpsert(context.Background(), []m.Metric{
+		{Kind: m.MetricKindCounter, Name: "PollCount", Delta: 5, Description: "count of polls"},
+		{Kind: m.MetricKindGauge, Name: "cpu.util", Value: 0.5, Labels: m.Labels{"core": "1"}},
+		{Kind: m.MetricKindGauge, Name: "cpu-util", Value: 0.7, Labels: m.Labels{"core": "1"}},
+		{Kind: m.MetricKindGauge, Name: "cpu.util", Value: 0.25, Labels: m.Labels{"core": `a"b`}},
+		{Kind: m.MetricKindGauge, Name: "1Conflict", Value: 1},
+		{Kind: m.MetricKindCounter, Name: "1Conflict", Delta: 1},
+		{Kind: m.MetricKindHistogram, Name: "latency", Histogram: histogram},
+		{Kind: m.MetricKindSummary, Name: "size", Summary: m.NewSummary(m.DefaultSummaryAccuracy)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP PollCount count of polls
+# TYPE PollCount counter
+PollCount 5
+# HELP _1Conflict 1Conflict
+# TYPE _1Conflict counter
+_1Conflict 1
+# HELP cpu_util cpu-util
+# TYPE cpu_util gauge
+cpu_util{core="1"} 0.7
+cpu_util{core="a\"b"} 0.25
+# HELP latency latency
+# TYPE latency histogram
+latency_bucket{le="0.1"} 1
+latency_bucket{le="1"} 2
+latency_bucket{le="+Inf"} 2
+latency_sum 0.55
+latency_count 2
+# HELP size size
+# TYPE size summary
+size_sum 0
+size_count 0
+`
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: st,
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+
+	resp, body := testRequest(t, ts, http.MethodGet, "/metrics", nil, nil)
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, m.HTTPHeaderContentTypeTextPrometheus, resp.Header.Get(m.HTTPHeaderContentType))
+	assert.Equal(t, want, body+"\n")
+
+	// transport decompresses response itself only if it requests gzip
+	transport := &http.Transport{DisableCompression: true}
+	defer transport.CloseIdleConnections()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, ts.URL+"/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set(m.HTTPHeaderAcceptEncoding, "gzip, deflate")
+	gzResp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = gzResp.Body.Close()
+	}()
+	assert.Equal(t, m.HTTPHeaderEncodingGzip, gzResp.Header.Get(m.HTTPHeaderContentEncoding))
+	compressed, err := io.ReadAll(gzResp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := utils.Decompress(compressed)
+	assert.NoError(t, err)
+	assert.Equal(t, want, string(data))
+}
+
+func TestRouter_postInfluxWrite(t *testing.T) {
+	const key = "secret"
+	lines := "cpu,host=a usage=0.5,procs=3i,errors=1i 1700000000\ncpu,host=a usage=0.25 1700000001\n"
+	compressed, err := utils.Compress([]byte(lines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	type want struct {
+		response string
+		code     int
+	}
+	tests := []struct {
+		name    string
+		request string
+		header  http.Header
+		body    string
+		want    want
+	}{
+		{
+			name:    "write lines",
+			request: "/write?precision=s",
+			header:  http.Header{m.HTTPHeaderSign: []string{utils.Hash([]byte(lines), key)}},
+			body:    lines,
+			want:    want{code: http.StatusNoContent},
+		},
+		{
+			name:    "write gzipped lines",
+			request: "/write?precision=s",
+			header: http.Header{
+				m.HTTPHeaderContentEncoding: []string{m.HTTPHeaderEncodingGzip},
+				// sign is checked before decompression as for agent requests
+				m.HTTPHeaderSign: []string{utils.Hash(compressed, key)},
+			},
+			body: string(compressed),
+			want: want{code: http.StatusNoContent},
+		},
+		{
+			name:    "write line without timestamp",
+			request: "/write",
+			header:  http.Header{m.HTTPHeaderSign: []string{utils.Hash([]byte("cpu,host=a errors=2i"), key)}},
+			body:    "cpu,host=a errors=2i",
+			want:    want{code: http.StatusNoContent},
+		},
+		{
+			name:    "write outdated line",
+			request: "/write?precision=s",
+			header:  http.Header{m.HTTPHeaderSign: []string{utils.Hash([]byte("cpu,host=a usage=0.75 1600000000"), key)}},
+			body:    "cpu,host=a usage=0.75 1600000000",
+			want:    want{code: http.StatusNoContent},
+		},
+		{
+			name:    "invalid sign",
+			request: "/write",
+			header:  http.Header{m.HTTPHeaderSign: []string{utils.Hash([]byte(lines), "unknown")}},
+			body:    lines,
+			want:    want{code: http.StatusBadRequest, response: "Invalid sign"},
+		},
+		{
+			name:    "invalid line",
+			request: "/write",
+			body:    "cpu usage=high",
+			want:    want{code: http.StatusBadRequest, response: "invalid line protocol: line 1: field 'usage': not float"},
+		},
+		{
+			name:    "invalid precision",
+			request: "/write?precision=h",
+			body:    lines,
+			want:    want{code: http.StatusBadRequest, response: "invalid line protocol: precision 'h'"},
+		},
+	}
+	keyCfg := cfg
+	keyCfg.Key = key
+	st := storage.NewMemoryStorage()
+	r := NewRouterWithOptions(&config.Options{
+		Config:         keyCfg,
+		Storage:        st,
+		Logger:         *lm,
+		InfluxCounters: m.InfluxCounters{"cpu_err*"},
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, http.MethodPost, tt.request, tt.header, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			if tt.want.response != "" {
+				assert.Equal(t, tt.want.response, body, tt.name)
+			}
+		})
+	}
+	labels := m.Labels{"host": "a"}
+	gauge, err := GetMetric(context.Background(), st, m.MetricKindGauge, "cpu_usage", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, 0.25, gauge.Value)
+	// integer field is gauge, same lines written twice don't change it
+	gauge, err = GetMetric(context.Background(), st, m.MetricKindGauge, "cpu_procs", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(3), gauge.Value)
+	// deltas of counter are added once, repeated line with same timestamp is skipped
+	counter, err := GetMetric(context.Background(), st, m.MetricKindCounter, "cpu_errors", labels)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), counter.Delta)
+}
+
+func TestRouter_postRemoteWrite(t *testing.T) {
+	// remote write 1.0 request as Prometheus sends it: series of target with two samples of counter,
+	// histogram bucket, metadata of families and series marked stale
+	payload, err := os.ReadFile("testdata/remote_write.snappy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remoteHeader := http.Header{
+		m.HTTPHeaderContentType:     []string{"application/x-protobuf"},
+		m.HTTPHeaderContentEncoding: []string{"snappy"},
+	}
+	type want struct {
+		response string
+		code     int
+	}
+	tests := []struct {
+		name   string
+		header http.Header
+		body   string
+		want   want
+	}{
+		{
+			name:   "write samples",
+			header: remoteHeader,
+			body:   string(payload),
+			want:   want{code: http.StatusNoContent},
+		},
+		{
+			name:   "not compressed",
+			header: remoteHeader,
+			body:   "samples",
+			want:   want{code: http.StatusBadRequest, response: "invalid remote write: snappy: corrupt input"},
+		},
+		{
+			name: "remote write 2.0",
+			header: http.Header{
+				m.HTTPHeaderContentType: []string{"application/x-protobuf;proto=io.prometheus.write.v2.Request"},
+			},
+			body: string(payload),
+			want: want{code: http.StatusUnsupportedMediaType, response: "not supported"},
+		},
+	}
+	st := storage.NewMemoryStorage()
+	r := NewRouterWithOptions(&config.Options{
+		Config:  cfg,
+		Storage: st,
+		Logger:  *lm,
+	})
+	ts := httptest.NewServer(r)
+	defer ts.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, body := testRequest(t, ts, http.MethodPost, "/api/v1/write", tt.header, strings.NewReader(tt.body))
+			defer func() {
+				_ = resp.Body.Close()
+			}()
+			assert.Equal(t, tt.want.code, resp.StatusCode, tt.name)
+			if tt.want.response != "" {
+				assert.Equal(t, tt.want.response, body, tt.name)
+			}
+		})
+	}
+
+	ctx := context.Background()
+	target := m.Labels{"instance": "localhost:9090", "job": "prometheus"}
+	up, err := GetMetric(ctx, st, m.MetricKindGauge, "up", target)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, up.Value)
+	requests, err := GetMetric(ctx, st, m.MetricKindGauge, "prometheus_http_requests_total",
+		m.Labels{"code": "200", "handler": "/metrics", "instance": "localhost:9090", "job": "prometheus"})
+	assert.NoError(t, err)
+	assert.Equal(t, 42.0, requests.Value)
+	assert.Equal(t, "Counter of HTTP requests.", requests.Description)
+	bucket, err := GetMetric(ctx, st, m.MetricKindGauge, "prometheus_http_request_duration_seconds_bucket",
+		m.Labels{"handler": "/metrics", "instance": "localhost:9090", "job": "prometheus", "le": "0.1"})
+	assert.NoError(t, err)
+	assert.Equal(t, 40.0, bucket.Value)
+	assert.Equal(t, "seconds", bucket.Unit)
+	// label with empty value is skipped, stale sample is ignored
+	goroutines, err := GetMetric(ctx, st, m.MetricKindGauge, "go_goroutines", target)
+	assert.NoError(t, err)
+	assert.Equal(t, 33.0, goroutines.Value)
+	selected, err := st.Select(ctx, m.MetricKindGauge, "scrape_duration_seconds")
+	assert.NoError(t, err)
+	assert.Empty(t, selected)
+}
@@ -1,9 +1,7 @@
 package server
 
 import (
-	"fmt"
 	"net"
-	"net/http"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -31,6 +29,9 @@ func NewRouterWithOptions(opts *config.Options) *Router {
 		router.opts.TrustedSubnets = []net.IPNet{}
 	}
 	router.opts.Logger = opts.Logger
+	router.opts.TTLPolicy = opts.TTLPolicy
+	router.opts.Tenants = opts.Tenants
+	router.opts.InfluxCounters = opts.InfluxCounters
 	router.SetMiddlewares()
 	router.SetHandlers()
 	return router
@@ -45,26 +46,30 @@ func (r *Router) SetMiddlewares() {
 	if r.opts.PrivateKey != nil {
 		r.Use(r.decryptHandler)
 	}
-	r.Use(checkHashHandle)
+	if len(r.opts.Tenants) == 0 {
+		r.Use(tenantHandle)
+	}
+	r.Use(r.checkHashHandle)
 	r.Use(gzipHandle)
+	r.Use(sourceHandle)
 }
 
 func (r *Router) SetHandlers() {
-	r.Post("/"+models.MetricPathPostPrefix+"/{kind}/{name}/{value}", func(w http.ResponseWriter, req *http.Request) {
-		metric := models.Metric{
-			Kind:  chi.URLParam(req, "kind"),
-			Value: chi.URLParam(req, "value"),
-		}
-		if err := CheckMetricKind(metric); err != nil {
-			http.Error(w, fmt.Sprintf("%s", err), http.StatusBadRequest)
-			return
-		}
-		r.postUpdate(w, req)
-	})
+	r.Post("/"+models.MetricPathPostPrefix+"/{kind}/{name}/{value}", r.postUpdate)
+	r.Post("/"+models.MetricPathPostPrefix+"/"+models.MetricKindCounter+"/{name}/"+models.MetricPathReset,
+		r.postResetCounter)
 	r.Post("/"+models.MetricPathPostPrefix+"/", r.postUpdateJSON)
 	r.Post("/"+models.MetricPathPostsPrefix+"/", r.postUpdatesJSON)
+	r.Post("/"+models.InfluxPath, r.postInfluxWrite)
+	r.Post("/"+models.RemoteWritePath, r.postRemoteWrite)
 	r.Get("/"+models.MetricPathGetPrefix+"/{kind}/{name}", r.getValue)
+	r.Delete("/"+models.MetricPathGetPrefix+"/{kind}/{name}", r.deleteValue)
+	r.Delete("/"+models.MetricPathGetPrefix+"/", r.deleteValues)
 	r.Get("/", r.getIndex)
 	r.Post("/"+models.MetricPathGetPrefix+"/", r.getMetricJSON)
+	r.Post("/"+models.MetricPathGetsPrefix+"/", r.selectMetricsJSON)
+	r.Get("/"+models.MetricPathGetsPrefix, r.listMetricsJSON)
 	r.Get("/"+models.PingPath, r.pingStorage)
+	r.Get("/"+models.SchemaPath, r.getSchemaVersion)
+	r.Get("/"+models.PrometheusPath, r.getPrometheusMetrics)
 }
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
+)
+
+// UpdateMetricsFromInflux updates metrics from InfluxDB line protocol with timestamps of precision.
+// Integer fields of counters are counter deltas. Points with timestamp older than last update of their
+// metric are skipped, so repeated lines don't change metrics.
+func UpdateMetricsFromInflux(ctx context.Context, st config.Storage, req []byte, precision string,
+	counters models.InfluxCounters) error {
+	points, err := models.ParseInfluxLines(req, precision, counters, time.Now())
+	if err != nil {
+		return err
+	}
+	metrics := make([]models.Metric, 0, len(points))
+	for _, point := range points {
+		if !point.Time.IsZero() {
+			meta, err := st.GetMetadata(ctx, point.Metric)
+			switch {
+			case errors.Is(err, models.ErrHTTPNotFound):
+			case err != nil:
+				return err
+			case point.Time.Before(meta.UpdatedAt):
+				continue
+			}
+		}
+		metrics = append(metrics, point.Metric)
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	return st.MassUpsert(ctx, metrics)
+}
@@ -3,14 +3,15 @@ package server
 import (
 	"context"
 	"encoding/json"
-	"strconv"
+	"fmt"
 
 	"github.com/sejo412/ya-metrics/internal/config"
 	"github.com/sejo412/ya-metrics/internal/models"
 )
 
-// UpdateMetricFromJSON updates metric from incoming json.
-func UpdateMetricFromJSON(st config.Storage, req []byte) ([]byte, error) {
+// UpdateMetricFromJSON updates metric from incoming json. Gauge with version or mode condition is updated
+// only if condition holds, response has new version of gauge then.
+func UpdateMetricFromJSON(ctx context.Context, st config.Storage, req []byte) ([]byte, error) {
 	var (
 		metric models.MetricV2
 		err    error
@@ -19,54 +20,83 @@ func UpdateMetricFromJSON(st config.Storage, req []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	m := models.Metric{
-		Kind: metric.MType,
-		Name: metric.ID,
+	if err = metric.Labels.Validate(); err != nil {
+		return nil, err
 	}
 	switch metric.MType {
-	case models.MetricKindGauge:
-		m.Value = strconv.FormatFloat(*metric.Value, 'f', -1, 64)
-	case models.MetricKindCounter:
-		m.Value = strconv.FormatInt(*metric.Delta, 10)
+	case models.MetricKindGauge, models.MetricKindCounter, models.MetricKindHistogram, models.MetricKindSummary:
 	default:
 		return nil, models.ErrNotSupported
 	}
+	m, err := models.ConvertV2ToV1(&metric)
+	if err != nil {
+		return nil, metricValueError(err)
+	}
+	if metric.IfVersion != nil || metric.Mode != "" {
+		return updateMetricIfJSON(ctx, st, m, models.Condition{Version: metric.IfVersion, Mode: metric.Mode})
+	}
+	if err := st.Upsert(ctx, *m); err != nil {
+		return nil, err
+	}
+	return getMetricJSON(ctx, st, metric.MType, metric.ID, metric.Labels, false)
+}
 
-	if err := CheckMetricKind(m); err != nil {
+// updateMetricIfJSON updates gauge by condition and returns JSON representation of it with new version.
+func updateMetricIfJSON(ctx context.Context, st config.Storage, metric *models.Metric,
+	cond models.Condition) ([]byte, error) {
+	version, err := st.UpsertIf(ctx, *metric, cond)
+	if err != nil {
 		return nil, err
 	}
-	ctx := context.Background()
-	if err := st.Upsert(ctx, m); err != nil {
+	res, err := models.ConvertV1ToV2(metric)
+	if err != nil {
 		return nil, err
 	}
-	return GetMetricJSON(st, metric.MType, metric.ID)
+	res.Version = &version
+	return json.Marshal(res)
 }
 
 // UpdateMetricsFromJSON updates metrics from incoming JSON slice.
-func UpdateMetricsFromJSON(st config.Storage, req []byte) error {
+func UpdateMetricsFromJSON(ctx context.Context, st config.Storage, req []byte) error {
 	parsedMetrics, err := ParsePostRequestJSONSlice(req)
 	if err != nil {
 		return err
 	}
 	res := make([]models.Metric, 0, len(parsedMetrics))
 	for _, metric := range parsedMetrics {
+		if err = metric.Labels.Validate(); err != nil {
+			return err
+		}
+		if metric.IfVersion != nil || metric.Mode != "" {
+			return fmt.Errorf("%w: conditional update of %s in batch", models.ErrNotSupported, metric.ID)
+		}
 		m, err := models.ConvertV2ToV1(&metric)
 		if err != nil {
-			return err
+			return metricValueError(err)
 		}
 		res = append(res, *m)
 	}
-	ctx := context.Background()
 	if err := st.MassUpsert(ctx, res); err != nil {
 		return err
 	}
 	return nil
 }
 
-// GetMetricJSON return JSON representation metric by name.
-func GetMetricJSON(st config.Storage, kind, name string) ([]byte, error) {
-	ctx := context.Background()
-	metric, err := st.Get(ctx, kind, name)
+// GetMetricJSON return JSON representation metric by name and labels.
+func GetMetricJSON(st config.Storage, kind, name string, labels models.Labels) ([]byte, error) {
+	return getMetricJSON(context.Background(), st, kind, name, labels, false)
+}
+
+// GetMetricWithMetadataJSON returns JSON representation of metric by name and labels
+// with time, source and version of last update.
+func GetMetricWithMetadataJSON(ctx context.Context, st config.Storage, kind, name string,
+	labels models.Labels) ([]byte, error) {
+	return getMetricJSON(ctx, st, kind, name, labels, true)
+}
+
+func getMetricJSON(ctx context.Context, st config.Storage, kind, name string, labels models.Labels,
+	withMetadata bool) ([]byte, error) {
+	metric, err := GetMetric(ctx, st, kind, name, labels)
 	if err != nil {
 		return nil, err
 	}
@@ -74,9 +104,64 @@ func GetMetricJSON(st config.Storage, kind, name string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if withMetadata {
+		meta, err := st.GetMetadata(ctx, metric)
+		if err != nil {
+			return nil, err
+		}
+		m.UpdatedAt = &meta.UpdatedAt
+		m.Source = meta.Source
+		m.Version = &meta.Version
+	}
 	return json.Marshal(m)
 }
 
+// SelectMetricsJSON returns JSON representation of metrics selected by query.
+func SelectMetricsJSON(ctx context.Context, st config.Storage, req []byte) ([]byte, error) {
+	var query models.MetricsQuery
+	if err := json.Unmarshal(req, &query); err != nil {
+		return nil, models.ErrHTTPBadRequest
+	}
+	matchers := make([]models.LabelMatcher, 0, len(query.Matchers))
+	for _, s := range query.Matchers {
+		matcher, err := models.ParseLabelMatcher(s)
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, matcher)
+	}
+	metrics, err := st.Select(ctx, query.MType, query.ID, matchers...)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*models.MetricV2, 0, len(metrics))
+	for _, metric := range metrics {
+		m, err := models.ConvertV1ToV2(&metric)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return json.Marshal(res)
+}
+
+// ListMetricsJSON returns JSON representation of page of metrics listed by query.
+func ListMetricsJSON(ctx context.Context, st config.Storage, query models.ListQuery) ([]byte, error) {
+	page, err := st.List(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	res := models.MetricsList{Metrics: make([]*models.MetricV2, 0, len(page.Metrics)), NextCursor: page.Next}
+	for _, metric := range page.Metrics {
+		m, err := models.ConvertV1ToV2(&metric)
+		if err != nil {
+			return nil, err
+		}
+		res.Metrics = append(res.Metrics, m)
+	}
+	return json.Marshal(res)
+}
+
 // ParsePostRequestJSON converts incoming json to MetricV2 type.
 func ParsePostRequestJSON(request []byte) (models.MetricV2, error) {
 	metrics := models.MetricV2{}
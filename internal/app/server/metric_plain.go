@@ -8,6 +8,6 @@ import (
 )
 
 // UpdateMetric inserts or updates MetricV1
-func UpdateMetric(st config.Storage, metric models.Metric) error {
-	return st.Upsert(context.TODO(), metric)
+func UpdateMetric(ctx context.Context, st config.Storage, metric models.Metric) error {
+	return st.Upsert(ctx, metric)
 }
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
+	"github.com/sejo412/ya-metrics/internal/storage"
+)
+
+// prometheusFamily describes metrics with the same name in Prometheus exposition.
+type prometheusFamily struct {
+	kind    string
+	help    string // description of first described metric
+	metrics []models.Metric
+}
+
+// PrometheusMetrics returns metrics in Prometheus text exposition format. Metrics stale according to policy
+// are hidden. Names are sanitised, metrics whose sanitised name is taken by metric of other kind or
+// which duplicate series of other metric are skipped.
+func PrometheusMetrics(ctx context.Context, st config.Storage, policy storage.TTLPolicy) ([]byte, error) {
+	var metrics []models.Metric
+	for metric, err := range st.AllFresh(ctx, policy, time.Now()) {
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, metric)
+	}
+	slices.SortFunc(metrics, models.CompareMetrics)
+	families := make(map[string]*prometheusFamily)
+	series := make(map[string]struct{}, len(metrics))
+	for _, metric := range metrics {
+		if metric.Kind == models.MetricKindHistogram && metric.Histogram == nil ||
+			metric.Kind == models.MetricKindSummary && metric.Summary == nil {
+			continue
+		}
+		name := PrometheusName(metric.Name)
+		family, ok := families[name]
+		if !ok {
+			family = &prometheusFamily{kind: metric.Kind}
+			families[name] = family
+		}
+		if family.kind != metric.Kind {
+			continue
+		}
+		id := name + metric.Labels.String()
+		if _, ok = series[id]; ok {
+			continue
+		}
+		series[id] = struct{}{}
+		if family.help == "" {
+			family.help = metric.Description
+		}
+		family.metrics = append(family.metrics, metric)
+	}
+	buf := new(bytes.Buffer)
+	for _, name := range slices.Sorted(maps.Keys(families)) {
+		family := families[name]
+		slices.SortFunc(family.metrics, func(a, b models.Metric) int {
+			return models.CompareLabels(a.Labels, b.Labels)
+		})
+		help := family.help
+		if help == "" {
+			// original name is kept in help if metric is not described
+			help = family.metrics[0].Name
+		}
+		buf.WriteString("# HELP " + name + " " + prometheusHelpReplacer.Replace(help) + "\n")
+		buf.WriteString("# TYPE " + name + " " + family.kind + "\n")
+		for _, metric := range family.metrics {
+			writePrometheusMetric(buf, name, metric)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// writePrometheusMetric writes samples of metric.
+func writePrometheusMetric(buf *bytes.Buffer, name string, metric models.Metric) {
+	switch metric.Kind {
+	case models.MetricKindGauge:
+		writePrometheusSample(buf, name, metric.Labels, "", "", prometheusFloat(metric.Value))
+	case models.MetricKindCounter:
+		writePrometheusSample(buf, name, metric.Labels, "", "", strconv.FormatInt(metric.Delta, 10))
+	case models.MetricKindHistogram:
+		h := metric.Histogram
+		var cumulative uint64
+		for i, c := range h.Counts {
+			cumulative += c
+			bound := math.Inf(1)
+			if i < len(h.Bounds) {
+				bound = h.Bounds[i]
+			}
+			writePrometheusSample(buf, name+"_bucket", metric.Labels, "le", prometheusFloat(bound),
+				strconv.FormatUint(cumulative, 10))
+		}
+		writePrometheusSample(buf, name+"_sum", metric.Labels, "", "", prometheusFloat(h.Sum))
+		writePrometheusSample(buf, name+"_count", metric.Labels, "", "", strconv.FormatUint(cumulative, 10))
+	case models.MetricKindSummary:
+		s := metric.Summary
+		count := s.Count()
+		if count > 0 {
+			for _, q := range models.SummaryQuantiles {
+				writePrometheusSample(buf, name, metric.Labels, "quantile", prometheusFloat(q),
+					prometheusFloat(s.Quantile(q)))
+			}
+		}
+		writePrometheusSample(buf, name+"_sum", metric.Labels, "", "", prometheusFloat(s.Sum))
+		writePrometheusSample(buf, name+"_count", metric.Labels, "", "", strconv.FormatUint(count, 10))
+	}
+}
+
+// writePrometheusSample writes sample line like `name{label="value",extra="value"} 1`.
+// Extra label is skipped if its name is empty.
+func writePrometheusSample(buf *bytes.Buffer, name string, labels models.Labels, extraName, extraValue,
+	value string) {
+	buf.WriteString(name)
+	if len(labels) > 0 || extraName != "" {
+		buf.WriteByte('{')
+		for i, label := range slices.Sorted(maps.Keys(labels)) {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(label + `="` + prometheusLabelReplacer.Replace(labels[label]) + `"`)
+		}
+		if extraName != "" {
+			if len(labels) > 0 {
+				buf.WriteByte(',')
+			}
+			buf.WriteString(extraName + `="` + extraValue + `"`)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteString(" " + value + "\n")
+}
+
+// prometheusHelpReplacer escapes text of HELP line.
+var prometheusHelpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+// prometheusLabelReplacer escapes label value.
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+// prometheusFloat returns float in Prometheus format, e.g. +Inf or 0.5.
+func prometheusFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// PrometheusName returns valid Prometheus metric name. Invalid characters are replaced by underscore,
+// name starting with digit is prefixed by underscore.
+func PrometheusName(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	if sb.Len() == 0 {
+		return "_"
+	}
+	return sb.String()
+}
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/golang/snappy"
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
+	"github.com/sejo412/ya-metrics/proto/prompb"
+	"google.golang.org/protobuf/proto"
+)
+
+// UpdateMetricsFromRemoteWrite updates metrics from snappy compressed Prometheus remote write request.
+func UpdateMetricsFromRemoteWrite(ctx context.Context, st config.Storage, req []byte) error {
+	data, err := snappy.Decode(nil, req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", models.ErrInvalidRemoteWrite, err)
+	}
+	var writeRequest prompb.WriteRequest
+	if err = proto.Unmarshal(data, &writeRequest); err != nil {
+		return fmt.Errorf("%w: %w", models.ErrInvalidRemoteWrite, err)
+	}
+	metrics, err := models.ConvertWriteRequestToV1(&writeRequest)
+	if err != nil {
+		return err
+	}
+	if len(metrics) == 0 {
+		return nil
+	}
+	return st.MassUpsert(ctx, metrics)
+}
@@ -19,15 +19,24 @@ import (
 	"google.golang.org/grpc/reflection"
 )
 
+const (
+	historyPruneInterval = time.Minute // how often remove expired metrics history
+	maxExpireInterval    = time.Minute // how often remove stale metrics at most
+)
+
 type Server struct {
-	HTTPServer *Router
-	GRPCServer *GRPCServer
+	HTTPServer     *Router
+	GRPCServer     *GRPCServer
+	StatsDServer   *StatsDServer
+	GraphiteServer *GraphiteServer
 }
 
 func NewServerWithOptions(opts *config.Options) *Server {
 	return &Server{
-		HTTPServer: NewRouterWithOptions(opts),
-		GRPCServer: NewGRPCServerWithOptions(opts),
+		HTTPServer:     NewRouterWithOptions(opts),
+		GRPCServer:     NewGRPCServerWithOptions(opts),
+		StatsDServer:   NewStatsDServerWithOptions(opts),
+		GraphiteServer: NewGraphiteServerWithOptions(opts),
 	}
 }
 
@@ -61,9 +70,9 @@ func StartServer(ctx context.Context, opts *config.Options,
 
 	// we don't want check error twice (already checked in main)
 	dsn, _ := storage.ParseDSN(cfg.DatabaseDSN)
-	// start flushing metrics on timer
+	// start flushing metrics on timer (only memory storage keeps its state in store file)
 	wg := sync.WaitGroup{}
-	if cfg.StoreInterval > 0 && dsn.Scheme == "memory" && cfg.StoreFile != "" {
+	if cfg.StoreInterval > 0 && storage.UsesStoreFile(dsn.Scheme) && cfg.StoreFile != "" {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -71,12 +80,33 @@ func StartServer(ctx context.Context, opts *config.Options,
 		}()
 	}
 
+	// start pruning metrics history on timer
+	if cfg.HistoryRetention != nil && *cfg.HistoryRetention > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			PruningHistory(ctx, opts.Storage, time.Duration(*cfg.HistoryRetention)*time.Second, historyPruneInterval)
+		}()
+	}
+
+	// start removing stale metrics on timer
+	if opts.TTLPolicy.Enabled() && !cfg.KeepStaleMetrics {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ExpiringMetrics(ctx, opts.Storage, opts.TTLPolicy, min(opts.TTLPolicy.MinTTL(), maxExpireInterval))
+		}()
+	}
+
 	// convert trusted subnets to human readable format
 	hrTrustedSubnets := make([]string, 0, len(opts.TrustedSubnets))
 	for _, subnet := range opts.TrustedSubnets {
 		hrTrustedSubnets = append(hrTrustedSubnets, subnet.String())
 	}
 
+	// storage without versioned schema reports zero version
+	schemaVersion, _ := GetSchemaVersion(ctx, opts.Storage)
+
 	setKey := false
 	if cfg.Key != "" {
 		setKey = true
@@ -85,10 +115,22 @@ func StartServer(ctx context.Context, opts *config.Options,
 		"version", config.GetVersion(),
 		"address", cfg.Address,
 		"address_grpc", cfg.AddressGRPC,
+		"address_statsd", cfg.AddressStatsD,
+		"statsdFlushInterval", cfg.StatsDFlushInterval,
+		"address_graphite", cfg.AddressGraphite,
+		"graphiteRules", cfg.GraphiteRules,
+		"influxCounters", cfg.InfluxCounters,
 		"storeInterval", cfg.StoreInterval,
 		"fileStoragePath", cfg.StoreFile,
 		"restore", cfg.Restore,
+		"importFile", cfg.ImportFile,
+		"historyRetention", cfg.HistoryRetention,
+		"metricTTL", cfg.MetricTTL,
+		"metricTTLRules", cfg.MetricTTLRules,
+		"keepStaleMetrics", cfg.KeepStaleMetrics,
+		"schemaVersion", schemaVersion,
 		"setKey", setKey,
+		"tenants", len(opts.Tenants),
 		"trustedSubnets", hrTrustedSubnets)
 	if len(warnings) > 0 {
 		log.Warnln("warnings: ", warnings)
@@ -112,6 +154,24 @@ func StartServer(ctx context.Context, opts *config.Options,
 		return err
 	}
 
+	// StatsD and Graphite listeners are optional
+	var statsdConn net.PacketConn
+	if cfg.AddressStatsD != "" {
+		statsdConn, err = net.ListenPacket("udp", cfg.AddressStatsD)
+		if err != nil {
+			log.Errorw("failed to listen", "address", cfg.AddressStatsD, "error", err)
+			return err
+		}
+	}
+	var graphiteListener net.Listener
+	if cfg.AddressGraphite != "" {
+		graphiteListener, err = net.Listen("tcp", cfg.AddressGraphite)
+		if err != nil {
+			log.Errorw("failed to listen", "address", cfg.AddressGraphite, "error", err)
+			return err
+		}
+	}
+
 	idleConnsClosed := make(chan struct{})
 	sigs := make(chan os.Signal, 1)
 	signal.Notify(sigs, config.GracefulSignals...)
@@ -134,6 +194,18 @@ func StartServer(ctx context.Context, opts *config.Options,
 	errGroup.Go(func() error {
 		return httpServer.ListenAndServe()
 	})
+	if statsdConn != nil {
+		// stops on cancel of ctx and writes aggregated metrics before storage is closed
+		errGroup.Go(func() error {
+			return server.StatsDServer.Serve(ctx, statsdConn)
+		})
+	}
+	if graphiteListener != nil {
+		// stops on cancel of ctx and writes metrics of closed connections before storage is closed
+		errGroup.Go(func() error {
+			return server.GraphiteServer.Serve(ctx, graphiteListener)
+		})
+	}
 	if err = errGroup.Wait(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("error starting server: %w", err)
 	}
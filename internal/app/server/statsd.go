@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
+)
+
+// Settings of StatsD server.
+const (
+	maxStatsDPacket    = 65535 // max size of UDP packet with StatsD metrics
+	statsdGaugeRetries = 3     // how many times retry relative change of gauge updated concurrently
+)
+
+// StatsDServer receives StatsD metrics over UDP and writes them to storage aggregated over flush interval.
+//
+// Counters are written as deltas scaled by sample rate, gauges as last value, timers and histograms as summaries.
+// Gauge changed by signed values only is changed relative to stored value.
+type StatsDServer struct {
+	opts     config.Options
+	counters map[string]*statsdCounter
+	gauges   map[string]*statsdGauge
+	timers   map[string]*models.Metric
+	interval time.Duration
+	mu       sync.Mutex
+}
+
+// statsdCounter is counter aggregated over flush interval.
+type statsdCounter struct {
+	metric models.Metric
+	value  float64
+}
+
+// statsdGauge is gauge aggregated over flush interval.
+type statsdGauge struct {
+	metric models.Metric
+	set    bool // value is set, otherwise value is delta of stored value
+}
+
+func NewStatsDServerWithOptions(opts *config.Options) *StatsDServer {
+	interval := time.Duration(opts.Config.StatsDFlushInterval) * time.Second
+	if interval <= 0 {
+		interval = time.Duration(config.DefaultStatsDFlushInterval) * time.Second
+	}
+	s := &StatsDServer{opts: *opts, interval: interval}
+	s.reset()
+	return s
+}
+
+// Serve receives metrics from conn until ctx is done or conn fails. Aggregated metrics are written on every
+// flush interval and once more before return.
+func (s *StatsDServer) Serve(ctx context.Context, conn net.PacketConn) error {
+	done := make(chan struct{})
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				// unblocks ReadFrom
+				_ = conn.Close()
+				return
+			case <-done:
+				return
+			case <-ticker.C:
+				s.Flush(ctx)
+			}
+		}
+	}()
+	err := s.receive(conn)
+	close(done)
+	wg.Wait()
+	s.Flush(context.WithoutCancel(ctx))
+	if ctx.Err() != nil && errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
+
+// receive handles packets from conn until read fails.
+func (s *StatsDServer) receive(conn net.PacketConn) error {
+	buf := make([]byte, maxStatsDPacket)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		s.handlePacket(buf[:n], addr)
+	}
+}
+
+// handlePacket aggregates metrics of packet. Packets from untrusted addresses and invalid lines are skipped.
+func (s *StatsDServer) handlePacket(data []byte, addr net.Addr) {
+	log := s.opts.Logger.Logger
+	if len(s.opts.TrustedSubnets) > 0 {
+		host, _, _ := net.SplitHostPort(addr.String())
+		if !isNetsContainsIP(host, s.opts.TrustedSubnets) {
+			log.Debugw("skip statsd packet from untrusted address", "address", addr.String())
+			return
+		}
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sample, err := models.ParseStatsDLine(line)
+		if err != nil {
+			log.Debugw("skip statsd line", "address", addr.String(), "error", err)
+			continue
+		}
+		s.add(sample)
+	}
+}
+
+// add aggregates sample.
+func (s *StatsDServer) add(sample models.StatsDSample) {
+	key := sample.Type + " " + sample.Name + sample.Labels.String()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch sample.Type {
+	case models.StatsDCounter:
+		c, ok := s.counters[key]
+		if !ok {
+			c = &statsdCounter{metric: models.Metric{Kind: models.MetricKindCounter, Name: sample.Name,
+				Labels: sample.Labels}}
+			s.counters[key] = c
+		}
+		c.value += sample.Value / sample.Rate
+	case models.StatsDGauge:
+		g, ok := s.gauges[key]
+		if !ok {
+			g = &statsdGauge{metric: models.Metric{Kind: models.MetricKindGauge, Name: sample.Name,
+				Labels: sample.Labels}}
+			s.gauges[key] = g
+		}
+		if sample.Relative {
+			g.metric.Value += sample.Value
+		} else {
+			g.metric.Value = sample.Value
+			g.set = true
+		}
+	default:
+		// sample rate doesn't change distribution of timer values
+		m, ok := s.timers[key]
+		if !ok {
+			m = &models.Metric{Kind: models.MetricKindSummary, Name: sample.Name, Labels: sample.Labels,
+				Summary: models.NewSummary(models.DefaultSummaryAccuracy)}
+			s.timers[key] = m
+		}
+		m.Summary.Observe(sample.Value)
+	}
+}
+
+// Flush writes metrics aggregated since previous flush to storage.
+func (s *StatsDServer) Flush(ctx context.Context) {
+	s.mu.Lock()
+	counters, gauges, timers := s.counters, s.gauges, s.timers
+	s.reset()
+	s.mu.Unlock()
+	metrics := make([]models.Metric, 0, len(counters)+len(gauges)+len(timers))
+	for _, c := range counters {
+		c.metric.Delta = int64(math.Round(c.value))
+		metrics = append(metrics, c.metric)
+	}
+	for _, g := range gauges {
+		if !g.set {
+			s.changeGauge(ctx, g.metric)
+			continue
+		}
+		metrics = append(metrics, g.metric)
+	}
+	for _, m := range timers {
+		metrics = append(metrics, *m)
+	}
+	if len(metrics) == 0 {
+		return
+	}
+	if err := s.opts.Storage.MassUpsert(ctx, metrics); err != nil {
+		s.opts.Logger.Logger.Errorw("write statsd metrics", "count", len(metrics), "error", err)
+	}
+}
+
+// changeGauge adds value of delta to stored gauge. Stored gauge is updated only if it isn't changed
+// since it was read, so concurrent updates of gauge by other clients are not lost.
+func (s *StatsDServer) changeGauge(ctx context.Context, delta models.Metric) {
+	log := s.opts.Logger.Logger
+	var err error
+	for i := 0; i < statsdGaugeRetries; i++ {
+		if err = s.tryChangeGauge(ctx, delta); !errors.Is(err, models.ErrConflict) {
+			break
+		}
+	}
+	if err != nil {
+		log.Errorw("change statsd gauge", "name", delta.Name, "labels", delta.Labels, "error", err)
+	}
+}
+
+// tryChangeGauge adds value of delta to stored gauge if it isn't changed since it was read.
+// Returns models.ErrConflict if gauge is changed concurrently.
+func (s *StatsDServer) tryChangeGauge(ctx context.Context, delta models.Metric) error {
+	st := s.opts.Storage
+	// version is read before value, so value changed after version was read fails condition
+	var version int64
+	meta, err := st.GetMetadata(ctx, delta)
+	switch {
+	case errors.Is(err, models.ErrHTTPNotFound):
+		// gauge must not exist, stored value is zero
+	case err != nil:
+		return err
+	default:
+		version = meta.Version
+		stored, err := GetMetric(ctx, st, models.MetricKindGauge, delta.Name, delta.Labels)
+		switch {
+		case errors.Is(err, models.ErrHTTPNotFound):
+			// gauge is deleted concurrently
+			return models.ErrConflict
+		case err != nil:
+			return err
+		}
+		delta.Value += stored.Value
+	}
+	_, err = st.UpsertIf(ctx, delta, models.Condition{Version: &version})
+	return err
+}
+
+// reset starts new flush interval.
+func (s *StatsDServer) reset() {
+	s.counters = make(map[string]*statsdCounter)
+	s.gauges = make(map[string]*statsdGauge)
+	s.timers = make(map[string]*models.Metric)
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
+	"github.com/sejo412/ya-metrics/internal/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsDServer_Flush(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStorage()
+	err := st.Upsert(ctx, models.Metric{Kind: models.MetricKindGauge, Name: "queue", Value: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStatsDServerWithOptions(&config.Options{Storage: st, Logger: *lm})
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8125}
+	s.handlePacket([]byte("requests:1|c|@0.5\nrequests:1|c\nqueue:+5|g\nqueue:-2|g\n"+
+		"temp:20|g\ntemp:21.5|g|#room:a\nlatency:100|ms\nlatency:300|ms\ninvalid\nusers:alice|s"), addr)
+	s.Flush(ctx)
+
+	requests, err := st.Get(ctx, models.MetricKindCounter, "requests")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), requests.Delta)
+	queue, err := st.Get(ctx, models.MetricKindGauge, "queue")
+	assert.NoError(t, err)
+	assert.Equal(t, 13.0, queue.Value)
+	temp, err := st.Get(ctx, models.MetricKindGauge, "temp")
+	assert.NoError(t, err)
+	assert.Equal(t, 20.0, temp.Value)
+	room, err := GetMetric(ctx, st, models.MetricKindGauge, "temp", models.Labels{"room": "a"})
+	assert.NoError(t, err)
+	assert.Equal(t, 21.5, room.Value)
+	latency, err := st.Get(ctx, models.MetricKindSummary, "latency")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), latency.Summary.Count())
+	assert.Equal(t, 400.0, latency.Summary.Sum)
+
+	// next interval adds to counter and sets gauge
+	s.handlePacket([]byte("requests:2|c\nqueue:1|g"), addr)
+	s.Flush(ctx)
+	requests, err = st.Get(ctx, models.MetricKindCounter, "requests")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), requests.Delta)
+	queue, err = st.Get(ctx, models.MetricKindGauge, "queue")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, queue.Value)
+}
+
+// racingStorage is storage where gauge is updated by other client after metadata of it is read.
+type racingStorage struct {
+	config.Storage
+	race  models.Metric
+	err   error
+	raced bool
+}
+
+func (r *racingStorage) GetMetadata(ctx context.Context, metric models.Metric) (models.Metadata, error) {
+	if r.err != nil {
+		return models.Metadata{}, r.err
+	}
+	meta, err := r.Storage.GetMetadata(ctx, metric)
+	if !r.raced {
+		r.raced = true
+		_ = r.Storage.Upsert(ctx, r.race)
+	}
+	return meta, err
+}
+
+func TestStatsDServer_FlushRelativeGauge(t *testing.T) {
+	ctx := context.Background()
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8125}
+	tests := []struct {
+		name string
+		race models.Metric
+		err  error
+		want float64
+	}{
+		{
+			name: "concurrent update is not lost",
+			race: models.Metric{Kind: models.MetricKindGauge, Name: "queue", Value: 100},
+			want: 105,
+		},
+		{
+			name: "storage error keeps stored gauge",
+			err:  errors.New("connection reset"),
+			want: 10,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := storage.NewMemoryStorage()
+			err := st.Upsert(ctx, models.Metric{Kind: models.MetricKindGauge, Name: "queue", Value: 10})
+			if err != nil {
+				t.Fatal(err)
+			}
+			racing := &racingStorage{Storage: st, race: tt.race, err: tt.err, raced: tt.race.Name == ""}
+			s := NewStatsDServerWithOptions(&config.Options{Storage: racing, Logger: *lm})
+			s.handlePacket([]byte("queue:+5|g"), addr)
+			s.Flush(ctx)
+			queue, err := st.Get(ctx, models.MetricKindGauge, "queue")
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, queue.Value)
+		})
+	}
+}
+
+func TestStatsDServer_handlePacketUntrusted(t *testing.T) {
+	ctx := context.Background()
+	st := storage.NewMemoryStorage()
+	_, trusted, err := net.ParseCIDR("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStatsDServerWithOptions(&config.Options{Storage: st, Logger: *lm,
+		TrustedSubnets: []net.IPNet{*trusted}})
+	s.handlePacket([]byte("untrusted:1|c"), &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1), Port: 8125})
+	s.handlePacket([]byte("trusted:1|c"), &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 8125})
+	s.Flush(ctx)
+	_, err = st.Get(ctx, models.MetricKindCounter, "untrusted")
+	assert.Error(t, err)
+	_, err = st.Get(ctx, models.MetricKindCounter, "trusted")
+	assert.NoError(t, err)
+}
+
+func TestStatsDServer_Serve(t *testing.T) {
+	st := storage.NewMemoryStorage()
+	s := NewStatsDServerWithOptions(&config.Options{Storage: st, Logger: *lm})
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	served := make(chan error)
+	go func() {
+		served <- s.Serve(ctx, conn)
+	}()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+	_, err = client.Write([]byte("requests:1|c"))
+	assert.NoError(t, err)
+	// packet is received asynchronously
+	assert.Eventually(t, func() bool {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return len(s.counters) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	// aggregated metrics are written on shutdown
+	cancel()
+	select {
+	case err = <-served:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("server not stopped")
+	}
+	requests, err := st.Get(context.Background(), models.MetricKindCounter, "requests")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), requests.Delta)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/caarlos0/env/v6"
 	"github.com/sejo412/ya-metrics/internal/logger"
+	"github.com/sejo412/ya-metrics/internal/models"
 	"github.com/spf13/pflag"
 )
 
@@ -21,6 +22,7 @@ const (
 	ContextTimeout               = 1 * time.Second  // timeout for network communications
 	DefaultRateLimit      int    = 2
 	DefaultMode                  = HTTPModeName
+	DefaultGCPauseBuckets string = "0.00001,0.0001,0.001,0.01,0.1" // default bounds of GC pause buckets in seconds
 )
 
 // AgentConfig contains configuration for agent application.
@@ -40,6 +42,10 @@ type AgentConfig struct {
 	// PollInterval - how often poll runtime metrics.
 	PollInterval int `env:"POLL_INTERVAL" json:"poll_interval,omitempty"`
 	RateLimit    int `env:"RATE_LIMIT" json:"rate_limit,omitempty"`
+	// GCPauseBuckets - comma separated bounds of GC pause duration buckets in seconds.
+	GCPauseBuckets string `env:"GC_PAUSE_BUCKETS" json:"gc_pause_buckets,omitempty"`
+	// GCPauseBounds - don't use it from code. It generates from GCPauseBuckets.
+	GCPauseBounds []float64
 	// RealReportInterval - don't use it from code. It generates from ReportInterval.
 	RealReportInterval time.Duration
 	// RealPollInterval - don't use it from code. It generates from PollInterval.
@@ -73,6 +79,8 @@ func (a *AgentConfig) Load() error {
 		fmt.Sprintf("rate limit in seconds (default %d)", DefaultRateLimit))
 	pflag.StringVarP(&cfg.Mode, "mode", "m", "",
 		fmt.Sprintf("mode to use (default %q)", DefaultMode))
+	pflag.StringVar(&cfg.GCPauseBuckets, "gc-pause-buckets", "",
+		fmt.Sprintf("comma separated bounds of GC pause buckets in seconds (default %q)", DefaultGCPauseBuckets))
 	pflag.Parse()
 	if *cfgFile != "" {
 		// rewrite flags from config (needs only for parsing config file)
@@ -113,6 +121,13 @@ func (a *AgentConfig) Load() error {
 	if cfg.Mode == "" {
 		cfg.Mode = DefaultMode
 	}
+	if cfg.GCPauseBuckets == "" {
+		cfg.GCPauseBuckets = DefaultGCPauseBuckets
+	}
+	gcPauseBounds, err := models.ParseBounds(cfg.GCPauseBuckets)
+	if err != nil {
+		return fmt.Errorf("invalid gc pause buckets: %w", err)
+	}
 	// Check agent Mode.
 	if !ModeFromString(cfg.Mode).IsValid() {
 		return fmt.Errorf("invalid mode %q", cfg.Mode)
@@ -128,5 +143,7 @@ func (a *AgentConfig) Load() error {
 	a.RealPollInterval = time.Duration(cfg.PollInterval) * time.Second
 	a.PathStyle = cfg.PathStyle
 	a.Mode = cfg.Mode
+	a.GCPauseBuckets = cfg.GCPauseBuckets
+	a.GCPauseBounds = gcPauseBounds
 	return nil
 }
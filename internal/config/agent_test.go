@@ -42,6 +42,7 @@ func TestAgentConfig_Load(t *testing.T) {
 				PathStyle:          DefaultPathStyle,
 				RealReportInterval: time.Duration(DefaultReportInterval) * time.Second,
 				RealPollInterval:   time.Duration(DefaultPollInterval) * time.Second,
+				GCPauseBounds:      []float64{0.00001, 0.0001, 0.001, 0.01, 0.1},
 			},
 		},
 		{
@@ -110,6 +111,28 @@ func TestAgentConfig_Load(t *testing.T) {
 				RealPollInterval:   4 * time.Second,
 			},
 		},
+		{
+			name: "GC pause buckets from flag",
+			args: []string{"--gc-pause-buckets=0.001, 0.1,1"},
+			want: AgentConfig{
+				Address:            DefaultServerAddress,
+				ReportInterval:     DefaultReportInterval,
+				PollInterval:       DefaultPollInterval,
+				Key:                DefaultSecretKey,
+				CryptoKey:          DefaultCryptoKey,
+				RateLimit:          DefaultRateLimit,
+				PathStyle:          DefaultPathStyle,
+				RealReportInterval: time.Duration(DefaultReportInterval) * time.Second,
+				RealPollInterval:   time.Duration(DefaultPollInterval) * time.Second,
+				GCPauseBounds:      []float64{0.001, 0.1, 1},
+			},
+		},
+		{
+			name:        "Invalid GC pause buckets",
+			env:         map[string]string{"GC_PAUSE_BUCKETS": "0.1,0.01"},
+			wantErr:     true,
+			errContains: "invalid gc pause buckets",
+		},
 		{
 			name: "env overrides flags which overrides config",
 			args: []string{
@@ -213,6 +236,9 @@ func TestAgentConfig_Load(t *testing.T) {
 			require.Equal(t, tt.want.PathStyle, cfg.PathStyle)
 			require.Equal(t, tt.want.RealReportInterval, cfg.RealReportInterval)
 			require.Equal(t, tt.want.RealPollInterval, cfg.RealPollInterval)
+			if tt.want.GCPauseBounds != nil {
+				require.Equal(t, tt.want.GCPauseBounds, cfg.GCPauseBounds)
+			}
 		})
 	}
 }
@@ -6,8 +6,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
 	"net"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 	"github.com/sejo412/ya-metrics/internal/logger"
@@ -18,13 +22,20 @@ import (
 
 // Default settings for server.
 const (
-	DefaultAddress       string = ":8080"             // listen address
-	DefaultAddressGRPC   string = ":3200"             // listen grpc address
-	DefaultStoreInterval int    = 300                 // how often flush metrics from memory to disk
-	DefaultStoreFile     string = "/tmp/metrics.json" // file for saved metrics
-	DefaultRestore       bool   = true                // restore metrics from file at startup
-	DefaultDatabaseDSN   string = ""                  // default dsn string
-	DefaultTrustedSubnet string = ""                  // default trusted CIDR
+	DefaultAddress          string = ":8080"             // listen address
+	DefaultAddressGRPC      string = ":3200"             // listen grpc address
+	DefaultStoreInterval    int    = 300                 // how often flush metrics from memory to disk
+	DefaultStoreFile        string = "/tmp/metrics.json" // file for saved metrics
+	DefaultRestore          bool   = true                // restore metrics from file at startup
+	DefaultDatabaseDSN      string = ""                  // default dsn string
+	DefaultTrustedSubnet    string = ""                  // default trusted CIDR
+	DefaultHistoryRetention int    = 86400               // how long keep metrics history in seconds
+	// DefaultStatsDFlushInterval - how often write aggregated StatsD metrics to storage in seconds.
+	DefaultStatsDFlushInterval int = 10
+	// DefaultDatabaseConnectRetries - how many times retry connect to database.
+	DefaultDatabaseConnectRetries int = models.RetryMaxRetries
+	// DefaultDatabaseConnectRetryDelay - delay before first retry of connect to database in seconds.
+	DefaultDatabaseConnectRetryDelay int = int(models.RetryInitDelay / time.Second)
 )
 
 // ServerConfig contains configuration for server application.
@@ -35,10 +46,19 @@ type ServerConfig struct {
 	Address string `env:"ADDRESS" json:"address,omitempty"`
 	// AddressGRPC - listen grpc address.
 	AddressGRPC string `env:"ADDRESS_GRPC" json:"address_grpc,omitempty"`
+	// AddressStatsD - listen UDP address for StatsD metrics, empty for disabled listener.
+	// StatsD metrics aren't signed, they are accepted from trusted subnets only if subnets are specified.
+	AddressStatsD string `env:"ADDRESS_STATSD" json:"address_statsd,omitempty"`
+	// AddressGraphite - listen TCP address for Graphite plaintext metrics, empty for disabled listener.
+	// Graphite metrics aren't signed, they are accepted from trusted subnets only if subnets are specified.
+	AddressGraphite string `env:"ADDRESS_GRAPHITE" json:"address_graphite,omitempty"`
 	// CryptoKey - path to private key
 	CryptoKey string `env:"CRYPTO_KEY" json:"crypto_key,omitempty"`
 	// StoreFile - file for saved metrics.
 	StoreFile string `env:"STORE_FILE" json:"store_file,omitempty"`
+	// ImportFile - file with saved metrics for one-off import at startup, counters are added to stored ones.
+	// Store file is restored automatically only into memory storage, import is used for another storages.
+	ImportFile string `env:"IMPORT_FILE" json:"import_file,omitempty"`
 	// TrustedSubnet - trusted CIDR (comma separated) for incoming connections.
 	TrustedSubnet string `env:"TRUSTED_SUBNET" json:"trusted_subnet,omitempty"`
 	// DatabaseDSN - dsn string.
@@ -47,6 +67,37 @@ type ServerConfig struct {
 	Key string `env:"KEY" json:"key,omitempty"`
 	// StoreInterval - how often flush metrics from memory to disk.
 	StoreInterval int `env:"STORE_INTERVAL" json:"store_interval,omitempty"`
+	// HistoryRetention - how long keep metric samples history in seconds, 0 for disabled pruning.
+	HistoryRetention *int `env:"HISTORY_RETENTION" json:"history_retention,omitempty"`
+	// DatabaseMaxConns - max connections in database pool, 0 for driver default.
+	DatabaseMaxConns int `env:"DATABASE_MAX_CONNS" json:"database_max_conns,omitempty"`
+	// DatabaseMinConns - min idle connections in database pool, 0 for driver default.
+	DatabaseMinConns int `env:"DATABASE_MIN_CONNS" json:"database_min_conns,omitempty"`
+	// DatabaseMaxConnLifetime - how long database connection may be reused in seconds, 0 for driver default.
+	DatabaseMaxConnLifetime int `env:"DATABASE_MAX_CONN_LIFETIME" json:"database_max_conn_lifetime,omitempty"`
+	// DatabaseStatementTimeout - database statement timeout in seconds, 0 for no timeout.
+	DatabaseStatementTimeout int `env:"DATABASE_STATEMENT_TIMEOUT" json:"database_statement_timeout,omitempty"`
+	// DatabaseConnectRetries - how many times retry connect to database.
+	DatabaseConnectRetries int `env:"DATABASE_CONNECT_RETRIES" json:"database_connect_retries,omitempty"`
+	// DatabaseConnectRetryDelay - delay before first retry of connect to database in seconds.
+	DatabaseConnectRetryDelay int `env:"DATABASE_CONNECT_RETRY_DELAY" json:"database_connect_retry_delay,omitempty"`
+	// MetricTTL - how long metric lives without updates in seconds, 0 for forever.
+	MetricTTL int `env:"METRIC_TTL" json:"metric_ttl,omitempty"`
+	// StatsDFlushInterval - how often write aggregated StatsD metrics to storage in seconds.
+	StatsDFlushInterval int `env:"STATSD_FLUSH_INTERVAL" json:"statsd_flush_interval,omitempty"`
+	// MetricTTLRules - TTL in seconds for metric name patterns like "agent1_*=60", first matching rule wins.
+	MetricTTLRules []string `env:"METRIC_TTL_RULES" json:"metric_ttl_rules,omitempty"`
+	// KeepStaleMetrics - only hide stale metrics from index page instead of removing them.
+	KeepStaleMetrics bool `env:"KEEP_STALE_METRICS" json:"keep_stale_metrics,omitempty"`
+	// TenantKeys - keys for sign data of tenants like "team1=secret1", client belongs to tenant whose key signs
+	// its requests. Without tenant keys tenant is taken from X-Tenant header or metadata.
+	TenantKeys []string `env:"TENANT_KEYS" json:"tenant_keys,omitempty"`
+	// GraphiteRules - mapping of Graphite paths like "servers.*.cpu=cpu,host=$2", first matching rule wins.
+	// Metric name and label values may refer to N-th node of path by $N.
+	GraphiteRules []string `env:"GRAPHITE_RULES" json:"graphite_rules,omitempty"`
+	// InfluxCounters - metric name patterns like "requests_*" whose InfluxDB integer fields are counter deltas,
+	// integer fields of other metrics are gauges.
+	InfluxCounters []string `env:"INFLUX_COUNTERS" json:"influx_counters,omitempty"`
 }
 
 // Storage interface for used backend.
@@ -61,10 +112,33 @@ type Storage interface {
 	Upsert(context.Context, models.Metric) error
 	// MassUpsert inserts or updates slice of metrics.
 	MassUpsert(context.Context, []models.Metric) error
-	// Get returns metric by kind and name.
+	// UpsertIf updates gauge if condition holds for stored one and returns new version of gauge.
+	// Returns models.ErrConflict if condition fails.
+	UpsertIf(ctx context.Context, metric models.Metric, cond models.Condition) (int64, error)
+	// Get returns metric without labels by kind and name.
 	Get(ctx context.Context, kind string, name string) (models.Metric, error)
-	// GetAll returns all metrics.
-	GetAll(ctx context.Context) ([]models.Metric, error)
+	// GetMetadata returns metadata of metric with kind, name and labels of metric.
+	GetMetadata(ctx context.Context, metric models.Metric) (models.Metadata, error)
+	// All returns iterator over all metrics in unspecified order, iteration stops on error.
+	All(ctx context.Context) iter.Seq2[models.Metric, error]
+	// AllFresh returns iterator over metrics which are not stale at now according to policy.
+	AllFresh(ctx context.Context, policy storage.TTLPolicy, now time.Time) iter.Seq2[models.Metric, error]
+	// Select returns metrics of all label sets by kind and name matching all matchers.
+	Select(ctx context.Context, kind string, name string, matchers ...models.LabelMatcher) ([]models.Metric, error)
+	// List returns page of metrics matching query sorted by name, kind and labels.
+	List(ctx context.Context, query models.ListQuery) (models.ListPage, error)
+	// GetRange returns samples of metric without labels stored between from and to.
+	GetRange(ctx context.Context, kind string, name string, from, to time.Time) ([]models.Sample, error)
+	// PruneHistory removes metric samples stored before specified time.
+	PruneHistory(ctx context.Context, before time.Time) error
+	// Delete removes metric of all label sets by kind and name.
+	Delete(ctx context.Context, kind string, name string) error
+	// DeleteByPrefix removes metrics with name prefix and returns count of removed metrics.
+	DeleteByPrefix(ctx context.Context, prefix string) (int, error)
+	// DeleteStale removes metrics which are stale at now according to policy and returns count of removed metrics.
+	DeleteStale(ctx context.Context, policy storage.TTLPolicy, now time.Time) (int, error)
+	// ResetCounter sets counter value of all label sets to zero.
+	ResetCounter(ctx context.Context, name string) error
 	// Flush saves metrics to file.
 	Flush(ctx context.Context, dst io.Writer) error
 	// Load resores metrics from file.
@@ -73,6 +147,12 @@ type Storage interface {
 	Init(ctx context.Context) error
 }
 
+// SchemaVersioner is implemented by storage with versioned schema.
+type SchemaVersioner interface {
+	// SchemaVersion returns version of applied schema.
+	SchemaVersion(ctx context.Context) (int, error)
+}
+
 // Options contains server's options for startup.
 type Options struct {
 	// Storage - used storage backend.
@@ -85,6 +165,14 @@ type Options struct {
 	Config ServerConfig
 	// TrustedSubnets - used for restrict access only from trusted networks.
 	TrustedSubnets []net.IPNet
+	// TTLPolicy - how long metrics live without updates.
+	TTLPolicy storage.TTLPolicy
+	// Tenants - tenants keyed by their keys for sign data.
+	Tenants map[string]string
+	// GraphiteMapping - how Graphite paths are mapped to metrics.
+	GraphiteMapping models.GraphiteMapping
+	// InfluxCounters - metrics whose InfluxDB integer fields are counter deltas.
+	InfluxCounters models.InfluxCounters
 }
 
 // NewServerConfig returns new *ServerConfig
@@ -110,6 +198,8 @@ func (s *ServerConfig) Load() error {
 		fmt.Sprintf("File storage path (default: %q)", DefaultStoreFile))
 	flagRestore := flagSet.BoolP("restore", "r", false,
 		fmt.Sprintf("Restore metrics (default: %t)", DefaultRestore))
+	flagImportFile := flagSet.String("import_file", "",
+		"file with saved metrics for one-off import at startup, counters are added (default: no import)")
 	flagDatabaseDSN := flagSet.StringP("database-dsn", "d", "",
 		fmt.Sprintf("Database DSN (default: %q)", DefaultDatabaseDSN))
 	flagKey := flagSet.StringP("key", "k", "",
@@ -119,6 +209,41 @@ func (s *ServerConfig) Load() error {
 	flagTrustedSubnet := flagSet.StringP("trusted_subnet", "t", "",
 		fmt.Sprintf("comma separated trusted subnets CIDR for incoming requests, example %q (default: %q)",
 			"192.168.0.0/24,127.0.0.0/8", DefaultTrustedSubnet))
+	flagHistoryRetention := flagSet.Int("history_retention", 0,
+		fmt.Sprintf("how long keep metrics history in seconds, 0 for keep forever (default: %d)",
+			DefaultHistoryRetention))
+	flagDatabaseMaxConns := flagSet.Int("database_max_conns", 0,
+		"max connections in database pool (default: driver default)")
+	flagDatabaseMinConns := flagSet.Int("database_min_conns", 0,
+		"min idle connections in database pool (default: driver default)")
+	flagDatabaseMaxConnLifetime := flagSet.Int("database_max_conn_lifetime", 0,
+		"how long database connection may be reused in seconds (default: driver default)")
+	flagDatabaseStatementTimeout := flagSet.Int("database_statement_timeout", 0,
+		"database statement timeout in seconds (default: no timeout)")
+	flagDatabaseConnectRetries := flagSet.Int("database_connect_retries", 0,
+		fmt.Sprintf("how many times retry connect to database (default: %d)", DefaultDatabaseConnectRetries))
+	flagDatabaseConnectRetryDelay := flagSet.Int("database_connect_retry_delay", 0,
+		fmt.Sprintf("delay before first retry of connect to database in seconds (default: %d)",
+			DefaultDatabaseConnectRetryDelay))
+	flagMetricTTL := flagSet.Int("metric_ttl", 0,
+		"how long metric lives without updates in seconds (default: forever)")
+	flagMetricTTLRules := flagSet.StringArray("metric_ttl_rule", nil,
+		fmt.Sprintf("TTL in seconds for metric name pattern, may be repeated, example %q", "agent1_*=60"))
+	flagKeepStaleMetrics := flagSet.Bool("keep_stale_metrics", false,
+		"only hide stale metrics from index page instead of removing them")
+	flagTenantKeys := flagSet.StringArray("tenant_key", nil,
+		fmt.Sprintf("key for sign data of tenant, may be repeated, example %q", "team1=secret1"))
+	flagAddressStatsD := flagSet.String("statsd", "",
+		"Listen UDP address for StatsD metrics (default: disabled)")
+	flagStatsDFlushInterval := flagSet.Int("statsd_flush_interval", 0,
+		fmt.Sprintf("how often write aggregated StatsD metrics in seconds (default: %d)", DefaultStatsDFlushInterval))
+	flagAddressGraphite := flagSet.String("graphite", "",
+		"Listen TCP address for Graphite plaintext metrics (default: disabled)")
+	flagGraphiteRules := flagSet.StringArray("graphite_rule", nil,
+		fmt.Sprintf("mapping of Graphite path to metric, may be repeated, example %q", "servers.*.cpu=cpu,host=$2"))
+	flagInfluxCounters := flagSet.StringArray("influx_counter", nil,
+		fmt.Sprintf("pattern of metric names whose InfluxDB integer fields are counter deltas, may be repeated, "+
+			"example %q", "requests_*"))
 
 	if err := flagSet.Parse(os.Args[1:]); err != nil {
 		return fmt.Errorf("error parse flags: %w", err)
@@ -149,6 +274,9 @@ func (s *ServerConfig) Load() error {
 	if flagSet.Changed("restore") {
 		s.Restore = flagRestore
 	}
+	if flagSet.Changed("import_file") {
+		s.ImportFile = *flagImportFile
+	}
 	if flagSet.Changed("database_dsn") {
 		s.DatabaseDSN = *flagDatabaseDSN
 	}
@@ -161,6 +289,54 @@ func (s *ServerConfig) Load() error {
 	if flagSet.Changed("trusted_subnet") {
 		s.TrustedSubnet = *flagTrustedSubnet
 	}
+	if flagSet.Changed("history_retention") {
+		s.HistoryRetention = flagHistoryRetention
+	}
+	if flagSet.Changed("database_max_conns") {
+		s.DatabaseMaxConns = *flagDatabaseMaxConns
+	}
+	if flagSet.Changed("database_min_conns") {
+		s.DatabaseMinConns = *flagDatabaseMinConns
+	}
+	if flagSet.Changed("database_max_conn_lifetime") {
+		s.DatabaseMaxConnLifetime = *flagDatabaseMaxConnLifetime
+	}
+	if flagSet.Changed("database_statement_timeout") {
+		s.DatabaseStatementTimeout = *flagDatabaseStatementTimeout
+	}
+	if flagSet.Changed("database_connect_retries") {
+		s.DatabaseConnectRetries = *flagDatabaseConnectRetries
+	}
+	if flagSet.Changed("database_connect_retry_delay") {
+		s.DatabaseConnectRetryDelay = *flagDatabaseConnectRetryDelay
+	}
+	if flagSet.Changed("metric_ttl") {
+		s.MetricTTL = *flagMetricTTL
+	}
+	if flagSet.Changed("metric_ttl_rule") {
+		s.MetricTTLRules = *flagMetricTTLRules
+	}
+	if flagSet.Changed("keep_stale_metrics") {
+		s.KeepStaleMetrics = *flagKeepStaleMetrics
+	}
+	if flagSet.Changed("tenant_key") {
+		s.TenantKeys = *flagTenantKeys
+	}
+	if flagSet.Changed("statsd") {
+		s.AddressStatsD = *flagAddressStatsD
+	}
+	if flagSet.Changed("statsd_flush_interval") {
+		s.StatsDFlushInterval = *flagStatsDFlushInterval
+	}
+	if flagSet.Changed("graphite") {
+		s.AddressGraphite = *flagAddressGraphite
+	}
+	if flagSet.Changed("graphite_rule") {
+		s.GraphiteRules = *flagGraphiteRules
+	}
+	if flagSet.Changed("influx_counter") {
+		s.InfluxCounters = *flagInfluxCounters
+	}
 
 	// rewrite flags from envs
 	err := env.Parse(s)
@@ -196,5 +372,113 @@ func (s *ServerConfig) Load() error {
 	if s.TrustedSubnet == "" {
 		s.TrustedSubnet = DefaultTrustedSubnet
 	}
+	if s.HistoryRetention == nil {
+		s.HistoryRetention = new(int)
+		*s.HistoryRetention = DefaultHistoryRetention
+	}
+	if s.DatabaseConnectRetries == 0 {
+		s.DatabaseConnectRetries = DefaultDatabaseConnectRetries
+	}
+	if s.DatabaseConnectRetryDelay == 0 {
+		s.DatabaseConnectRetryDelay = DefaultDatabaseConnectRetryDelay
+	}
+	if s.StatsDFlushInterval == 0 {
+		s.StatsDFlushInterval = DefaultStatsDFlushInterval
+	}
 	return nil
 }
+
+// DatabaseOptions returns storage options with pool settings from config.
+// Settings specified in DSN query have priority.
+func (s *ServerConfig) DatabaseOptions(opts storage.Options) storage.Options {
+	if opts.MaxConns == 0 {
+		opts.MaxConns = int32(s.DatabaseMaxConns)
+	}
+	if opts.MinConns == 0 {
+		opts.MinConns = int32(s.DatabaseMinConns)
+	}
+	if opts.MaxConnLifetime == 0 {
+		opts.MaxConnLifetime = time.Duration(s.DatabaseMaxConnLifetime) * time.Second
+	}
+	if opts.StatementTimeout == 0 {
+		opts.StatementTimeout = time.Duration(s.DatabaseStatementTimeout) * time.Second
+	}
+	if opts.ConnectRetries == 0 {
+		opts.ConnectRetries = s.DatabaseConnectRetries
+	}
+	if opts.ConnectRetryDelay == 0 {
+		opts.ConnectRetryDelay = time.Duration(s.DatabaseConnectRetryDelay) * time.Second
+	}
+	return opts
+}
+
+// TTLPolicy returns metrics TTL policy from config.
+func (s *ServerConfig) TTLPolicy() (storage.TTLPolicy, error) {
+	if s.MetricTTL < 0 {
+		return storage.TTLPolicy{}, fmt.Errorf("negative metric ttl: %d", s.MetricTTL)
+	}
+	policy := storage.TTLPolicy{TTL: time.Duration(s.MetricTTL) * time.Second}
+	for _, r := range s.MetricTTLRules {
+		pattern, ttl, ok := strings.Cut(r, "=")
+		if !ok {
+			return storage.TTLPolicy{}, fmt.Errorf("invalid metric ttl rule %q, want pattern=seconds", r)
+		}
+		seconds, err := strconv.Atoi(ttl)
+		if err != nil {
+			return storage.TTLPolicy{}, fmt.Errorf("invalid ttl of metric ttl rule %q", r)
+		}
+		rule, err := storage.NewTTLRule(pattern, time.Duration(seconds)*time.Second)
+		if err != nil {
+			return storage.TTLPolicy{}, err
+		}
+		policy.Rules = append(policy.Rules, rule)
+	}
+	return policy, nil
+}
+
+// Tenants returns tenants keyed by their keys for sign data from config.
+// Key of tenant must differ from keys of other tenants and from key of default tenant.
+func (s *ServerConfig) Tenants() (map[string]string, error) {
+	tenants := make(map[string]string, len(s.TenantKeys))
+	for _, k := range s.TenantKeys {
+		tenant, key, ok := strings.Cut(k, "=")
+		if !ok || tenant == "" || key == "" {
+			return nil, fmt.Errorf("invalid tenant key %q, want tenant=key", k)
+		}
+		if _, ok = tenants[key]; ok || key == s.Key {
+			return nil, fmt.Errorf("key of tenant %q is not unique", tenant)
+		}
+		tenants[key] = tenant
+	}
+	return tenants, nil
+}
+
+// GraphiteMapping returns mapping of Graphite paths from config.
+// Rule is "pattern=name" followed by comma separated labels like ",host=$2".
+func (s *ServerConfig) GraphiteMapping() (models.GraphiteMapping, error) {
+	var mapping models.GraphiteMapping
+	for _, r := range s.GraphiteRules {
+		pattern, target, ok := strings.Cut(r, "=")
+		if !ok {
+			return models.GraphiteMapping{}, fmt.Errorf("invalid graphite rule %q, want pattern=name", r)
+		}
+		parts := strings.Split(target, ",")
+		var labels models.Labels
+		for _, label := range parts[1:] {
+			name, value, ok := strings.Cut(label, "=")
+			if !ok {
+				return models.GraphiteMapping{}, fmt.Errorf("invalid label %q of graphite rule %q", label, r)
+			}
+			if labels == nil {
+				labels = make(models.Labels, len(parts)-1)
+			}
+			labels[name] = value
+		}
+		rule, err := models.NewGraphiteRule(pattern, parts[0], labels)
+		if err != nil {
+			return models.GraphiteMapping{}, err
+		}
+		mapping.Rules = append(mapping.Rules, rule)
+	}
+	return mapping, nil
+}
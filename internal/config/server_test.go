@@ -4,7 +4,10 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/sejo412/ya-metrics/internal/models"
+	"github.com/sejo412/ya-metrics/internal/storage"
 	"github.com/stretchr/testify/require"
 )
 
@@ -21,8 +24,36 @@ func TestServerConfig_Load(t *testing.T) {
 			name: "Default values",
 			args: []string{},
 			want: ServerConfig{
-				Address: DefaultAddress,
-				Restore: boolPtr(DefaultRestore),
+				Address:          DefaultAddress,
+				Restore:          boolPtr(DefaultRestore),
+				HistoryRetention: intPtr(DefaultHistoryRetention),
+			},
+		},
+		{
+			name: "Disable history retention via CLI flag",
+			args: []string{"--history_retention=0"},
+			want: ServerConfig{
+				Address:          DefaultAddress,
+				Restore:          boolPtr(DefaultRestore),
+				HistoryRetention: intPtr(0),
+			},
+		},
+		{
+			name: "Disable history retention via ENV",
+			env:  map[string]string{"HISTORY_RETENTION": "0"},
+			want: ServerConfig{
+				Address:          DefaultAddress,
+				Restore:          boolPtr(DefaultRestore),
+				HistoryRetention: intPtr(0),
+			},
+		},
+		{
+			name:   "Disable history retention via config file",
+			config: `{"history_retention": 0}`,
+			want: ServerConfig{
+				Address:          DefaultAddress,
+				Restore:          boolPtr(DefaultRestore),
+				HistoryRetention: intPtr(0),
 			},
 		},
 		{
@@ -131,6 +162,210 @@ func TestServerConfig_Load(t *testing.T) {
 			} else {
 				require.Nil(t, cfg.Restore)
 			}
+			if tt.want.HistoryRetention != nil {
+				require.NotNil(t, cfg.HistoryRetention)
+				require.Equal(t, *tt.want.HistoryRetention, *cfg.HistoryRetention)
+			}
+		})
+	}
+}
+
+func TestServerConfig_DatabaseOptions(t *testing.T) {
+	cfg := ServerConfig{
+		DatabaseMaxConns:          10,
+		DatabaseMinConns:          1,
+		DatabaseMaxConnLifetime:   60,
+		DatabaseStatementTimeout:  5,
+		DatabaseConnectRetries:    DefaultDatabaseConnectRetries,
+		DatabaseConnectRetryDelay: DefaultDatabaseConnectRetryDelay,
+	}
+	tests := []struct {
+		name string
+		opts storage.Options
+		want storage.Options
+	}{
+		{
+			name: "settings from config",
+			opts: storage.Options{Scheme: "postgres", Host: "localhost"},
+			want: storage.Options{
+				Scheme:            "postgres",
+				Host:              "localhost",
+				MaxConns:          10,
+				MinConns:          1,
+				MaxConnLifetime:   time.Minute,
+				StatementTimeout:  5 * time.Second,
+				ConnectRetries:    DefaultDatabaseConnectRetries,
+				ConnectRetryDelay: time.Duration(DefaultDatabaseConnectRetryDelay) * time.Second,
+			},
+		},
+		{
+			name: "DSN settings have priority",
+			opts: storage.Options{Scheme: "postgres", MaxConns: 20, StatementTimeout: time.Second, ConnectRetries: 1},
+			want: storage.Options{
+				Scheme:            "postgres",
+				MaxConns:          20,
+				MinConns:          1,
+				MaxConnLifetime:   time.Minute,
+				StatementTimeout:  time.Second,
+				ConnectRetries:    1,
+				ConnectRetryDelay: time.Duration(DefaultDatabaseConnectRetryDelay) * time.Second,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, cfg.DatabaseOptions(tt.opts))
+		})
+	}
+}
+
+func TestServerConfig_TTLPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ServerConfig
+		want    storage.TTLPolicy
+		wantErr bool
+	}{
+		{
+			name: "disabled",
+			cfg:  ServerConfig{},
+			want: storage.TTLPolicy{},
+		},
+		{
+			name: "default and rules",
+			cfg:  ServerConfig{MetricTTL: 3600, MetricTTLRules: []string{"agent1_*=60", "Poll*=0"}},
+			want: storage.TTLPolicy{
+				TTL: time.Hour,
+				Rules: []storage.TTLRule{
+					{Pattern: "agent1_*", TTL: time.Minute},
+					{Pattern: "Poll*", TTL: 0},
+				},
+			},
+		},
+		{
+			name:    "rule without ttl",
+			cfg:     ServerConfig{MetricTTLRules: []string{"agent1_*"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid pattern",
+			cfg:     ServerConfig{MetricTTLRules: []string{"agent[=60"}},
+			wantErr: true,
+		},
+		{
+			name:    "negative ttl",
+			cfg:     ServerConfig{MetricTTL: -1},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.TTLPolicy()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServerConfig_Tenants(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     ServerConfig
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name: "no tenants",
+			cfg:  ServerConfig{Key: "secret"},
+			want: map[string]string{},
+		},
+		{
+			name: "tenants keyed by keys",
+			cfg:  ServerConfig{Key: "secret", TenantKeys: []string{"team1=secret1", "team2=a=b"}},
+			want: map[string]string{"secret1": "team1", "a=b": "team2"},
+		},
+		{
+			name:    "tenant without key",
+			cfg:     ServerConfig{TenantKeys: []string{"team1="}},
+			wantErr: true,
+		},
+		{
+			name:    "key without tenant",
+			cfg:     ServerConfig{TenantKeys: []string{"secret1"}},
+			wantErr: true,
+		},
+		{
+			name:    "key of two tenants",
+			cfg:     ServerConfig{TenantKeys: []string{"team1=secret1", "team2=secret1"}},
+			wantErr: true,
+		},
+		{
+			name:    "key of default tenant",
+			cfg:     ServerConfig{Key: "secret", TenantKeys: []string{"team1=secret"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.Tenants()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestServerConfig_GraphiteMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []string
+		want    []models.GraphiteRule
+		wantErr bool
+	}{
+		{
+			name: "no rules",
+		},
+		{
+			name:  "rules with and without labels",
+			rules: []string{"servers.*.cpu=cpu,host=$2,dc=eu", "jobs.*=job_$2"},
+			want: []models.GraphiteRule{
+				{Pattern: "servers.*.cpu", Name: "cpu", Labels: models.Labels{"host": "$2", "dc": "eu"}},
+				{Pattern: "jobs.*", Name: "job_$2"},
+			},
+		},
+		{
+			name:    "rule without name",
+			rules:   []string{"servers.*.cpu"},
+			wantErr: true,
+		},
+		{
+			name:    "label without value",
+			rules:   []string{"servers.*.cpu=cpu,host"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid rule",
+			rules:   []string{"servers.*.cpu=cpu_$4"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ServerConfig{GraphiteRules: tt.rules}
+			got, err := cfg.GraphiteMapping()
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got.Rules)
 		})
 	}
 }
@@ -138,3 +373,7 @@ func TestServerConfig_Load(t *testing.T) {
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,49 @@
+package models
+
+import "fmt"
+
+// Modes of conditional update.
+const (
+	UpdateModeSet string = "set" // set value unconditionally
+	UpdateModeMax string = "max" // set value if it is greater than stored one
+	UpdateModeMin string = "min" // set value if it is less than stored one
+)
+
+// Condition describes when gauge is updated by conditional update. Both version and mode must hold.
+type Condition struct {
+	// Version - expected version of stored gauge, 0 if gauge must not exist, nil for any version
+	Version *int64
+	// Mode - set, max or min, empty means set
+	Mode string
+}
+
+// Validate returns error if mode is unknown or version is negative.
+func (c Condition) Validate() error {
+	switch c.Mode {
+	case "", UpdateModeSet, UpdateModeMax, UpdateModeMin:
+	default:
+		return fmt.Errorf("%w: mode '%s'", ErrInvalidCondition, c.Mode)
+	}
+	if c.Version != nil && *c.Version < 0 {
+		return fmt.Errorf("%w: version %d", ErrInvalidCondition, *c.Version)
+	}
+	return nil
+}
+
+// Check returns ErrConflict if update to value doesn't satisfy condition for stored gauge with version,
+// version is 0 if gauge doesn't exist. Gauge which doesn't exist satisfies any mode.
+func (c Condition) Check(version int64, stored, value float64) error {
+	if c.Version != nil && *c.Version != version {
+		return fmt.Errorf("%w: version is %d, not %d", ErrConflict, version, *c.Version)
+	}
+	if version == 0 {
+		return nil
+	}
+	switch {
+	case c.Mode == UpdateModeMax && !(value > stored):
+		return fmt.Errorf("%w: %v is not greater than %v", ErrConflict, value, stored)
+	case c.Mode == UpdateModeMin && !(value < stored):
+		return fmt.Errorf("%w: %v is not less than %v", ErrConflict, value, stored)
+	}
+	return nil
+}
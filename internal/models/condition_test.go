@@ -0,0 +1,44 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCondition_Check(t *testing.T) {
+	one, negative := int64(1), int64(-1)
+	tests := []struct {
+		wantErr error
+		cond    Condition
+		name    string
+		version int64
+		stored  float64
+		value   float64
+	}{
+		{name: "no condition", version: 5, stored: 2, value: 1},
+		{name: "version matches", cond: Condition{Version: &one}, version: 1},
+		{name: "version differs", cond: Condition{Version: &one}, version: 2, wantErr: ErrConflict},
+		{name: "max of not existing", cond: Condition{Mode: UpdateModeMax}, stored: 2, value: 1},
+		{name: "max with greater", cond: Condition{Mode: UpdateModeMax}, version: 1, stored: 1, value: 2},
+		{name: "max with equal", cond: Condition{Mode: UpdateModeMax}, version: 1, stored: 1, value: 1,
+			wantErr: ErrConflict},
+		{name: "min with less", cond: Condition{Mode: UpdateModeMin}, version: 1, stored: 1, value: 0},
+		{name: "min with greater", cond: Condition{Mode: UpdateModeMin}, version: 1, stored: 1, value: 2,
+			wantErr: ErrConflict},
+		{name: "version and mode", cond: Condition{Version: &one, Mode: UpdateModeMax}, version: 1, stored: 2,
+			value: 1, wantErr: ErrConflict},
+		{name: "unknown mode", cond: Condition{Mode: "preved"}, wantErr: ErrInvalidCondition},
+		{name: "negative version", cond: Condition{Version: &negative}, wantErr: ErrInvalidCondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cond.Validate()
+			if err == nil {
+				err = tt.cond.Check(tt.version, tt.stored, tt.value)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Check() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
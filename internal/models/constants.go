@@ -6,26 +6,45 @@ import "time"
 const (
 	MetricKindGauge                string = "gauge"
 	MetricKindCounter              string = "counter"
+	MetricKindHistogram            string = "histogram"
+	MetricKindSummary              string = "summary"
 	MetricPathPostPrefix           string = "update"
 	MetricPathPostsPrefix                 = MetricPathPostPrefix + "s"
 	MetricPathGetPrefix            string = "value"
+	MetricPathGetsPrefix                  = MetricPathGetPrefix + "s"
 	MetricNamePollCount            string = "PollCount"
 	MetricNameRandomValue          string = "RandomValue"
 	MetricNameTotalMemory          string = "TotalMemory"
 	MetricNameFreeMemory           string = "FreeMemory"
+	MetricNameGCPauseDuration      string = "GCPauseDuration"
 	MetricNamePrefixCPUUtilization string = "CPUutilization"
 	PingPath                       string = "ping"
+	SchemaPath                     string = "schema"
+	PrometheusPath                 string = "metrics"
+	InfluxPath                     string = "write"
+	InfluxQueryPrecision           string = "precision"
+	RemoteWritePath                string = "api/v1/write"
+	MetricPathReset                string = "reset"
+	MetricQueryPrefix              string = "prefix"
+	MetricQueryKind                string = "kind"
+	MetricQueryRegex               string = "regex"
+	MetricQueryLimit               string = "limit"
+	MetricQueryCursor              string = "cursor"
 )
 
 // HTTP headers.
 const (
 	HTTPHeaderContentTypeApplicationJSON     string = "application/json"
 	HTTPHeaderContentTypeApplicationTextHTML string = "text/html"
+	HTTPHeaderContentTypeTextPrometheus      string = "text/plain; version=0.0.4; charset=utf-8"
+	HTTPHeaderContentTypeRemoteWriteV2       string = "io.prometheus.write.v2.Request"
 	HTTPHeaderEncodingGzip                   string = "gzip"
 	HTTPHeaderContentType                    string = "Content-Type"
 	HTTPHeaderContentEncoding                string = "Content-Encoding"
 	HTTPHeaderAcceptEncoding                 string = "Accept-Encoding"
 	HTTPHeaderSign                           string = "HashSHA256"
+	HTTPHeaderRealIP                         string = "X-Real-IP"
+	HTTPHeaderTenant                         string = "X-Tenant"
 )
 
 // Ancillary constants.
@@ -42,5 +61,5 @@ const (
 )
 
 const (
-	TotalCountMetrics int = 43 // total metrics count
+	TotalCountMetrics int = 44 // total metrics count
 )
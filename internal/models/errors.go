@@ -14,6 +14,16 @@ var (
 	ErrNotSupported            = errors.New("not supported")         // error if metric not float nor integer
 	ErrUnmarshalling           = errors.New("error unmarshalling")   // error for unmarshalling error
 	ErrHTTPForbidden           = errors.New("forbidden")             // error for 403
+	ErrInvalidLabel            = errors.New("invalid label")         // error for invalid label or matcher
+	ErrInvalidHistogram        = errors.New("invalid histogram")     // error for invalid histogram buckets
+	ErrInvalidSummary          = errors.New("invalid summary")       // error for invalid summary sketch
+	ErrInvalidQuery            = errors.New("invalid query")         // error for invalid filter or page of list
+	ErrInvalidCondition        = errors.New("invalid condition")     // error for invalid condition of update
+	ErrConflict                = errors.New("conflict")              // error if condition of update fails
+	ErrInvalidLine             = errors.New("invalid line protocol") // error for invalid line of InfluxDB protocol
+	ErrInvalidStatsD           = errors.New("invalid statsd line")   // error for invalid line of StatsD protocol
+	ErrInvalidGraphite         = errors.New("invalid graphite line") // error for invalid line of Graphite protocol
+	ErrInvalidRemoteWrite      = errors.New("invalid remote write")  // error for invalid Prometheus remote write
 )
 
 const (
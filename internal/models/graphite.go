@@ -0,0 +1,143 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// graphiteNodeRe matches reference to node of Graphite path like $1.
+var graphiteNodeRe = regexp.MustCompile(`\$[0-9]+`)
+
+// GraphiteRule maps Graphite paths matching pattern to metric name and labels.
+type GraphiteRule struct {
+	// Labels - labels of metric, values may refer to nodes of path like name
+	Labels Labels
+	// Pattern - dotted pattern, every node in path.Match syntax matches one node of path, e.g. "servers.*.cpu"
+	Pattern string
+	// Name - name of metric, $N is replaced by N-th node of path, e.g. "cpu_$2"
+	Name string
+}
+
+// GraphiteMapping maps Graphite paths to metric names and labels.
+// Paths not matching any rule are named by path with dots replaced by underscores.
+type GraphiteMapping struct {
+	// Rules - mapping rules, first matching rule wins.
+	Rules []GraphiteRule
+}
+
+// NewGraphiteRule returns rule after checking pattern, name and labels.
+func NewGraphiteRule(pattern, name string, labels Labels) (GraphiteRule, error) {
+	nodes := strings.Split(pattern, ".")
+	for _, node := range nodes {
+		if _, err := path.Match(node, ""); err != nil || node == "" {
+			return GraphiteRule{}, fmt.Errorf("invalid graphite pattern %q", pattern)
+		}
+	}
+	if name == "" {
+		return GraphiteRule{}, fmt.Errorf("empty metric name for graphite pattern %q", pattern)
+	}
+	if err := labels.Validate(); err != nil {
+		return GraphiteRule{}, err
+	}
+	for _, s := range append([]string{name}, slices.Collect(maps.Values(labels))...) {
+		for _, ref := range graphiteNodeRe.FindAllString(s, -1) {
+			if n, _ := strconv.Atoi(ref[1:]); n < 1 || n > len(nodes) {
+				return GraphiteRule{}, fmt.Errorf("reference %s to missing node of graphite pattern %q", ref, pattern)
+			}
+		}
+	}
+	return GraphiteRule{Pattern: pattern, Name: name, Labels: labels}, nil
+}
+
+// match returns true if nodes of path match nodes of pattern.
+func (r GraphiteRule) match(nodes []string) bool {
+	patterns := strings.Split(r.Pattern, ".")
+	if len(patterns) != len(nodes) {
+		return false
+	}
+	for i, pattern := range patterns {
+		// patterns are checked in NewGraphiteRule
+		if ok, _ := path.Match(pattern, nodes[i]); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Map returns metric name and labels of Graphite path.
+func (m GraphiteMapping) Map(p string) (string, Labels) {
+	nodes := strings.Split(p, ".")
+	for _, rule := range m.Rules {
+		if !rule.match(nodes) {
+			continue
+		}
+		expand := func(s string) string {
+			return graphiteNodeRe.ReplaceAllStringFunc(s, func(ref string) string {
+				// references are checked in NewGraphiteRule
+				n, _ := strconv.Atoi(ref[1:])
+				return nodes[n-1]
+			})
+		}
+		var labels Labels
+		if len(rule.Labels) > 0 {
+			labels = make(Labels, len(rule.Labels))
+			for name, value := range rule.Labels {
+				labels[name] = expand(value)
+			}
+		}
+		return expand(rule.Name), labels
+	}
+	return strings.ReplaceAll(p, ".", "_"), nil
+}
+
+// ParseGraphiteLine parses Graphite plaintext line like "servers.a.cpu 0.5 1700000000" to gauge.
+// Tags of path like "cpu;host=a" are labels, labels of mapping rule override them. Timestamp is checked,
+// but gauge is stored at time of receiving as other metrics.
+func ParseGraphiteLine(line string, mapping GraphiteMapping) (Metric, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return Metric{}, fmt.Errorf("%w: '%s'", ErrInvalidGraphite, line)
+	}
+	value, err := strconv.ParseFloat(fields[1], metricBitSize)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return Metric{}, fmt.Errorf("%w: %w: '%s'", ErrInvalidGraphite, ErrNotFloat, fields[1])
+	}
+	if len(fields) == 3 {
+		// -1 is timestamp of receiving
+		if _, err = strconv.ParseFloat(fields[2], metricBitSize); err != nil {
+			return Metric{}, fmt.Errorf("%w: timestamp '%s'", ErrInvalidGraphite, fields[2])
+		}
+	}
+	p, tags, _ := strings.Cut(fields[0], ";")
+	if p == "" || strings.HasPrefix(p, ".") || strings.HasSuffix(p, ".") || strings.Contains(p, "..") {
+		return Metric{}, fmt.Errorf("%w: path '%s'", ErrInvalidGraphite, p)
+	}
+	var labels Labels
+	if tags != "" {
+		labels = make(Labels)
+		for _, tag := range strings.Split(tags, ";") {
+			name, v, ok := strings.Cut(tag, "=")
+			if !ok || v == "" {
+				return Metric{}, fmt.Errorf("%w: tag '%s'", ErrInvalidLabel, tag)
+			}
+			labels[name] = v
+		}
+		if err = labels.Validate(); err != nil {
+			return Metric{}, err
+		}
+	}
+	name, ruleLabels := mapping.Map(p)
+	if len(ruleLabels) > 0 {
+		if labels == nil {
+			labels = make(Labels, len(ruleLabels))
+		}
+		maps.Copy(labels, ruleLabels)
+	}
+	return Metric{Kind: MetricKindGauge, Name: name, Labels: labels, Value: value}, nil
+}
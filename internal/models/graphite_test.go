@@ -0,0 +1,142 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewGraphiteRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		metric  string
+		labels  Labels
+		wantErr bool
+	}{
+		{
+			name:    "ok",
+			pattern: "servers.*.cpu-[0-9]",
+			metric:  "cpu_$3",
+			labels:  Labels{"host": "$2"},
+		},
+		{
+			name:    "invalid pattern",
+			pattern: "servers.[",
+			metric:  "cpu",
+			wantErr: true,
+		},
+		{
+			name:    "empty node",
+			pattern: "servers..cpu",
+			metric:  "cpu",
+			wantErr: true,
+		},
+		{
+			name:    "empty name",
+			pattern: "servers",
+			wantErr: true,
+		},
+		{
+			name:    "missing node",
+			pattern: "servers.*",
+			metric:  "cpu_$3",
+			wantErr: true,
+		},
+		{
+			name:    "missing node in label",
+			pattern: "servers.*",
+			metric:  "cpu",
+			labels:  Labels{"host": "$0"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid label name",
+			pattern: "servers.*",
+			metric:  "cpu",
+			labels:  Labels{"host-name": "$2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGraphiteRule(tt.pattern, tt.metric, tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewGraphiteRule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseGraphiteLine(t *testing.T) {
+	rule, err := NewGraphiteRule("servers.*.cpu.*", "cpu_$4", Labels{"host": "$2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := GraphiteMapping{Rules: []GraphiteRule{rule}}
+	tests := []struct {
+		name    string
+		line    string
+		want    Metric
+		wantErr error
+	}{
+		{
+			name: "mapped path",
+			line: "servers.web1.cpu.user 0.5 1700000000",
+			want: Metric{Kind: MetricKindGauge, Name: "cpu_user", Value: 0.5, Labels: Labels{"host": "web1"}},
+		},
+		{
+			name: "unmapped path without timestamp",
+			line: "jobs.backup.duration 42",
+			want: Metric{Kind: MetricKindGauge, Name: "jobs_backup_duration", Value: 42},
+		},
+		{
+			name: "tags",
+			line: "servers.web1.cpu.user;host=ignored;env=prod 1 -1",
+			want: Metric{Kind: MetricKindGauge, Name: "cpu_user", Value: 1,
+				Labels: Labels{"host": "web1", "env": "prod"}},
+		},
+		{
+			name:    "no value",
+			line:    "jobs.backup.duration",
+			wantErr: ErrInvalidGraphite,
+		},
+		{
+			name:    "invalid value",
+			line:    "jobs.backup.duration fast 1700000000",
+			wantErr: ErrNotFloat,
+		},
+		{
+			name:    "invalid timestamp",
+			line:    "jobs.backup.duration 42 yesterday",
+			wantErr: ErrInvalidGraphite,
+		},
+		{
+			name:    "empty node",
+			line:    "jobs..duration 42",
+			wantErr: ErrInvalidGraphite,
+		},
+		{
+			name:    "invalid tag",
+			line:    "jobs.backup.duration;env 42",
+			wantErr: ErrInvalidLabel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseGraphiteLine(tt.line, mapping)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ParseGraphiteLine() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGraphiteLine() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGraphiteLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
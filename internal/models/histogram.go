@@ -0,0 +1,129 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Histogram describes distribution of observed values between buckets.
+type Histogram struct {
+	// Bounds - upper inclusive bounds of buckets in increasing order, +Inf bucket is implicit
+	Bounds []float64 `json:"bounds"`
+	// Counts - count of observations in each bucket, last one is +Inf bucket
+	Counts []uint64 `json:"counts"`
+	// Sum - sum of observed values
+	Sum float64 `json:"sum"`
+}
+
+// NewHistogram returns empty histogram with bounds.
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		Bounds: slices.Clone(bounds),
+		Counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe adds value to histogram.
+func (h *Histogram) Observe(value float64) {
+	i, _ := slices.BinarySearch(h.Bounds, value)
+	h.Counts[i]++
+	h.Sum += value
+}
+
+// Count returns total count of observations.
+func (h *Histogram) Count() uint64 {
+	var count uint64
+	for _, c := range h.Counts {
+		count += c
+	}
+	return count
+}
+
+// Validate returns error if bounds are not finite and strictly increasing
+// or count of buckets doesn't match bounds.
+func (h *Histogram) Validate() error {
+	if h == nil {
+		return fmt.Errorf("%w: no buckets", ErrInvalidHistogram)
+	}
+	for i, bound := range h.Bounds {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("%w: bound %v", ErrInvalidHistogram, bound)
+		}
+		if i > 0 && bound <= h.Bounds[i-1] {
+			return fmt.Errorf("%w: bounds are not increasing", ErrInvalidHistogram)
+		}
+	}
+	if len(h.Counts) != len(h.Bounds)+1 {
+		return fmt.Errorf("%w: %d counts for %d bounds", ErrInvalidHistogram, len(h.Counts), len(h.Bounds))
+	}
+	if math.IsNaN(h.Sum) {
+		return fmt.Errorf("%w: sum is NaN", ErrInvalidHistogram)
+	}
+	return nil
+}
+
+// Clone returns deep copy of histogram.
+func (h *Histogram) Clone() *Histogram {
+	if h == nil {
+		return nil
+	}
+	return &Histogram{
+		Bounds: slices.Clone(h.Bounds),
+		Counts: slices.Clone(h.Counts),
+		Sum:    h.Sum,
+	}
+}
+
+// Merge returns histogram with observations of both histograms.
+// Histogram with other bounds replaces previous one, so reporter may change buckets.
+func (h *Histogram) Merge(other *Histogram) *Histogram {
+	if h == nil || !slices.Equal(h.Bounds, other.Bounds) {
+		return other.Clone()
+	}
+	res := h.Clone()
+	for i, c := range other.Counts {
+		res.Counts[i] += c
+	}
+	res.Sum += other.Sum
+	return res
+}
+
+// String returns count, sum and cumulative counts of buckets like
+// "count=3 sum=0.6 le0.1=1 le1=3 le+Inf=3".
+func (h *Histogram) String() string {
+	var sb strings.Builder
+	sb.WriteString("count=" + strconv.FormatUint(h.Count(), base10) + " sum=" + RoundFloatToString(h.Sum))
+	var cumulative uint64
+	for i, c := range h.Counts {
+		cumulative += c
+		bound := "+Inf"
+		if i < len(h.Bounds) {
+			bound = strconv.FormatFloat(h.Bounds[i], 'g', -1, metricBitSize)
+		}
+		sb.WriteString(" le" + bound + "=" + strconv.FormatUint(cumulative, base10))
+	}
+	return sb.String()
+}
+
+// ParseBounds parses comma separated bucket bounds like "0.001,0.01,0.1".
+func ParseBounds(s string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	bounds := make([]float64, 0, len(parts))
+	for _, part := range parts {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(part), metricBitSize)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bound '%s'", ErrInvalidHistogram, part)
+		}
+		bounds = append(bounds, bound)
+	}
+	if err := NewHistogram(bounds).Validate(); err != nil {
+		return nil, err
+	}
+	return bounds, nil
+}
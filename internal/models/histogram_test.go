@@ -0,0 +1,161 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestHistogram_Observe(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 1, 2} {
+		h.Observe(v)
+	}
+	if want := []uint64{2, 2, 1}; !reflect.DeepEqual(h.Counts, want) {
+		t.Errorf("Observe() counts = %v, want %v", h.Counts, want)
+	}
+	if h.Count() != 5 || h.Sum != 3.65 {
+		t.Errorf("Observe() count = %d, sum = %v, want 5, 3.65", h.Count(), h.Sum)
+	}
+}
+
+func TestHistogram_Validate(t *testing.T) {
+	tests := []struct {
+		h       *Histogram
+		name    string
+		wantErr bool
+	}{
+		{
+			name: "ok",
+			h:    &Histogram{Bounds: []float64{-1, 0, 1}, Counts: []uint64{0, 1, 2, 3}, Sum: 1},
+		},
+		{
+			name: "only +Inf bucket",
+			h:    &Histogram{Counts: []uint64{1}},
+		},
+		{
+			name:    "nil",
+			wantErr: true,
+		},
+		{
+			name:    "bounds not increasing",
+			h:       &Histogram{Bounds: []float64{1, 1}, Counts: []uint64{0, 0, 0}},
+			wantErr: true,
+		},
+		{
+			name:    "infinite bound",
+			h:       &Histogram{Bounds: []float64{math.Inf(1)}, Counts: []uint64{0, 0}},
+			wantErr: true,
+		},
+		{
+			name:    "counts don't match bounds",
+			h:       &Histogram{Bounds: []float64{1}, Counts: []uint64{0}},
+			wantErr: true,
+		},
+		{
+			name:    "sum is NaN",
+			h:       &Histogram{Counts: []uint64{1}, Sum: math.NaN()},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.h.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidHistogram) {
+				t.Errorf("Validate() error = %v, want %v", err, ErrInvalidHistogram)
+			}
+		})
+	}
+}
+
+func TestHistogram_Merge(t *testing.T) {
+	tests := []struct {
+		h     *Histogram
+		other *Histogram
+		want  *Histogram
+		name  string
+	}{
+		{
+			name:  "same bounds",
+			h:     &Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 3},
+			other: &Histogram{Bounds: []float64{1}, Counts: []uint64{3, 0}, Sum: 1.5},
+			want:  &Histogram{Bounds: []float64{1}, Counts: []uint64{4, 2}, Sum: 4.5},
+		},
+		{
+			name:  "other bounds",
+			h:     &Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 3},
+			other: &Histogram{Bounds: []float64{2}, Counts: []uint64{3, 0}, Sum: 1.5},
+			want:  &Histogram{Bounds: []float64{2}, Counts: []uint64{3, 0}, Sum: 1.5},
+		},
+		{
+			name:  "nil",
+			other: &Histogram{Bounds: []float64{2}, Counts: []uint64{3, 0}, Sum: 1.5},
+			want:  &Histogram{Bounds: []float64{2}, Counts: []uint64{3, 0}, Sum: 1.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, other := tt.h.Clone(), tt.other.Clone()
+			got := h.Merge(other)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge() got = %v, want %v", got, tt.want)
+			}
+			// merged histogram doesn't share buckets with arguments
+			got.Counts[0]++
+			if !reflect.DeepEqual(h, tt.h) || !reflect.DeepEqual(other, tt.other) {
+				t.Errorf("Merge() modified arguments")
+			}
+		})
+	}
+}
+
+func TestHistogram_String(t *testing.T) {
+	h := &Histogram{Bounds: []float64{0.001, 10}, Counts: []uint64{1, 0, 2}, Sum: 30.0005}
+	if got, want := h.String(), "count=3 sum=30.001 le0.001=1 le10=1 le+Inf=3"; got != want {
+		t.Errorf("String() got = %q, want %q", got, want)
+	}
+}
+
+func TestParseBounds(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    []float64
+		wantErr bool
+	}{
+		{
+			name: "ok",
+			s:    "0.001, 0.01,1e2",
+			want: []float64{0.001, 0.01, 100},
+		},
+		{
+			name: "empty",
+			s:    " ",
+		},
+		{
+			name:    "not a number",
+			s:       "0.1,one",
+			wantErr: true,
+		},
+		{
+			name:    "not increasing",
+			s:       "1,0.1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseBounds(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBounds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseBounds() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timestamp precisions of InfluxDB line protocol.
+const (
+	InfluxPrecisionNanoseconds  string = "ns"
+	InfluxPrecisionMicroseconds string = "us"
+	InfluxPrecisionMilliseconds string = "ms"
+	InfluxPrecisionSeconds      string = "s"
+)
+
+// influxFieldValue is default field name, metric of this field is named by measurement only.
+const influxFieldValue = "value"
+
+// InfluxCounters - patterns of metric names in path.Match syntax, integer fields of matching metrics
+// are counter deltas.
+type InfluxCounters []string
+
+// NewInfluxCounters returns counter patterns after checking them.
+func NewInfluxCounters(patterns []string) (InfluxCounters, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil || pattern == "" {
+			return nil, fmt.Errorf("invalid influx counter pattern %q", pattern)
+		}
+	}
+	return patterns, nil
+}
+
+// match returns true if metric name matches any pattern.
+func (c InfluxCounters) match(name string) bool {
+	for _, pattern := range c {
+		// patterns are checked in NewInfluxCounters
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// InfluxPoint is metric parsed from line protocol with timestamp of its line.
+type InfluxPoint struct {
+	// Time - timestamp of line, zero if line has no timestamp
+	Time time.Time
+	// Metric - metric of field
+	Metric Metric
+}
+
+// influxSample is point parsed from line with timestamp of line in nanoseconds, now if line has no timestamp.
+type influxSample struct {
+	point InfluxPoint
+	ts    int64
+}
+
+// ParseInfluxLines parses InfluxDB line protocol like "cpu,host=a usage=0.5,procs=3i 1700000000000000000".
+//
+// Every numeric field of line is metric named measurement_field with tags as labels, field named "value"
+// is metric named by measurement. Numeric and boolean fields are gauges, as line protocol carries readings
+// of values. Integer and unsigned fields of metrics matching counters are counter deltas. String fields
+// are skipped. Timestamps are scaled by precision, empty precision means nanoseconds, lines without
+// timestamp are written at now. Gauge written by several lines has value of latest line, deltas of
+// counter are summed and point has timestamp of latest line.
+func ParseInfluxLines(data []byte, precision string, counters InfluxCounters, now time.Time) ([]InfluxPoint,
+	error) {
+	scale, err := influxPrecisionScale(precision)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		samples []influxSample
+		index   = make(map[string]int)
+	)
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		metrics, ts, stamped, err := parseInfluxLine(line, scale, counters, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %w", ErrInvalidLine, n+1, err)
+		}
+		point := InfluxPoint{}
+		if stamped {
+			point.Time = time.Unix(0, ts)
+		}
+		for _, metric := range metrics {
+			point.Metric = metric
+			id := metric.Kind + " " + metric.Name + metric.Labels.String()
+			i, ok := index[id]
+			switch {
+			case !ok:
+				index[id] = len(samples)
+				samples = append(samples, influxSample{point: point, ts: ts})
+			case metric.Kind == MetricKindCounter:
+				samples[i].point.Metric.Delta += metric.Delta
+				if ts >= samples[i].ts {
+					samples[i].point.Time, samples[i].ts = point.Time, ts
+				}
+			case ts >= samples[i].ts:
+				samples[i] = influxSample{point: point, ts: ts}
+			}
+		}
+	}
+	res := make([]InfluxPoint, len(samples))
+	for i, sample := range samples {
+		res[i] = sample.point
+	}
+	return res, nil
+}
+
+// influxPrecisionScale returns nanoseconds in unit of timestamp precision.
+func influxPrecisionScale(precision string) (int64, error) {
+	switch precision {
+	case "", InfluxPrecisionNanoseconds:
+		return int64(time.Nanosecond), nil
+	case InfluxPrecisionMicroseconds:
+		return int64(time.Microsecond), nil
+	case InfluxPrecisionMilliseconds:
+		return int64(time.Millisecond), nil
+	case InfluxPrecisionSeconds:
+		return int64(time.Second), nil
+	default:
+		return 0, fmt.Errorf("%w: precision '%s'", ErrInvalidLine, precision)
+	}
+}
+
+// parseInfluxLine returns metrics of line and timestamp of line in nanoseconds, now if line has no timestamp.
+// Returns false if line has no timestamp.
+func parseInfluxLine(line string, scale int64, counters InfluxCounters, now time.Time) ([]Metric, int64, bool,
+	error) {
+	// quotes are literal in measurement and tags, so only fields and timestamp are split with quotes
+	key := splitInfluxEscaped(line, ' ', false)[0]
+	if len(key) == len(line) {
+		return nil, 0, false, errors.New("expected measurement, fields and optional timestamp")
+	}
+	sections := append([]string{key}, splitInfluxEscaped(line[len(key)+1:], ' ', true)...)
+	if len(sections) > 3 {
+		return nil, 0, false, errors.New("expected measurement, fields and optional timestamp")
+	}
+	ts := now.UnixNano()
+	stamped := len(sections) == 3
+	if stamped {
+		v, err := strconv.ParseInt(sections[2], base10, metricBitSize)
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("timestamp '%s'", sections[2])
+		}
+		ts = v * scale
+	}
+	series := splitInfluxEscaped(sections[0], ',', false)
+	measurement := unescapeInflux(series[0])
+	if measurement == "" {
+		return nil, 0, false, errors.New("empty measurement")
+	}
+	var labels Labels
+	for _, tag := range series[1:] {
+		key, value, ok := cutInfluxEscaped(tag, false)
+		if !ok || key == "" {
+			return nil, 0, false, fmt.Errorf("tag '%s'", tag)
+		}
+		if labels == nil {
+			labels = make(Labels, len(series)-1)
+		}
+		labels[key] = value
+	}
+	if err := labels.Validate(); err != nil {
+		return nil, 0, false, err
+	}
+	var metrics []Metric
+	for _, field := range splitInfluxEscaped(sections[1], ',', true) {
+		key, value, ok := cutInfluxEscaped(field, true)
+		if !ok || key == "" || value == "" {
+			return nil, 0, false, fmt.Errorf("field '%s'", field)
+		}
+		name := measurement
+		if key != influxFieldValue {
+			name += "_" + key
+		}
+		metric, ok, err := parseInfluxField(name, value, counters.match(name))
+		if err != nil {
+			return nil, 0, false, fmt.Errorf("field '%s': %w", key, err)
+		}
+		if ok {
+			metric.Labels = labels
+			metrics = append(metrics, metric)
+		}
+	}
+	return metrics, ts, stamped, nil
+}
+
+// parseInfluxField returns metric of field value, integer value is counter delta if counter is true.
+// Returns false for string field.
+func parseInfluxField(name, value string, counter bool) (Metric, bool, error) {
+	metric := Metric{Name: name}
+	switch last := value[len(value)-1]; {
+	case value[0] == '"':
+		if len(value) < 2 || last != '"' {
+			return Metric{}, false, errors.New("unterminated string")
+		}
+		return Metric{}, false, nil
+	case last == 'i' || last == 'u':
+		v, err := strconv.ParseInt(value[:len(value)-1], base10, metricBitSize)
+		if err != nil || last == 'u' && v < 0 {
+			return Metric{}, false, ErrNotInteger
+		}
+		if counter {
+			metric.Kind = MetricKindCounter
+			metric.Delta = v
+		} else {
+			metric.Kind = MetricKindGauge
+			metric.Value = float64(v)
+		}
+	default:
+		metric.Kind = MetricKindGauge
+		switch value {
+		case "t", "T", "true", "True", "TRUE":
+			metric.Value = 1
+		case "f", "F", "false", "False", "FALSE":
+			metric.Value = 0
+		default:
+			v, err := strconv.ParseFloat(value, metricBitSize)
+			if err != nil {
+				return Metric{}, false, ErrNotFloat
+			}
+			metric.Value = v
+		}
+	}
+	return metric, true, nil
+}
+
+// splitInfluxEscaped splits s by separator which is not escaped by backslash.
+// Separators inside double quotes are skipped if quotes is true.
+func splitInfluxEscaped(s string, sep byte, quotes bool) []string {
+	var (
+		res     []string
+		start   int
+		escaped bool
+		quoted  bool
+	)
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == '"' && quotes:
+			quoted = !quoted
+		case c == sep && !quoted:
+			res = append(res, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(res, s[start:])
+}
+
+// cutInfluxEscaped cuts key=value pair by first not escaped equals sign and unescapes key.
+// Value is unescaped unless quotes is true and value is quoted string.
+func cutInfluxEscaped(s string, quotes bool) (string, string, bool) {
+	key := splitInfluxEscaped(s, '=', false)[0]
+	if len(key) == len(s) {
+		return "", "", false
+	}
+	value := s[len(key)+1:]
+	key = unescapeInflux(key)
+	if !quotes || !strings.HasPrefix(value, `"`) {
+		value = unescapeInflux(value)
+	}
+	return key, value, true
+}
+
+// influxReplacer removes backslashes escaping special characters of line protocol.
+var influxReplacer = strings.NewReplacer(`\ `, " ", `\,`, ",", `\=`, "=", `\"`, `"`, `\\`, `\`)
+
+// unescapeInflux returns s without escaping backslashes.
+func unescapeInflux(s string) string {
+	return influxReplacer.Replace(s)
+}
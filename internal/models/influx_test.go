@@ -0,0 +1,145 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseInfluxLines(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		data      string
+		precision string
+		counters  InfluxCounters
+		want      []InfluxPoint
+		wantErr   bool
+	}{
+		{
+			name: "float and integer fields",
+			data: "cpu,host=a usage=0.5,procs=3i 1700000000000000000\n",
+			want: []InfluxPoint{
+				{Time: now, Metric: Metric{Kind: MetricKindGauge, Name: "cpu_usage", Value: 0.5,
+					Labels: Labels{"host": "a"}}},
+				{Time: now, Metric: Metric{Kind: MetricKindGauge, Name: "cpu_procs", Value: 3,
+					Labels: Labels{"host": "a"}}},
+			},
+		},
+		{
+			name: "value field, boolean, unsigned and string",
+			data: "# comment\r\n\ntemp value=21.5,ok=t,errors=2u,state=\"idle, ok\"",
+			want: []InfluxPoint{
+				{Metric: Metric{Kind: MetricKindGauge, Name: "temp", Value: 21.5}},
+				{Metric: Metric{Kind: MetricKindGauge, Name: "temp_ok", Value: 1}},
+				{Metric: Metric{Kind: MetricKindGauge, Name: "temp_errors", Value: 2}},
+			},
+		},
+		{
+			name: "escaped characters",
+			data: `disk\ io,path=C:\\data,dev=a\,b\=c bytes=1e3`,
+			want: []InfluxPoint{
+				{Metric: Metric{Kind: MetricKindGauge, Name: "disk io_bytes", Value: 1000,
+					Labels: Labels{"path": `C:\data`, "dev": "a,b=c"}}},
+			},
+		},
+		{
+			name: "latest gauge wins",
+			data: "mem used=3,hits=1i 20\nmem used=2,hits=2i 10\nmem used=1,hits=3i 30\nmem used=4 25",
+			want: []InfluxPoint{
+				{Time: time.Unix(0, 30), Metric: Metric{Kind: MetricKindGauge, Name: "mem_used", Value: 1}},
+				{Time: time.Unix(0, 30), Metric: Metric{Kind: MetricKindGauge, Name: "mem_hits", Value: 3}},
+			},
+		},
+		{
+			name:     "integer fields of counters are summed",
+			data:     "mem used=3i,hits=1i 20\nmem used=2i,hits=2i 10\nmem used=1i,hits=3i 30",
+			counters: InfluxCounters{"mem_hit?", "disk_*"},
+			want: []InfluxPoint{
+				{Time: time.Unix(0, 30), Metric: Metric{Kind: MetricKindGauge, Name: "mem_used", Value: 1}},
+				{Time: time.Unix(0, 30), Metric: Metric{Kind: MetricKindCounter, Name: "mem_hits", Delta: 6}},
+			},
+		},
+		{
+			name:      "line without timestamp is written now",
+			data:      "mem used=1\nmem used=2 1600000000",
+			precision: InfluxPrecisionSeconds,
+			want:      []InfluxPoint{{Metric: Metric{Kind: MetricKindGauge, Name: "mem_used", Value: 1}}},
+		},
+		{
+			name:      "unknown precision",
+			data:      "mem used=1",
+			precision: "h",
+			wantErr:   true,
+		},
+		{
+			name:    "no fields",
+			data:    "mem",
+			wantErr: true,
+		},
+		{
+			name:    "invalid float",
+			data:    "mem used=abc",
+			wantErr: true,
+		},
+		{
+			name:    "invalid integer",
+			data:    "mem used=-1u",
+			wantErr: true,
+		},
+		{
+			name:    "invalid timestamp",
+			data:    "mem used=1 yesterday",
+			wantErr: true,
+		},
+		{
+			name:    "invalid tag name",
+			data:    "mem,a-b=1 used=1",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated string",
+			data:    `mem state="idle`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseInfluxLines([]byte(tt.data), tt.precision, tt.counters, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidLine) {
+					t.Errorf("ParseInfluxLines() error = %v, want %v", err, ErrInvalidLine)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseInfluxLines() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseInfluxLines() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInfluxCounters(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		wantErr  bool
+	}{
+		{name: "valid patterns", patterns: []string{"requests_*", "mem_hits"}},
+		{name: "no patterns"},
+		{name: "empty pattern", patterns: []string{""}, wantErr: true},
+		{name: "invalid pattern", patterns: []string{"requests_["}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewInfluxCounters(tt.patterns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewInfluxCounters() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
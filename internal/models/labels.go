@@ -0,0 +1,179 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Label matcher types.
+const (
+	MatchEqual     string = "="  // label value equals
+	MatchNotEqual  string = "!=" // label value not equals
+	MatchRegexp    string = "=~" // label value matches regexp
+	MatchNotRegexp string = "!~" // label value doesn't match regexp
+)
+
+// labelNameRe describes valid label name.
+var labelNameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Labels describes set of metric labels. Nil and empty labels are the same.
+type Labels map[string]string
+
+// String returns canonical representation of labels, e.g. {host="a",service="b"}.
+// Returns empty string for empty labels.
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range slices.Sorted(maps.Keys(l)) {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(strconv.Quote(l[name]))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// Equal returns true if labels have the same names and values.
+func (l Labels) Equal(other Labels) bool {
+	return maps.Equal(l, other)
+}
+
+// Validate returns error if labels have invalid name.
+func (l Labels) Validate() error {
+	for name := range l {
+		if !labelNameRe.MatchString(name) {
+			return fmt.Errorf("%w: label name '%s'", ErrInvalidLabel, name)
+		}
+	}
+	return nil
+}
+
+// Normalize returns nil for empty labels and labels itself otherwise.
+func (l Labels) Normalize() Labels {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
+
+// LabelMatcher describes condition for label value. Missing label has empty value.
+type LabelMatcher struct {
+	re *regexp.Regexp
+	// Name - label name.
+	Name string
+	// Type - one of Match* constants.
+	Type string
+	// Value - label value or regexp.
+	Value string
+}
+
+// NewLabelMatcher returns new LabelMatcher. Regexp must match whole value.
+func NewLabelMatcher(name, typ, value string) (LabelMatcher, error) {
+	m := LabelMatcher{Name: name, Type: typ, Value: value}
+	if !labelNameRe.MatchString(name) {
+		return LabelMatcher{}, fmt.Errorf("%w: label name '%s'", ErrInvalidLabel, name)
+	}
+	switch typ {
+	case MatchEqual, MatchNotEqual:
+	case MatchRegexp, MatchNotRegexp:
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		if err != nil {
+			return LabelMatcher{}, fmt.Errorf("%w: %w", ErrInvalidLabel, err)
+		}
+		m.re = re
+	default:
+		return LabelMatcher{}, fmt.Errorf("%w: matcher type '%s'", ErrInvalidLabel, typ)
+	}
+	return m, nil
+}
+
+// ParseLabelMatcher parses matcher like host=a, host!=a, host=~a.* or host!~a.*.
+func ParseLabelMatcher(s string) (LabelMatcher, error) {
+	i := strings.IndexAny(s, "=!")
+	if i < 0 {
+		return LabelMatcher{}, fmt.Errorf("%w: matcher '%s'", ErrInvalidLabel, s)
+	}
+	name, rest := s[:i], s[i:]
+	for _, typ := range []string{MatchRegexp, MatchNotRegexp, MatchNotEqual, MatchEqual} {
+		if value, ok := strings.CutPrefix(rest, typ); ok {
+			return NewLabelMatcher(name, typ, value)
+		}
+	}
+	return LabelMatcher{}, fmt.Errorf("%w: matcher '%s'", ErrInvalidLabel, s)
+}
+
+// Matches returns true if labels satisfy matcher.
+func (m LabelMatcher) Matches(l Labels) bool {
+	value := l[m.Name]
+	switch m.Type {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	case MatchNotRegexp:
+		return !m.re.MatchString(value)
+	default:
+		return false
+	}
+}
+
+// String returns matcher in form accepted by ParseLabelMatcher.
+func (m LabelMatcher) String() string {
+	return m.Name + m.Type + m.Value
+}
+
+// MatchLabels returns true if labels satisfy all matchers.
+func MatchLabels(l Labels, matchers ...LabelMatcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(l) {
+			return false
+		}
+	}
+	return true
+}
+
+// EqualMatchers returns matchers selecting series with all specified labels.
+func EqualMatchers(l Labels) []LabelMatcher {
+	res := make([]LabelMatcher, 0, len(l))
+	for _, name := range slices.Sorted(maps.Keys(l)) {
+		res = append(res, LabelMatcher{Name: name, Type: MatchEqual, Value: l[name]})
+	}
+	return res
+}
+
+// CompareMetrics compares metrics by name, kind and labels. Used for sorting.
+func CompareMetrics(a, b Metric) int {
+	if c := strings.Compare(a.Name, b.Name); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+		return c
+	}
+	return CompareLabels(a.Labels, b.Labels)
+}
+
+// CompareLabels compares labels by sorted name and value pairs. Labels with less pairs are first on equal prefix.
+func CompareLabels(a, b Labels) int {
+	aNames, bNames := slices.Sorted(maps.Keys(a)), slices.Sorted(maps.Keys(b))
+	for i := 0; i < len(aNames) && i < len(bNames); i++ {
+		if c := strings.Compare(aNames[i], bNames[i]); c != 0 {
+			return c
+		}
+		if c := strings.Compare(a[aNames[i]], b[bNames[i]]); c != 0 {
+			return c
+		}
+	}
+	return len(aNames) - len(bNames)
+}
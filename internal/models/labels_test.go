@@ -0,0 +1,153 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestLabels_String(t *testing.T) {
+	tests := []struct {
+		name   string
+		labels Labels
+		want   string
+	}{
+		{
+			name: "nil",
+			want: "",
+		},
+		{
+			name:   "empty",
+			labels: Labels{},
+			want:   "",
+		},
+		{
+			name:   "sorted",
+			labels: Labels{"service": "api", "host": "a"},
+			want:   `{host="a",service="api"}`,
+		},
+		{
+			name:   "quoted",
+			labels: Labels{"host": `a",b="c`},
+			want:   `{host="a\",b=\"c"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.labels.String(); got != tt.want {
+				t.Errorf("String() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabels_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  Labels
+		wantErr bool
+	}{
+		{
+			name:   "valid",
+			labels: Labels{"host": "a", "_service2": ""},
+		},
+		{
+			name:    "starts with digit",
+			labels:  Labels{"2host": "a"},
+			wantErr: true,
+		},
+		{
+			name:    "contains dash",
+			labels:  Labels{"host-name": "a"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.labels.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidLabel) {
+				t.Errorf("Validate() error = %v, want %v", err, ErrInvalidLabel)
+			}
+		})
+	}
+}
+
+func TestParseLabelMatcher(t *testing.T) {
+	labels := Labels{"host": "web1", "dc": "eu-west"}
+	tests := []struct {
+		name    string
+		matcher string
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "equal",
+			matcher: "host=web1",
+			want:    true,
+		},
+		{
+			name:    "equal missing label",
+			matcher: "service=",
+			want:    true,
+		},
+		{
+			name:    "not equal",
+			matcher: "host!=web1",
+			want:    false,
+		},
+		{
+			name:    "regexp",
+			matcher: "dc=~eu-.*",
+			want:    true,
+		},
+		{
+			name:    "regexp is anchored",
+			matcher: "dc=~eu",
+			want:    false,
+		},
+		{
+			name:    "not regexp",
+			matcher: "host!~web[0-9]+",
+			want:    false,
+		},
+		{
+			name:    "value with operator",
+			matcher: "host=a=b",
+			want:    false,
+		},
+		{
+			name:    "without operator",
+			matcher: "host",
+			wantErr: true,
+		},
+		{
+			name:    "invalid name",
+			matcher: "=web1",
+			wantErr: true,
+		},
+		{
+			name:    "invalid regexp",
+			matcher: "host=~(",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := ParseLabelMatcher(tt.matcher)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLabelMatcher() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if m.String() != tt.matcher {
+				t.Errorf("String() = %v, want %v", m.String(), tt.matcher)
+			}
+			if got := m.Matches(labels); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// List settings.
+const (
+	DefaultListLimit int = 100  // default count of metrics in page
+	MaxListLimit     int = 1000 // max count of metrics in page
+)
+
+// ListQuery describes filter and page of metrics sorted by name, kind and labels.
+type ListQuery struct {
+	// After - position of last metric of previous page, nil for first page
+	After *ListCursor
+	// Regex - regexp matching whole name, nil for any name
+	Regex *regexp.Regexp
+	// Kind - metric kind, empty for any kind
+	Kind string
+	// Prefix - name prefix, empty for any name
+	Prefix string
+	// Limit - max count of metrics in page
+	Limit int
+}
+
+// ListCursor describes position of metric in sorted list.
+type ListCursor struct {
+	// Labels - metric labels
+	Labels Labels `json:"labels,omitempty"`
+	// Name - metric name
+	Name string `json:"name"`
+	// Kind - metric kind
+	Kind string `json:"kind"`
+}
+
+// ListPage describes page of listed metrics.
+type ListPage struct {
+	// Metrics - metrics of page
+	Metrics []Metric
+	// Next - cursor of next page, empty for last page
+	Next string
+}
+
+// NewListQuery returns query by filter and page parameters. Empty cursor means first page, zero limit means
+// DefaultListLimit and limit greater than MaxListLimit is reduced to it. Regex must match whole name.
+func NewListQuery(kind, prefix, regex, cursor string, limit int) (ListQuery, error) {
+	q := ListQuery{Kind: kind, Prefix: prefix, Limit: limit}
+	switch kind {
+	case "", MetricKindGauge, MetricKindCounter, MetricKindHistogram, MetricKindSummary:
+	default:
+		return ListQuery{}, fmt.Errorf("%w: metric kind '%s'", ErrInvalidQuery, kind)
+	}
+	if regex != "" {
+		re, err := regexp.Compile("^(?:" + regex + ")$")
+		if err != nil {
+			return ListQuery{}, fmt.Errorf("%w: %w", ErrInvalidQuery, err)
+		}
+		q.Regex = re
+	}
+	if cursor != "" {
+		after, err := ParseListCursor(cursor)
+		if err != nil {
+			return ListQuery{}, err
+		}
+		q.After = after
+	}
+	switch {
+	case limit < 0:
+		return ListQuery{}, fmt.Errorf("%w: limit %d", ErrInvalidQuery, limit)
+	case limit == 0:
+		q.Limit = DefaultListLimit
+	case limit > MaxListLimit:
+		q.Limit = MaxListLimit
+	}
+	return q, nil
+}
+
+// Matches returns true if metric satisfies filter and follows cursor.
+func (q ListQuery) Matches(m Metric) bool {
+	if q.Kind != "" && m.Kind != q.Kind {
+		return false
+	}
+	if !strings.HasPrefix(m.Name, q.Prefix) {
+		return false
+	}
+	if q.Regex != nil && !q.Regex.MatchString(m.Name) {
+		return false
+	}
+	return q.After == nil || CompareMetrics(m, Metric{Kind: q.After.Kind, Name: q.After.Name,
+		Labels: q.After.Labels}) > 0
+}
+
+// NewListPage returns page of first limit metrics of sorted metrics. Cursor of next page is set
+// if there are more metrics.
+func NewListPage(metrics []Metric, limit int) ListPage {
+	if len(metrics) <= limit {
+		return ListPage{Metrics: metrics}
+	}
+	last := metrics[limit-1]
+	return ListPage{
+		Metrics: metrics[:limit],
+		Next:    ListCursor{Kind: last.Kind, Name: last.Name, Labels: last.Labels.Normalize()}.String(),
+	}
+}
+
+// String returns opaque cursor accepted by ParseListCursor.
+func (c ListCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParseListCursor parses cursor returned by ListCursor.String.
+func ParseListCursor(s string) (*ListCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: cursor '%s'", ErrInvalidQuery, s)
+	}
+	c := new(ListCursor)
+	if err = json.Unmarshal(b, c); err != nil {
+		return nil, fmt.Errorf("%w: cursor '%s'", ErrInvalidQuery, s)
+	}
+	c.Labels = c.Labels.Normalize()
+	return c, nil
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNewListQuery(t *testing.T) {
+	cursor := ListCursor{Kind: MetricKindGauge, Name: "test1", Labels: Labels{"host": "a"}}.String()
+	tests := []struct {
+		name      string
+		kind      string
+		regex     string
+		cursor    string
+		limit     int
+		wantLimit int
+		wantErr   bool
+	}{
+		{name: "default limit", wantLimit: DefaultListLimit},
+		{name: "max limit", limit: MaxListLimit + 1, wantLimit: MaxListLimit},
+		{name: "all parameters", kind: MetricKindSummary, regex: "test.*", cursor: cursor, limit: 5, wantLimit: 5},
+		{name: "negative limit", limit: -1, wantErr: true},
+		{name: "invalid kind", kind: "preved", wantErr: true},
+		{name: "invalid regex", regex: "(", wantErr: true},
+		{name: "invalid cursor", cursor: "preved", wantErr: true},
+		{name: "cursor is not JSON", cursor: "cHJldmVk", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewListQuery(tt.kind, "", tt.regex, tt.cursor, tt.limit)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidQuery) {
+					t.Errorf("NewListQuery() error = %v, want %v", err, ErrInvalidQuery)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewListQuery() error = %v", err)
+			}
+			if got.Limit != tt.wantLimit {
+				t.Errorf("NewListQuery() limit = %d, want %d", got.Limit, tt.wantLimit)
+			}
+			if tt.cursor != "" && !reflect.DeepEqual(*got.After, ListCursor{Kind: MetricKindGauge, Name: "test1",
+				Labels: Labels{"host": "a"}}) {
+				t.Errorf("NewListQuery() cursor = %v", got.After)
+			}
+		})
+	}
+}
+
+func TestListQuery_Matches(t *testing.T) {
+	query, err := NewListQuery(MetricKindGauge, "test", "test[0-9]", "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query.After = &ListCursor{Kind: MetricKindGauge, Name: "test1", Labels: Labels{"host": "a"}}
+	tests := []struct {
+		metric Metric
+		want   bool
+	}{
+		{metric: Metric{Kind: MetricKindGauge, Name: "test2"}, want: true},
+		{metric: Metric{Kind: MetricKindGauge, Name: "test1", Labels: Labels{"host": "b"}}, want: true},
+		{metric: Metric{Kind: MetricKindGauge, Name: "test1", Labels: Labels{"host": "a"}}},
+		{metric: Metric{Kind: MetricKindGauge, Name: "test1"}},
+		{metric: Metric{Kind: MetricKindCounter, Name: "test2"}},
+		{metric: Metric{Kind: MetricKindGauge, Name: "test22"}},
+		{metric: Metric{Kind: MetricKindGauge, Name: "other2"}},
+	}
+	for _, tt := range tests {
+		if got := query.Matches(tt.metric); got != tt.want {
+			t.Errorf("Matches(%v) = %v, want %v", tt.metric, got, tt.want)
+		}
+	}
+}
+
+func TestNewListPage(t *testing.T) {
+	metrics := []Metric{
+		{Kind: MetricKindGauge, Name: "test1"},
+		{Kind: MetricKindGauge, Name: "test2", Labels: Labels{"host": "a"}},
+		{Kind: MetricKindGauge, Name: "test3"},
+	}
+	page := NewListPage(metrics, 2)
+	if len(page.Metrics) != 2 {
+		t.Fatalf("NewListPage() metrics = %v", page.Metrics)
+	}
+	cursor, err := ParseListCursor(page.Next)
+	if err != nil {
+		t.Fatalf("ParseListCursor() error = %v", err)
+	}
+	if want := (ListCursor{Kind: MetricKindGauge, Name: "test2", Labels: Labels{"host": "a"}}); !reflect.DeepEqual(
+		*cursor, want) {
+		t.Errorf("NewListPage() cursor = %v, want %v", *cursor, want)
+	}
+	if page = NewListPage(metrics, 3); page.Next != "" {
+		t.Errorf("NewListPage() of last page cursor = %q, want empty", page.Next)
+	}
+}
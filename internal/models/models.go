@@ -1,10 +1,13 @@
 package models
 
 import (
+	"maps"
 	"runtime"
+	"slices"
 	"strconv"
 
 	pb "github.com/sejo412/ya-metrics/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 // RuntimeMetricsMap returns mapping for runtime metrics.
@@ -52,22 +55,24 @@ func PSMetricsCPU(c []float64) map[string]float64 {
 // ConvertV1ToV2 converts V1 api to V2 for backward compatibility.
 func ConvertV1ToV2(m *Metric) (*MetricV2, error) {
 	res := &MetricV2{
-		ID:    m.Name,
-		MType: m.Kind,
+		ID:          m.Name,
+		MType:       m.Kind,
+		Labels:      m.Labels.Normalize(),
+		Unit:        m.Unit,
+		Description: m.Description,
 	}
 	switch m.Kind {
-	case "counter":
-		v, err := strconv.ParseInt(m.Value, base10, metricBitSize)
-		if err != nil {
-			return nil, ErrNotInteger
-		}
-		res.Delta = &v
-	case "gauge":
-		v, err := strconv.ParseFloat(m.Value, metricBitSize)
-		if err != nil {
-			return nil, ErrNotFloat
-		}
-		res.Value = &v
+	case MetricKindCounter:
+		delta := m.Delta
+		res.Delta = &delta
+	case MetricKindGauge:
+		value := m.Value
+		res.Value = &value
+	case MetricKindHistogram:
+		res.Histogram = m.Histogram.Clone()
+	case MetricKindSummary:
+		res.Summary = m.Summary.Clone()
+		res.Quantiles = m.Summary.Quantiles()
 	}
 	return res, nil
 }
@@ -75,36 +80,103 @@ func ConvertV1ToV2(m *Metric) (*MetricV2, error) {
 // ConvertV2ToV1 converts V2 api to V1 for backward compatibility.
 func ConvertV2ToV1(m *MetricV2) (*Metric, error) {
 	metric := &Metric{
-		Kind: m.MType,
-		Name: m.ID,
+		Kind:        m.MType,
+		Name:        m.ID,
+		Labels:      m.Labels.Normalize(),
+		Unit:        m.Unit,
+		Description: m.Description,
 	}
 	switch m.MType {
-	case "counter":
-		metric.Value = strconv.FormatInt(*m.Delta, base10)
-	case "gauge":
-		metric.Value = strconv.FormatFloat(*m.Value, 'f', -1, metricBitSize)
+	case MetricKindCounter:
+		if m.Delta == nil {
+			return nil, ErrNotInteger
+		}
+		metric.Delta = *m.Delta
+	case MetricKindGauge:
+		if m.Value == nil {
+			return nil, ErrNotFloat
+		}
+		metric.Value = *m.Value
+	case MetricKindHistogram:
+		if err := m.Histogram.Validate(); err != nil {
+			return nil, err
+		}
+		metric.Histogram = m.Histogram.Clone()
+	case MetricKindSummary:
+		if err := m.Summary.Validate(); err != nil {
+			return nil, err
+		}
+		metric.Summary = m.Summary.Clone()
 	}
 	return metric, nil
 }
 
-// ConvertPbToV1 converts protobuf type to V1.
-func ConvertPbToV1(m *pb.Metric) Metric {
-	var mType string
-	var value string
-
-	switch *m.Type {
+// ConvertPbKindToV1 converts protobuf metric type to V1 kind.
+func ConvertPbKindToV1(t pb.MType) string {
+	switch t {
 	case pb.MType_GAUGE:
-		mType = "gauge"
-		value = strconv.FormatFloat(m.GetValue(), 'f', -1, metricBitSize)
+		return MetricKindGauge
 	case pb.MType_COUNTER:
-		mType = "counter"
-		value = strconv.FormatInt(m.GetDelta(), base10)
+		return MetricKindCounter
+	case pb.MType_HISTOGRAM:
+		return MetricKindHistogram
+	case pb.MType_SUMMARY:
+		return MetricKindSummary
+	default:
+		return t.String()
+	}
+}
+
+// ConvertPbToCondition returns condition of protobuf update request.
+func ConvertPbToCondition(in *pb.UpdateMetricRequest) Condition {
+	var cond Condition
+	switch in.GetMode() {
+	case pb.UpdateMode_SET:
+		cond.Mode = UpdateModeSet
+	case pb.UpdateMode_MAX:
+		cond.Mode = UpdateModeMax
+	case pb.UpdateMode_MIN:
+		cond.Mode = UpdateModeMin
+	default:
+		cond.Mode = in.GetMode().String()
+	}
+	if in.IfVersion != nil {
+		version := in.GetIfVersion()
+		cond.Version = &version
 	}
-	return Metric{
-		Kind:  mType,
-		Name:  *m.Id,
-		Value: value,
+	return cond
+}
+
+// ConvertPbToV1 converts protobuf type to V1.
+func ConvertPbToV1(m *pb.Metric) Metric {
+	res := Metric{
+		Kind:        ConvertPbKindToV1(m.GetType()),
+		Name:        m.GetId(),
+		Delta:       m.GetDelta(),
+		Value:       m.GetValue(),
+		Labels:      Labels(m.GetLabels()).Normalize(),
+		Unit:        m.GetUnit(),
+		Description: m.GetDescription(),
+	}
+	if h := m.GetHistogram(); h != nil {
+		res.Histogram = &Histogram{
+			Bounds: slices.Clone(h.GetBounds()),
+			Counts: slices.Clone(h.GetCounts()),
+			Sum:    h.GetSum(),
+		}
 	}
+	if s := m.GetSummary(); s != nil {
+		res.Summary = &Summary{
+			Positive: maps.Clone(s.GetPositive()),
+			Negative: maps.Clone(s.GetNegative()),
+			Accuracy: s.GetAccuracy(),
+			Zero:     s.GetZero(),
+			Sum:      s.GetSum(),
+			Min:      s.GetMin(),
+			Max:      s.GetMax(),
+		}
+	}
+	return res
 }
 
 // ConvertPbsToV1s converts protobuf type to V1 slices.
@@ -120,31 +192,66 @@ func ConvertPbsToV1s(m []*pb.Metric) []Metric {
 func ConvertV1ToPb(m Metric) (*pb.Metric, error) {
 	res := new(pb.Metric)
 	res.Id = &m.Name
-	res.Type, res.Delta, res.Value = nil, nil, nil
+	res.Labels = m.Labels.Normalize()
 	switch m.Kind {
-	case "counter":
+	case MetricKindCounter:
 		mType := pb.MType_COUNTER
-		delta, err := strconv.Atoi(m.Value)
-		value := int64(delta)
-		if err != nil {
-			return nil, ErrNotInteger
-		}
 		res.Type = &mType
-		res.Delta = &value
-	case "gauge":
+		res.Delta = &m.Delta
+	case MetricKindGauge:
 		mType := pb.MType_GAUGE
-		value, err := strconv.ParseFloat(m.Value, metricBitSize)
-		if err != nil {
-			return nil, ErrNotFloat
+		res.Type = &mType
+		res.Value = &m.Value
+	case MetricKindHistogram:
+		if m.Histogram == nil {
+			return nil, ErrInvalidHistogram
 		}
+		mType := pb.MType_HISTOGRAM
+		sum := m.Histogram.Sum
 		res.Type = &mType
-		res.Value = &value
+		res.Histogram = &pb.Histogram{
+			Bounds: slices.Clone(m.Histogram.Bounds),
+			Counts: slices.Clone(m.Histogram.Counts),
+			Sum:    &sum,
+		}
+	case MetricKindSummary:
+		if m.Summary == nil {
+			return nil, ErrInvalidSummary
+		}
+		mType := pb.MType_SUMMARY
+		s := m.Summary.Clone()
+		res.Type = &mType
+		res.Summary = &pb.Summary{
+			Positive:  s.Positive,
+			Negative:  s.Negative,
+			Accuracy:  &s.Accuracy,
+			Zero:      &s.Zero,
+			Sum:       &s.Sum,
+			Min:       &s.Min,
+			Max:       &s.Max,
+			Quantiles: s.Quantiles(),
+		}
 	default:
 		return nil, ErrNotSupported
 	}
+	if m.Unit != "" {
+		res.Unit = &m.Unit
+	}
+	if m.Description != "" {
+		res.Description = &m.Description
+	}
 	return res, nil
 }
 
+// ConvertMetadataToPb converts metadata to protobuf type.
+func ConvertMetadataToPb(m Metadata) *pb.MetricMetadata {
+	res := &pb.MetricMetadata{UpdatedAt: timestamppb.New(m.UpdatedAt), Version: &m.Version}
+	if m.Source != "" {
+		res.Source = &m.Source
+	}
+	return res
+}
+
 // ConvertV1sToPbs converts V1 metric slice to protobuf slice type.
 func ConvertV1sToPbs(m []Metric) ([]*pb.Metric, error) {
 	var err error
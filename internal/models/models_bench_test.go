@@ -0,0 +1,78 @@
+package models
+
+import (
+	"strconv"
+	"testing"
+)
+
+// stringMetric is previous Metric representation with value stored as string, kept for comparison.
+type stringMetric struct {
+	Kind  string
+	Name  string
+	Value string
+}
+
+// stringConvertV1ToV2 is previous ConvertV1ToV2 implementation which parses value on every call.
+func stringConvertV1ToV2(m *stringMetric) (*MetricV2, error) {
+	res := &MetricV2{ID: m.Name, MType: m.Kind}
+	switch m.Kind {
+	case MetricKindGauge:
+		value, err := strconv.ParseFloat(m.Value, 64)
+		if err != nil {
+			return nil, ErrNotFloat
+		}
+		res.Value = &value
+	case MetricKindCounter:
+		delta, err := strconv.ParseInt(m.Value, 10, 64)
+		if err != nil {
+			return nil, ErrNotInteger
+		}
+		res.Delta = &delta
+	}
+	return res, nil
+}
+
+func BenchmarkConvertV1ToV2(b *testing.B) {
+	b.Run("string value", func(b *testing.B) {
+		metrics := []stringMetric{
+			{Kind: MetricKindGauge, Name: "Alloc", Value: "123456.789"},
+			{Kind: MetricKindCounter, Name: "PollCount", Value: "9007199254740993"},
+		}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = stringConvertV1ToV2(&metrics[i%len(metrics)])
+		}
+	})
+	b.Run("typed value", func(b *testing.B) {
+		metrics := []Metric{
+			{Kind: MetricKindGauge, Name: "Alloc", Value: 123456.789},
+			{Kind: MetricKindCounter, Name: "PollCount", Delta: 9007199254740993},
+		}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, _ = ConvertV1ToV2(&metrics[i%len(metrics)])
+		}
+	})
+}
+
+// BenchmarkCounterAccumulate compares adding delta to stored counter, as storages do on every upsert.
+func BenchmarkCounterAccumulate(b *testing.B) {
+	b.Run("string value", func(b *testing.B) {
+		stored := stringMetric{Kind: MetricKindCounter, Name: "PollCount", Value: "0"}
+		update := stringMetric{Kind: MetricKindCounter, Name: "PollCount", Value: "1"}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			oldValue, _ := strconv.Atoi(stored.Value)
+			delta, _ := strconv.Atoi(update.Value)
+			stored.Value = strconv.Itoa(oldValue + delta)
+		}
+	})
+	b.Run("typed value", func(b *testing.B) {
+		stored := Metric{Kind: MetricKindCounter, Name: "PollCount"}
+		update := Metric{Kind: MetricKindCounter, Name: "PollCount", Delta: 1}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			stored.Delta += update.Delta
+		}
+	})
+}
@@ -32,7 +32,7 @@ func TestConvertV1ToV2(t *testing.T) {
 				m: &Metric{
 					Kind:  MetricKindGauge,
 					Name:  "gauge1",
-					Value: "99.9",
+					Value: 99.9,
 				},
 			},
 			want: &MetricV2{
@@ -42,25 +42,13 @@ func TestConvertV1ToV2(t *testing.T) {
 			},
 			wantErr: false,
 		},
-		{
-			name: "gauge error",
-			args: args{
-				m: &Metric{
-					Kind:  MetricKindGauge,
-					Name:  "gauge2",
-					Value: "99.z",
-				},
-			},
-			want:    nil,
-			wantErr: true,
-		},
 		{
 			name: "counter ok",
 			args: args{
 				m: &Metric{
 					Kind:  MetricKindCounter,
 					Name:  "counter1",
-					Value: "99",
+					Delta: 99,
 				},
 			},
 			want: &MetricV2{
@@ -71,16 +59,24 @@ func TestConvertV1ToV2(t *testing.T) {
 			wantErr: false,
 		},
 		{
-			name: "counter error",
+			name: "unit and description",
 			args: args{
 				m: &Metric{
-					Kind:  MetricKindCounter,
-					Name:  "counter2",
-					Value: "99z",
+					Kind:        MetricKindGauge,
+					Name:        "gauge1",
+					Value:       1024,
+					Unit:        "bytes",
+					Description: "heap size",
 				},
 			},
-			want:    nil,
-			wantErr: true,
+			want: &MetricV2{
+				ID:          "gauge1",
+				MType:       MetricKindGauge,
+				Value:       floatToPointer(1024),
+				Unit:        "bytes",
+				Description: "heap size",
+			},
+			wantErr: false,
 		},
 	}
 	for _, tt := range tests {
@@ -119,7 +115,7 @@ func TestConvertV2ToV1(t *testing.T) {
 			want: &Metric{
 				Kind:  MetricKindGauge,
 				Name:  "gauge1",
-				Value: "99.9",
+				Value: 99.9,
 			},
 			wantErr: false,
 		},
@@ -135,10 +131,32 @@ func TestConvertV2ToV1(t *testing.T) {
 			want: &Metric{
 				Kind:  MetricKindCounter,
 				Name:  "counter1",
-				Value: "99",
+				Delta: 99,
 			},
 			wantErr: false,
 		},
+		{
+			name: "counter without delta",
+			args: args{
+				m: &MetricV2{
+					ID:    "counter2",
+					MType: MetricKindCounter,
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
+		{
+			name: "gauge without value",
+			args: args{
+				m: &MetricV2{
+					ID:    "gauge2",
+					MType: MetricKindGauge,
+				},
+			},
+			want:    nil,
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
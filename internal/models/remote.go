@@ -0,0 +1,85 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/sejo412/ya-metrics/proto/prompb"
+)
+
+// Prometheus specific constants.
+const (
+	// PrometheusLabelName - label with metric name of series.
+	PrometheusLabelName string = "__name__"
+	// prometheusStaleNaN - NaN marking series as stale in Prometheus.
+	prometheusStaleNaN uint64 = 0x7ff0000000000002
+)
+
+// prometheusSuffixes - suffixes of series of metric family, e.g. http_requests_total of http_requests.
+var prometheusSuffixes = []string{"_total", "_bucket", "_sum", "_count", "_created", "_info"}
+
+// ConvertWriteRequestToV1 returns gauges with value of latest sample of every series of Prometheus remote write.
+//
+// Samples are absolute values even for counters, so all series are gauges. Labels with empty values are
+// skipped as Prometheus does, help and unit of metric family from metadata of request are description and
+// unit of its series. Series with only stale samples are skipped.
+func ConvertWriteRequestToV1(req *prompb.WriteRequest) ([]Metric, error) {
+	metadata := make(map[string]*prompb.MetricMetadata, len(req.GetMetadata()))
+	for _, m := range req.GetMetadata() {
+		metadata[m.GetMetricFamilyName()] = m
+	}
+	res := make([]Metric, 0, len(req.GetTimeseries()))
+	for _, series := range req.GetTimeseries() {
+		metric := Metric{Kind: MetricKindGauge}
+		for _, label := range series.GetLabels() {
+			switch {
+			case label.GetName() == PrometheusLabelName:
+				metric.Name = label.GetValue()
+			case label.GetValue() == "":
+			default:
+				if metric.Labels == nil {
+					metric.Labels = make(Labels, len(series.GetLabels()))
+				}
+				metric.Labels[label.GetName()] = label.GetValue()
+			}
+		}
+		if metric.Name == "" {
+			return nil, fmt.Errorf("%w: series %s without name", ErrInvalidRemoteWrite, metric.Labels)
+		}
+		if err := metric.Labels.Validate(); err != nil {
+			return nil, err
+		}
+		latest := int64(math.MinInt64)
+		for _, sample := range series.GetSamples() {
+			if math.Float64bits(sample.GetValue()) == prometheusStaleNaN || sample.GetTimestamp() < latest {
+				continue
+			}
+			latest = sample.GetTimestamp()
+			metric.Value = sample.GetValue()
+		}
+		if latest == math.MinInt64 {
+			continue
+		}
+		if m := familyMetadata(metadata, metric.Name); m != nil {
+			metric.Description, metric.Unit = m.GetHelp(), m.GetUnit()
+		}
+		res = append(res, metric)
+	}
+	return res, nil
+}
+
+// familyMetadata returns metadata of metric family of series name, nil if there is no metadata.
+func familyMetadata(metadata map[string]*prompb.MetricMetadata, name string) *prompb.MetricMetadata {
+	if m, ok := metadata[name]; ok {
+		return m
+	}
+	for _, suffix := range prometheusSuffixes {
+		if family, ok := strings.CutSuffix(name, suffix); ok {
+			if m, ok := metadata[family]; ok {
+				return m
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/sejo412/ya-metrics/proto/prompb"
+)
+
+// newSeries returns series with labels as name and value pairs.
+func newSeries(labels []string, samples ...*prompb.Sample) *prompb.TimeSeries {
+	series := &prompb.TimeSeries{Samples: samples}
+	for i := 0; i+1 < len(labels); i += 2 {
+		series.Labels = append(series.Labels, &prompb.Label{Name: &labels[i], Value: &labels[i+1]})
+	}
+	return series
+}
+
+// newSample returns sample with value at timestamp in milliseconds.
+func newSample(value float64, ts int64) *prompb.Sample {
+	return &prompb.Sample{Value: &value, Timestamp: &ts}
+}
+
+func TestConvertWriteRequestToV1(t *testing.T) {
+	counter := prompb.MetricMetadata_COUNTER
+	family, help, unit := "http_requests", "Count of requests.", "requests"
+	tests := []struct {
+		name    string
+		req     *prompb.WriteRequest
+		want    []Metric
+		wantErr error
+	}{
+		{
+			name: "latest sample and metadata of family",
+			req: &prompb.WriteRequest{
+				Timeseries: []*prompb.TimeSeries{
+					newSeries([]string{"__name__", "http_requests_total", "code", "200", "empty", ""},
+						newSample(2, 2000), newSample(1, 1000)),
+				},
+				Metadata: []*prompb.MetricMetadata{
+					{Type: &counter, MetricFamilyName: &family, Help: &help, Unit: &unit},
+				},
+			},
+			want: []Metric{
+				{Kind: MetricKindGauge, Name: "http_requests_total", Value: 2, Labels: Labels{"code": "200"},
+					Description: help, Unit: unit},
+			},
+		},
+		{
+			name: "stale samples are skipped",
+			req: &prompb.WriteRequest{
+				Timeseries: []*prompb.TimeSeries{
+					newSeries([]string{"__name__", "up"}, newSample(1, 1000),
+						newSample(math.Float64frombits(prometheusStaleNaN), 2000)),
+					newSeries([]string{"__name__", "gone"}, newSample(math.Float64frombits(prometheusStaleNaN), 2000)),
+					newSeries([]string{"__name__", "empty"}),
+				},
+			},
+			want: []Metric{{Kind: MetricKindGauge, Name: "up", Value: 1}},
+		},
+		{
+			name: "series without name",
+			req: &prompb.WriteRequest{
+				Timeseries: []*prompb.TimeSeries{newSeries([]string{"job", "node"}, newSample(1, 1000))},
+			},
+			wantErr: ErrInvalidRemoteWrite,
+		},
+		{
+			name: "invalid label name",
+			req: &prompb.WriteRequest{
+				Timeseries: []*prompb.TimeSeries{newSeries([]string{"__name__", "up", "a-b", "c"}, newSample(1, 1000))},
+			},
+			wantErr: ErrInvalidLabel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertWriteRequestToV1(tt.req)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ConvertWriteRequestToV1() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertWriteRequestToV1() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ConvertWriteRequestToV1() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package models
+
+import "context"
+
+// sourceKey is context key for address of metrics source.
+type sourceKey struct{}
+
+// WithSource returns context carrying address of client which sends metrics.
+func WithSource(ctx context.Context, source string) context.Context {
+	return context.WithValue(ctx, sourceKey{}, source)
+}
+
+// SourceFromContext returns address of client which sends metrics, empty if unknown or ctx is nil.
+func SourceFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	source, _ := ctx.Value(sourceKey{}).(string)
+	return source
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Types of StatsD metrics.
+const (
+	StatsDCounter   string = "c"  // value is added to counter
+	StatsDGauge     string = "g"  // value sets gauge, value with sign is added to gauge
+	StatsDTimer     string = "ms" // value is observed by summary
+	StatsDHistogram string = "h"  // the same as timer
+)
+
+// StatsDSample describes metric value parsed from StatsD line.
+type StatsDSample struct {
+	// Labels - tags of line, nil if line has no tags
+	Labels Labels
+	// Name - metric name
+	Name string
+	// Type - type of metric, one of StatsD types
+	Type string
+	// Value - value of metric
+	Value float64
+	// Rate - sample rate between 0 and 1, counter value is scaled by 1/Rate
+	Rate float64
+	// Relative - gauge value has sign and is added to gauge
+	Relative bool
+}
+
+// ParseStatsDLine parses StatsD line like "requests:1|c|@0.5|#host:a". Tags are DogStatsD extension,
+// tag without value is invalid because labels need values. Unknown extensions of line are ignored.
+func ParseStatsDLine(line string) (StatsDSample, error) {
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" {
+		return StatsDSample{}, fmt.Errorf("%w: '%s'", ErrInvalidStatsD, line)
+	}
+	parts := strings.Split(rest, "|")
+	if len(parts) < 2 {
+		return StatsDSample{}, fmt.Errorf("%w: '%s'", ErrInvalidStatsD, line)
+	}
+	sample := StatsDSample{Name: name, Type: parts[1], Rate: 1}
+	switch sample.Type {
+	case StatsDCounter, StatsDGauge, StatsDTimer, StatsDHistogram:
+	default:
+		return StatsDSample{}, fmt.Errorf("%w: type '%s'", ErrNotSupported, sample.Type)
+	}
+	value, err := strconv.ParseFloat(parts[0], metricBitSize)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return StatsDSample{}, fmt.Errorf("%w: %w: '%s'", ErrInvalidStatsD, ErrNotFloat, parts[0])
+	}
+	sample.Value = value
+	sample.Relative = sample.Type == StatsDGauge && (parts[0][0] == '+' || parts[0][0] == '-')
+	for _, ext := range parts[2:] {
+		switch {
+		case strings.HasPrefix(ext, "@"):
+			rate, err := strconv.ParseFloat(ext[1:], metricBitSize)
+			if err != nil || !(rate > 0 && rate <= 1) {
+				return StatsDSample{}, fmt.Errorf("%w: sample rate '%s'", ErrInvalidStatsD, ext[1:])
+			}
+			sample.Rate = rate
+		case strings.HasPrefix(ext, "#"):
+			if sample.Labels, err = parseStatsDTags(ext[1:]); err != nil {
+				return StatsDSample{}, err
+			}
+		}
+	}
+	return sample, nil
+}
+
+// parseStatsDTags parses comma separated tags like "host:a,env:prod" to labels.
+func parseStatsDTags(s string) (Labels, error) {
+	labels := make(Labels)
+	for _, tag := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(tag, ":")
+		if !ok {
+			return nil, fmt.Errorf("%w: tag '%s' without value", ErrInvalidLabel, tag)
+		}
+		labels[name] = value
+	}
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseStatsDLine(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    StatsDSample
+		wantErr error
+	}{
+		{
+			name: "counter",
+			line: "requests:2|c",
+			want: StatsDSample{Name: "requests", Type: StatsDCounter, Value: 2, Rate: 1},
+		},
+		{
+			name: "counter with sample rate and tags",
+			line: "requests:1|c|@0.1|#host:a,env:prod",
+			want: StatsDSample{Name: "requests", Type: StatsDCounter, Value: 1, Rate: 0.1,
+				Labels: Labels{"host": "a", "env": "prod"}},
+		},
+		{
+			name: "gauge",
+			line: "temp:21.5|g",
+			want: StatsDSample{Name: "temp", Type: StatsDGauge, Value: 21.5, Rate: 1},
+		},
+		{
+			name: "relative gauge",
+			line: "temp:-1.5|g",
+			want: StatsDSample{Name: "temp", Type: StatsDGauge, Value: -1.5, Rate: 1, Relative: true},
+		},
+		{
+			name: "timer with unknown extension",
+			line: "latency:320|ms|T1700000000",
+			want: StatsDSample{Name: "latency", Type: StatsDTimer, Value: 320, Rate: 1},
+		},
+		{
+			name:    "set",
+			line:    "users:alice|s",
+			wantErr: ErrNotSupported,
+		},
+		{
+			name:    "no type",
+			line:    "requests:1",
+			wantErr: ErrInvalidStatsD,
+		},
+		{
+			name:    "no value",
+			line:    "requests",
+			wantErr: ErrInvalidStatsD,
+		},
+		{
+			name:    "invalid value",
+			line:    "requests:one|c",
+			wantErr: ErrNotFloat,
+		},
+		{
+			name:    "invalid sample rate",
+			line:    "requests:1|c|@2",
+			wantErr: ErrInvalidStatsD,
+		},
+		{
+			name:    "tag without value",
+			line:    "requests:1|c|#canary",
+			wantErr: ErrInvalidLabel,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseStatsDLine(tt.line)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ParseStatsDLine() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseStatsDLine() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseStatsDLine() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package models
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Summary settings.
+const (
+	DefaultSummaryAccuracy float64 = 0.01 // default relative accuracy of quantiles
+	MaxSummaryBuckets      int     = 2048 // max buckets of positive or negative values, lowest ones are collapsed
+)
+
+// SummaryQuantiles - quantiles reported for summaries.
+var SummaryQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// Summary is mergeable quantile sketch (DDSketch) of observed values.
+//
+// Value v is counted in bucket i with gamma^(i-1) < |v| <= gamma^i, where gamma = (1+Accuracy)/(1-Accuracy),
+// so quantiles estimated by bucket have relative error not greater than Accuracy. Sketches with the same
+// accuracy are merged by adding counts of buckets.
+type Summary struct {
+	// Positive - count of positive observations by bucket index
+	Positive map[int32]uint64 `json:"positive,omitempty"`
+	// Negative - count of negative observations by bucket index of absolute value
+	Negative map[int32]uint64 `json:"negative,omitempty"`
+	// Accuracy - relative accuracy of quantiles between 0 and 1
+	Accuracy float64 `json:"accuracy"`
+	// Zero - count of zero observations
+	Zero uint64 `json:"zero,omitempty"`
+	// Sum - sum of observed values
+	Sum float64 `json:"sum"`
+	// Min - minimal observed value, zero if there are no observations
+	Min float64 `json:"min"`
+	// Max - maximal observed value, zero if there are no observations
+	Max float64 `json:"max"`
+}
+
+// NewSummary returns empty summary with relative accuracy.
+func NewSummary(accuracy float64) *Summary {
+	return &Summary{
+		Positive: make(map[int32]uint64),
+		Negative: make(map[int32]uint64),
+		Accuracy: accuracy,
+	}
+}
+
+// Observe adds value to summary. NaN and infinite values are ignored.
+func (s *Summary) Observe(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+	if s.Count() == 0 {
+		s.Min, s.Max = value, value
+	} else {
+		s.Min, s.Max = min(s.Min, value), max(s.Max, value)
+	}
+	switch {
+	case value > 0:
+		s.Positive = addSummaryBucket(s.Positive, s.index(value), 1)
+		collapseSummaryBuckets(s.Positive)
+	case value < 0:
+		s.Negative = addSummaryBucket(s.Negative, s.index(-value), 1)
+		collapseSummaryBuckets(s.Negative)
+	default:
+		s.Zero++
+	}
+	s.Sum += value
+}
+
+// Count returns total count of observations.
+func (s *Summary) Count() uint64 {
+	count := s.Zero
+	for _, c := range s.Positive {
+		count += c
+	}
+	for _, c := range s.Negative {
+		count += c
+	}
+	return count
+}
+
+// Quantile returns estimated value of quantile q between 0 and 1, NaN if there are no observations.
+// Quantile is estimated by nearest rank, so it is value of ceil(q*count)-th observation.
+func (s *Summary) Quantile(q float64) float64 {
+	count := s.Count()
+	if count == 0 || math.IsNaN(q) {
+		return math.NaN()
+	}
+	rank := max(uint64(math.Ceil(min(max(q, 0), 1)*float64(count))), 1)
+	var cumulative uint64
+	// negative values from the lowest one, so buckets of greater absolute values go first
+	negative := slices.Sorted(maps.Keys(s.Negative))
+	for i := len(negative) - 1; i >= 0; i-- {
+		cumulative += s.Negative[negative[i]]
+		if cumulative >= rank {
+			return s.clamp(-s.value(negative[i]))
+		}
+	}
+	cumulative += s.Zero
+	if cumulative >= rank {
+		return s.clamp(0)
+	}
+	for _, i := range slices.Sorted(maps.Keys(s.Positive)) {
+		cumulative += s.Positive[i]
+		if cumulative >= rank {
+			return s.clamp(s.value(i))
+		}
+	}
+	return s.Max
+}
+
+// Validate returns error if accuracy is not between 0 and 1, sketch has too many buckets
+// or sum, min and max are not numbers.
+func (s *Summary) Validate() error {
+	if s == nil {
+		return fmt.Errorf("%w: no sketch", ErrInvalidSummary)
+	}
+	if !(s.Accuracy > 0 && s.Accuracy < 1) {
+		return fmt.Errorf("%w: accuracy %v", ErrInvalidSummary, s.Accuracy)
+	}
+	if len(s.Positive) > MaxSummaryBuckets || len(s.Negative) > MaxSummaryBuckets {
+		return fmt.Errorf("%w: more than %d buckets", ErrInvalidSummary, MaxSummaryBuckets)
+	}
+	if math.IsNaN(s.Sum) || math.IsNaN(s.Min) || math.IsNaN(s.Max) {
+		return fmt.Errorf("%w: sum, min or max is NaN", ErrInvalidSummary)
+	}
+	if s.Min > s.Max {
+		return fmt.Errorf("%w: min is greater than max", ErrInvalidSummary)
+	}
+	return nil
+}
+
+// Clone returns deep copy of summary.
+func (s *Summary) Clone() *Summary {
+	if s == nil {
+		return nil
+	}
+	res := *s
+	res.Positive = maps.Clone(s.Positive)
+	res.Negative = maps.Clone(s.Negative)
+	return &res
+}
+
+// Merge returns summary with observations of both summaries.
+// Summary with other accuracy replaces previous one, so reporter may change accuracy.
+func (s *Summary) Merge(other *Summary) *Summary {
+	if s == nil || s.Accuracy != other.Accuracy {
+		return other.Clone()
+	}
+	if other.Count() == 0 {
+		return s.Clone()
+	}
+	res := s.Clone()
+	if s.Count() == 0 {
+		res.Min, res.Max = other.Min, other.Max
+	} else {
+		res.Min, res.Max = min(s.Min, other.Min), max(s.Max, other.Max)
+	}
+	for i, c := range other.Positive {
+		res.Positive = addSummaryBucket(res.Positive, i, c)
+	}
+	for i, c := range other.Negative {
+		res.Negative = addSummaryBucket(res.Negative, i, c)
+	}
+	collapseSummaryBuckets(res.Positive)
+	collapseSummaryBuckets(res.Negative)
+	res.Zero += other.Zero
+	res.Sum += other.Sum
+	return res
+}
+
+// String returns count, sum, min, max and quantiles of summary like
+// "count=3 sum=0.6 min=0.1 max=0.3 p50=0.2 p90=0.298 p95=0.298 p99=0.298".
+func (s *Summary) String() string {
+	var sb strings.Builder
+	count := s.Count()
+	sb.WriteString("count=" + strconv.FormatUint(count, base10) + " sum=" + RoundFloatToString(s.Sum))
+	if count == 0 {
+		return sb.String()
+	}
+	sb.WriteString(" min=" + RoundFloatToString(s.Min) + " max=" + RoundFloatToString(s.Max))
+	for _, q := range SummaryQuantiles {
+		sb.WriteString(" " + QuantileName(q) + "=" + RoundFloatToString(s.Quantile(q)))
+	}
+	return sb.String()
+}
+
+// Quantiles returns values of SummaryQuantiles by quantile names, nil if there are no observations.
+func (s *Summary) Quantiles() map[string]float64 {
+	if s.Count() == 0 {
+		return nil
+	}
+	res := make(map[string]float64, len(SummaryQuantiles))
+	for _, q := range SummaryQuantiles {
+		res[QuantileName(q)] = s.Quantile(q)
+	}
+	return res
+}
+
+// QuantileName returns name of quantile like "p99" for 0.99.
+func QuantileName(q float64) string {
+	// rounding hides float error like 0.29*100 = 28.999999999999996
+	return "p" + strconv.FormatFloat(math.Round(q*1e4)/1e2, 'g', -1, metricBitSize)
+}
+
+// gamma returns base of bucket bounds.
+func (s *Summary) gamma() float64 {
+	return (1 + s.Accuracy) / (1 - s.Accuracy)
+}
+
+// index returns index of bucket for positive value.
+func (s *Summary) index(value float64) int32 {
+	return int32(math.Ceil(math.Log(value) / math.Log(s.gamma())))
+}
+
+// value returns estimated value of bucket, relative error is not greater than accuracy
+// for any value of bucket.
+func (s *Summary) value(index int32) float64 {
+	gamma := s.gamma()
+	return 2 * math.Pow(gamma, float64(index)) / (gamma + 1)
+}
+
+// clamp returns value limited by observed min and max.
+func (s *Summary) clamp(value float64) float64 {
+	return min(max(value, s.Min), s.Max)
+}
+
+// addSummaryBucket adds count to bucket, creates buckets if nil.
+func addSummaryBucket(buckets map[int32]uint64, index int32, count uint64) map[int32]uint64 {
+	if buckets == nil {
+		buckets = make(map[int32]uint64)
+	}
+	buckets[index] += count
+	return buckets
+}
+
+// collapseSummaryBuckets merges lowest buckets into one, so there are no more than MaxSummaryBuckets.
+// Accuracy of quantiles is lost for the lowest absolute values only.
+func collapseSummaryBuckets(buckets map[int32]uint64) {
+	if len(buckets) <= MaxSummaryBuckets {
+		return
+	}
+	indexes := slices.Sorted(maps.Keys(buckets))
+	excess := len(indexes) - MaxSummaryBuckets
+	target := indexes[excess]
+	for _, i := range indexes[:excess] {
+		buckets[target] += buckets[i]
+		delete(buckets, i)
+	}
+}
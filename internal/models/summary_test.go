@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestSummary_Quantile(t *testing.T) {
+	s := NewSummary(DefaultSummaryAccuracy)
+	for i := 1; i <= 1000; i++ {
+		s.Observe(float64(i))
+		s.Observe(-float64(i))
+	}
+	s.Observe(0)
+	s.Observe(math.NaN())
+	if s.Count() != 2001 || s.Sum != 0 || s.Min != -1000 || s.Max != 1000 {
+		t.Fatalf("Observe() count = %d, sum = %v, min = %v, max = %v", s.Count(), s.Sum, s.Min, s.Max)
+	}
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{q: 0, want: -1000},
+		{q: 0.25, want: -500},
+		{q: 0.5, want: 0},
+		{q: 0.75, want: 500},
+		{q: 0.99, want: 980},
+		{q: 1, want: 1000},
+	}
+	for _, tt := range tests {
+		got := s.Quantile(tt.q)
+		if math.Abs(got-tt.want) > math.Abs(tt.want)*DefaultSummaryAccuracy {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+	if got := NewSummary(DefaultSummaryAccuracy).Quantile(0.5); !math.IsNaN(got) {
+		t.Errorf("Quantile() of empty summary = %v, want NaN", got)
+	}
+}
+
+func TestSummary_Merge(t *testing.T) {
+	whole, first, second := NewSummary(0.02), NewSummary(0.02), NewSummary(0.02)
+	for i := 1; i <= 100; i++ {
+		whole.Observe(float64(i))
+		if i%2 == 0 {
+			first.Observe(float64(i))
+		} else {
+			second.Observe(float64(i))
+		}
+	}
+	got := first.Merge(second)
+	if !reflect.DeepEqual(got, whole) {
+		t.Errorf("Merge() got = %v, want %v", got, whole)
+	}
+	// merged summary doesn't share buckets with arguments
+	got.Positive[1]++
+	if first.Count() != 50 || second.Count() != 50 {
+		t.Errorf("Merge() modified arguments")
+	}
+
+	other := NewSummary(0.05)
+	other.Observe(1)
+	if got = whole.Merge(other); !reflect.DeepEqual(got, other) {
+		t.Errorf("Merge() with other accuracy got = %v, want %v", got, other)
+	}
+	if got = (*Summary)(nil).Merge(other); !reflect.DeepEqual(got, other) {
+		t.Errorf("Merge() of nil got = %v, want %v", got, other)
+	}
+	if got = other.Merge(NewSummary(0.05)); !reflect.DeepEqual(got, other) {
+		t.Errorf("Merge() with empty got = %v, want %v", got, other)
+	}
+}
+
+func TestSummary_collapse(t *testing.T) {
+	s := NewSummary(0.01)
+	for i := 0; i < 2*MaxSummaryBuckets; i++ {
+		s.Observe(math.Pow(1.05, float64(i)))
+	}
+	if len(s.Positive) != MaxSummaryBuckets || s.Count() != uint64(2*MaxSummaryBuckets) {
+		t.Fatalf("Observe() buckets = %d, count = %d", len(s.Positive), s.Count())
+	}
+	// highest values keep accuracy
+	if got, want := s.Quantile(1), s.Max; got != want {
+		t.Errorf("Quantile(1) = %v, want %v", got, want)
+	}
+	if err := s.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestSummary_Validate(t *testing.T) {
+	tests := []struct {
+		s       *Summary
+		name    string
+		wantErr bool
+	}{
+		{
+			name: "ok",
+			s:    &Summary{Positive: map[int32]uint64{1: 1}, Accuracy: 0.01, Sum: 1, Min: 1, Max: 1},
+		},
+		{
+			name: "empty",
+			s:    NewSummary(0.5),
+		},
+		{
+			name:    "nil",
+			wantErr: true,
+		},
+		{
+			name:    "zero accuracy",
+			s:       &Summary{},
+			wantErr: true,
+		},
+		{
+			name:    "accuracy is NaN",
+			s:       &Summary{Accuracy: math.NaN()},
+			wantErr: true,
+		},
+		{
+			name:    "min is greater than max",
+			s:       &Summary{Accuracy: 0.01, Min: 1},
+			wantErr: true,
+		},
+		{
+			name:    "too many buckets",
+			s:       &Summary{Negative: make(map[int32]uint64, MaxSummaryBuckets+1), Accuracy: 0.01},
+			wantErr: true,
+		},
+	}
+	for i := int32(0); i <= int32(MaxSummaryBuckets); i++ {
+		tests[len(tests)-1].s.Negative[i] = 1
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSummary) {
+				t.Errorf("Validate() error = %v, want %v", err, ErrInvalidSummary)
+			}
+		})
+	}
+}
+
+func TestSummary_String(t *testing.T) {
+	s := NewSummary(0.01)
+	if got, want := s.String(), "count=0 sum=0"; got != want {
+		t.Errorf("String() got = %q, want %q", got, want)
+	}
+	s.Observe(0.1)
+	s.Observe(0.2)
+	s.Observe(0.3)
+	// estimated quantiles have relative error not greater than accuracy
+	want := "count=3 sum=0.6 min=0.1 max=0.3 p50=0.2 p90=0.298 p95=0.298 p99=0.298"
+	if got := s.String(); got != want {
+		t.Errorf("String() got = %q, want %q", got, want)
+	}
+}
+
+func TestQuantileName(t *testing.T) {
+	for q, want := range map[float64]string{0.5: "p50", 0.29: "p29", 0.999: "p99.9", 1: "p100"} {
+		if got := QuantileName(q); got != want {
+			t.Errorf("QuantileName(%v) = %q, want %q", q, got, want)
+		}
+	}
+}
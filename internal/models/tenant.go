@@ -0,0 +1,23 @@
+package models
+
+import "context"
+
+// DefaultTenant - tenant of clients which are not assigned to any tenant.
+const DefaultTenant = ""
+
+// tenantKey is context key for tenant of client.
+type tenantKey struct{}
+
+// WithTenant returns context carrying tenant of client, storages read and write metrics of this tenant only.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFromContext returns tenant of client, DefaultTenant if unknown or ctx is nil.
+func TenantFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return DefaultTenant
+	}
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
@@ -3,33 +3,93 @@ package models
 import (
 	"math"
 	"strconv"
+	"time"
 )
 
 // Metric describes metric object.
 type Metric struct {
-	// Kind - gauge or counter
+	// Kind - gauge, counter, histogram or summary
 	Kind string
 	// Name - metric name
 	Name string
-	// Value - metric value
-	Value string
+	// Labels - metric labels, nil if metric has no labels
+	Labels Labels
+	// Delta - counter value
+	Delta int64
+	// Value - gauge value
+	Value float64
+	// Histogram - histogram buckets, nil for other kinds
+	Histogram *Histogram
+	// Summary - quantile sketch, nil for other kinds
+	Summary *Summary
+	// Unit - optional unit of value, like "bytes"
+	Unit string
+	// Description - optional description of metric
+	Description string
+	// Tenant - namespace of metric, empty for default tenant
+	Tenant string
 }
 
-// GetMetricValueString returns string of metric value.
-func GetMetricValueString(metric Metric) (string, error) {
-	switch metric.Kind {
+// Metadata describes last update of metric.
+type Metadata struct {
+	// UpdatedAt - when metric was updated last time
+	UpdatedAt time.Time
+	// Source - address of client which updated metric last time, empty if unknown
+	Source string
+	// Version - count of updates since metric was created, starts from 1
+	Version int64
+}
+
+// Sample describes metric value at a point in time.
+type Sample struct {
+	// Timestamp - when value was stored
+	Timestamp time.Time
+	// Delta - counter value or count of histogram or summary observations
+	Delta int64
+	// Value - gauge value or sum of histogram or summary observations
+	Value float64
+}
+
+// ParseMetric returns metric with value parsed from string according to kind.
+func ParseMetric(kind, name, value string) (Metric, error) {
+	metric := Metric{Kind: kind, Name: name}
+	switch kind {
 	case MetricKindGauge:
-		v, err := strconv.ParseFloat(metric.Value, 64)
+		v, err := strconv.ParseFloat(value, metricBitSize)
 		if err != nil {
-			return "", ErrNotFloat
+			return Metric{}, ErrNotFloat
 		}
-		return RoundFloatToString(v), nil
+		metric.Value = v
 	case MetricKindCounter:
-		v, err := strconv.ParseInt(metric.Value, 10, 64)
+		v, err := strconv.ParseInt(value, base10, metricBitSize)
 		if err != nil {
-			return "", ErrNotInteger
+			return Metric{}, ErrNotInteger
+		}
+		metric.Delta = v
+	default:
+		// histogram and summary can't be represented by single value
+		return Metric{}, ErrNotSupported
+	}
+	return metric, nil
+}
+
+// GetMetricValueString returns string of metric value.
+func GetMetricValueString(metric Metric) (string, error) {
+	switch metric.Kind {
+	case MetricKindGauge:
+		return RoundFloatToString(metric.Value), nil
+	case MetricKindCounter:
+		return strconv.FormatInt(metric.Delta, base10), nil
+	case MetricKindHistogram:
+		if metric.Histogram == nil {
+			return "", ErrInvalidHistogram
+		}
+		return metric.Histogram.String(), nil
+	case MetricKindSummary:
+		if metric.Summary == nil {
+			return "", ErrInvalidSummary
 		}
-		return strconv.FormatInt(v, 10), nil
+		return metric.Summary.String(), nil
 	default:
 		return "", ErrNotSupported
 	}
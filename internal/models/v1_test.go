@@ -1,6 +1,62 @@
 package models
 
-import "testing"
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseMetric(t *testing.T) {
+	type args struct {
+		kind  string
+		name  string
+		value string
+	}
+	tests := []struct {
+		wantErr error
+		name    string
+		args    args
+		want    Metric
+	}{
+		{
+			name: "gauge OK",
+			args: args{kind: MetricKindGauge, name: "testGauge1", value: "99.9"},
+			want: Metric{Kind: MetricKindGauge, Name: "testGauge1", Value: 99.9},
+		},
+		{
+			name:    "gauge ERROR",
+			args:    args{kind: MetricKindGauge, name: "testGauge2", value: "99.z"},
+			wantErr: ErrNotFloat,
+		},
+		{
+			name: "counter OK",
+			args: args{kind: MetricKindCounter, name: "testCounter1", value: "9007199254740993"},
+			want: Metric{Kind: MetricKindCounter, Name: "testCounter1", Delta: 9007199254740993},
+		},
+		{
+			name:    "counter ERROR",
+			args:    args{kind: MetricKindCounter, name: "testCounter2", value: "99.33"},
+			wantErr: ErrNotInteger,
+		},
+		{
+			name:    "unsupported kind",
+			args:    args{kind: "preved", name: "testCounter2", value: "1"},
+			wantErr: ErrNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMetric(tt.args.kind, tt.args.name, tt.args.value)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ParseMetric() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMetric() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
 
 func TestGetMetricValueString(t *testing.T) {
 	type args struct {
@@ -18,23 +74,23 @@ func TestGetMetricValueString(t *testing.T) {
 				metric: Metric{
 					Kind:  MetricKindGauge,
 					Name:  "testGauge1",
-					Value: "99.9",
+					Value: 99.9,
 				},
 			},
 			want:    "99.9",
 			wantErr: false,
 		},
 		{
-			name: "gauge metric to string ERROR",
+			name: "gauge metric is rounded",
 			args: args{
 				metric: Metric{
 					Kind:  MetricKindGauge,
 					Name:  "testGauge2",
-					Value: "99.z",
+					Value: 1.23456,
 				},
 			},
-			want:    "",
-			wantErr: true,
+			want:    "1.235",
+			wantErr: false,
 		},
 		{
 			name: "counter metric to string OK",
@@ -42,19 +98,19 @@ func TestGetMetricValueString(t *testing.T) {
 				metric: Metric{
 					Kind:  MetricKindCounter,
 					Name:  "testCounter1",
-					Value: "99",
+					Delta: 99,
 				},
 			},
 			want:    "99",
 			wantErr: false,
 		},
 		{
-			name: "counter metric to string ERROR",
+			name: "unsupported metric to string ERROR",
 			args: args{
 				metric: Metric{
-					Kind:  MetricKindCounter,
+					Kind:  "preved",
 					Name:  "testCounter2",
-					Value: "99.33",
+					Delta: 99,
 				},
 			},
 			want:    "",
@@ -1,13 +1,57 @@
 package models
 
+import "time"
+
 // MetricV2 describes metric object.
 type MetricV2 struct {
 	// Delta.
 	Delta *int64 `json:"delta,omitempty"`
 	// Value - metrics value.
 	Value *float64 `json:"value,omitempty"`
+	// ID - metrics id.
+	ID string `json:"id"`
+	// MType - gauge, counter, histogram or summary.
+	MType string `json:"type"`
+	// Histogram - histogram buckets.
+	Histogram *Histogram `json:"histogram,omitempty"`
+	// Summary - quantile sketch.
+	Summary *Summary `json:"summary,omitempty"`
+	// Quantiles - quantiles estimated by summary sketch, ignored in requests.
+	Quantiles map[string]float64 `json:"quantiles,omitempty"`
+	// Labels - metrics labels.
+	Labels Labels `json:"labels,omitempty"`
+	// Unit - optional unit of value.
+	Unit string `json:"unit,omitempty"`
+	// Description - optional description of metric.
+	Description string `json:"description,omitempty"`
+	// UpdatedAt - when metric was updated last time, ignored in requests.
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// Source - address of client which updated metric last time, ignored in requests.
+	Source string `json:"source,omitempty"`
+	// Version - version of metric incremented by every update, ignored in requests.
+	Version *int64 `json:"version,omitempty"`
+	// IfVersion - optional condition: update gauge only if stored one has this version, 0 if it doesn't exist.
+	IfVersion *int64 `json:"if_version,omitempty"`
+	// Mode - optional condition: max or min updates gauge only if value is greater or less than stored one.
+	Mode string `json:"mode,omitempty"`
+	// Tenant - namespace of metric in files of storage, ignored in requests.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// MetricsList describes page of metrics listed by query.
+type MetricsList struct {
+	// Metrics - metrics of page.
+	Metrics []*MetricV2 `json:"metrics"`
+	// NextCursor - cursor of next page, empty for last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// MetricsQuery describes request for metrics selected by labels.
+type MetricsQuery struct {
 	// ID - metrics id.
 	ID string `json:"id"`
 	// MType - gauge or counter.
 	MType string `json:"type"`
+	// Matchers - label matchers like host=a, host!=a, host=~a.* or host!~a.*.
+	Matchers []string `json:"matchers,omitempty"`
 }
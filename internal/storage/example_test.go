@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"github.com/sejo412/ya-metrics/internal/models"
 )
@@ -13,7 +14,7 @@ func ExampleMemoryStorage_Upsert() {
 	err := exampleMemoryStorage.Upsert(context.Background(), models.Metric{
 		Kind:  models.MetricKindGauge,
 		Name:  "metric1",
-		Value: "99.99",
+		Value: 99.99,
 	})
 
 	fmt.Println(err)
@@ -35,12 +36,12 @@ func ExampleMemoryStorage_MassUpsert() {
 		{
 			Kind:  models.MetricKindGauge,
 			Name:  "metric1",
-			Value: "88.99",
+			Value: 88.99,
 		},
 		{
 			Kind:  models.MetricKindCounter,
 			Name:  "metric2",
-			Value: "2",
+			Delta: 2,
 		},
 	}
 	err := exampleMemoryStorage.MassUpsert(context.Background(), metrics)
@@ -50,10 +51,20 @@ func ExampleMemoryStorage_MassUpsert() {
 	// <nil>
 }
 
-func ExampleMemoryStorage_GetAll() {
-	metrics, _ := exampleMemoryStorage.GetAll(context.Background())
+func ExampleMemoryStorage_All() {
+	metrics := make([]models.Metric, 0)
+	for m, err := range exampleMemoryStorage.All(context.Background()) {
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		metrics = append(metrics, m)
+	}
+	// order of iteration is unspecified
+	slices.SortFunc(metrics, models.CompareMetrics)
 	for _, m := range metrics {
-		fmt.Printf("%s=%s\n", m.Name, m.Value)
+		value, _ := models.GetMetricValueString(m)
+		fmt.Printf("%s=%s\n", m.Name, value)
 	}
 
 	// Output:
@@ -0,0 +1,598 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/models"
+)
+
+// Settings for files of FileStorage.
+const (
+	walFilePrefix      string      = "wal-"      // prefix for write-ahead log files
+	snapshotFilePrefix string      = "snapshot-" // prefix for snapshot files
+	tmpFileSuffix      string      = ".tmp"      // suffix for not finished snapshot
+	fileMode           os.FileMode = 0o600       // permissions for created files
+	dirMode            os.FileMode = 0o700       // permissions for created directory
+)
+
+// Operations recorded in write-ahead log.
+const (
+	walOpUpsert         string = "upsert"         // insert or update metrics
+	walOpDelete         string = "delete"         // delete metric by kind and name
+	walOpDeleteByPrefix string = "delete_prefix"  // delete metrics by name prefix
+	walOpResetCounter   string = "reset_counter"  // set counter to zero
+	walOpDeleteMetrics  string = "delete_metrics" // delete metrics by kind, name and labels
+)
+
+// walRecord describes one operation in write-ahead log.
+type walRecord struct {
+	// Time - when operation was applied.
+	Time time.Time `json:"time"`
+	// Op - operation.
+	Op string `json:"op"`
+	// Metrics - metrics for operation with their tenants.
+	Metrics []models.Metric `json:"metrics,omitempty"`
+	// Kind - metric kind for operation.
+	Kind string `json:"kind,omitempty"`
+	// Name - metric name or name prefix for operation.
+	Name string `json:"name,omitempty"`
+	// Source - address of client requested operation.
+	Source string `json:"source,omitempty"`
+	// Tenant - tenant of metrics for operation by kind and name or name prefix.
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// FileStorage is backend for RAM with crash-safe persistence.
+//
+// Every change is appended to write-ahead log with fsync before it is applied.
+// Log is periodically compacted into snapshot. Both have generation number in their names:
+// snapshot N contains state before log N was started, so on Open the newest snapshot is loaded
+// and logs with the same or newer generation are replayed.
+// Metrics of all tenants share log and snapshot. Metrics history is kept in RAM only.
+// Update time, source and version of metrics are kept in snapshot and restored from log.
+type FileStorage struct {
+	*MemoryStorage
+	wal        *os.File
+	done       chan struct{}
+	dir        string
+	generation int
+	wg         sync.WaitGroup
+	mutex      sync.Mutex
+}
+
+// NewFileStorage returns new FileStorage object.
+func NewFileStorage() *FileStorage {
+	return &FileStorage{MemoryStorage: NewMemoryStorage()}
+}
+
+// Open restores metrics from snapshot and log in opts.Path and starts compaction on timer.
+func (f *FileStorage) Open(ctx context.Context, opts Options) error {
+	if err := os.MkdirAll(opts.Path, dirMode); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", opts.Path, err)
+	}
+	f.dir = opts.Path
+	generation, err := f.replay()
+	if err != nil {
+		return fmt.Errorf("failed to replay log: %w", err)
+	}
+	wal, err := os.OpenFile(f.walPath(generation), os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	f.wal = wal
+	f.generation = generation
+
+	interval := opts.CompactInterval
+	if interval <= 0 {
+		interval = defaultCompactInterval
+	}
+	f.done = make(chan struct{})
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		f.compacting(interval)
+	}()
+	return nil
+}
+
+// Close stops compaction and closes log.
+func (f *FileStorage) Close() {
+	if f.done != nil {
+		close(f.done)
+		f.wg.Wait()
+		f.done = nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.wal != nil {
+		_ = f.wal.Sync()
+		_ = f.wal.Close()
+		f.wal = nil
+	}
+	f.MemoryStorage.Close()
+}
+
+// Ping checks log is opened.
+func (f *FileStorage) Ping(ctx context.Context) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.wal == nil {
+		return errors.New("log is not opened")
+	}
+	return nil
+}
+
+// Upsert inserts or updates metric.
+func (f *FileStorage) Upsert(ctx context.Context, metric models.Metric) error {
+	return f.MassUpsert(ctx, []models.Metric{metric})
+}
+
+// MassUpsert inserts or updates slice of metrics.
+func (f *FileStorage) MassUpsert(ctx context.Context, metrics []models.Metric) error {
+	return f.massUpsert(withTenant(metrics, models.TenantFromContext(ctx)), models.SourceFromContext(ctx))
+}
+
+// massUpsert inserts or updates slice of metrics of their tenants sent by source.
+func (f *FileStorage) massUpsert(metrics []models.Metric, source string) error {
+	// invalid metrics must not get into log, otherwise it can't be replayed
+	for _, metric := range metrics {
+		if err := validateMetric(metric); err != nil {
+			return err
+		}
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	record := walRecord{
+		Time:    time.Now(),
+		Op:      walOpUpsert,
+		Metrics: metrics,
+		Source:  source,
+	}
+	if err := f.appendWAL(record); err != nil {
+		return err
+	}
+	return f.apply(record)
+}
+
+// UpsertIf updates gauge if condition holds for stored one and returns new version of gauge.
+// Only succeeded updates are logged, as unconditional ones, so replay doesn't check conditions again.
+func (f *FileStorage) UpsertIf(ctx context.Context, metric models.Metric, cond models.Condition) (int64, error) {
+	if err := validateCondition(metric, cond); err != nil {
+		return 0, err
+	}
+	metric.Tenant = models.TenantFromContext(ctx)
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	// storage is modified under mutex only, so condition holds until record is applied
+	if err := f.MemoryStorage.check(metric, cond); err != nil {
+		return 0, err
+	}
+	record := walRecord{
+		Time:    time.Now(),
+		Op:      walOpUpsert,
+		Metrics: []models.Metric{metric},
+		Source:  models.SourceFromContext(ctx),
+	}
+	if err := f.appendWAL(record); err != nil {
+		return 0, err
+	}
+	if err := f.apply(record); err != nil {
+		return 0, err
+	}
+	meta, err := f.MemoryStorage.GetMetadata(ctx, metric)
+	return meta.Version, err
+}
+
+// Delete removes metric of all label sets by kind and name.
+func (f *FileStorage) Delete(ctx context.Context, kind, name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	// don't log operation which fails anyway
+	if err := f.exists(ctx, kind, name); err != nil {
+		return err
+	}
+	record := walRecord{
+		Time:   time.Now(),
+		Op:     walOpDelete,
+		Kind:   kind,
+		Name:   name,
+		Tenant: models.TenantFromContext(ctx),
+	}
+	if err := f.appendWAL(record); err != nil {
+		return err
+	}
+	return f.apply(record)
+}
+
+// DeleteByPrefix removes metrics of all kinds with name prefix. Returns count of removed metrics.
+func (f *FileStorage) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	record := walRecord{
+		Time:   time.Now(),
+		Op:     walOpDeleteByPrefix,
+		Name:   prefix,
+		Tenant: models.TenantFromContext(ctx),
+	}
+	if err := f.appendWAL(record); err != nil {
+		return 0, err
+	}
+	return f.MemoryStorage.DeleteByPrefix(ctx, prefix)
+}
+
+// ResetCounter sets counter value of all label sets to zero.
+func (f *FileStorage) ResetCounter(ctx context.Context, name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if err := f.exists(ctx, models.MetricKindCounter, name); err != nil {
+		return err
+	}
+	record := walRecord{
+		Time:   time.Now(),
+		Op:     walOpResetCounter,
+		Name:   name,
+		Source: models.SourceFromContext(ctx),
+		Tenant: models.TenantFromContext(ctx),
+	}
+	if err := f.appendWAL(record); err != nil {
+		return err
+	}
+	return f.apply(record)
+}
+
+// DeleteStale removes metrics which are stale at now according to policy. Returns count of removed metrics.
+// Removed metrics are logged explicitly, so replay doesn't depend on update times.
+func (f *FileStorage) DeleteStale(ctx context.Context, policy TTLPolicy, now time.Time) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	stale := f.MemoryStorage.stale(policy, now)
+	if len(stale) == 0 {
+		return 0, nil
+	}
+	record := walRecord{
+		Time:    now,
+		Op:      walOpDeleteMetrics,
+		Metrics: stale,
+	}
+	if err := f.appendWAL(record); err != nil {
+		return 0, err
+	}
+	return f.MemoryStorage.deleteMetrics(stale), nil
+}
+
+// exists returns error if metric with any label set doesn't exist.
+func (f *FileStorage) exists(ctx context.Context, kind, name string) error {
+	metrics, err := f.MemoryStorage.Select(ctx, kind, name)
+	if err != nil {
+		return err
+	}
+	if len(metrics) == 0 {
+		return models.ErrHTTPNotFound
+	}
+	return nil
+}
+
+// Load loads metrics from source, every metric is restored to its own tenant.
+func (f *FileStorage) Load(ctx context.Context, src io.Reader) error {
+	metrics, err := decodeMetrics(src)
+	if err != nil {
+		return err
+	}
+	return f.massUpsert(metrics, models.SourceFromContext(ctx))
+}
+
+// Compact saves current state to snapshot and removes obsolete logs and snapshots.
+func (f *FileStorage) Compact() error {
+	f.mutex.Lock()
+	if f.wal == nil {
+		f.mutex.Unlock()
+		return errors.New("log is not opened")
+	}
+	next := f.generation + 1
+	wal, err := os.OpenFile(f.walPath(next), os.O_CREATE|os.O_WRONLY|os.O_APPEND, fileMode)
+	if err != nil {
+		f.mutex.Unlock()
+		return fmt.Errorf("failed to open log: %w", err)
+	}
+	// state and log rotation must be consistent, so take metrics under lock
+	metrics := f.MemoryStorage.getAllStored()
+	prev := f.wal
+	f.wal = wal
+	f.generation = next
+	f.mutex.Unlock()
+	_ = prev.Close()
+
+	if err = f.writeSnapshot(next, metrics); err != nil {
+		return err
+	}
+	return f.removeObsolete(next)
+}
+
+// compacting compacts log on timer until storage closed.
+func (f *FileStorage) compacting(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-f.done:
+			return
+		case <-ticker.C:
+			// on error log stays untouched, next attempt on next tick
+			_ = f.Compact()
+		}
+	}
+}
+
+// apply applies record to RAM.
+func (f *FileStorage) apply(record walRecord) error {
+	switch record.Op {
+	case walOpUpsert:
+		return f.MemoryStorage.massUpsertAt(record.Metrics, record.Time, record.Source)
+	case walOpDelete:
+		return f.MemoryStorage.Delete(models.WithTenant(context.Background(), record.Tenant), record.Kind, record.Name)
+	case walOpDeleteByPrefix:
+		_, err := f.MemoryStorage.DeleteByPrefix(models.WithTenant(context.Background(), record.Tenant), record.Name)
+		return err
+	case walOpResetCounter:
+		return f.MemoryStorage.resetCounterAt(record.Tenant, record.Name, record.Time, record.Source)
+	case walOpDeleteMetrics:
+		f.MemoryStorage.deleteMetrics(record.Metrics)
+		return nil
+	default:
+		return fmt.Errorf("unknown log operation: %s", record.Op)
+	}
+}
+
+// appendWAL writes record to log and flushes it to disk. Caller must hold mutex.
+func (f *FileStorage) appendWAL(record walRecord) error {
+	if f.wal == nil {
+		return errors.New("log is not opened")
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode log record: %w", err)
+	}
+	data = append(data, '\n')
+	if _, err = f.wal.Write(data); err != nil {
+		return fmt.Errorf("failed to write log: %w", err)
+	}
+	if err = f.wal.Sync(); err != nil {
+		return fmt.Errorf("failed to sync log: %w", err)
+	}
+	return nil
+}
+
+// replay loads newest snapshot and replays logs after it. Returns current generation.
+func (f *FileStorage) replay() (int, error) {
+	snapshots, wals, err := f.generations()
+	if err != nil {
+		return 0, err
+	}
+	generation := 0
+	if len(snapshots) > 0 {
+		generation = snapshots[len(snapshots)-1]
+		if err = f.loadSnapshot(generation); err != nil {
+			return 0, err
+		}
+	}
+	for _, g := range wals {
+		if g < generation {
+			continue
+		}
+		if err = f.replayWAL(f.walPath(g)); err != nil {
+			return 0, err
+		}
+		generation = g
+	}
+	return generation, nil
+}
+
+// loadSnapshot loads snapshot to RAM.
+func (f *FileStorage) loadSnapshot(generation int) error {
+	file, err := os.Open(f.snapshotPath(generation))
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	metrics, err := decodeSnapshot(file)
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+	return f.MemoryStorage.restore(metrics)
+}
+
+// replayWAL applies all records from log to RAM.
+// Incomplete record at the end of log (crash in the middle of write) is truncated.
+func (f *FileStorage) replayWAL(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read log %s: %w", path, err)
+	}
+	var offset int64
+	reader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(line) > 0 {
+				return os.Truncate(path, offset)
+			}
+			return nil
+		}
+		var record walRecord
+		if err = json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("corrupted log %s at offset %d: %w", path, offset, err)
+		}
+		// operation failed at runtime fails on replay the same way, so state stays identical
+		_ = f.apply(record)
+		offset += int64(len(line))
+	}
+}
+
+// writeSnapshot writes metrics to snapshot atomically.
+func (f *FileStorage) writeSnapshot(generation int, metrics []storedMetric) error {
+	path := f.snapshotPath(generation)
+	tmp := path + tmpFileSuffix
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fileMode)
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	writer := bufio.NewWriter(file)
+	err = encodeSnapshot(writer, metrics)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if er := file.Close(); err == nil {
+		err = er
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename snapshot: %w", err)
+	}
+	return syncDir(f.dir)
+}
+
+// encodeSnapshot writes metrics of all tenants with metadata of their last updates
+// as newline-delimited MetricV2 JSON.
+func encodeSnapshot(dst io.Writer, metrics []storedMetric) error {
+	encoder := json.NewEncoder(dst)
+	for _, stored := range metrics {
+		m, err := models.ConvertV1ToV2(&stored.metric)
+		if err != nil {
+			return err
+		}
+		m.Tenant = stored.metric.Tenant
+		m.UpdatedAt = &stored.meta.UpdatedAt
+		m.Source = stored.meta.Source
+		m.Version = &stored.meta.Version
+		if err = encoder.Encode(m); err != nil {
+			return fmt.Errorf("error encode metric %s: %w", stored.metric.Name, err)
+		}
+	}
+	return nil
+}
+
+// decodeSnapshot reads metrics of all tenants with metadata of their last updates.
+// Metrics without metadata (written by older versions) are considered updated once at load time by unknown source.
+func decodeSnapshot(src io.Reader) ([]storedMetric, error) {
+	metrics := make([]storedMetric, 0)
+	now := time.Now()
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		m := models.MetricV2{}
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("error unmarshal metric %s: %w", scanner.Text(), err)
+		}
+		res, err := models.ConvertV2ToV1(&m)
+		if err != nil {
+			return nil, err
+		}
+		res.Tenant = m.Tenant
+		meta := models.Metadata{UpdatedAt: now, Version: 1}
+		if m.Version != nil && m.UpdatedAt != nil {
+			meta = models.Metadata{UpdatedAt: *m.UpdatedAt, Source: m.Source, Version: *m.Version}
+		}
+		metrics = append(metrics, storedMetric{metric: *res, meta: meta})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error read metrics: %w", err)
+	}
+	return metrics, nil
+}
+
+// removeObsolete removes logs and snapshots older than generation.
+func (f *FileStorage) removeObsolete(generation int) error {
+	snapshots, wals, err := f.generations()
+	if err != nil {
+		return err
+	}
+	for _, g := range snapshots {
+		if g < generation {
+			err = errors.Join(err, os.Remove(f.snapshotPath(g)))
+		}
+	}
+	for _, g := range wals {
+		if g < generation {
+			err = errors.Join(err, os.Remove(f.walPath(g)))
+		}
+	}
+	return err
+}
+
+// generations returns sorted generations of snapshots and logs in directory.
+func (f *FileStorage) generations() (snapshots, wals []int, err error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read directory %s: %w", f.dir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, tmpFileSuffix) {
+			continue
+		}
+		if g, ok := parseGeneration(name, snapshotFilePrefix); ok {
+			snapshots = append(snapshots, g)
+		}
+		if g, ok := parseGeneration(name, walFilePrefix); ok {
+			wals = append(wals, g)
+		}
+	}
+	slices.Sort(snapshots)
+	slices.Sort(wals)
+	return snapshots, wals, nil
+}
+
+func (f *FileStorage) walPath(generation int) string {
+	return filepath.Join(f.dir, walFilePrefix+strconv.Itoa(generation))
+}
+
+func (f *FileStorage) snapshotPath(generation int) string {
+	return filepath.Join(f.dir, snapshotFilePrefix+strconv.Itoa(generation))
+}
+
+func parseGeneration(name, prefix string) (int, bool) {
+	s, ok := strings.CutPrefix(name, prefix)
+	if !ok {
+		return 0, false
+	}
+	g, err := strconv.Atoi(s)
+	if err != nil || g < 0 {
+		return 0, false
+	}
+	return g, true
+}
+
+// syncDir flushes directory entries to disk.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open directory %s: %w", dir, err)
+	}
+	defer func() {
+		_ = d.Close()
+	}()
+	if err = d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync directory %s: %w", dir, err)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/models"
+)
+
+func openTestFileStorage(t *testing.T, dir string) *FileStorage {
+	t.Helper()
+	s := NewFileStorage()
+	if err := s.Open(context.Background(), Options{Scheme: FileScheme, Path: dir}); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	return s
+}
+
+var fileStorageTestMetrics = []models.Metric{
+	{
+		Kind:  models.MetricKindCounter,
+		Name:  "testCounter1",
+		Delta: 1,
+	},
+	{
+		Kind:  models.MetricKindGauge,
+		Name:  "testGauge1",
+		Value: 9999.11,
+	},
+	{
+		Kind:  models.MetricKindCounter,
+		Name:  "testCounter1",
+		Delta: 2,
+	},
+}
+
+var fileStorageTestWant = []models.Metric{
+	{
+		Kind:  models.MetricKindCounter,
+		Name:  "testCounter1",
+		Delta: 3,
+	},
+	{
+		Kind:  models.MetricKindGauge,
+		Name:  "testGauge1",
+		Value: 9999.11,
+	},
+}
+
+func TestFileStorage_Replay(t *testing.T) {
+	tests := []struct {
+		name    string
+		compact bool
+		torn    bool
+	}{
+		{
+			name: "replay log",
+		},
+		{
+			name:    "replay snapshot and log",
+			compact: true,
+		},
+		{
+			name: "replay log with torn tail",
+			torn: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := openTestFileStorage(t, dir)
+			if err := s.MassUpsert(context.Background(), fileStorageTestMetrics[:2]); err != nil {
+				t.Fatalf("MassUpsert() error = %v", err)
+			}
+			if tt.compact {
+				if err := s.Compact(); err != nil {
+					t.Fatalf("Compact() error = %v", err)
+				}
+			}
+			if err := s.Upsert(context.Background(), fileStorageTestMetrics[2]); err != nil {
+				t.Fatalf("Upsert() error = %v", err)
+			}
+			generation := s.generation
+			s.Close()
+			if tt.torn {
+				f, err := os.OpenFile(s.walPath(generation), os.O_WRONLY|os.O_APPEND, fileMode)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, _ = f.WriteString(`{"time":"2025-01-01T00:00:00Z","op":"ups`)
+				_ = f.Close()
+			}
+
+			s = openTestFileStorage(t, dir)
+			defer s.Close()
+			got, _ := collectMetrics(s.All(context.Background()))
+			if !reflect.DeepEqual(got, fileStorageTestWant) {
+				t.Errorf("All() got = %v, want %v", got, fileStorageTestWant)
+			}
+			snapshots, wals, _ := s.generations()
+			if tt.compact && (len(snapshots) != 1 || len(wals) != 1) {
+				t.Errorf("Compact() left snapshots %v and logs %v", snapshots, wals)
+			}
+			if err := s.Upsert(context.Background(), fileStorageTestMetrics[0]); err != nil {
+				t.Errorf("Upsert() after replay error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFileStorage_ReplayUpsertIf(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openTestFileStorage(t, dir)
+	metric := models.Metric{Kind: models.MetricKindGauge, Name: "testGauge1", Value: 1}
+	if _, err := s.UpsertIf(ctx, metric, models.Condition{Mode: models.UpdateModeMax}); err != nil {
+		t.Fatalf("UpsertIf() error = %v", err)
+	}
+	metric.Value = 2
+	if _, err := s.UpsertIf(ctx, metric, models.Condition{Mode: models.UpdateModeMax}); err != nil {
+		t.Fatalf("UpsertIf() error = %v", err)
+	}
+	// failed updates don't get into log
+	metric.Value = 0
+	if _, err := s.UpsertIf(ctx, metric, models.Condition{Mode: models.UpdateModeMax}); !errors.Is(err,
+		models.ErrConflict) {
+		t.Errorf("UpsertIf() error = %v, want %v", err, models.ErrConflict)
+	}
+	s.Close()
+
+	s = openTestFileStorage(t, dir)
+	defer s.Close()
+	got, err := s.Get(ctx, models.MetricKindGauge, "testGauge1")
+	if err != nil || got.Value != 2 {
+		t.Errorf("Get() got = %v, error = %v, want value 2", got, err)
+	}
+	// version is restored from log
+	version := int64(2)
+	if v, err := s.UpsertIf(ctx, metric, models.Condition{Version: &version}); err != nil || v != 3 {
+		t.Errorf("UpsertIf() after replay version = %d, error = %v, want 3", v, err)
+	}
+}
+
+func TestFileStorage_ReplayTenants(t *testing.T) {
+	dir := t.TempDir()
+	team1 := models.WithTenant(context.Background(), "team1")
+	team2 := models.WithTenant(context.Background(), "team2")
+	s := openTestFileStorage(t, dir)
+	counter := models.Metric{Kind: models.MetricKindCounter, Name: "testCounter1", Delta: 1}
+	for _, ctx := range []context.Context{team1, team2} {
+		if err := s.Upsert(ctx, counter); err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+	}
+	if err := s.ResetCounter(team1, "testCounter1"); err != nil {
+		t.Fatalf("ResetCounter() error = %v", err)
+	}
+	if err := s.Delete(team2, models.MetricKindCounter, "testCounter1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	s.Close()
+
+	s = openTestFileStorage(t, dir)
+	defer s.Close()
+	got, err := s.Get(team1, models.MetricKindCounter, "testCounter1")
+	if err != nil || got.Delta != 0 {
+		t.Errorf("Get() of team1 got = %v, error = %v, want delta 0", got, err)
+	}
+	if _, err = s.Get(team2, models.MetricKindCounter, "testCounter1"); !errors.Is(err, models.ErrHTTPNotFound) {
+		t.Errorf("Get() of team2 error = %v, want %v", err, models.ErrHTTPNotFound)
+	}
+}
+
+func TestFileStorage_Ping(t *testing.T) {
+	s := NewFileStorage()
+	if err := s.Ping(context.Background()); err == nil {
+		t.Errorf("Ping() of not opened storage error = nil")
+	}
+	s = openTestFileStorage(t, t.TempDir())
+	defer s.Close()
+	if err := s.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+}
+
+func TestFileStorage_ReplayDelete(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openTestFileStorage(t, dir)
+	if err := s.MassUpsert(ctx, fileStorageTestMetrics); err != nil {
+		t.Fatalf("MassUpsert() error = %v", err)
+	}
+	if err := s.ResetCounter(ctx, "testCounter1"); err != nil {
+		t.Fatalf("ResetCounter() error = %v", err)
+	}
+	if err := s.Delete(ctx, models.MetricKindGauge, "testGauge1"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := s.Upsert(ctx, models.Metric{Kind: models.MetricKindGauge, Name: "testGauge2", Value: 1}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if _, err := s.DeleteByPrefix(ctx, "testGauge"); err != nil {
+		t.Fatalf("DeleteByPrefix() error = %v", err)
+	}
+	// failed operations don't get into log
+	if err := s.Delete(ctx, models.MetricKindGauge, "testGauge1"); err == nil {
+		t.Errorf("Delete() of deleted metric error = nil")
+	}
+	s.Close()
+
+	s = openTestFileStorage(t, dir)
+	defer s.Close()
+	want := []models.Metric{
+		{
+			Kind:  models.MetricKindCounter,
+			Name:  "testCounter1",
+			Delta: 0,
+		},
+	}
+	got, _ := collectMetrics(s.All(ctx))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+}
+
+func TestFileStorage_ReplayDeleteStale(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s := openTestFileStorage(t, dir)
+	if err := s.MassUpsert(ctx, fileStorageTestMetrics); err != nil {
+		t.Fatalf("MassUpsert() error = %v", err)
+	}
+	policy := TTLPolicy{Rules: []TTLRule{{Pattern: "testGauge*", TTL: time.Hour}}}
+	deleted, err := s.DeleteStale(ctx, policy, time.Now().Add(2*time.Hour))
+	if err != nil || deleted != 1 {
+		t.Fatalf("DeleteStale() got = %d, error = %v, want 1", deleted, err)
+	}
+	s.Close()
+
+	// replay removes the same metrics regardless of time of replay
+	s = openTestFileStorage(t, dir)
+	defer s.Close()
+	want := fileStorageTestWant[:1]
+	got, _ := collectMetrics(s.All(ctx))
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+}
@@ -1,28 +1,109 @@
 package storage
 
 import (
-	"bufio"
+	"container/heap"
 	"context"
-	"encoding/json"
 	"fmt"
 	"io"
+	"iter"
+	"slices"
 	"sort"
-	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/sejo412/ya-metrics/internal/models"
 )
 
-// MemoryStorage is backend for RAM.
+// metricKey identifies metric by tenant, kind, name and labels.
+type metricKey struct {
+	tenant string
+	kind   string
+	name   string
+	labels string
+}
+
+// newMetricKey returns key of metric.
+func newMetricKey(metric models.Metric) metricKey {
+	return metricKey{tenant: metric.Tenant, kind: metric.Kind, name: metric.Name, labels: metric.Labels.String()}
+}
+
+// memoryShards - count of MemoryStorage shards.
+const memoryShards = 32
+
+// memoryShard contains part of metrics guarded by own lock.
+type memoryShard struct {
+	metrics map[metricKey]models.Metric
+	history map[metricKey][]models.Sample
+	meta    map[metricKey]models.Metadata
+	mutex   sync.RWMutex
+}
+
+// shardIndex returns shard of metric. All label sets of metric are in the same shard,
+// so lookups by kind and name lock only one shard.
+func shardIndex(kind, name string) int {
+	// FNV-1a
+	const (
+		offset = 14695981039346656037
+		prime  = 1099511628211
+	)
+	var h uint64 = offset
+	for i := 0; i < len(kind); i++ {
+		h = (h ^ uint64(kind[i])) * prime
+	}
+	// zero byte separates kind and name
+	h *= prime
+	for i := 0; i < len(name); i++ {
+		h = (h ^ uint64(name[i])) * prime
+	}
+	return int(h % memoryShards)
+}
+
+// MemoryStorage is backend for RAM. Metrics are split between shards by kind and name,
+// so concurrent requests for different metrics don't wait for each other.
+// Requests see metrics of tenant from their context only, metrics of all tenants share shards.
 type MemoryStorage struct {
-	metrics map[string]models.Metric
-	mutex   sync.Mutex
+	shards [memoryShards]memoryShard
 }
 
 // NewMemoryStorage returns new MemoryStorage object.
 func NewMemoryStorage() *MemoryStorage {
-	metrics := make(map[string]models.Metric, models.TotalCountMetrics)
-	return &MemoryStorage{metrics: metrics}
+	s := &MemoryStorage{}
+	for i := range s.shards {
+		s.shards[i].metrics = make(map[metricKey]models.Metric, models.TotalCountMetrics/memoryShards)
+		s.shards[i].history = make(map[metricKey][]models.Sample, models.TotalCountMetrics/memoryShards)
+		s.shards[i].meta = make(map[metricKey]models.Metadata, models.TotalCountMetrics/memoryShards)
+	}
+	return s
+}
+
+// shard returns shard of metric by kind and name.
+func (s *MemoryStorage) shard(kind, name string) *memoryShard {
+	return &s.shards[shardIndex(kind, name)]
+}
+
+// lockAll locks all shards in order for writing and returns function to unlock them.
+func (s *MemoryStorage) lockAll() func() {
+	for i := range s.shards {
+		s.shards[i].mutex.Lock()
+	}
+	return func() {
+		for i := range s.shards {
+			s.shards[i].mutex.Unlock()
+		}
+	}
+}
+
+// rlockAll locks all shards in order for reading and returns function to unlock them.
+func (s *MemoryStorage) rlockAll() func() {
+	for i := range s.shards {
+		s.shards[i].mutex.RLock()
+	}
+	return func() {
+		for i := range s.shards {
+			s.shards[i].mutex.RUnlock()
+		}
+	}
 }
 
 // Open not implemented for RAM.
@@ -31,9 +112,13 @@ func (s *MemoryStorage) Open(ctx context.Context, opts Options) error {
 }
 
 func (s *MemoryStorage) Close() {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	s.metrics = nil
+	unlock := s.lockAll()
+	defer unlock()
+	for i := range s.shards {
+		s.shards[i].metrics = nil
+		s.shards[i].history = nil
+		s.shards[i].meta = nil
+	}
 }
 
 // Ping not implemented for RAM.
@@ -48,93 +133,501 @@ func (s *MemoryStorage) Init(ctx context.Context) error {
 
 // Upsert inserts or updates metric.
 func (s *MemoryStorage) Upsert(ctx context.Context, metric models.Metric) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	if metric.Kind == models.MetricKindCounter {
-		if m, ok := s.metrics[metric.Name]; ok {
-			currentInt, err := strconv.Atoi(m.Value)
-			if err != nil {
-				return fmt.Errorf("could not convert saved metric '%s' to int", metric.Name)
-			}
-			newInt, err := strconv.Atoi(metric.Value)
-			if err != nil {
-				return fmt.Errorf("could not convert new metric '%s' to int", metric.Name)
-			}
-			currentInt += newInt
-			metric.Value = strconv.Itoa(currentInt)
-		}
-	}
-	s.metrics[metric.Name] = metric
-	return nil
+	metric.Tenant = models.TenantFromContext(ctx)
+	return s.massUpsertAt([]models.Metric{metric}, time.Now(), models.SourceFromContext(ctx))
 }
 
-// MassUpsert inserts or updates slice of metrics.
+// MassUpsert inserts or updates slice of metrics. Either all metrics are applied or none of them.
 func (s *MemoryStorage) MassUpsert(ctx context.Context, metrics []models.Metric) error {
-	for _, metric := range metrics {
-		if err := s.Upsert(ctx, metric); err != nil {
+	return s.massUpsertAt(withTenant(metrics, models.TenantFromContext(ctx)), time.Now(),
+		models.SourceFromContext(ctx))
+}
+
+// memoryUpdate describes metric prepared for upsert.
+type memoryUpdate struct {
+	key   metricKey
+	shard int
+}
+
+// massUpsertAt inserts or updates slice of metrics of their tenants sent by source with samples stored at ts.
+// Metrics are validated before locking and shards of all metrics are locked at once,
+// so batch is applied completely and readers see either whole batch or nothing.
+func (s *MemoryStorage) massUpsertAt(metrics []models.Metric, ts time.Time, source string) error {
+	updates := make([]memoryUpdate, len(metrics))
+	var locked [memoryShards]bool
+	for i, metric := range metrics {
+		if err := validateMetric(metric); err != nil {
 			return err
 		}
+		updates[i].key = newMetricKey(metric)
+		updates[i].shard = shardIndex(metric.Kind, metric.Name)
+		locked[updates[i].shard] = true
+	}
+	// locking in order of shards prevents deadlock between concurrent batches
+	for i := range s.shards {
+		if locked[i] {
+			s.shards[i].mutex.Lock()
+		}
+	}
+	defer func() {
+		for i := range s.shards {
+			if locked[i] {
+				s.shards[i].mutex.Unlock()
+			}
+		}
+	}()
+	for i, metric := range metrics {
+		s.shards[updates[i].shard].upsert(updates[i].key, metric, ts, source)
 	}
 	return nil
 }
 
-// Get returns metric by name.
-// kind not implemented for RAM storage.
+// upsert inserts or updates metric by key and increments its version. Caller must hold write lock of shard.
+func (shard *memoryShard) upsert(key metricKey, metric models.Metric, ts time.Time, source string) {
+	metric.Labels = metric.Labels.Normalize()
+	prev := shard.metrics[key]
+	switch metric.Kind {
+	case models.MetricKindCounter:
+		metric.Delta += prev.Delta
+	case models.MetricKindHistogram:
+		// merged histogram and summary are copies, so caller can't modify stored ones
+		metric.Histogram = prev.Histogram.Merge(metric.Histogram)
+	case models.MetricKindSummary:
+		metric.Summary = prev.Summary.Merge(metric.Summary)
+	}
+	// unit and description are optional in updates
+	if metric.Unit == "" {
+		metric.Unit = prev.Unit
+	}
+	if metric.Description == "" {
+		metric.Description = prev.Description
+	}
+	shard.metrics[key] = metric
+	shard.history[key] = append(shard.history[key], newSample(metric, ts))
+	shard.meta[key] = models.Metadata{UpdatedAt: ts, Source: source, Version: shard.meta[key].Version + 1}
+}
+
+// UpsertIf updates gauge if condition holds for stored one and returns new version of gauge.
+// Returns models.ErrConflict if condition fails.
+func (s *MemoryStorage) UpsertIf(ctx context.Context, metric models.Metric, cond models.Condition) (int64,
+	error) {
+	if err := validateCondition(metric, cond); err != nil {
+		return 0, err
+	}
+	metric.Tenant = models.TenantFromContext(ctx)
+	key := newMetricKey(metric)
+	shard := s.shard(metric.Kind, metric.Name)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	if err := cond.Check(shard.meta[key].Version, shard.metrics[key].Value, metric.Value); err != nil {
+		return 0, err
+	}
+	shard.upsert(key, metric, time.Now(), models.SourceFromContext(ctx))
+	return shard.meta[key].Version, nil
+}
+
+// check returns models.ErrConflict if condition fails for stored gauge of metric tenant.
+func (s *MemoryStorage) check(metric models.Metric, cond models.Condition) error {
+	key := newMetricKey(metric)
+	shard := s.shard(metric.Kind, metric.Name)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	return cond.Check(shard.meta[key].Version, shard.metrics[key].Value, metric.Value)
+}
+
+// Get returns metric without labels by kind and name.
 func (s *MemoryStorage) Get(ctx context.Context, kind, name string) (models.Metric, error) {
-	// kind not used in this implementation, because name is "primary key" for MemoryStorage
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-	if metric, ok := s.metrics[name]; ok {
+	if !isValidKind(kind) {
+		return models.Metric{}, fmt.Errorf("%w: metric kind '%s'", models.ErrNotSupported, kind)
+	}
+	shard := s.shard(kind, name)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	if metric, ok := shard.metrics[metricKey{tenant: models.TenantFromContext(ctx), kind: kind, name: name}]; ok {
 		return metric, nil
 	}
 	return models.Metric{}, models.ErrHTTPNotFound
 }
 
-// GetAll returns slice of all metrics.
-func (s *MemoryStorage) GetAll(ctx context.Context) ([]models.Metric, error) {
-	s.mutex.Lock()
-	metrics := make([]models.Metric, 0, len(s.metrics))
-	for _, metric := range s.metrics {
-		metrics = append(metrics, metric)
+// GetMetadata returns metadata of metric with kind, name and labels of metric.
+func (s *MemoryStorage) GetMetadata(ctx context.Context, metric models.Metric) (models.Metadata, error) {
+	if !isValidKind(metric.Kind) {
+		return models.Metadata{}, fmt.Errorf("%w: metric kind '%s'", models.ErrNotSupported, metric.Kind)
+	}
+	metric.Tenant = models.TenantFromContext(ctx)
+	key := newMetricKey(metric)
+	shard := s.shard(metric.Kind, metric.Name)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	if meta, ok := shard.meta[key]; ok {
+		return meta, nil
+	}
+	return models.Metadata{}, models.ErrHTTPNotFound
+}
+
+// storedMetric is metric with metadata of its last update.
+type storedMetric struct {
+	metric models.Metric
+	meta   models.Metadata
+}
+
+// getAllStored returns sorted metrics of all tenants with metadata of their last updates.
+func (s *MemoryStorage) getAllStored() []storedMetric {
+	unlock := s.rlockAll()
+	stored := make([]storedMetric, 0)
+	for i := range s.shards {
+		shard := &s.shards[i]
+		for key, metric := range shard.metrics {
+			stored = append(stored, storedMetric{metric: metric, meta: shard.meta[key]})
+		}
 	}
-	s.mutex.Unlock()
-	sort.Slice(metrics, func(i, j int) bool {
-		return metrics[i].Name < metrics[j].Name
+	unlock()
+	slices.SortFunc(stored, func(a, b storedMetric) int {
+		return models.CompareMetrics(a.metric, b.metric)
 	})
+	return stored
+}
+
+// restore stores metrics of their tenants with metadata of their last updates, replacing stored ones.
+// History of restored metric starts with its value at time of last update.
+func (s *MemoryStorage) restore(stored []storedMetric) error {
+	for _, m := range stored {
+		if err := validateMetric(m.metric); err != nil {
+			return err
+		}
+	}
+	unlock := s.lockAll()
+	defer unlock()
+	for _, m := range stored {
+		m.metric.Labels = m.metric.Labels.Normalize()
+		key := newMetricKey(m.metric)
+		shard := s.shard(m.metric.Kind, m.metric.Name)
+		shard.metrics[key] = m.metric
+		shard.history[key] = []models.Sample{newSample(m.metric, m.meta.UpdatedAt)}
+		shard.meta[key] = m.meta
+	}
+	return nil
+}
+
+// All returns iterator over all metrics in unspecified order.
+func (s *MemoryStorage) All(ctx context.Context) iter.Seq2[models.Metric, error] {
+	tenant := models.TenantFromContext(ctx)
+	return s.all(func(key metricKey, _ time.Time) bool {
+		return key.tenant == tenant
+	})
+}
+
+// AllFresh returns iterator over metrics which are not stale at now according to policy.
+func (s *MemoryStorage) AllFresh(ctx context.Context, policy TTLPolicy, now time.Time) iter.Seq2[models.Metric,
+	error] {
+	tenant := models.TenantFromContext(ctx)
+	return s.all(func(key metricKey, updated time.Time) bool {
+		return key.tenant == tenant && !policy.IsStale(key.name, updated, now)
+	})
+}
+
+// all returns iterator over metrics accepted by filter, all metrics of all tenants if filter is nil.
+// Shards are copied one by one, so only one shard is in memory and consumer may modify storage.
+func (s *MemoryStorage) all(filter func(key metricKey, updated time.Time) bool) iter.Seq2[models.Metric, error] {
+	return func(yield func(models.Metric, error) bool) {
+		var metrics []models.Metric
+		for i := range s.shards {
+			shard := &s.shards[i]
+			metrics = metrics[:0]
+			shard.mutex.RLock()
+			for key, metric := range shard.metrics {
+				if filter == nil || filter(key, shard.meta[key].UpdatedAt) {
+					metrics = append(metrics, metric)
+				}
+			}
+			shard.mutex.RUnlock()
+			for _, metric := range metrics {
+				if !yield(metric, nil) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Select returns metrics of all label sets by kind and name matching all matchers.
+func (s *MemoryStorage) Select(ctx context.Context, kind, name string, matchers ...models.LabelMatcher) (
+	[]models.Metric, error) {
+	if !isValidKind(kind) {
+		return nil, fmt.Errorf("%w: metric kind '%s'", models.ErrNotSupported, kind)
+	}
+	tenant := models.TenantFromContext(ctx)
+	shard := s.shard(kind, name)
+	shard.mutex.RLock()
+	metrics := make([]models.Metric, 0)
+	for key, metric := range shard.metrics {
+		if key.tenant == tenant && key.kind == kind && key.name == name &&
+			models.MatchLabels(metric.Labels, matchers...) {
+			metrics = append(metrics, metric)
+		}
+	}
+	shard.mutex.RUnlock()
+	slices.SortFunc(metrics, models.CompareMetrics)
 	return metrics, nil
 }
 
-// Flush saves metrics to destination.
-func (s *MemoryStorage) Flush(ctx context.Context, dst io.Writer) error {
-	metrics, _ := s.GetAll(ctx)
+// List returns page of metrics matching query sorted by name, kind and labels.
+// Only first metrics of page are kept while shards are scanned, so memory doesn't depend on count of metrics.
+func (s *MemoryStorage) List(ctx context.Context, query models.ListQuery) (models.ListPage, error) {
+	tenant := models.TenantFromContext(ctx)
+	// one more metric shows whether next page exists
+	first := &metricsHeap{limit: query.Limit + 1}
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.RLock()
+		for key, metric := range shard.metrics {
+			if key.tenant == tenant && query.Matches(metric) {
+				first.add(metric)
+			}
+		}
+		shard.mutex.RUnlock()
+	}
+	return models.NewListPage(first.sorted(), query.Limit), nil
+}
+
+// metricsHeap keeps first metrics in order of models.CompareMetrics up to limit, the last one is on top.
+type metricsHeap struct {
+	metrics []models.Metric
+	limit   int
+}
+
+func (h *metricsHeap) Len() int {
+	return len(h.metrics)
+}
+
+func (h *metricsHeap) Less(i, j int) bool {
+	return models.CompareMetrics(h.metrics[i], h.metrics[j]) > 0
+}
+
+func (h *metricsHeap) Swap(i, j int) {
+	h.metrics[i], h.metrics[j] = h.metrics[j], h.metrics[i]
+}
+
+func (h *metricsHeap) Push(x any) {
+	h.metrics = append(h.metrics, x.(models.Metric))
+}
+
+func (h *metricsHeap) Pop() any {
+	last := h.metrics[len(h.metrics)-1]
+	h.metrics = h.metrics[:len(h.metrics)-1]
+	return last
+}
+
+// add keeps metric if it is one of first metrics.
+func (h *metricsHeap) add(metric models.Metric) {
+	if h.Len() < h.limit {
+		heap.Push(h, metric)
+		return
+	}
+	if models.CompareMetrics(metric, h.metrics[0]) < 0 {
+		h.metrics[0] = metric
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted returns kept metrics in order.
+func (h *metricsHeap) sorted() []models.Metric {
+	slices.SortFunc(h.metrics, models.CompareMetrics)
+	return h.metrics
+}
+
+// GetRange returns samples of metric without labels stored between from and to inclusive.
+func (s *MemoryStorage) GetRange(ctx context.Context, kind, name string, from, to time.Time) ([]models.Sample,
+	error) {
+	if !isValidKind(kind) {
+		return nil, fmt.Errorf("%w: metric kind '%s'", models.ErrNotSupported, kind)
+	}
+	key := metricKey{tenant: models.TenantFromContext(ctx), kind: kind, name: name}
+	shard := s.shard(kind, name)
+	shard.mutex.RLock()
+	defer shard.mutex.RUnlock()
+	if _, ok := shard.metrics[key]; !ok {
+		return nil, models.ErrHTTPNotFound
+	}
+	samples := make([]models.Sample, 0)
+	for _, sample := range shard.history[key] {
+		if sample.Timestamp.Before(from) || sample.Timestamp.After(to) {
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples, nil
+}
+
+// PruneHistory removes samples of all tenants stored before specified time.
+func (s *MemoryStorage) PruneHistory(ctx context.Context, before time.Time) error {
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.Lock()
+		for key, samples := range shard.history {
+			// samples are appended in chronological order
+			i := sort.Search(len(samples), func(i int) bool {
+				return !samples[i].Timestamp.Before(before)
+			})
+			if i == 0 {
+				continue
+			}
+			shard.history[key] = slices.Clone(samples[i:])
+		}
+		shard.mutex.Unlock()
+	}
+	return nil
+}
+
+// Delete removes metric of all label sets by kind and name.
+func (s *MemoryStorage) Delete(ctx context.Context, kind, name string) error {
+	if !isValidKind(kind) {
+		return fmt.Errorf("%w: metric kind '%s'", models.ErrNotSupported, kind)
+	}
+	tenant := models.TenantFromContext(ctx)
+	shard := s.shard(kind, name)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	deleted := 0
+	for key := range shard.metrics {
+		if key.tenant == tenant && key.kind == kind && key.name == name {
+			delete(shard.metrics, key)
+			delete(shard.history, key)
+			delete(shard.meta, key)
+			deleted++
+		}
+	}
+	if deleted == 0 {
+		return models.ErrHTTPNotFound
+	}
+	return nil
+}
+
+// DeleteByPrefix removes metrics of all kinds with name prefix. Returns count of removed metrics.
+func (s *MemoryStorage) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	tenant := models.TenantFromContext(ctx)
+	unlock := s.lockAll()
+	defer unlock()
+	deleted := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		for key := range shard.metrics {
+			if key.tenant == tenant && strings.HasPrefix(key.name, prefix) {
+				delete(shard.metrics, key)
+				delete(shard.history, key)
+				delete(shard.meta, key)
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// DeleteStale removes metrics of all tenants which are stale at now according to policy.
+// Returns count of removed metrics.
+func (s *MemoryStorage) DeleteStale(ctx context.Context, policy TTLPolicy, now time.Time) (int, error) {
+	if !policy.Enabled() {
+		return 0, nil
+	}
+	deleted := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mutex.Lock()
+		for key, meta := range shard.meta {
+			if policy.IsStale(key.name, meta.UpdatedAt, now) {
+				delete(shard.metrics, key)
+				delete(shard.history, key)
+				delete(shard.meta, key)
+				deleted++
+			}
+		}
+		shard.mutex.Unlock()
+	}
+	return deleted, nil
+}
+
+// stale returns tenant, kind, name and labels of metrics which are stale at now according to policy.
+func (s *MemoryStorage) stale(policy TTLPolicy, now time.Time) []models.Metric {
+	metrics := make([]models.Metric, 0)
+	if !policy.Enabled() {
+		return metrics
+	}
+	unlock := s.rlockAll()
+	defer unlock()
+	for i := range s.shards {
+		shard := &s.shards[i]
+		for key, meta := range shard.meta {
+			if policy.IsStale(key.name, meta.UpdatedAt, now) {
+				metric := shard.metrics[key]
+				metrics = append(metrics, models.Metric{Kind: metric.Kind, Name: metric.Name, Labels: metric.Labels,
+					Tenant: key.tenant})
+			}
+		}
+	}
+	return metrics
+}
+
+// deleteMetrics removes metrics with the same tenant, kind, name and labels. Returns count of removed metrics.
+func (s *MemoryStorage) deleteMetrics(metrics []models.Metric) int {
+	deleted := 0
 	for _, metric := range metrics {
-		m, err := models.ConvertV1ToV2(&metric)
-		if err != nil {
-			return err
+		key := newMetricKey(metric)
+		shard := s.shard(metric.Kind, metric.Name)
+		shard.mutex.Lock()
+		if _, ok := shard.metrics[key]; ok {
+			delete(shard.metrics, key)
+			delete(shard.history, key)
+			delete(shard.meta, key)
+			deleted++
 		}
-		err = json.NewEncoder(dst).Encode(m)
-		if err != nil {
-			return fmt.Errorf("error encode metric %s: %w", metric, err)
+		shard.mutex.Unlock()
+	}
+	return deleted
+}
+
+// ResetCounter sets counter value of all label sets to zero.
+func (s *MemoryStorage) ResetCounter(ctx context.Context, name string) error {
+	return s.resetCounterAt(models.TenantFromContext(ctx), name, time.Now(), models.SourceFromContext(ctx))
+}
+
+// resetCounterAt sets counter value of tenant to zero by request of source with sample stored at ts.
+func (s *MemoryStorage) resetCounterAt(tenant, name string, ts time.Time, source string) error {
+	shard := s.shard(models.MetricKindCounter, name)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+	found := false
+	for key, metric := range shard.metrics {
+		if key.tenant != tenant || key.kind != models.MetricKindCounter || key.name != name {
+			continue
 		}
+		found = true
+		metric.Delta = 0
+		shard.metrics[key] = metric
+		shard.history[key] = append(shard.history[key], models.Sample{
+			Timestamp: ts,
+		})
+		shard.meta[key] = models.Metadata{UpdatedAt: ts, Source: source, Version: shard.meta[key].Version + 1}
+	}
+	if !found {
+		return models.ErrHTTPNotFound
 	}
 	return nil
 }
 
-// Load loads metrics from source.
+// Flush saves metrics of all tenants to destination.
+func (s *MemoryStorage) Flush(ctx context.Context, dst io.Writer) error {
+	return encodeMetrics(dst, s.all(nil))
+}
+
+// Load loads metrics from source, every metric is restored to its own tenant.
 func (s *MemoryStorage) Load(ctx context.Context, src io.Reader) error {
-	scanner := bufio.NewScanner(src)
-	for scanner.Scan() {
-		m := models.MetricV2{}
-		err := json.Unmarshal(scanner.Bytes(), &m)
-		if err != nil {
-			return fmt.Errorf("error unmarshal metric %s: %w", scanner.Text(), err)
-		}
-		res, err := models.ConvertV2ToV1(&m)
-		if err != nil {
-			return err
-		}
-		if err = s.Upsert(ctx, *res); err != nil {
-			return fmt.Errorf("error add or update metric %s: %w", res.Name, err)
+	metrics, err := decodeMetrics(src)
+	if err != nil {
+		return err
+	}
+	for _, metric := range metrics {
+		if err = s.massUpsertAt([]models.Metric{metric}, time.Now(), models.SourceFromContext(ctx)); err != nil {
+			return fmt.Errorf("error add or update metric %s: %w", metric.Name, err)
 		}
 	}
 	return nil
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/models"
+)
+
+// benchMemoryStorage is part of memory backend used by parallel benchmarks.
+type benchMemoryStorage interface {
+	MassUpsert(ctx context.Context, metrics []models.Metric) error
+	Get(ctx context.Context, kind, name string) (models.Metric, error)
+}
+
+// globalLockStorage is previous MemoryStorage implementation with one mutex for all metrics,
+// kept for comparison.
+type globalLockStorage struct {
+	metrics map[metricKey]models.Metric
+	history map[metricKey][]models.Sample
+	mutex   sync.Mutex
+}
+
+func newGlobalLockStorage() *globalLockStorage {
+	return &globalLockStorage{
+		metrics: make(map[metricKey]models.Metric, models.TotalCountMetrics),
+		history: make(map[metricKey][]models.Sample, models.TotalCountMetrics),
+	}
+}
+
+func (s *globalLockStorage) MassUpsert(ctx context.Context, metrics []models.Metric) error {
+	ts := time.Now()
+	for _, metric := range metrics {
+		s.mutex.Lock()
+		err := s.upsert(metric, ts)
+		s.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *globalLockStorage) upsert(metric models.Metric, ts time.Time) error {
+	metric.Labels = metric.Labels.Normalize()
+	key := newMetricKey(metric)
+	if metric.Kind == models.MetricKindCounter {
+		metric.Delta += s.metrics[key].Delta
+	}
+	s.metrics[key] = metric
+	s.history[key] = append(s.history[key], models.Sample{Timestamp: ts, Delta: metric.Delta, Value: metric.Value})
+	return nil
+}
+
+func (s *globalLockStorage) Get(ctx context.Context, kind, name string) (models.Metric, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if metric, ok := s.metrics[metricKey{kind: kind, name: name}]; ok {
+		return metric, nil
+	}
+	return models.Metric{}, models.ErrHTTPNotFound
+}
+
+// genAgentMetrics returns report of agent with own metric names.
+func genAgentMetrics(agent int64) []models.Metric {
+	metrics := genMetrics(models.TotalCountMetrics)
+	for i := range metrics {
+		metrics[i].Name = fmt.Sprintf("agent%d_%s", agent, metrics[i].Name)
+	}
+	return append(metrics, models.Metric{
+		Kind:  models.MetricKindCounter,
+		Name:  fmt.Sprintf("agent%d_PollCount", agent),
+		Delta: 1,
+	})
+}
+
+func benchmarkStorages() []struct {
+	newStorage func() benchMemoryStorage
+	name       string
+} {
+	return []struct {
+		newStorage func() benchMemoryStorage
+		name       string
+	}{
+		{name: "global lock", newStorage: func() benchMemoryStorage { return newGlobalLockStorage() }},
+		{name: "sharded", newStorage: func() benchMemoryStorage { return NewMemoryStorage() }},
+	}
+}
+
+// BenchmarkMemoryStorage_ParallelMassUpsert simulates many agents posting reports concurrently.
+func BenchmarkMemoryStorage_ParallelMassUpsert(b *testing.B) {
+	for _, bs := range benchmarkStorages() {
+		b.Run(bs.name, func(b *testing.B) {
+			st := bs.newStorage()
+			var agents atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				report := genAgentMetrics(agents.Add(1))
+				for pb.Next() {
+					_ = st.MassUpsert(context.Background(), report)
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkMemoryStorage_ParallelMixed simulates agents posting reports while clients read metrics.
+// Every tenth operation is write.
+func BenchmarkMemoryStorage_ParallelMixed(b *testing.B) {
+	for _, bs := range benchmarkStorages() {
+		b.Run(bs.name, func(b *testing.B) {
+			st := bs.newStorage()
+			var agents atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				report := genAgentMetrics(agents.Add(1))
+				_ = st.MassUpsert(context.Background(), report)
+				for i := 0; pb.Next(); i++ {
+					if i%10 == 0 {
+						_ = st.MassUpsert(context.Background(), report)
+						continue
+					}
+					m := report[i%len(report)]
+					_, _ = st.Get(context.Background(), m.Kind, m.Name)
+				}
+			})
+		})
+	}
+}
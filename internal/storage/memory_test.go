@@ -6,8 +6,11 @@ import (
 	"io"
 	"math/rand/v2"
 	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/sejo412/ya-metrics/internal/models"
 )
@@ -31,7 +34,7 @@ func TestMemoryStorage_Upsert(t *testing.T) {
 				metric: models.Metric{
 					Kind:  models.MetricKindGauge,
 					Name:  "testGauge1",
-					Value: "9999.11",
+					Value: 9999.11,
 				},
 			},
 			wantErr: false,
@@ -42,7 +45,7 @@ func TestMemoryStorage_Upsert(t *testing.T) {
 				metric: models.Metric{
 					Kind:  models.MetricKindCounter,
 					Name:  "testCounter1",
-					Value: "1",
+					Delta: 1,
 				},
 			},
 			wantErr: false,
@@ -53,18 +56,18 @@ func TestMemoryStorage_Upsert(t *testing.T) {
 				metric: models.Metric{
 					Kind:  models.MetricKindCounter,
 					Name:  "testCounter1",
-					Value: "2",
+					Delta: 2,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "update counter Error",
+			name: "update unsupported Error",
 			args: args{
 				metric: models.Metric{
-					Kind:  models.MetricKindCounter,
+					Kind:  "preved",
 					Name:  "testCounter1",
-					Value: "preved",
+					Delta: 1,
 				},
 			},
 			wantErr: true,
@@ -100,7 +103,7 @@ func TestMemoryStorage_Get(t *testing.T) {
 			want: models.Metric{
 				Kind:  models.MetricKindGauge,
 				Name:  "testGauge1",
-				Value: "9999.11",
+				Value: 9999.11,
 			},
 			wantErr: false,
 		},
@@ -145,12 +148,12 @@ func TestMemoryStorage_MassUpsert(t *testing.T) {
 					{
 						Kind:  models.MetricKindGauge,
 						Name:  "testGauge2",
-						Value: "9999.22",
+						Value: 9999.22,
 					},
 					{
 						Kind:  models.MetricKindCounter,
 						Name:  "testCounter2",
-						Value: "1",
+						Delta: 1,
 					},
 				},
 			},
@@ -163,12 +166,12 @@ func TestMemoryStorage_MassUpsert(t *testing.T) {
 					{
 						Kind:  models.MetricKindGauge,
 						Name:  "testGauge3",
-						Value: "9999.33",
+						Value: 9999.33,
 					},
 					{
-						Kind:  models.MetricKindCounter,
+						Kind:  "preved",
 						Name:  "testCounter2",
-						Value: "preved",
+						Delta: 1,
 					},
 				},
 			},
@@ -184,7 +187,7 @@ func TestMemoryStorage_MassUpsert(t *testing.T) {
 	}
 }
 
-func TestMemoryStorage_GetAll(t *testing.T) {
+func TestMemoryStorage_All(t *testing.T) {
 	type args struct {
 		ctx context.Context
 	}
@@ -200,27 +203,22 @@ func TestMemoryStorage_GetAll(t *testing.T) {
 				{
 					Kind:  models.MetricKindCounter,
 					Name:  "testCounter1",
-					Value: "3",
+					Delta: 3,
 				},
 				{
 					Kind:  models.MetricKindCounter,
 					Name:  "testCounter2",
-					Value: "1",
+					Delta: 1,
 				},
 				{
 					Kind:  models.MetricKindGauge,
 					Name:  "testGauge1",
-					Value: "9999.11",
+					Value: 9999.11,
 				},
 				{
 					Kind:  models.MetricKindGauge,
 					Name:  "testGauge2",
-					Value: "9999.22",
-				},
-				{
-					Kind:  models.MetricKindGauge,
-					Name:  "testGauge3",
-					Value: "9999.33",
+					Value: 9999.22,
 				},
 			},
 			wantErr: false,
@@ -228,13 +226,13 @@ func TestMemoryStorage_GetAll(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := memoryStorageTest.GetAll(tt.args.ctx)
+			got, err := collectMetrics(memoryStorageTest.All(tt.args.ctx))
 			if (err != nil) != tt.wantErr {
-				t.Errorf("GetAll() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("All() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 			if !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("GetAll() got = %v, want %v", got, tt.want)
+				t.Errorf("All() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -252,11 +250,10 @@ func TestMemoryStorage_Flush(t *testing.T) {
 	}{
 		{
 			name: "flush OK",
-			wantDst: `{"delta":3,"id":"testCounter1","type":"counter"}
-{"delta":1,"id":"testCounter2","type":"counter"}
+			wantDst: `{"delta":1,"id":"testCounter2","type":"counter"}
+{"delta":3,"id":"testCounter1","type":"counter"}
 {"value":9999.11,"id":"testGauge1","type":"gauge"}
 {"value":9999.22,"id":"testGauge2","type":"gauge"}
-{"value":9999.33,"id":"testGauge3","type":"gauge"}
 `,
 			wantErr: false,
 		},
@@ -268,7 +265,10 @@ func TestMemoryStorage_Flush(t *testing.T) {
 				t.Errorf("Flush() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if gotDst := bufferTest.String(); gotDst != tt.wantDst {
+			// metrics are flushed in unspecified order
+			lines := strings.SplitAfter(bufferTest.String(), "\n")
+			slices.Sort(lines)
+			if gotDst := strings.Join(lines, ""); gotDst != tt.wantDst {
 				t.Errorf("Flush() gotDst = %v, want %v", gotDst, tt.wantDst)
 			}
 		})
@@ -322,9 +322,9 @@ func BenchmarkMemoryStorage_MassUpsert(b *testing.B) {
 	}
 }
 
-func BenchmarkMemoryStorage_GetAll(b *testing.B) {
+func BenchmarkMemoryStorage_All(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		_, _ = benchStorage.GetAll(context.Background())
+		_, _ = collectMetrics(benchStorage.All(context.Background()))
 	}
 }
 
@@ -334,7 +334,7 @@ func genMetrics(count int) []models.Metric {
 		metrics[i] = models.Metric{
 			Kind:  models.MetricKindGauge,
 			Name:  "testGauge" + strconv.Itoa(i),
-			Value: strconv.FormatFloat(rand.Float64(), 'f', -1, 64),
+			Value: rand.Float64(),
 		}
 	}
 	return metrics
@@ -432,17 +432,120 @@ func TestMemoryStorage_Close(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := &MemoryStorage{}
-			s.metrics = make(map[string]models.Metric)
-			s.metrics["testGauge1"] = models.Metric{
+			s := NewMemoryStorage()
+			_ = s.Upsert(context.Background(), models.Metric{
 				Kind:  models.MetricKindGauge,
 				Name:  "testGauge1",
-				Value: "9999.11",
-			}
+				Value: 9999.11,
+			})
 			s.Close()
-			if s.metrics != nil {
-				t.Errorf("Close() metrics = %v", s.metrics)
+			for i := range s.shards {
+				if s.shards[i].metrics != nil {
+					t.Errorf("Close() metrics = %v", s.shards[i].metrics)
+				}
 			}
 		})
 	}
 }
+
+func TestMemoryStorage_GetRange(t *testing.T) {
+	s := NewMemoryStorage()
+	from := time.Now()
+	_ = s.Upsert(context.Background(), models.Metric{
+		Kind:  models.MetricKindCounter,
+		Name:  "testCounter1",
+		Delta: 1,
+	})
+	_ = s.Upsert(context.Background(), models.Metric{
+		Kind:  models.MetricKindCounter,
+		Name:  "testCounter1",
+		Delta: 2,
+	})
+	to := time.Now()
+	type args struct {
+		from time.Time
+		to   time.Time
+		name string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []int64
+		wantErr bool
+	}{
+		{
+			name: "all samples",
+			args: args{
+				name: "testCounter1",
+				from: from,
+				to:   to,
+			},
+			want:    []int64{1, 3},
+			wantErr: false,
+		},
+		{
+			name: "samples out of range",
+			args: args{
+				name: "testCounter1",
+				from: to.Add(time.Second),
+				to:   to.Add(2 * time.Second),
+			},
+			want:    []int64{},
+			wantErr: false,
+		},
+		{
+			name: "not found",
+			args: args{
+				name: "testCounter2",
+				from: from,
+				to:   to,
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.GetRange(context.Background(), models.MetricKindCounter, tt.args.name, tt.args.from,
+				tt.args.to)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetRange() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			values := make([]int64, 0, len(got))
+			for _, sample := range got {
+				values = append(values, sample.Delta)
+			}
+			if !reflect.DeepEqual(values, tt.want) {
+				t.Errorf("GetRange() got = %v, want %v", values, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryStorage_PruneHistory(t *testing.T) {
+	s := NewMemoryStorage()
+	_ = s.Upsert(context.Background(), models.Metric{
+		Kind:  models.MetricKindGauge,
+		Name:  "testGauge1",
+		Value: 1.1,
+	})
+	before := time.Now()
+	_ = s.Upsert(context.Background(), models.Metric{
+		Kind:  models.MetricKindGauge,
+		Name:  "testGauge1",
+		Value: 2.2,
+	})
+	if err := s.PruneHistory(context.Background(), before); err != nil {
+		t.Fatalf("PruneHistory() error = %v", err)
+	}
+	got, err := s.GetRange(context.Background(), models.MetricKindGauge, "testGauge1", time.Time{}, time.Now())
+	if err != nil {
+		t.Fatalf("GetRange() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Value != 2.2 {
+		t.Errorf("PruneHistory() left = %v, want only 2.2", got)
+	}
+}
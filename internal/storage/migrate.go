@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// TblSchemaMigrations - table name for applied schema migrations.
+const TblSchemaMigrations = "schema_migrations"
+
+// migrationsLockID - key of advisory lock, so only one server migrates schema at once.
+const migrationsLockID int64 = 7_952_148_301
+
+// migrationsFS contains up migrations named like 0001_description.sql.
+//
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// migration describes one schema change.
+type migration struct {
+	name    string
+	query   string
+	version int
+}
+
+// loadMigrations returns migrations from directory ordered by version.
+// Versions must start from 1 without gaps.
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+		prefix, _, _ := strings.Cut(name, "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("invalid migration version '%s'", entry.Name())
+		}
+		query, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read migration '%s': %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, query: string(query)})
+	}
+	slices.SortFunc(migrations, func(a, b migration) int {
+		return a.version - b.version
+	})
+	for i, m := range migrations {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("migration '%s' has version %d, want %d", m.name, m.version, i+1)
+		}
+	}
+	return migrations, nil
+}
+
+// migrate applies migrations newer than current schema version. Each migration is applied in own transaction.
+// Returns schema version after migration.
+func (p *PostgresStorage) migrate(ctx context.Context, migrations []migration) (int, error) {
+	query := `CREATE TABLE IF NOT EXISTS ` + TblSchemaMigrations + ` (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`
+	if _, err := p.Client.Exec(ctx, query); err != nil {
+		return 0, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+	version := 0
+	for _, m := range migrations {
+		err := p.withTx(ctx, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock($1);`, migrationsLockID); err != nil {
+				return fmt.Errorf("failed to lock migrations: %w", err)
+			}
+			// other server may have migrated schema while we waited for lock
+			current, err := schemaVersion(ctx, tx)
+			if err != nil {
+				return err
+			}
+			if m.version <= current {
+				version = current
+				return nil
+			}
+			if _, err = tx.Exec(ctx, m.query); err != nil {
+				return fmt.Errorf("failed to apply migration '%s': %w", m.name, err)
+			}
+			query := `INSERT INTO ` + TblSchemaMigrations + ` (version, name) VALUES ($1, $2);`
+			if _, err = tx.Exec(ctx, query, m.version, m.name); err != nil {
+				return fmt.Errorf("failed to save migration '%s': %w", m.name, err)
+			}
+			version = m.version
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	return version, nil
+}
+
+// SchemaVersion returns version of last applied migration.
+func (p *PostgresStorage) SchemaVersion(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	return schemaVersion(ctx, p.Client)
+}
+
+// queryRower is implemented by *pgxpool.Pool and pgx.Tx.
+type queryRower interface {
+	QueryRow(ctx context.Context, query string, args ...any) pgx.Row
+}
+
+func schemaVersion(ctx context.Context, q queryRower) (int, error) {
+	var version int
+	query := `SELECT COALESCE(MAX(version), 0) FROM ` + TblSchemaMigrations + `;`
+	if err := q.QueryRow(ctx, query).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to get schema version: %w", err)
+	}
+	return version, nil
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func Test_loadMigrations(t *testing.T) {
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []migration
+		wantErr bool
+	}{
+		{
+			name: "ordered by version",
+			fsys: fstest.MapFS{
+				"migrations/0002_second.sql": {Data: []byte("SELECT 2;")},
+				"migrations/0001_first.sql":  {Data: []byte("SELECT 1;")},
+				"migrations/README.md":       {Data: []byte("skipped")},
+			},
+			want: []migration{
+				{version: 1, name: "0001_first", query: "SELECT 1;"},
+				{version: 2, name: "0002_second", query: "SELECT 2;"},
+			},
+		},
+		{
+			name: "gap in versions",
+			fsys: fstest.MapFS{
+				"migrations/0001_first.sql": {Data: []byte("SELECT 1;")},
+				"migrations/0003_third.sql": {Data: []byte("SELECT 3;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicated version",
+			fsys: fstest.MapFS{
+				"migrations/0001_first.sql":  {Data: []byte("SELECT 1;")},
+				"migrations/0001_second.sql": {Data: []byte("SELECT 2;")},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid version",
+			fsys: fstest.MapFS{
+				"migrations/first.sql": {Data: []byte("SELECT 1;")},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadMigrations(tt.fsys, "migrations")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("loadMigrations() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadMigrations() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_loadMigrationsEmbedded(t *testing.T) {
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		t.Fatalf("loadMigrations() error = %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Errorf("loadMigrations() returned no migrations")
+	}
+}
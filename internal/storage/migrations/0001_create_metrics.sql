@@ -0,0 +1,19 @@
+-- metric names and current values
+CREATE TABLE IF NOT EXISTS metric_mapping (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    name VARCHAR(60) NOT NULL UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS metric_gauges (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    metric_id INTEGER NOT NULL UNIQUE,
+    value DOUBLE PRECISION NOT NULL,
+    FOREIGN KEY (metric_id) REFERENCES metric_mapping (id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS metric_counters (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    metric_id INTEGER NOT NULL UNIQUE,
+    value BIGINT NOT NULL,
+    FOREIGN KEY (metric_id) REFERENCES metric_mapping (id) ON DELETE CASCADE
+);
@@ -0,0 +1,22 @@
+-- timestamped metric values
+CREATE TABLE IF NOT EXISTS metric_gauges_history (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    metric_id INTEGER NOT NULL,
+    ts TIMESTAMPTZ NOT NULL DEFAULT now(),
+    value DOUBLE PRECISION NOT NULL,
+    FOREIGN KEY (metric_id) REFERENCES metric_mapping (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS metric_gauges_history_metric_id_ts_idx
+    ON metric_gauges_history (metric_id, ts);
+
+CREATE TABLE IF NOT EXISTS metric_counters_history (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    metric_id INTEGER NOT NULL,
+    ts TIMESTAMPTZ NOT NULL DEFAULT now(),
+    value BIGINT NOT NULL,
+    FOREIGN KEY (metric_id) REFERENCES metric_mapping (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS metric_counters_history_metric_id_ts_idx
+    ON metric_counters_history (metric_id, ts);
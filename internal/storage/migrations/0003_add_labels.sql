@@ -0,0 +1,7 @@
+-- metric is identified by name and labels
+ALTER TABLE metric_mapping ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+
+ALTER TABLE metric_mapping DROP CONSTRAINT IF EXISTS metric_mapping_name_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS metric_mapping_name_labels_idx
+    ON metric_mapping (name, labels);
@@ -0,0 +1,2 @@
+-- names with prefixes of agents don't fit into 60 characters
+ALTER TABLE metric_mapping ALTER COLUMN name TYPE VARCHAR(255);
@@ -0,0 +1,4 @@
+-- last update time of metrics for expiry
+ALTER TABLE metric_gauges ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
+
+ALTER TABLE metric_counters ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT now();
@@ -0,0 +1,10 @@
+-- optional unit and description of metrics and address of last writer
+ALTER TABLE metric_gauges
+    ADD COLUMN IF NOT EXISTS unit TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE metric_counters
+    ADD COLUMN IF NOT EXISTS unit TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS source TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,26 @@
+-- summary quantile sketches, sketches are merged by storage before update
+CREATE TABLE IF NOT EXISTS metric_summaries (
+    id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    metric_id INTEGER NOT NULL UNIQUE,
+    sketch JSONB NOT NULL,
+    sum DOUBLE PRECISION NOT NULL,
+    count BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    unit TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
+    FOREIGN KEY (metric_id) REFERENCES metric_mapping (id) ON DELETE CASCADE
+);
+
+-- timestamped count and sum of observations
+CREATE TABLE IF NOT EXISTS metric_summaries_history (
+    id BIGINT GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    metric_id INTEGER NOT NULL,
+    ts TIMESTAMPTZ NOT NULL DEFAULT now(),
+    count BIGINT NOT NULL,
+    sum DOUBLE PRECISION NOT NULL,
+    FOREIGN KEY (metric_id) REFERENCES metric_mapping (id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS metric_summaries_history_metric_id_ts_idx
+    ON metric_summaries_history (metric_id, ts);
@@ -0,0 +1,8 @@
+-- versions of metrics for conditional updates, incremented by every update
+ALTER TABLE metric_gauges ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE metric_counters ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE metric_histograms ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
+
+ALTER TABLE metric_summaries ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
@@ -0,0 +1,7 @@
+-- metric is identified by tenant, name and labels
+ALTER TABLE metric_mapping ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT '';
+
+DROP INDEX IF EXISTS metric_mapping_name_labels_idx;
+
+CREATE UNIQUE INDEX IF NOT EXISTS metric_mapping_tenant_name_labels_idx
+    ON metric_mapping (tenant, name, labels);
@@ -2,27 +2,75 @@ package storage
 
 import (
 	"context"
-	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"maps"
+	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
-	_ "github.com/jackc/pgx/v5/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sejo412/ya-metrics/internal/models"
 )
 
 // Table names for metrics.
 const (
-	TblGauges   = "metric_gauges"   // table name for Gauge metrics
-	TblCounters = "metric_counters" // table name for Counter metrics
-	TblMapping  = "metric_mapping"  // table name for mapping metrics
+	TblGauges     = "metric_gauges"     // table name for Gauge metrics
+	TblCounters   = "metric_counters"   // table name for Counter metrics
+	TblHistograms = "metric_histograms" // table name for Histogram metrics
+	TblSummaries  = "metric_summaries"  // table name for Summary metrics
+	TblMapping    = "metric_mapping"    // table name for mapping metrics
+
+	TblGaugesHistory     = "metric_gauges_history"     // table name for Gauge metrics history
+	TblCountersHistory   = "metric_counters_history"   // table name for Counter metrics history
+	TblHistogramsHistory = "metric_histograms_history" // table name for Histogram metrics history
+	TblSummariesHistory  = "metric_summaries_history"  // table name for Summary metrics history
 )
 
-// PostgresStorage is backend for PostgresSQL.
+// postgresKinds - metric kinds stored in tables.
+var postgresKinds = []string{
+	models.MetricKindGauge, models.MetricKindCounter, models.MetricKindHistogram, models.MetricKindSummary,
+}
+
+// PostgresStorage is backend for PostgresSQL. Names and labels of metrics are mapped to ids per tenant,
+// requests see metrics of tenant from their context only.
 type PostgresStorage struct {
-	Client *sql.DB
+	Client *pgxpool.Pool
+	retry  retryPolicy
+}
+
+// retryPolicy describes how to retry connecting to database.
+type retryPolicy struct {
+	// retries - how many times retry after first failed attempt.
+	retries int
+	// delay - delay before first retry, next delays grow linearly: delay, 3*delay, 5*delay, ...
+	delay time.Duration
+}
+
+// do calls fn until it succeeds, retries are exhausted or ctx is done.
+func (r retryPolicy) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(ctx); err == nil {
+			return nil
+		}
+		if attempt >= r.retries {
+			return fmt.Errorf("all attempts failed [%d], last error is: %w", attempt+1, err)
+		}
+		timer := time.NewTimer(r.delay * time.Duration(2*attempt+1))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w, last error is: %w", ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
 }
 
 // NewPostgresStorage returns new PostgresStorage object.
@@ -32,168 +80,803 @@ func NewPostgresStorage() *PostgresStorage {
 
 // Upsert inserts or updates metric.
 func (p *PostgresStorage) Upsert(ctx context.Context, metric models.Metric) error {
+	return p.MassUpsert(ctx, []models.Metric{metric})
+}
+
+// MassUpsert inserts or updates slice of metrics with one statement per metric kind.
+// Repeated counters, histograms and summaries are summed and repeated gauges take the last value,
+// so one history sample is stored per metric in batch.
+func (p *PostgresStorage) MassUpsert(ctx context.Context, metrics []models.Metric) error {
+	batch, err := newPostgresTenantBatch(models.TenantFromContext(ctx), metrics)
+	if err != nil {
+		return err
+	}
 	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
 	defer cancel()
 
-	query, args, err := postgresUpsertQueryByMetric(metric)
+	source := models.SourceFromContext(ctx)
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		return batch.upsert(ctx, tx, source)
+	})
+}
+
+// postgresTenantBatch contains batches of metrics of one tenant.
+type postgresTenantBatch struct {
+	gauges     *postgresBatch[float64]
+	counters   *postgresBatch[int64]
+	histograms *postgresBatch[*models.Histogram]
+	summaries  *postgresBatch[*models.Summary]
+	tenant     string
+}
+
+// newPostgresTenantBatch splits metrics of tenant to batches.
+func newPostgresTenantBatch(tenant string, metrics []models.Metric) (postgresTenantBatch, error) {
+	batch := postgresTenantBatch{tenant: tenant}
+	var err error
+	batch.gauges, batch.counters, batch.histograms, batch.summaries, err = newPostgresBatches(metrics)
 	if err != nil {
-		return fmt.Errorf("could not construct query: %w", err)
+		return postgresTenantBatch{}, fmt.Errorf("could not construct query: %w", err)
 	}
+	return batch, nil
+}
 
-	tx, err := p.Client.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+// upsert inserts or updates metrics of batch sent by source with one statement per metric kind.
+func (b postgresTenantBatch) upsert(ctx context.Context, tx pgx.Tx, source string) error {
+	if b.gauges.len() > 0 {
+		query := postgresBatchUpsertQuery(TblGauges, TblGaugesHistory, "DOUBLE PRECISION", "EXCLUDED.value")
+		if _, err := tx.Exec(ctx, query, b.gauges.names, b.gauges.labels, b.gauges.values, b.gauges.units,
+			b.gauges.descriptions, source, b.tenant); err != nil {
+			return fmt.Errorf("failed to insert/update gauges: %w", err)
+		}
 	}
-	defer func() {
-		if err != nil {
-			_ = tx.Rollback()
-		} else {
-			err = tx.Commit()
+	if b.counters.len() > 0 {
+		query := postgresBatchUpsertQuery(TblCounters, TblCountersHistory, "BIGINT",
+			TblCounters+".value + EXCLUDED.value")
+		if _, err := tx.Exec(ctx, query, b.counters.names, b.counters.labels, b.counters.values, b.counters.units,
+			b.counters.descriptions, source, b.tenant); err != nil {
+			return fmt.Errorf("failed to insert/update counters: %w", err)
+		}
+	}
+	if b.histograms.len() > 0 {
+		bounds, counts, sums, totals := postgresHistogramColumns(b.histograms.values)
+		if _, err := tx.Exec(ctx, postgresHistogramUpsertQuery(), b.histograms.names, b.histograms.labels, bounds,
+			counts, sums, totals, b.histograms.units, b.histograms.descriptions, source, b.tenant); err != nil {
+			return fmt.Errorf("failed to insert/update histograms: %w", err)
+		}
+	}
+	if b.summaries.len() > 0 {
+		if err := upsertPostgresSummaries(ctx, tx, b.summaries, b.tenant, source); err != nil {
+			return fmt.Errorf("failed to insert/update summaries: %w", err)
 		}
-	}()
-	if _, err = tx.ExecContext(ctx, query, args...); err != nil {
-		return fmt.Errorf("failed to insert/update metric: %w", err)
 	}
 	return nil
 }
 
-// MassUpsert inserts or updates slice of metrics.
-func (p *PostgresStorage) MassUpsert(ctx context.Context, metrics []models.Metric) error {
+// UpsertIf updates gauge if condition holds for stored one and returns new version of gauge.
+// Returns models.ErrConflict if condition fails.
+//
+// Stored gauge is locked until end of transaction, so concurrent updates of it are checked one by one.
+// Gauge inserted concurrently after check that it doesn't exist fails condition too.
+func (p *PostgresStorage) UpsertIf(ctx context.Context, metric models.Metric, cond models.Condition) (int64,
+	error) {
+	if err := validateCondition(metric, cond); err != nil {
+		return 0, err
+	}
+	labels, err := labelsToJSON(metric.Labels)
+	if err != nil {
+		return 0, err
+	}
 	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
 	defer cancel()
 
-	tx, err := p.Client.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
-	}
-	defer func() {
+	source := models.SourceFromContext(ctx)
+	var version int64
+	err = p.withTx(ctx, func(tx pgx.Tx) error {
+		id, err := postgresMappingID(ctx, tx, models.TenantFromContext(ctx), metric.Name, labels)
 		if err != nil {
-			_ = tx.Rollback()
-		} else {
-			err = tx.Commit()
+			return err
 		}
-	}()
-
-	for _, metric := range metrics {
-		query, args, err := postgresUpsertQueryByMetric(metric)
-		if err != nil {
-			return fmt.Errorf("could not construct query: %w", err)
+		var stored float64
+		err = tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT value, version
+		FROM %s
+		WHERE metric_id = $1
+		FOR UPDATE;`, TblGauges), id).Scan(&stored, &version)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to query: %w", err)
 		}
-		_, err = tx.ExecContext(ctx, query, args...)
-		if err != nil {
-			return fmt.Errorf("failed to insert/update metric: %w", err)
+		if err = cond.Check(version, stored, metric.Value); err != nil {
+			return err
+		}
+		if version == 0 {
+			res, err := tx.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (metric_id, value, unit, description, source)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (metric_id) DO NOTHING;`, TblGauges),
+				id, metric.Value, metric.Unit, metric.Description, source)
+			if err != nil {
+				return fmt.Errorf("failed to insert gauge: %w", err)
+			}
+			if res.RowsAffected() == 0 {
+				return fmt.Errorf("%w: gauge is inserted concurrently", models.ErrConflict)
+			}
+			version = 1
+		} else if err = tx.QueryRow(ctx, fmt.Sprintf(`
+		UPDATE %s
+		SET value = $2, updated_at = now(), source = $5, version = version + 1,
+			unit = COALESCE(NULLIF($3, ''), unit),
+			description = COALESCE(NULLIF($4, ''), description)
+		WHERE metric_id = $1
+		RETURNING version;`, TblGauges),
+			id, metric.Value, metric.Unit, metric.Description, source).Scan(&version); err != nil {
+			return fmt.Errorf("failed to update gauge: %w", err)
+		}
+		if _, err = tx.Exec(ctx, fmt.Sprintf(`
+		INSERT INTO %s (metric_id, value)
+		VALUES ($1, $2);`, TblGaugesHistory), id, metric.Value); err != nil {
+			return fmt.Errorf("failed to insert history: %w", err)
 		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
-	return nil
+	return version, nil
 }
 
-// Get returns metric by name.
+// Get returns metric without labels by kind and name.
 func (p *PostgresStorage) Get(ctx context.Context, kind, name string) (models.Metric, error) {
+	tbl, _, err := postgresTablesByKind(kind)
+	if err != nil {
+		return models.Metric{}, err
+	}
 	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
 	defer cancel()
-	var query string
-	switch kind {
-	// shut up linter
-	case models.MetricKindGauge:
-		query = fmt.Sprintf(`
-		SELECT m.name, $1 AS type, t.value::TEXT AS value
+	query := fmt.Sprintf(`
+		SELECT %s, %s
 		FROM %s m
 		JOIN %s t ON m.id = t.metric_id
-		WHERE m.name = $2;`,
-			TblMapping, TblGauges)
-	case models.MetricKindCounter:
-		query = fmt.Sprintf(`
-		SELECT m.name, $1 AS type, t.value::TEXT AS value
+		WHERE m.tenant = $2 AND m.name = $1 AND m.labels = '{}'::JSONB;`,
+		postgresMetricColumns(kind), postgresDescColumns, TblMapping, tbl)
+	metric := models.Metric{Kind: kind, Name: name, Tenant: models.TenantFromContext(ctx)}
+	var d postgresDistribution
+	err = p.Client.QueryRow(ctx, query, name, metric.Tenant).Scan(&metric.Delta, &metric.Value, &d.bounds,
+		&d.counts, &d.sketch, &metric.Unit, &metric.Description)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Metric{}, models.ErrHTTPNotFound
+		}
+		return models.Metric{}, fmt.Errorf("failed to query: %w", err)
+	}
+	if err = d.set(&metric); err != nil {
+		return models.Metric{}, err
+	}
+	return metric, nil
+}
+
+// GetMetadata returns metadata of metric with kind, name and labels of metric.
+func (p *PostgresStorage) GetMetadata(ctx context.Context, metric models.Metric) (models.Metadata, error) {
+	tbl, _, err := postgresTablesByKind(metric.Kind)
+	if err != nil {
+		return models.Metadata{}, err
+	}
+	labels, err := labelsToJSON(metric.Labels)
+	if err != nil {
+		return models.Metadata{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	query := fmt.Sprintf(`
+		SELECT t.updated_at, t.source, t.version
 		FROM %s m
 		JOIN %s t ON m.id = t.metric_id
-		WHERE m.name = $2;`,
-			TblMapping, TblCounters)
-	default:
-		return models.Metric{}, fmt.Errorf("invalid metric kind")
+		WHERE m.tenant = $3 AND m.name = $1 AND m.labels = $2::JSONB;`, TblMapping, tbl)
+	var meta models.Metadata
+	err = p.Client.QueryRow(ctx, query, metric.Name, labels, models.TenantFromContext(ctx)).Scan(&meta.UpdatedAt,
+		&meta.Source, &meta.Version)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Metadata{}, models.ErrHTTPNotFound
+		}
+		return models.Metadata{}, fmt.Errorf("failed to query: %w", err)
 	}
+	return meta, nil
+}
+
+// All returns iterator over all metrics in unspecified order.
+func (p *PostgresStorage) All(ctx context.Context) iter.Seq2[models.Metric, error] {
+	return p.all(ctx, postgresTenantFilter(ctx, nil))
+}
+
+// AllFresh returns iterator over metrics which are not stale at now according to policy.
+// Stale metrics are filtered by database, so they are not read.
+func (p *PostgresStorage) AllFresh(ctx context.Context, policy TTLPolicy, now time.Time) iter.Seq2[models.Metric,
+	error] {
+	filter := postgresTenantFilter(ctx, func(metric models.Metric, updated time.Time) bool {
+		return !policy.IsStale(metric.Name, updated, now)
+	})
+	filter.policy, filter.now = policy, now
+	return p.all(ctx, filter)
+}
+
+// postgresFilter describes filter of metrics selected by query with tenant,
+// metrics of all tenants are selected if tenant is nil. Metrics stale at now according to policy
+// are filtered by query as far as policy is translated to SQL, accept checks the rest of them.
+type postgresFilter struct {
+	tenant *string
+	policy TTLPolicy
+	now    time.Time
+	accept func(metric models.Metric, updated time.Time) bool
+}
 
-	var value string
-	if err := p.Client.QueryRowContext(ctx, query, kind, name).Scan(&name, &kind, &value); err != nil {
-		if err == sql.ErrNoRows {
-			return models.Metric{}, errors.New("metric not found")
+// postgresTenantFilter returns filter selecting metrics of tenant from ctx accepted by accept, all of them
+// if accept is nil.
+func postgresTenantFilter(ctx context.Context,
+	accept func(metric models.Metric, updated time.Time) bool) postgresFilter {
+	tenant := models.TenantFromContext(ctx)
+	return postgresFilter{tenant: &tenant, accept: accept}
+}
+
+// all returns iterator over metrics selected by filter, metrics of all tenants if filter is zero.
+// Rows are scanned while consumer iterates, so metrics are not kept in memory.
+// Iteration lasts as long as consumer needs, so it is bounded by ctx of caller only.
+func (p *PostgresStorage) all(ctx context.Context, filter postgresFilter) iter.Seq2[models.Metric, error] {
+	return func(yield func(models.Metric, error) bool) {
+		query, args := postgresAllQuery(filter)
+		rows, err := p.Client.Query(ctx, query, args...)
+		if err != nil {
+			yield(models.Metric{}, fmt.Errorf("failed to query: %w", err))
+			return
 		}
-		return models.Metric{}, fmt.Errorf("failed to query: %w", err)
+		defer rows.Close()
+		for rows.Next() {
+			var updated time.Time
+			var tenant string
+			metric, err := scanMetric(rows, &updated, &tenant)
+			if err != nil {
+				yield(models.Metric{}, err)
+				return
+			}
+			metric.Tenant = tenant
+			if filter.accept != nil && !filter.accept(metric, updated) {
+				continue
+			}
+			if !yield(metric, nil) {
+				return
+			}
+		}
+		if err = rows.Err(); err != nil {
+			yield(models.Metric{}, fmt.Errorf("failed to iterate: %w", err))
+		}
+	}
+}
+
+// postgresAllQuery returns query selecting metrics of all kinds by filter with update time and tenant.
+func postgresAllQuery(filter postgresFilter) (string, []any) {
+	args := make([]any, 0)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	kinds := make([]string, len(postgresKinds))
+	for i, kind := range postgresKinds {
+		kinds[i] = arg(kind)
+	}
+	where := make([]string, 0)
+	if filter.tenant != nil {
+		where = append(where, "m.tenant = "+arg(*filter.tenant))
+	}
+	if fresh := postgresFreshCondition(filter.policy, filter.now, arg); fresh != "" {
+		where = append(where, fresh)
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = "\n\t\tWHERE " + strings.Join(where, " AND ")
+	}
+	selects := make([]string, len(postgresKinds))
+	for i, kind := range postgresKinds {
+		tbl, _, _ := postgresTablesByKind(kind)
+		selects[i] = fmt.Sprintf(`
+		SELECT m.name, %s AS type, %s, m.labels::TEXT AS labels, %s, t.updated_at, m.tenant
+		FROM %s m
+		JOIN %s t ON m.id = t.metric_id%s`, kinds[i], postgresMetricColumns(kind), postgresDescColumns, TblMapping,
+			tbl, cond)
 	}
-	return models.Metric{
-		Kind:  kind,
-		Name:  name,
-		Value: value,
-	}, nil
+	return strings.Join(selects, "\n\t\tUNION ALL") + ";", args
 }
 
-// GetAll returns slice of all metrics.
-func (p *PostgresStorage) GetAll(ctx context.Context) ([]models.Metric, error) {
+// postgresFreshCondition returns condition selecting metrics of mapping m updated in table t which are not
+// stale at now according to policy, empty if metrics live forever. Patterns of rules are matched by regexps
+// up to the first pattern with character class, names not matching preceding rules are selected
+// by the longest TTL of the rest rules then.
+func postgresFreshCondition(policy TTLPolicy, now time.Time, arg func(v any) string) string {
+	if !policy.Enabled() {
+		return ""
+	}
+	fresh := func(ttl time.Duration) string {
+		if ttl == 0 {
+			return "TRUE"
+		}
+		return "t.updated_at >= " + arg(now.Add(-ttl))
+	}
+	cases := make([]string, 0, len(policy.Rules))
+	rest := policy.TTL
+	for i, rule := range policy.Rules {
+		re, ok := postgresPatternRegexp(rule.Pattern)
+		if !ok {
+			for _, r := range policy.Rules[i:] {
+				if rest == 0 || r.TTL == 0 {
+					rest = 0
+					break
+				}
+				rest = max(rest, r.TTL)
+			}
+			break
+		}
+		cases = append(cases, fmt.Sprintf("WHEN m.name ~ %s THEN %s", arg(re), fresh(rule.TTL)))
+	}
+	if len(cases) == 0 {
+		if rest == 0 {
+			return ""
+		}
+		return fresh(rest)
+	}
+	return fmt.Sprintf("CASE %s ELSE %s END", strings.Join(cases, " "), fresh(rest))
+}
+
+// postgresPatternRegexp returns regexp matching the same names as path.Match pattern.
+// Returns false if pattern has character class.
+func postgresPatternRegexp(pattern string) (string, bool) {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			return "", false
+		case '\\':
+			// patterns are checked in NewTTLRule, so escape is followed by character
+			i++
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String(), true
+}
+
+// Select returns metrics of all label sets by kind and name matching all matchers.
+func (p *PostgresStorage) Select(ctx context.Context, kind, name string, matchers ...models.LabelMatcher) (
+	[]models.Metric, error) {
+	tbl, _, err := postgresTablesByKind(kind)
+	if err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
 	defer cancel()
-	metrics := make([]models.Metric, 0)
 	query := fmt.Sprintf(`
-		SELECT m.name, $1 AS type, g.value::TEXT AS value
+		SELECT m.name, $1 AS type, %s, m.labels::TEXT AS labels, %s
 		FROM %s m
-		JOIN %s g ON m.id = g.metric_id
-		UNION ALL
-		SELECT m.name, $2 AS type, c.value::TEXT AS value
+		JOIN %s t ON m.id = t.metric_id
+		WHERE m.tenant = $3 AND m.name = $2;`,
+		postgresMetricColumns(kind), postgresDescColumns, TblMapping, tbl)
+	tenant := models.TenantFromContext(ctx)
+	rows, err := p.Client.Query(ctx, query, kind, name, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+	metrics := make([]models.Metric, 0)
+	for rows.Next() {
+		metric, err := scanMetric(rows)
+		if err != nil {
+			return nil, err
+		}
+		metric.Tenant = tenant
+		if models.MatchLabels(metric.Labels, matchers...) {
+			metrics = append(metrics, metric)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate: %w", err)
+	}
+	slices.SortFunc(metrics, models.CompareMetrics)
+	return metrics, nil
+}
+
+// List returns page of metrics matching query sorted by name, kind and labels.
+// Filter, order and limit are applied by database, regexp is matched by database engine.
+func (p *PostgresStorage) List(ctx context.Context, query models.ListQuery) (models.ListPage, error) {
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	tenant := models.TenantFromContext(ctx)
+	sql, args := postgresListQuery(tenant, query)
+	rows, err := p.Client.Query(ctx, sql, args...)
+	if err != nil {
+		return models.ListPage{}, fmt.Errorf("failed to query: %w", err)
+	}
+	defer rows.Close()
+	metrics := make([]models.Metric, 0)
+	for rows.Next() {
+		metric, err := scanMetric(rows)
+		if err != nil {
+			return models.ListPage{}, err
+		}
+		metric.Tenant = tenant
+		metrics = append(metrics, metric)
+	}
+	if err = rows.Err(); err != nil {
+		return models.ListPage{}, fmt.Errorf("failed to iterate: %w", err)
+	}
+	return models.NewListPage(metrics, query.Limit), nil
+}
+
+// postgresListQuery returns query and its arguments selecting metrics of tenant, one more than limit of list query,
+// so next page is known to exist.
+//
+// Metrics are sorted in the same order as models.CompareMetrics: names and kinds are compared bytewise,
+// labels are compared as arrays of name and value pairs sorted by name.
+func postgresListQuery(tenant string, query models.ListQuery) (string, []any) {
+	args := make([]any, 0)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+	selects := make([]string, 0, len(postgresKinds))
+	for _, kind := range postgresKinds {
+		if query.Kind != "" && kind != query.Kind {
+			continue
+		}
+		tbl, _, _ := postgresTablesByKind(kind)
+		selects = append(selects, fmt.Sprintf(`
+			SELECT m.name, %s::TEXT AS type, %s, m.labels::TEXT AS labels, %s, %s AS label_key, m.tenant
+			FROM %s m
+			JOIN %s t ON m.id = t.metric_id`,
+			arg(kind), postgresMetricColumns(kind), postgresDescColumns, postgresLabelKey, TblMapping, tbl))
+	}
+	where := []string{"l.tenant = " + arg(tenant)}
+	if query.Prefix != "" {
+		where = append(where, "l.name LIKE "+arg(escapeLike(query.Prefix)+"%"))
+	}
+	if query.Regex != nil {
+		where = append(where, "l.name ~ "+arg(query.Regex.String()))
+	}
+	if after := query.After; after != nil {
+		where = append(where, fmt.Sprintf(
+			`(l.name COLLATE "C", l.type COLLATE "C", l.label_key COLLATE "C") > (%s, %s, %s::TEXT[])`,
+			arg(after.Name), arg(after.Kind), arg(postgresLabelPairs(after.Labels))))
+	}
+	sql := fmt.Sprintf(`
+		SELECT l.name, l.type, l.delta, l.value, l.bounds, l.counts, l.sketch, l.labels, l.unit, l.description
+		FROM (%s
+		) l
+		WHERE %s
+		ORDER BY l.name COLLATE "C", l.type COLLATE "C", l.label_key COLLATE "C"
+		LIMIT %s;`,
+		strings.Join(selects, "\n\t\t\tUNION ALL"), strings.Join(where, " AND "), arg(query.Limit+1))
+	return sql, args
+}
+
+// postgresLabelKey - labels of mapping m as array of name and value pairs sorted by name,
+// the same as postgresLabelPairs returns.
+const postgresLabelKey = `ARRAY(
+				SELECT p.v COLLATE "C"
+				FROM jsonb_each_text(m.labels) AS e(k, v)
+				CROSS JOIN LATERAL unnest(ARRAY[e.k, e.v]) WITH ORDINALITY AS p(v, i)
+				ORDER BY e.k COLLATE "C", p.i)`
+
+// postgresLabelPairs returns labels as array of name and value pairs sorted by name.
+func postgresLabelPairs(labels models.Labels) []string {
+	pairs := make([]string, 0, 2*len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		pairs = append(pairs, name, labels[name])
+	}
+	return pairs
+}
+
+// GetRange returns samples of metric without labels stored between from and to inclusive.
+func (p *PostgresStorage) GetRange(ctx context.Context, kind, name string, from, to time.Time) ([]models.Sample,
+	error) {
+	_, tblHistory, err := postgresTablesByKind(kind)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = p.Get(ctx, kind, name); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	query := fmt.Sprintf(`
+		SELECT t.ts, %s
 		FROM %s m
-		JOIN %s c ON m.id = c.metric_id;`,
-		TblMapping, TblGauges, TblMapping, TblCounters)
-	rows, err := p.Client.QueryContext(ctx, query, models.MetricKindGauge, models.MetricKindCounter)
+		JOIN %s t ON m.id = t.metric_id
+		WHERE m.tenant = $4 AND m.name = $1 AND m.labels = '{}'::JSONB AND t.ts BETWEEN $2 AND $3
+		ORDER BY t.ts;`,
+		postgresValueColumns(kind), TblMapping, tblHistory)
+	rows, err := p.Client.Query(ctx, query, name, from, to, models.TenantFromContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("failed to query: %w", err)
 	}
-	defer func() {
-		_ = rows.Close()
-	}()
+	defer rows.Close()
+	samples := make([]models.Sample, 0)
 	for rows.Next() {
-		var metric models.Metric
-		if err = rows.Scan(&metric.Name, &metric.Kind, &metric.Value); err != nil {
+		var sample models.Sample
+		if err = rows.Scan(&sample.Timestamp, &sample.Delta, &sample.Value); err != nil {
 			return nil, fmt.Errorf("failed to scan: %w", err)
 		}
-		metrics = append(metrics, metric)
+		samples = append(samples, sample)
 	}
 	if err = rows.Err(); err != nil {
 		return nil, fmt.Errorf("failed to iterate: %w", err)
 	}
-	return metrics, nil
+	return samples, nil
 }
 
-// Flush not implemented for PostgresSQL storage.
-func (p *PostgresStorage) Flush(ctx context.Context, dst io.Writer) error {
-	// not implemented yet
+// PruneHistory removes samples of all tenants stored before specified time.
+func (p *PostgresStorage) PruneHistory(ctx context.Context, before time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	for _, tbl := range []string{TblGaugesHistory, TblCountersHistory, TblHistogramsHistory, TblSummariesHistory} {
+		query := fmt.Sprintf(`DELETE FROM %s WHERE ts < $1;`, tbl)
+		if _, err := p.Client.Exec(ctx, query, before); err != nil {
+			return fmt.Errorf("failed to prune history: %w", err)
+		}
+	}
 	return nil
 }
 
-// Load not implemented for PostgresSQL storage.
-func (p *PostgresStorage) Load(ctx context.Context, src io.Reader) error {
-	// not implemented yet
-	return nil
+// Delete removes metric of all label sets by kind and name.
+func (p *PostgresStorage) Delete(ctx context.Context, kind, name string) error {
+	tbl, tblHistory, err := postgresTablesByKind(kind)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	tenant := models.TenantFromContext(ctx)
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		res, err := tx.Exec(ctx, fmt.Sprintf(`
+			DELETE FROM %s
+			WHERE metric_id IN (SELECT id FROM %s WHERE tenant = $2 AND name = $1);`, tbl, TblMapping), name, tenant)
+		if err != nil {
+			return fmt.Errorf("failed to delete metric: %w", err)
+		}
+		if res.RowsAffected() == 0 {
+			return models.ErrHTTPNotFound
+		}
+		if _, err = tx.Exec(ctx, fmt.Sprintf(`
+			DELETE FROM %s
+			WHERE metric_id IN (SELECT id FROM %s WHERE tenant = $2 AND name = $1);`, tblHistory, TblMapping),
+			name, tenant); err != nil {
+			return fmt.Errorf("failed to delete metric history: %w", err)
+		}
+		// remove name if metric of other kind doesn't exist
+		if _, err = tx.Exec(ctx, fmt.Sprintf(`
+			DELETE FROM %s m
+			WHERE m.tenant = $2 AND m.name = $1
+			AND NOT EXISTS (SELECT 1 FROM %s g WHERE g.metric_id = m.id)
+			AND NOT EXISTS (SELECT 1 FROM %s c WHERE c.metric_id = m.id)
+			AND NOT EXISTS (SELECT 1 FROM %s h WHERE h.metric_id = m.id)
+			AND NOT EXISTS (SELECT 1 FROM %s s WHERE s.metric_id = m.id);`,
+			TblMapping, TblGauges, TblCounters, TblHistograms, TblSummaries), name, tenant); err != nil {
+			return fmt.Errorf("failed to delete metric name: %w", err)
+		}
+		return nil
+	})
 }
 
-// Open tries open connections to database with retry.
-func (p *PostgresStorage) Open(ctx context.Context, opts Options) error {
-	ps := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		opts.Host, opts.Port, opts.Username, opts.Password, opts.Database, opts.SSLMode)
-	var lastErr error
-	for attempt := 0; attempt < models.RetryMaxRetries; attempt++ {
-		db, err := sql.Open("pgx", ps)
-		if err == nil {
-			p.Client = db
+// DeleteByPrefix removes metrics of all kinds with name prefix. Returns count of removed metrics.
+func (p *PostgresStorage) DeleteByPrefix(ctx context.Context, prefix string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	// subqueries see rows before deletion, so they count deleted metrics
+	query := fmt.Sprintf(`
+		WITH deleted AS (
+			DELETE FROM %s
+			WHERE tenant = $2 AND name LIKE $1
+			RETURNING id
+		)
+		SELECT
+			(SELECT count(*) FROM %s WHERE metric_id IN (SELECT id FROM deleted)) +
+			(SELECT count(*) FROM %s WHERE metric_id IN (SELECT id FROM deleted)) +
+			(SELECT count(*) FROM %s WHERE metric_id IN (SELECT id FROM deleted)) +
+			(SELECT count(*) FROM %s WHERE metric_id IN (SELECT id FROM deleted));`,
+		TblMapping, TblGauges, TblCounters, TblHistograms, TblSummaries)
+	var deleted int
+	if err := p.Client.QueryRow(ctx, query, escapeLike(prefix)+"%", models.TenantFromContext(ctx)).Scan(
+		&deleted); err != nil {
+		return 0, fmt.Errorf("failed to delete metrics: %w", err)
+	}
+	return deleted, nil
+}
+
+// DeleteStale removes metrics of all tenants which are stale at now according to policy.
+// Returns count of removed metrics.
+//
+// Candidates not updated within the shortest TTL are selected first and checked against policy,
+// then removed only if they are still not updated, so metrics updated meanwhile survive.
+func (p *PostgresStorage) DeleteStale(ctx context.Context, policy TTLPolicy, now time.Time) (int, error) {
+	if !policy.Enabled() {
+		return 0, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	deleted := 0
+	err := p.withTx(ctx, func(tx pgx.Tx) error {
+		ids := make([]int32, 0)
+		for _, kind := range postgresKinds {
+			tbl, tblHistory, _ := postgresTablesByKind(kind)
+			candidates, cutoffs, err := staleCandidates(ctx, tx, tbl, policy, now)
+			if err != nil {
+				return err
+			}
+			if len(candidates) == 0 {
+				continue
+			}
+			rows, err := tx.Query(ctx, fmt.Sprintf(`
+				WITH stale AS (
+					DELETE FROM %s t
+					USING unnest($1::INTEGER[], $2::TIMESTAMPTZ[]) AS s(id, cutoff)
+					WHERE t.metric_id = s.id AND t.updated_at < s.cutoff
+					RETURNING t.metric_id
+				), history AS (
+					DELETE FROM %s WHERE metric_id IN (SELECT metric_id FROM stale)
+				)
+				SELECT metric_id FROM stale;`, tbl, tblHistory), candidates, cutoffs)
+			if err != nil {
+				return fmt.Errorf("failed to delete stale metrics: %w", err)
+			}
+			removed, err := pgx.CollectRows(rows, pgx.RowTo[int32])
+			if err != nil {
+				return fmt.Errorf("failed to delete stale metrics: %w", err)
+			}
+			ids = append(ids, removed...)
+		}
+		deleted = len(ids)
+		if deleted == 0 {
 			return nil
 		}
-		lastErr = err
-		delay := models.RetryInitDelay + time.Duration(attempt)*models.RetryDeltaDelay
-		time.Sleep(delay)
-		continue
+		// remove names if metrics of other kind don't exist
+		if _, err := tx.Exec(ctx, fmt.Sprintf(`
+			DELETE FROM %s m
+			WHERE m.id = ANY($1)
+			AND NOT EXISTS (SELECT 1 FROM %s g WHERE g.metric_id = m.id)
+			AND NOT EXISTS (SELECT 1 FROM %s c WHERE c.metric_id = m.id)
+			AND NOT EXISTS (SELECT 1 FROM %s h WHERE h.metric_id = m.id)
+			AND NOT EXISTS (SELECT 1 FROM %s s WHERE s.metric_id = m.id);`,
+			TblMapping, TblGauges, TblCounters, TblHistograms, TblSummaries), ids); err != nil {
+			return fmt.Errorf("failed to delete metric names: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// staleCandidates returns ids of metrics in table which are stale according to policy
+// and update times before which they are removed.
+func staleCandidates(ctx context.Context, tx pgx.Tx, tbl string, policy TTLPolicy, now time.Time) (
+	ids []int32, cutoffs []time.Time, err error) {
+	rows, err := tx.Query(ctx, fmt.Sprintf(`
+		SELECT m.id, m.name, t.updated_at
+		FROM %s m
+		JOIN %s t ON m.id = t.metric_id
+		WHERE t.updated_at < $1;`, TblMapping, tbl), now.Add(-policy.MinTTL()))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query stale metrics: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int32
+		var name string
+		var updated time.Time
+		if err = rows.Scan(&id, &name, &updated); err != nil {
+			return nil, nil, fmt.Errorf("failed to scan: %w", err)
+		}
+		if policy.IsStale(name, updated, now) {
+			ids = append(ids, id)
+			cutoffs = append(cutoffs, now.Add(-policy.TTLOf(name)))
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("failed to iterate: %w", err)
+	}
+	return ids, cutoffs, nil
+}
+
+// ResetCounter sets counter value of all label sets to zero.
+func (p *PostgresStorage) ResetCounter(ctx context.Context, name string) error {
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+	query := fmt.Sprintf(`
+		WITH updated AS (
+			UPDATE %s
+			SET value = 0, updated_at = now(), source = $2, version = version + 1
+			WHERE metric_id IN (SELECT id FROM %s WHERE tenant = $3 AND name = $1)
+			RETURNING metric_id, value
+		)
+		INSERT INTO %s (metric_id, value)
+		SELECT metric_id, value FROM updated;`,
+		TblCounters, TblMapping, TblCountersHistory)
+	res, err := p.Client.Exec(ctx, query, name, models.SourceFromContext(ctx), models.TenantFromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to reset counter: %w", err)
 	}
-	return fmt.Errorf("failed to open postgres connection: %w", lastErr)
+	if res.RowsAffected() == 0 {
+		return models.ErrHTTPNotFound
+	}
+	return nil
+}
+
+// Flush saves metrics of all tenants to destination.
+func (p *PostgresStorage) Flush(ctx context.Context, dst io.Writer) error {
+	return encodeMetrics(dst, p.all(ctx, postgresFilter{}))
 }
 
-// Close closes connection.
+// Load loads metrics from source in one transaction, every metric is restored to its own tenant.
+// Counters from source are added to stored ones.
+func (p *PostgresStorage) Load(ctx context.Context, src io.Reader) error {
+	metrics, err := decodeMetrics(src)
+	if err != nil {
+		return err
+	}
+	tenants := make(map[string][]models.Metric)
+	for _, metric := range metrics {
+		tenants[metric.Tenant] = append(tenants[metric.Tenant], metric)
+	}
+	// tenants are upserted in the same order by all transactions, so they don't deadlock
+	batches := make([]postgresTenantBatch, 0, len(tenants))
+	for _, tenant := range slices.Sorted(maps.Keys(tenants)) {
+		batch, err := newPostgresTenantBatch(tenant, tenants[tenant])
+		if err != nil {
+			return err
+		}
+		batches = append(batches, batch)
+	}
+	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
+	defer cancel()
+
+	source := models.SourceFromContext(ctx)
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		for _, batch := range batches {
+			if err := batch.upsert(ctx, tx, source); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Open creates connection pool and checks database is reachable with retry.
+func (p *PostgresStorage) Open(ctx context.Context, opts Options) error {
+	cfg, err := postgresPoolConfig(opts)
+	if err != nil {
+		return err
+	}
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create postgres pool: %w", err)
+	}
+	retry := retryPolicy{retries: opts.ConnectRetries, delay: opts.ConnectRetryDelay}
+	if err = retry.do(ctx, pool.Ping); err != nil {
+		pool.Close()
+		return fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	p.Client = pool
+	p.retry = retry
+	return nil
+}
+
+// Close closes all connections of pool.
 func (p *PostgresStorage) Close() {
-	_ = p.Client.Close()
+	p.Client.Close()
 }
 
 // Ping tries ping with retry.
@@ -204,88 +887,537 @@ func (p *PostgresStorage) Close() {
 func (p *PostgresStorage) Ping(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
 	defer cancel()
-	var lastErr error
-	for attempt := 0; attempt < models.RetryMaxRetries; attempt++ {
-		err := p.Client.PingContext(ctx)
-		if err == nil {
-			return nil
-		}
-		lastErr = err
-		delay := models.RetryInitDelay + time.Duration(attempt)*models.RetryDeltaDelay
-		time.Sleep(delay)
-		continue
-	}
-	return fmt.Errorf("error: All attempts failed [%d], last error is: %w", models.RetryMaxRetries, lastErr)
+	return p.retry.do(ctx, p.Client.Ping)
 }
 
-// Init creates database schema.
+// Init creates or migrates database schema.
 func (p *PostgresStorage) Init(ctx context.Context) error {
 	ctx, cancel := context.WithTimeout(ctx, ctxTimeout)
 	defer cancel()
 	if err := p.Ping(ctx); err != nil {
 		return fmt.Errorf("could not ping database: %w", err)
 	}
-	for _, row := range postgresScheme() {
-		if _, err := p.Client.ExecContext(ctx, row); err != nil {
-			return fmt.Errorf("failed to execute query: %w", err)
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		return err
+	}
+	if _, err = p.migrate(ctx, migrations); err != nil {
+		return fmt.Errorf("failed to migrate schema: %w", err)
+	}
+	return nil
+}
+
+// withTx runs fn inside transaction. Transaction commits if fn returns nil, otherwise rolls back.
+func (p *PostgresStorage) withTx(ctx context.Context, fn func(tx pgx.Tx) error) (err error) {
+	tx, err := p.Client.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback(ctx)
+			return
+		}
+		if err = tx.Commit(ctx); err != nil {
+			err = fmt.Errorf("failed to commit transaction: %w", err)
+		}
+	}()
+	return fn(tx)
+}
+
+// postgresPoolConfig returns pool configuration from options. Zero options keep pgx defaults.
+func postgresPoolConfig(opts Options) (*pgxpool.Config, error) {
+	params := []struct {
+		key   string
+		value string
+	}{
+		{key: "host", value: opts.Host},
+		{key: "port", value: strconv.Itoa(opts.Port)},
+		{key: "user", value: opts.Username},
+		{key: "password", value: opts.Password},
+		{key: "dbname", value: opts.Database},
+		{key: "sslmode", value: opts.SSLMode},
+	}
+	connString := make([]string, 0, len(params))
+	for _, param := range params {
+		if param.value != "" {
+			connString = append(connString, param.key+"="+quoteConnParam(param.value))
+		}
+	}
+	cfg, err := pgxpool.ParseConfig(strings.Join(connString, " "))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse postgres config: %w", err)
+	}
+	if opts.MaxConns > 0 {
+		cfg.MaxConns = opts.MaxConns
+	}
+	if opts.MinConns > 0 {
+		cfg.MinConns = opts.MinConns
+	}
+	if opts.MaxConnLifetime > 0 {
+		cfg.MaxConnLifetime = opts.MaxConnLifetime
+	}
+	if opts.StatementTimeout > 0 {
+		cfg.ConnConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(opts.StatementTimeout.Milliseconds(), 10)
+	}
+	return cfg, nil
+}
+
+// quoteConnParam quotes value for key=value connection string.
+func quoteConnParam(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// postgresTablesByKind returns tables for values and history of metric kind.
+func postgresTablesByKind(kind string) (tbl, tblHistory string, err error) {
+	switch kind {
+	case models.MetricKindGauge:
+		return TblGauges, TblGaugesHistory, nil
+	case models.MetricKindCounter:
+		return TblCounters, TblCountersHistory, nil
+	case models.MetricKindHistogram:
+		return TblHistograms, TblHistogramsHistory, nil
+	case models.MetricKindSummary:
+		return TblSummaries, TblSummariesHistory, nil
+	default:
+		return "", "", fmt.Errorf("%w: metric kind '%s'", models.ErrNotSupported, kind)
+	}
+}
+
+// escapeLike escapes special characters of LIKE pattern.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// postgresValue is type of metric value in batch.
+type postgresValue interface {
+	int64 | float64 | *models.Histogram | *models.Summary
+}
+
+// postgresBatch contains columns of unique metrics of one kind for batch upsert.
+type postgresBatch[T postgresValue] struct {
+	index        map[metricKey]int
+	names        []string
+	labels       []string
+	values       []T
+	units        []string
+	descriptions []string
+}
+
+func newPostgresBatch[T postgresValue]() *postgresBatch[T] {
+	return &postgresBatch[T]{index: make(map[metricKey]int)}
+}
+
+// add appends metric to batch or merges value of already added metric.
+func (b *postgresBatch[T]) add(metric models.Metric, value T, merge func(prev, value T) T) error {
+	key := newMetricKey(metric)
+	if i, ok := b.index[key]; ok {
+		b.values[i] = merge(b.values[i], value)
+		if metric.Unit != "" {
+			b.units[i] = metric.Unit
+		}
+		if metric.Description != "" {
+			b.descriptions[i] = metric.Description
 		}
+		return nil
+	}
+	labels, err := labelsToJSON(metric.Labels)
+	if err != nil {
+		return err
 	}
+	b.index[key] = len(b.names)
+	b.names = append(b.names, metric.Name)
+	b.labels = append(b.labels, labels)
+	b.values = append(b.values, value)
+	b.units = append(b.units, metric.Unit)
+	b.descriptions = append(b.descriptions, metric.Description)
 	return nil
 }
 
-func postgresScheme() []string {
-	return []string{
-		`CREATE TABLE IF NOT EXISTS ` + TblMapping + ` (
-			id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-			name VARCHAR(60) NOT NULL UNIQUE
-		);`,
+func (b *postgresBatch[T]) len() int {
+	return len(b.names)
+}
 
-		`CREATE TABLE IF NOT EXISTS ` + TblGauges + ` (
-			id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-			metric_id INTEGER NOT NULL UNIQUE,
-			value DOUBLE PRECISION NOT NULL,
-			FOREIGN KEY (metric_id) REFERENCES ` + TblMapping + ` (id) ON DELETE CASCADE
-		);`,
+// newPostgresBatches splits metrics to batches of gauges, counters, histograms and summaries.
+func newPostgresBatches(metrics []models.Metric) (gauges *postgresBatch[float64], counters *postgresBatch[int64],
+	histograms *postgresBatch[*models.Histogram], summaries *postgresBatch[*models.Summary], err error) {
+	gauges, counters = newPostgresBatch[float64](), newPostgresBatch[int64]()
+	histograms, summaries = newPostgresBatch[*models.Histogram](), newPostgresBatch[*models.Summary]()
+	for _, metric := range metrics {
+		if err = validateMetric(metric); err != nil {
+			return nil, nil, nil, nil, err
+		}
+		switch metric.Kind {
+		case models.MetricKindCounter:
+			err = counters.add(metric, metric.Delta, func(prev, delta int64) int64 {
+				return prev + delta
+			})
+		case models.MetricKindGauge:
+			err = gauges.add(metric, metric.Value, func(_, last float64) float64 {
+				return last
+			})
+		case models.MetricKindHistogram:
+			err = histograms.add(metric, metric.Histogram, (*models.Histogram).Merge)
+		case models.MetricKindSummary:
+			err = summaries.add(metric, metric.Summary, (*models.Summary).Merge)
+		}
+		if err != nil {
+			return nil, nil, nil, nil, err
+		}
+	}
+	return gauges, counters, histograms, summaries, nil
+}
 
-		`CREATE TABLE IF NOT EXISTS ` + TblCounters + ` (
-			id INTEGER GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
-			metric_id INTEGER NOT NULL UNIQUE,
-			value BIGINT NOT NULL,
-			FOREIGN KEY (metric_id) REFERENCES ` + TblMapping + ` (id) ON DELETE CASCADE
-		);`,
+// postgresHistogramColumns returns bounds and counts of histograms as array literals,
+// sums and total counts of observations.
+func postgresHistogramColumns(histograms []*models.Histogram) (bounds, counts []string, sums []float64,
+	totals []int64) {
+	for _, h := range histograms {
+		b := make([]string, len(h.Bounds))
+		for i, bound := range h.Bounds {
+			b[i] = strconv.FormatFloat(bound, 'g', -1, 64)
+		}
+		c := make([]string, len(h.Counts))
+		for i, count := range h.Counts {
+			c[i] = strconv.FormatUint(count, 10)
+		}
+		bounds = append(bounds, "{"+strings.Join(b, ",")+"}")
+		counts = append(counts, "{"+strings.Join(c, ",")+"}")
+		sums = append(sums, h.Sum)
+		totals = append(totals, int64(h.Count()))
 	}
+	return bounds, counts, sums, totals
 }
 
-func postgresUpsertQueryWithSetValue(targetTable, setValue string) string {
+// postgresBatchUpsertQuery returns query which upserts arrays of names ($1), labels ($2), values ($3),
+// units ($4) and descriptions ($5) sent by source ($6) to tenant ($7). Names in arrays must be unique with labels.
+// Empty unit or description keeps stored one.
+func postgresBatchUpsertQuery(targetTable, historyTable, valueType, setValue string) string {
+	// select from mapping doesn't see rows inserted by statement itself, so ids are not duplicated
 	return fmt.Sprintf(`
-		WITH metric AS (
-			INSERT INTO %s (name)
-			VALUES ($1)
-			ON CONFLICT (name) DO NOTHING
+		WITH input AS (
+			SELECT t.name, t.labels::JSONB AS labels, t.value, t.unit, t.description
+			FROM unnest($1::TEXT[], $2::TEXT[], $3::%[4]s[], $4::TEXT[], $5::TEXT[])
+				AS t(name, labels, value, unit, description)
+		), %[6]s, upserted AS (
+			INSERT INTO %[2]s (metric_id, value, unit, description, source)
+			SELECT ids.id, input.value, input.unit, input.description, $6::TEXT
+			FROM input
+			JOIN ids ON ids.name = input.name AND ids.labels = input.labels
+			ON CONFLICT (metric_id) DO UPDATE
+			SET value = %[5]s, updated_at = now(), source = EXCLUDED.source, version = %[2]s.version + 1,
+				unit = COALESCE(NULLIF(EXCLUDED.unit, ''), %[2]s.unit),
+				description = COALESCE(NULLIF(EXCLUDED.description, ''), %[2]s.description)
+			RETURNING metric_id, value
+		)
+		INSERT INTO %[3]s (metric_id, value)
+		SELECT metric_id, value FROM upserted;`, TblMapping, targetTable, historyTable, valueType, setValue,
+		postgresMappingIDs("$7"))
+}
+
+// postgresMappingID inserts name and labels to mapping of tenant if they are not there and returns their id.
+func postgresMappingID(ctx context.Context, tx pgx.Tx, tenant, name, labels string) (int32, error) {
+	var id int32
+	err := tx.QueryRow(ctx, fmt.Sprintf(`
+		WITH inserted AS (
+			INSERT INTO %[1]s (tenant, name, labels)
+			VALUES ($3, $1, $2::JSONB)
+			ON CONFLICT (tenant, name, labels) DO NOTHING
 			RETURNING id
 		)
-		INSERT INTO %s (metric_id, value)
-		VALUES (
-			COALESCE((SELECT id FROM metric), (SELECT id FROM %s WHERE name = $1)), $2
+		SELECT id FROM inserted
+		UNION ALL
+		SELECT id FROM %[1]s WHERE tenant = $3 AND name = $1 AND labels = $2::JSONB;`, TblMapping),
+		name, labels, tenant).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// row inserted by concurrent transaction is not visible to snapshot of the statement above,
+		// but conflict means it is committed already, so next statement sees it
+		err = tx.QueryRow(ctx, fmt.Sprintf(`
+		SELECT id FROM %s WHERE tenant = $3 AND name = $1 AND labels = $2::JSONB;`, TblMapping),
+			name, labels, tenant).Scan(&id)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert mapping: %w", err)
+	}
+	return id, nil
+}
+
+// postgresMappingIDs returns common table expression of upsert queries which inserts new names and labels
+// from input to mapping of tenant (parameter tenantArg) and returns ids of all names and labels from input.
+//
+// Conflicting rows are updated with the same name instead of skipped: row inserted by concurrent transaction
+// is not visible to snapshot of the statement, so only update returns its id. Rows are locked in order of names
+// and labels, so concurrent batches don't deadlock on them.
+func postgresMappingIDs(tenantArg string) string {
+	return fmt.Sprintf(`ids AS (
+			INSERT INTO %[1]s (tenant, name, labels)
+			SELECT %[2]s::TEXT, name, labels FROM input
+			ORDER BY name, labels
+			ON CONFLICT (tenant, name, labels) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id, name, labels
+		)`, TblMapping, tenantArg)
+}
+
+// postgresHistogramUpsertQuery returns query which upserts arrays of names ($1), labels ($2),
+// bounds ($3) and counts ($4) as array literals, sums ($5), total counts ($6), units ($7) and descriptions ($8)
+// sent by source ($9) to tenant ($10). Names in arrays must be unique with labels.
+// Histogram with the same bounds is added to stored one, with other bounds replaces it.
+func postgresHistogramUpsertQuery() string {
+	// all expressions of SET see stored row, so bounds are compared before replacing
+	return fmt.Sprintf(`
+		WITH input AS (
+			SELECT t.name, t.labels::JSONB AS labels, t.bounds::DOUBLE PRECISION[] AS bounds,
+				t.counts::BIGINT[] AS counts, t.sum, t.count, t.unit, t.description
+			FROM unnest($1::TEXT[], $2::TEXT[], $3::TEXT[], $4::TEXT[], $5::DOUBLE PRECISION[], $6::BIGINT[],
+				$7::TEXT[], $8::TEXT[]) AS t(name, labels, bounds, counts, sum, count, unit, description)
+		), %[3]s, upserted AS (
+			INSERT INTO %[1]s (metric_id, bounds, counts, sum, count, unit, description, source)
+			SELECT ids.id, input.bounds, input.counts, input.sum, input.count, input.unit, input.description,
+				$9::TEXT
+			FROM input
+			JOIN ids ON ids.name = input.name AND ids.labels = input.labels
+			ON CONFLICT (metric_id) DO UPDATE
+			SET counts = CASE WHEN %[1]s.bounds = EXCLUDED.bounds
+					THEN ARRAY(
+						SELECT s.prev + s.next
+						FROM unnest(%[1]s.counts, EXCLUDED.counts) WITH ORDINALITY AS s(prev, next, i)
+						ORDER BY s.i)
+					ELSE EXCLUDED.counts END,
+				sum = CASE WHEN %[1]s.bounds = EXCLUDED.bounds
+					THEN %[1]s.sum + EXCLUDED.sum ELSE EXCLUDED.sum END,
+				count = CASE WHEN %[1]s.bounds = EXCLUDED.bounds
+					THEN %[1]s.count + EXCLUDED.count ELSE EXCLUDED.count END,
+				bounds = EXCLUDED.bounds, updated_at = now(), source = EXCLUDED.source, version = %[1]s.version + 1,
+				unit = COALESCE(NULLIF(EXCLUDED.unit, ''), %[1]s.unit),
+				description = COALESCE(NULLIF(EXCLUDED.description, ''), %[1]s.description)
+			RETURNING metric_id, count, sum
 		)
-		ON CONFLICT (metric_id) DO UPDATE
-		SET value = %s ;`, TblMapping, targetTable, TblMapping, setValue)
+		INSERT INTO %[2]s (metric_id, count, sum)
+		SELECT metric_id, count, sum FROM upserted;`, TblHistograms, TblHistogramsHistory, postgresMappingIDs("$10"))
 }
 
-func postgresUpsertQueryByMetric(metric models.Metric) (query string, args []interface{}, err error) {
-	switch metric.Kind {
-	case models.MetricKindCounter:
-		newCounter, err := strconv.Atoi(metric.Value)
+// upsertPostgresSummaries inserts new summaries of batch and merges stored ones.
+//
+// Sketches are merged here rather than by query, so they are collapsed the same way as in other storages.
+// Stored sketches are locked until end of transaction, insertion of the same metric by concurrent transaction
+// waits for it and merges with its sketch.
+func upsertPostgresSummaries(ctx context.Context, tx pgx.Tx, summaries *postgresBatch[*models.Summary],
+	tenant, source string) error {
+	sketches, counts, sums, err := postgresSummaryColumns(summaries.values)
+	if err != nil {
+		return err
+	}
+	rows, err := tx.Query(ctx, postgresSummaryInsertQuery(), summaries.names, summaries.labels, sketches, counts,
+		sums, summaries.units, summaries.descriptions, source, tenant)
+	if err != nil {
+		return err
+	}
+	// ids of stored summaries with their indexes in batch
+	stored := make(map[int32]int)
+	var id int32
+	var i int
+	if _, err = pgx.ForEachRow(rows, []any{&id, &i}, func() error {
+		stored[id] = i
+		return nil
+	}); err != nil {
+		return err
+	}
+	if len(stored) == 0 {
+		return nil
+	}
+
+	rows, err = tx.Query(ctx, fmt.Sprintf(`
+		SELECT metric_id, sketch
+		FROM %s
+		WHERE metric_id = ANY($1)
+		FOR UPDATE;`, TblSummaries), slices.Collect(maps.Keys(stored)))
+	if err != nil {
+		return err
+	}
+	ids := make([]int32, 0, len(stored))
+	merged := make([]*models.Summary, 0, len(stored))
+	units := make([]string, 0, len(stored))
+	descriptions := make([]string, 0, len(stored))
+	var sketch []byte
+	if _, err = pgx.ForEachRow(rows, []any{&id, &sketch}, func() error {
+		prev := new(models.Summary)
+		if err := json.Unmarshal(sketch, prev); err != nil {
+			return fmt.Errorf("failed to unmarshal summary: %w", err)
+		}
+		i := stored[id]
+		ids = append(ids, id)
+		merged = append(merged, prev.Merge(summaries.values[i]))
+		units = append(units, summaries.units[i])
+		descriptions = append(descriptions, summaries.descriptions[i])
+		return nil
+	}); err != nil {
+		return err
+	}
+	sketches, counts, sums, err = postgresSummaryColumns(merged)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, postgresSummaryUpdateQuery(), ids, sketches, counts, sums, units, descriptions, source)
+	return err
+}
+
+// postgresSummaryColumns returns sketches of summaries as JSON, total counts and sums of observations.
+func postgresSummaryColumns(summaries []*models.Summary) (sketches []string, counts []int64, sums []float64,
+	err error) {
+	for _, s := range summaries {
+		b, err := json.Marshal(s)
 		if err != nil {
-			return "", nil, fmt.Errorf("invalid counter value: %w", err)
+			return nil, nil, nil, fmt.Errorf("failed to marshal summary: %w", err)
 		}
-		query = postgresUpsertQueryWithSetValue(TblCounters, TblCounters+".value + EXCLUDED.value")
-		args = append(args, metric.Name, newCounter)
-	case models.MetricKindGauge:
-		query = postgresUpsertQueryWithSetValue(TblGauges, "EXCLUDED.value")
-		args = append(args, metric.Name, metric.Value)
+		sketches = append(sketches, string(b))
+		counts = append(counts, int64(s.Count()))
+		sums = append(sums, s.Sum)
+	}
+	return sketches, counts, sums, nil
+}
+
+// postgresSummaryInsertQuery returns query which inserts arrays of names ($1), labels ($2), sketches ($3),
+// total counts ($4), sums ($5), units ($6) and descriptions ($7) sent by source ($8) to tenant ($9)
+// if summaries are not stored.
+// Names in arrays must be unique with labels. Returns ids of stored summaries with 0-based index in arrays.
+func postgresSummaryInsertQuery() string {
+	return fmt.Sprintf(`
+		WITH input AS (
+			SELECT t.name, t.labels::JSONB AS labels, t.sketch::JSONB AS sketch, t.count, t.sum, t.unit,
+				t.description, t.i - 1 AS i
+			FROM unnest($1::TEXT[], $2::TEXT[], $3::TEXT[], $4::BIGINT[], $5::DOUBLE PRECISION[], $6::TEXT[],
+				$7::TEXT[]) WITH ORDINALITY AS t(name, labels, sketch, count, sum, unit, description, i)
+		), %[3]s, added AS (
+			INSERT INTO %[1]s (metric_id, sketch, count, sum, unit, description, source)
+			SELECT ids.id, input.sketch, input.count, input.sum, input.unit, input.description, $8::TEXT
+			FROM input
+			JOIN ids ON ids.name = input.name AND ids.labels = input.labels
+			ON CONFLICT (metric_id) DO NOTHING
+			RETURNING metric_id, count, sum
+		), history AS (
+			INSERT INTO %[2]s (metric_id, count, sum)
+			SELECT metric_id, count, sum FROM added
+		)
+		SELECT ids.id, input.i::INTEGER
+		FROM input
+		JOIN ids ON ids.name = input.name AND ids.labels = input.labels
+		WHERE ids.id NOT IN (SELECT metric_id FROM added);`, TblSummaries, TblSummariesHistory,
+		postgresMappingIDs("$9"))
+}
+
+// postgresSummaryUpdateQuery returns query which updates summaries by arrays of ids ($1), merged sketches ($2),
+// total counts ($3), sums ($4), units ($5) and descriptions ($6) sent by source ($7).
+// Empty unit or description keeps stored one.
+func postgresSummaryUpdateQuery() string {
+	return fmt.Sprintf(`
+		WITH input AS (
+			SELECT t.id, t.sketch::JSONB AS sketch, t.count, t.sum, t.unit, t.description
+			FROM unnest($1::INTEGER[], $2::TEXT[], $3::BIGINT[], $4::DOUBLE PRECISION[], $5::TEXT[], $6::TEXT[])
+				AS t(id, sketch, count, sum, unit, description)
+		), updated AS (
+			UPDATE %[1]s s
+			SET sketch = input.sketch, count = input.count, sum = input.sum, updated_at = now(), source = $7::TEXT,
+				version = s.version + 1,
+				unit = COALESCE(NULLIF(input.unit, ''), s.unit),
+				description = COALESCE(NULLIF(input.description, ''), s.description)
+			FROM input
+			WHERE s.metric_id = input.id
+			RETURNING s.metric_id, s.count, s.sum
+		)
+		INSERT INTO %[2]s (metric_id, count, sum)
+		SELECT metric_id, count, sum FROM updated;`, TblSummaries, TblSummariesHistory)
+}
+
+// labelsToJSON returns labels as JSON object, empty object for empty labels.
+func labelsToJSON(labels models.Labels) (string, error) {
+	if len(labels) == 0 {
+		return "{}", nil
+	}
+	b, err := json.Marshal(labels)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal labels: %w", err)
+	}
+	return string(b), nil
+}
+
+// postgresValueColumns returns delta and value columns of table t for metric kind.
+// Column of other kind is zero, histogram and summary have count as delta and sum as value.
+func postgresValueColumns(kind string) string {
+	switch kind {
+	case models.MetricKindCounter:
+		return "t.value AS delta, 0::DOUBLE PRECISION AS value"
+	case models.MetricKindHistogram, models.MetricKindSummary:
+		return "t.count AS delta, t.sum AS value"
+	default:
+		return "0::BIGINT AS delta, t.value AS value"
+	}
+}
+
+// postgresMetricColumns returns delta, value, bounds, counts and sketch columns of table t for metric kind.
+// Bounds and counts are NULL for kinds other than histogram, sketch is NULL for kinds other than summary.
+func postgresMetricColumns(kind string) string {
+	switch kind {
+	case models.MetricKindHistogram:
+		return postgresValueColumns(kind) + ", t.bounds, t.counts, NULL::JSONB AS sketch"
+	case models.MetricKindSummary:
+		return postgresValueColumns(kind) +
+			", NULL::DOUBLE PRECISION[] AS bounds, NULL::BIGINT[] AS counts, t.sketch"
 	default:
-		return "", nil, fmt.Errorf("invalid metric kind")
+		return postgresValueColumns(kind) +
+			", NULL::DOUBLE PRECISION[] AS bounds, NULL::BIGINT[] AS counts, NULL::JSONB AS sketch"
+	}
+}
+
+// postgresDistribution contains histogram and summary columns scanned with metric.
+type postgresDistribution struct {
+	bounds []float64
+	counts []int64
+	sketch []byte
+}
+
+// set sets histogram or summary of metric from scanned columns, count and sum scanned
+// as delta and value are reset. Metrics of other kinds are not changed.
+func (d *postgresDistribution) set(metric *models.Metric) error {
+	switch metric.Kind {
+	case models.MetricKindHistogram:
+		h := &models.Histogram{Bounds: d.bounds, Counts: make([]uint64, len(d.counts)), Sum: metric.Value}
+		for i, c := range d.counts {
+			h.Counts[i] = uint64(c)
+		}
+		metric.Histogram = h
+	case models.MetricKindSummary:
+		s := new(models.Summary)
+		if err := json.Unmarshal(d.sketch, s); err != nil {
+			return fmt.Errorf("failed to unmarshal summary: %w", err)
+		}
+		metric.Summary = s
+	default:
+		return nil
+	}
+	metric.Delta, metric.Value = 0, 0
+	return nil
+}
+
+// postgresDescColumns - unit and description columns of table t.
+const postgresDescColumns = "t.unit, t.description"
+
+// scanMetric scans row with name, type, delta, value, bounds, counts, sketch, labels, unit and description
+// columns followed by extra columns.
+func scanMetric(rows pgx.Rows, extra ...any) (models.Metric, error) {
+	var metric models.Metric
+	var labels string
+	var d postgresDistribution
+	dest := append([]any{&metric.Name, &metric.Kind, &metric.Delta, &metric.Value, &d.bounds, &d.counts, &d.sketch,
+		&labels, &metric.Unit, &metric.Description}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return models.Metric{}, fmt.Errorf("failed to scan: %w", err)
+	}
+	if err := json.Unmarshal([]byte(labels), &metric.Labels); err != nil {
+		return models.Metric{}, fmt.Errorf("failed to unmarshal labels: %w", err)
+	}
+	metric.Labels = metric.Labels.Normalize()
+	if err := d.set(&metric); err != nil {
+		return models.Metric{}, err
 	}
-	return query, args, nil
+	return metric, nil
 }
@@ -0,0 +1,378 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/models"
+)
+
+// Settings for concurrent batches test.
+const (
+	concurrentBatches       = 2
+	concurrentBatchAttempts = 20
+)
+
+// openBatchTestDB returns storage connected to integration tests database, test is skipped if it is not available.
+func openBatchTestDB(t *testing.T) *PostgresStorage {
+	t.Helper()
+	opts := Options{
+		Scheme:   "postgres",
+		Host:     "localhost",
+		Port:     5432,
+		Username: "metrics",
+		Password: "secret",
+		Database: "metrics",
+	}
+	if _, ok := os.LookupEnv("GITHUB_ACTIONS"); ok {
+		opts.Host = "postgres"
+	} else {
+		opts.Port = 15432
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	s := NewPostgresStorage()
+	if err := s.Open(ctx, opts); err != nil {
+		t.Skipf("postgres is not available: %v", err)
+	}
+	t.Cleanup(s.Close)
+	if err := s.Init(context.Background()); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	return s
+}
+
+func TestPostgresStorage_MassUpsertConcurrentNew(t *testing.T) {
+	s := openBatchTestDB(t)
+	prefix := fmt.Sprintf("testBatchRace%d_", time.Now().UnixNano())
+	t.Cleanup(func() {
+		_, _ = s.Client.Exec(context.Background(), "DELETE FROM "+TblMapping+" WHERE name LIKE $1", prefix+"%")
+	})
+	for i := 0; i < concurrentBatchAttempts; i++ {
+		// every attempt creates new metrics, so all batches race to insert their mapping
+		batch := []models.Metric{
+			{Kind: models.MetricKindCounter, Name: prefix + fmt.Sprint(i), Delta: 1},
+			{Kind: models.MetricKindCounter, Name: prefix + fmt.Sprint(i), Delta: 1,
+				Labels: models.Labels{"host": "a"}},
+		}
+		var wg sync.WaitGroup
+		errs := make(chan error, concurrentBatches)
+		for w := 0; w < concurrentBatches; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- s.MassUpsert(context.Background(), batch)
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatalf("MassUpsert() error = %v", err)
+			}
+		}
+		for _, m := range batch {
+			got, err := s.Select(context.Background(), m.Kind, m.Name)
+			if err != nil {
+				t.Fatalf("Select() error = %v", err)
+			}
+			for _, g := range got {
+				if g.Labels.String() == m.Labels.String() && g.Delta != concurrentBatches {
+					t.Errorf("MassUpsert() stored %s%s = %d, want %d", m.Name, m.Labels, g.Delta,
+						concurrentBatches)
+				}
+			}
+			if len(got) != len(batch) {
+				t.Errorf("Select(%s) got %d label sets, want %d", m.Name, len(got), len(batch))
+			}
+		}
+	}
+}
+
+func Test_newPostgresBatches(t *testing.T) {
+	tests := []struct {
+		name           string
+		metrics        []models.Metric
+		wantGauges     *postgresBatch[float64]
+		wantCounters   *postgresBatch[int64]
+		wantHistograms *postgresBatch[*models.Histogram]
+		wantSummaries  *postgresBatch[*models.Summary]
+		wantErr        error
+	}{
+		{
+			name: "repeated metrics are merged",
+			metrics: []models.Metric{
+				{Kind: models.MetricKindCounter, Name: "testCounter1", Delta: 1},
+				{Kind: models.MetricKindGauge, Name: "testGauge1", Value: 1.5, Unit: "bytes", Description: "old"},
+				{Kind: models.MetricKindCounter, Name: "testCounter1", Delta: 2,
+					Labels: models.Labels{"host": "a"}},
+				{Kind: models.MetricKindCounter, Name: "testCounter1", Delta: 3},
+				{Kind: models.MetricKindGauge, Name: "testGauge1", Value: -2.5, Description: "new"},
+				{Kind: models.MetricKindHistogram, Name: "testHistogram1",
+					Histogram: &models.Histogram{Bounds: []float64{1}, Counts: []uint64{1, 0}, Sum: 0.5}},
+				{Kind: models.MetricKindHistogram, Name: "testHistogram1",
+					Histogram: &models.Histogram{Bounds: []float64{1}, Counts: []uint64{1, 2}, Sum: 6}},
+				{Kind: models.MetricKindSummary, Name: "testSummary1", Unit: "seconds",
+					Summary: &models.Summary{Positive: map[int32]uint64{1: 1}, Accuracy: 0.01, Sum: 1, Min: 1,
+						Max: 1}},
+				{Kind: models.MetricKindSummary, Name: "testSummary1",
+					Summary: &models.Summary{Positive: map[int32]uint64{1: 1, 5: 1}, Accuracy: 0.01, Sum: 3, Min: 1,
+						Max: 2}},
+			},
+			wantGauges: &postgresBatch[float64]{
+				names:        []string{"testGauge1"},
+				labels:       []string{"{}"},
+				values:       []float64{-2.5},
+				units:        []string{"bytes"},
+				descriptions: []string{"new"},
+			},
+			wantCounters: &postgresBatch[int64]{
+				names:        []string{"testCounter1", "testCounter1"},
+				labels:       []string{"{}", `{"host":"a"}`},
+				values:       []int64{4, 2},
+				units:        []string{"", ""},
+				descriptions: []string{"", ""},
+			},
+			wantHistograms: &postgresBatch[*models.Histogram]{
+				names:        []string{"testHistogram1"},
+				labels:       []string{"{}"},
+				values:       []*models.Histogram{{Bounds: []float64{1}, Counts: []uint64{2, 2}, Sum: 6.5}},
+				units:        []string{""},
+				descriptions: []string{""},
+			},
+			wantSummaries: &postgresBatch[*models.Summary]{
+				names:  []string{"testSummary1"},
+				labels: []string{"{}"},
+				values: []*models.Summary{{Positive: map[int32]uint64{1: 2, 5: 1}, Accuracy: 0.01, Sum: 4, Min: 1,
+					Max: 2}},
+				units:        []string{"seconds"},
+				descriptions: []string{""},
+			},
+		},
+		{
+			name: "unsupported kind",
+			metrics: []models.Metric{
+				{Kind: "preved", Name: "testCounter1", Value: 1},
+			},
+			wantErr: models.ErrNotSupported,
+		},
+		{
+			name: "invalid histogram",
+			metrics: []models.Metric{
+				{Kind: models.MetricKindHistogram, Name: "testHistogram1",
+					Histogram: &models.Histogram{Bounds: []float64{1, 1}, Counts: []uint64{0, 0, 0}}},
+			},
+			wantErr: models.ErrInvalidHistogram,
+		},
+		{
+			name: "invalid summary",
+			metrics: []models.Metric{
+				{Kind: models.MetricKindSummary, Name: "testSummary1", Summary: &models.Summary{}},
+			},
+			wantErr: models.ErrInvalidSummary,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gauges, counters, histograms, summaries, err := newPostgresBatches(tt.metrics)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("newPostgresBatches() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("newPostgresBatches() error = %v", err)
+			}
+			gauges.index, counters.index, histograms.index, summaries.index = nil, nil, nil, nil
+			if !reflect.DeepEqual(gauges, tt.wantGauges) {
+				t.Errorf("newPostgresBatches() gauges = %v, want %v", gauges, tt.wantGauges)
+			}
+			if !reflect.DeepEqual(counters, tt.wantCounters) {
+				t.Errorf("newPostgresBatches() counters = %v, want %v", counters, tt.wantCounters)
+			}
+			if !reflect.DeepEqual(histograms, tt.wantHistograms) {
+				t.Errorf("newPostgresBatches() histograms = %v, want %v", histograms, tt.wantHistograms)
+			}
+			if !reflect.DeepEqual(summaries, tt.wantSummaries) {
+				t.Errorf("newPostgresBatches() summaries = %v, want %v", summaries, tt.wantSummaries)
+			}
+		})
+	}
+}
+
+func Test_postgresHistogramColumns(t *testing.T) {
+	bounds, counts, sums, totals := postgresHistogramColumns([]*models.Histogram{
+		{Bounds: []float64{0.001, 1e10}, Counts: []uint64{1, 2, 3}, Sum: 1.5},
+		{Counts: []uint64{4}},
+	})
+	if want := []string{"{0.001,1e+10}", "{}"}; !reflect.DeepEqual(bounds, want) {
+		t.Errorf("postgresHistogramColumns() bounds = %v, want %v", bounds, want)
+	}
+	if want := []string{"{1,2,3}", "{4}"}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("postgresHistogramColumns() counts = %v, want %v", counts, want)
+	}
+	if want := []float64{1.5, 0}; !reflect.DeepEqual(sums, want) {
+		t.Errorf("postgresHistogramColumns() sums = %v, want %v", sums, want)
+	}
+	if want := []int64{6, 4}; !reflect.DeepEqual(totals, want) {
+		t.Errorf("postgresHistogramColumns() totals = %v, want %v", totals, want)
+	}
+}
+
+func Test_postgresSummaryColumns(t *testing.T) {
+	sketches, counts, sums, err := postgresSummaryColumns([]*models.Summary{
+		{Positive: map[int32]uint64{-3: 1, 2: 2}, Negative: map[int32]uint64{1: 1}, Accuracy: 0.5, Zero: 1,
+			Sum: 1.5, Min: -1, Max: 3},
+		{Accuracy: 0.01},
+	})
+	if err != nil {
+		t.Fatalf("postgresSummaryColumns() error = %v", err)
+	}
+	want := []string{
+		`{"positive":{"-3":1,"2":2},"negative":{"1":1},"accuracy":0.5,"zero":1,"sum":1.5,"min":-1,"max":3}`,
+		`{"accuracy":0.01,"sum":0,"min":0,"max":0}`,
+	}
+	if !reflect.DeepEqual(sketches, want) {
+		t.Errorf("postgresSummaryColumns() sketches = %v, want %v", sketches, want)
+	}
+	if want := []int64{5, 0}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("postgresSummaryColumns() counts = %v, want %v", counts, want)
+	}
+	if want := []float64{1.5, 0}; !reflect.DeepEqual(sums, want) {
+		t.Errorf("postgresSummaryColumns() sums = %v, want %v", sums, want)
+	}
+}
+
+func Test_postgresListQuery(t *testing.T) {
+	query, err := models.NewListQuery(models.MetricKindGauge, "test_1", "test.*", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query.After = &models.ListCursor{Name: "test_1a", Kind: models.MetricKindGauge,
+		Labels: models.Labels{"z": "1", "a": "2"}}
+	sql, args := postgresListQuery("team1", query)
+	want := []any{models.MetricKindGauge, "team1", `test\_1%`, "^(?:test.*)$", "test_1a", models.MetricKindGauge,
+		[]string{"a", "2", "z", "1"}, 11}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("postgresListQuery() args = %v, want %v", args, want)
+	}
+	for _, part := range []string{"FROM " + TblMapping, "JOIN " + TblGauges, "l.tenant = $2", "l.name LIKE $3",
+		"l.name ~ $4", "> ($5, $6, $7::TEXT[])", "LIMIT $8;"} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("postgresListQuery() query doesn't contain %q:\n%s", part, sql)
+		}
+	}
+	if strings.Contains(sql, TblCounters) {
+		t.Errorf("postgresListQuery() query selects other kinds:\n%s", sql)
+	}
+}
+
+func Test_postgresAllQuery(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tenant := "team1"
+	tests := []struct {
+		name     string
+		filter   postgresFilter
+		wantArgs []any
+		want     string
+	}{
+		{
+			name:     "all tenants",
+			filter:   postgresFilter{},
+			wantArgs: []any{},
+		},
+		{
+			name:     "global ttl",
+			filter:   postgresFilter{tenant: &tenant, policy: TTLPolicy{TTL: time.Minute}, now: now},
+			wantArgs: []any{tenant, now.Add(-time.Minute)},
+			want:     "WHERE m.tenant = $5 AND t.updated_at >= $6",
+		},
+		{
+			name: "ttl rules",
+			filter: postgresFilter{tenant: &tenant, now: now, policy: TTLPolicy{
+				Rules: []TTLRule{{Pattern: "agent1_*", TTL: time.Second}, {Pattern: "Poll?", TTL: 0}},
+				TTL:   time.Hour,
+			}},
+			wantArgs: []any{tenant, "^agent1_[^/]*$", now.Add(-time.Second), "^Poll[^/]$", now.Add(-time.Hour)},
+			want: "WHERE m.tenant = $5 AND CASE WHEN m.name ~ $6 THEN t.updated_at >= $7 " +
+				"WHEN m.name ~ $8 THEN TRUE ELSE t.updated_at >= $9 END",
+		},
+		{
+			name: "rule with character class",
+			filter: postgresFilter{tenant: &tenant, now: now, policy: TTLPolicy{
+				Rules: []TTLRule{
+					{Pattern: "agent1_*", TTL: time.Second},
+					{Pattern: "agent[23]_*", TTL: time.Hour},
+					{Pattern: "agent4_*", TTL: time.Minute},
+				},
+				TTL: time.Minute,
+			}},
+			wantArgs: []any{tenant, "^agent1_[^/]*$", now.Add(-time.Second), now.Add(-time.Hour)},
+			want:     "WHERE m.tenant = $5 AND CASE WHEN m.name ~ $6 THEN t.updated_at >= $7 ELSE t.updated_at >= $8 END",
+		},
+		{
+			name: "rule with character class and forever",
+			filter: postgresFilter{tenant: &tenant, now: now, policy: TTLPolicy{
+				Rules: []TTLRule{{Pattern: "agent[23]_*", TTL: time.Hour}},
+			}},
+			wantArgs: []any{tenant},
+			want:     "WHERE m.tenant = $5",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, args := postgresAllQuery(tt.filter)
+			wantArgs := append([]any{models.MetricKindGauge, models.MetricKindCounter, models.MetricKindHistogram,
+				models.MetricKindSummary}, tt.wantArgs...)
+			if !reflect.DeepEqual(args, wantArgs) {
+				t.Errorf("postgresAllQuery() args = %v, want %v", args, wantArgs)
+			}
+			if got := strings.Count(sql, "JOIN "); got != len(postgresKinds) {
+				t.Errorf("postgresAllQuery() joins = %d, want %d:\n%s", got, len(postgresKinds), sql)
+			}
+			if tt.want == "" {
+				if strings.Contains(sql, "WHERE") {
+					t.Errorf("postgresAllQuery() query has condition:\n%s", sql)
+				}
+				return
+			}
+			if got := strings.Count(sql, tt.want); got != len(postgresKinds) {
+				t.Errorf("postgresAllQuery() query contains %q %d times, want %d:\n%s", tt.want, got,
+					len(postgresKinds), sql)
+			}
+		})
+	}
+}
+
+func Test_postgresPatternRegexp(t *testing.T) {
+	names := []string{"agent1_cpu", "agent1_", "agent1/cpu", "agent12", "Poll", "PollCount", "Poll*", "a.b", "axb",
+		"мetrics_1"}
+	for _, pattern := range []string{"agent1_*", "Poll?", "Poll\\*", "a.b", "*", "?etrics_?", "agent1*"} {
+		t.Run(pattern, func(t *testing.T) {
+			expr, ok := postgresPatternRegexp(pattern)
+			if !ok {
+				t.Fatalf("postgresPatternRegexp(%q) is not translated", pattern)
+			}
+			re := regexp.MustCompile(expr)
+			for _, name := range names {
+				want, _ := path.Match(pattern, name)
+				if got := re.MatchString(name); got != want {
+					t.Errorf("postgresPatternRegexp(%q) = %q matches %q = %v, want %v", pattern, expr, name, got, want)
+				}
+			}
+		})
+	}
+	if _, ok := postgresPatternRegexp("agent[12]_*"); ok {
+		t.Errorf("postgresPatternRegexp() translated character class")
+	}
+}
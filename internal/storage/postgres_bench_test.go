@@ -0,0 +1,102 @@
+//go:build integration
+
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/sejo412/ya-metrics/internal/models"
+)
+
+// massUpsertPerMetric is previous MassUpsert implementation with one statement per metric, kept for comparison.
+func (p *PostgresStorage) massUpsertPerMetric(ctx context.Context, metrics []models.Metric) error {
+	return p.withTx(ctx, func(tx pgx.Tx) error {
+		for _, metric := range metrics {
+			query, args, err := postgresUpsertQueryByMetric(metric)
+			if err != nil {
+				return fmt.Errorf("could not construct query: %w", err)
+			}
+			if _, err = tx.Exec(ctx, query, args...); err != nil {
+				return fmt.Errorf("failed to insert/update metric: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func postgresUpsertQueryWithSetValue(targetTable, historyTable, setValue string) string {
+	return fmt.Sprintf(`
+		WITH metric AS (
+			INSERT INTO %s (name, labels)
+			VALUES ($1, $3::JSONB)
+			ON CONFLICT (name, labels) DO NOTHING
+			RETURNING id
+		), upserted AS (
+			INSERT INTO %s (metric_id, value)
+			VALUES (
+				COALESCE((SELECT id FROM metric), (SELECT id FROM %s WHERE name = $1 AND labels = $3::JSONB)), $2
+			)
+			ON CONFLICT (metric_id) DO UPDATE
+			SET value = %s
+			RETURNING metric_id, value
+		)
+		INSERT INTO %s (metric_id, value)
+		SELECT metric_id, value FROM upserted;`, TblMapping, targetTable, TblMapping, setValue, historyTable)
+}
+
+func postgresUpsertQueryByMetric(metric models.Metric) (query string, args []interface{}, err error) {
+	labels, err := labelsToJSON(metric.Labels)
+	if err != nil {
+		return "", nil, err
+	}
+	switch metric.Kind {
+	case models.MetricKindCounter:
+		query = postgresUpsertQueryWithSetValue(TblCounters, TblCountersHistory, TblCounters+".value + EXCLUDED.value")
+		args = append(args, metric.Name, metric.Delta, labels)
+	case models.MetricKindGauge:
+		query = postgresUpsertQueryWithSetValue(TblGauges, TblGaugesHistory, "EXCLUDED.value")
+		args = append(args, metric.Name, metric.Value, labels)
+	default:
+		return "", nil, fmt.Errorf("%w: metric kind '%s'", models.ErrNotSupported, metric.Kind)
+	}
+	return query, args, nil
+}
+
+// genReport returns metrics like agent sends in one report.
+func genReport() []models.Metric {
+	metrics := genMetrics(models.TotalCountMetrics - 1)
+	return append(metrics, models.Metric{
+		Kind:  models.MetricKindCounter,
+		Name:  "testCounter" + models.MetricNamePollCount,
+		Delta: 1,
+	})
+}
+
+func BenchmarkPostgresStorage_MassUpsert(b *testing.B) {
+	tests := []struct {
+		name   string
+		upsert func(ctx context.Context, metrics []models.Metric) error
+	}{
+		{
+			name:   "per metric",
+			upsert: testDB.massUpsertPerMetric,
+		},
+		{
+			name:   "batch",
+			upsert: testDB.MassUpsert,
+		},
+	}
+	for _, tt := range tests {
+		b.Run(tt.name, func(b *testing.B) {
+			metrics := genReport()
+			for i := 0; i < b.N; i++ {
+				if err := tt.upsert(context.Background(), metrics); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
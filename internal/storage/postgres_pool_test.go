@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_postgresPoolConfig(t *testing.T) {
+	tests := []struct {
+		name                 string
+		opts                 Options
+		wantMinConns         int32
+		wantMaxConnLifetime  time.Duration
+		wantStatementTimeout string
+	}{
+		{
+			name: "driver defaults",
+			opts: Options{
+				Host:     "localhost",
+				Port:     5432,
+				Username: "metrics",
+				Password: "secret",
+				Database: "metrics",
+				SSLMode:  "disable",
+			},
+			wantMinConns:        0,
+			wantMaxConnLifetime: time.Hour,
+		},
+		{
+			name: "tuned pool",
+			opts: Options{
+				Host:             "localhost",
+				Port:             15432,
+				Username:         "metrics",
+				Password:         `it's \secret`,
+				Database:         "metrics",
+				SSLMode:          "disable",
+				MaxConns:         20,
+				MinConns:         2,
+				MaxConnLifetime:  10 * time.Minute,
+				StatementTimeout: 1500 * time.Millisecond,
+			},
+			wantMinConns:         2,
+			wantMaxConnLifetime:  10 * time.Minute,
+			wantStatementTimeout: "1500",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := postgresPoolConfig(tt.opts)
+			if err != nil {
+				t.Fatalf("postgresPoolConfig() error = %v", err)
+			}
+			conn := cfg.ConnConfig
+			if conn.Host != tt.opts.Host || int(conn.Port) != tt.opts.Port || conn.User != tt.opts.Username ||
+				conn.Database != tt.opts.Database {
+				t.Errorf("postgresPoolConfig() conn = %s@%s:%d/%s, want %s@%s:%d/%s", conn.User, conn.Host,
+					conn.Port, conn.Database, tt.opts.Username, tt.opts.Host, tt.opts.Port, tt.opts.Database)
+			}
+			if conn.Password != tt.opts.Password {
+				t.Errorf("postgresPoolConfig() password = %q, want %q", conn.Password, tt.opts.Password)
+			}
+			if tt.opts.MaxConns > 0 && cfg.MaxConns != tt.opts.MaxConns {
+				t.Errorf("postgresPoolConfig() MaxConns = %d, want %d", cfg.MaxConns, tt.opts.MaxConns)
+			}
+			if cfg.MinConns != tt.wantMinConns {
+				t.Errorf("postgresPoolConfig() MinConns = %d, want %d", cfg.MinConns, tt.wantMinConns)
+			}
+			if cfg.MaxConnLifetime != tt.wantMaxConnLifetime {
+				t.Errorf("postgresPoolConfig() MaxConnLifetime = %v, want %v", cfg.MaxConnLifetime,
+					tt.wantMaxConnLifetime)
+			}
+			if got := conn.RuntimeParams["statement_timeout"]; got != tt.wantStatementTimeout {
+				t.Errorf("postgresPoolConfig() statement_timeout = %q, want %q", got, tt.wantStatementTimeout)
+			}
+		})
+	}
+}
+
+func Test_retryPolicy_do(t *testing.T) {
+	errConnect := errors.New("connection refused")
+	tests := []struct {
+		name      string
+		policy    retryPolicy
+		failures  int
+		wantCalls int
+		wantErr   bool
+	}{
+		{
+			name:      "success at first attempt",
+			policy:    retryPolicy{retries: 3, delay: time.Millisecond},
+			failures:  0,
+			wantCalls: 1,
+		},
+		{
+			name:      "success after retries",
+			policy:    retryPolicy{retries: 3, delay: time.Millisecond},
+			failures:  2,
+			wantCalls: 3,
+		},
+		{
+			name:      "retries exhausted",
+			policy:    retryPolicy{retries: 2, delay: time.Millisecond},
+			failures:  5,
+			wantCalls: 3,
+			wantErr:   true,
+		},
+		{
+			name:      "no retries",
+			policy:    retryPolicy{},
+			failures:  1,
+			wantCalls: 1,
+			wantErr:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := tt.policy.do(context.Background(), func(context.Context) error {
+				calls++
+				if calls <= tt.failures {
+					return errConnect
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("do() calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("do() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, errConnect) {
+				t.Errorf("do() error = %v, want wrapped %v", err, errConnect)
+			}
+		})
+	}
+}
+
+func Test_retryPolicy_doCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	policy := retryPolicy{retries: 3, delay: time.Hour}
+	err := policy.do(ctx, func(context.Context) error {
+		return errors.New("connection refused")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("do() error = %v, want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,48 @@
+//go:build integration
+
+package storage_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/storage"
+	"github.com/sejo412/ya-metrics/internal/storage/storagetest"
+)
+
+func TestPostgresStorage_Suite(t *testing.T) {
+	opts := storage.Options{
+		Scheme:   "postgres",
+		Host:     "localhost",
+		Port:     5432,
+		Username: "metrics",
+		Password: "secret",
+		Database: "metrics",
+	}
+	if _, ok := os.LookupEnv("GITHUB_ACTIONS"); ok {
+		opts.Host = "postgres"
+	} else {
+		opts.Port = 15432
+	}
+	s := storage.NewPostgresStorage()
+	if err := s.Open(context.Background(), opts); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer s.Close()
+	if err := s.Init(context.Background()); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	clean := func() {
+		query := "DELETE FROM " + storage.TblMapping + " WHERE name LIKE '" + storagetest.Prefix + "%'"
+		if _, err := s.Client.Exec(context.Background(), query); err != nil {
+			t.Fatalf("clean suite metrics error = %v", err)
+		}
+	}
+	defer clean()
+	storagetest.Run(t, func(t *testing.T) config.Storage {
+		clean()
+		return s
+	})
+}
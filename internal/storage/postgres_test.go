@@ -5,13 +5,14 @@ package storage
 import (
 	"bytes"
 	"context"
-	"database/sql"
 	"fmt"
 	"io"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/sejo412/ya-metrics/internal/models"
 )
 
@@ -47,7 +48,7 @@ func TestMain(m *testing.M) {
 	if err := testDB.Open(context.Background(), opts); err != nil {
 		fmt.Println(err)
 	}
-	if _, err := testDB.Client.Exec("DELETE FROM metric_mapping WHERE name LIKE 'test%'"); err != nil {
+	if _, err := testDB.Client.Exec(context.Background(), "DELETE FROM metric_mapping WHERE name LIKE 'test%'"); err != nil {
 		fmt.Println(err)
 	}
 	os.Exit(exitVal)
@@ -95,12 +96,30 @@ func TestPostgresStorage_Init(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "init migrated",
+			args: args{
+				ctx: context.Background(),
+			},
+			wantErr: false,
+		},
+	}
+	migrations, err := loadMigrations(migrationsFS, "migrations")
+	if err != nil {
+		t.Fatal(err)
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			if err := testDB.Init(tt.args.ctx); (err != nil) != tt.wantErr {
 				t.Errorf("Init() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			version, err := testDB.SchemaVersion(tt.args.ctx)
+			if err != nil {
+				t.Errorf("SchemaVersion() error = %v", err)
+			}
+			if version != len(migrations) {
+				t.Errorf("SchemaVersion() got = %d, want %d", version, len(migrations))
+			}
 		})
 	}
 }
@@ -113,21 +132,50 @@ func TestPostgresStorage_Load(t *testing.T) {
 	tests := []struct {
 		name    string
 		args    args
+		want    models.Metric
 		wantErr bool
 	}{
 		{
-			name: "load",
+			name: "load OK",
 			args: args{
 				ctx: context.Background(),
+				src: bytes.NewBufferString(`{"delta":3,"id":"testCounterLoad","type":"counter"}
+{"delta":2,"id":"testCounterLoad","type":"counter"}
+{"value":9999.11,"id":"testGaugeLoad","type":"gauge"}
+`),
+			},
+			want: models.Metric{
+				Kind:  models.MetricKindCounter,
+				Name:  "testCounterLoad",
+				Delta: 5,
 			},
 			wantErr: false,
 		},
+		{
+			name: "load Error",
+			args: args{
+				ctx: context.Background(),
+				src: bytes.NewBufferString("zzz"),
+			},
+			wantErr: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			p := &PostgresStorage{}
-			if err := p.Load(tt.args.ctx, tt.args.src); (err != nil) != tt.wantErr {
+			if err := testDB.Load(tt.args.ctx, tt.args.src); (err != nil) != tt.wantErr {
 				t.Errorf("Load() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := testDB.Get(tt.args.ctx, tt.want.Kind, tt.want.Name)
+			if err != nil {
+				t.Errorf("Get() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Load() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
@@ -150,7 +198,7 @@ func TestPostgresStorage_Upsert(t *testing.T) {
 				metric: models.Metric{
 					Kind:  models.MetricKindGauge,
 					Name:  "testGauge1",
-					Value: "9999.11",
+					Value: 9999.11,
 				},
 			},
 			wantErr: false,
@@ -162,7 +210,7 @@ func TestPostgresStorage_Upsert(t *testing.T) {
 				metric: models.Metric{
 					Kind:  models.MetricKindCounter,
 					Name:  "testCounter1",
-					Value: "1",
+					Delta: 1,
 				},
 			},
 			wantErr: false,
@@ -174,19 +222,19 @@ func TestPostgresStorage_Upsert(t *testing.T) {
 				metric: models.Metric{
 					Kind:  models.MetricKindCounter,
 					Name:  "testCounter1",
-					Value: "2",
+					Delta: 2,
 				},
 			},
 			wantErr: false,
 		},
 		{
-			name: "update counter Error",
+			name: "update unsupported Error",
 			args: args{
 				ctx: context.Background(),
 				metric: models.Metric{
-					Kind:  models.MetricKindCounter,
+					Kind:  "preved",
 					Name:  "testCounter1",
-					Value: "preved",
+					Delta: 1,
 				},
 			},
 			wantErr: true,
@@ -223,7 +271,7 @@ func TestPostgresStorage_Get(t *testing.T) {
 			want: models.Metric{
 				Kind:  models.MetricKindGauge,
 				Name:  "testGauge1",
-				Value: "9999.11",
+				Value: 9999.11,
 			},
 			wantErr: false,
 		},
@@ -237,7 +285,7 @@ func TestPostgresStorage_Get(t *testing.T) {
 			want: models.Metric{
 				Kind:  models.MetricKindCounter,
 				Name:  "testCounter1",
-				Value: "3",
+				Delta: 3,
 			},
 		},
 		{
@@ -283,12 +331,12 @@ func TestPostgresStorage_MassUpsert(t *testing.T) {
 					{
 						Kind:  models.MetricKindGauge,
 						Name:  "testGauge2",
-						Value: "9999.22",
+						Value: 9999.22,
 					},
 					{
 						Kind:  models.MetricKindCounter,
 						Name:  "testCounter2",
-						Value: "1",
+						Delta: 1,
 					},
 				},
 			},
@@ -302,12 +350,12 @@ func TestPostgresStorage_MassUpsert(t *testing.T) {
 					{
 						Kind:  models.MetricKindGauge,
 						Name:  "testGauge3",
-						Value: "9999.33",
+						Value: 9999.33,
 					},
 					{
-						Kind:  models.MetricKindCounter,
+						Kind:  "preved",
 						Name:  "testCounter2",
-						Value: "preved",
+						Delta: 1,
 					},
 				},
 			},
@@ -323,7 +371,7 @@ func TestPostgresStorage_MassUpsert(t *testing.T) {
 	}
 }
 
-func TestPostgresStorage_GetAll(t *testing.T) {
+func TestPostgresStorage_All(t *testing.T) {
 	type args struct {
 		ctx context.Context
 	}
@@ -342,9 +390,9 @@ func TestPostgresStorage_GetAll(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := testDB.GetAll(tt.args.ctx)
+			_, err := collectMetrics(testDB.All(tt.args.ctx))
 			if (err != nil) != tt.wantErr {
-				t.Errorf("GetAll() error = %v, wantErr %v", err, tt.wantErr)
+				t.Errorf("All() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
 		})
@@ -356,31 +404,138 @@ func TestPostgresStorage_Flush(t *testing.T) {
 		ctx context.Context
 	}
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name     string
+		args     args
+		wantLine string
+		wantErr  bool
 	}{
 		{
 			name: "success",
 			args: args{
 				ctx: context.Background(),
 			},
-			wantErr: false,
+			wantLine: `{"value":9999.11,"id":"testGauge1","type":"gauge"}`,
+			wantErr:  false,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			p := &PostgresStorage{}
 			dst := &bytes.Buffer{}
-			err := p.Flush(tt.args.ctx, dst)
+			err := testDB.Flush(tt.args.ctx, dst)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Flush() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
+			if !bytes.Contains(dst.Bytes(), []byte(tt.wantLine)) {
+				t.Errorf("Flush() gotDst = %v, want line %v", dst.String(), tt.wantLine)
+			}
 		})
 	}
 }
 
+func TestPostgresStorage_FlushRestartRestore(t *testing.T) {
+	ctx := context.Background()
+	opts := Options{
+		Scheme:   "postgres",
+		Host:     "localhost",
+		Port:     5432,
+		Username: "metrics",
+		Password: "secret",
+		Database: "metrics",
+	}
+	if _, ok := os.LookupEnv("GITHUB_ACTIONS"); ok {
+		opts.Host = "postgres"
+	} else {
+		opts.Port = 15432
+	}
+	counter := models.Metric{
+		Kind:  models.MetricKindCounter,
+		Name:  "testCounterRestart",
+		Delta: 5,
+	}
+	before := NewPostgresStorage()
+	if err := before.Open(ctx, opts); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if err := before.Upsert(ctx, counter); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	want, err := before.Get(ctx, counter.Kind, counter.Name)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	dump := &bytes.Buffer{}
+	if err = before.Flush(ctx, dump); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	before.Close()
+
+	// restart the same way as server does: store file is restored only if storage keeps state in it
+	after := NewPostgresStorage()
+	if err = after.Open(ctx, opts); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer after.Close()
+	if UsesStoreFile(opts.Scheme) {
+		if err = after.Load(ctx, dump); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+	}
+	got, err := after.Get(ctx, counter.Kind, counter.Name)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	if got.Delta != want.Delta {
+		t.Errorf("Get() after restart delta = %d, want %d", got.Delta, want.Delta)
+	}
+}
+
+func TestPostgresStorage_GetRange(t *testing.T) {
+	type args struct {
+		ctx  context.Context
+		kind string
+		name string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "get range OK",
+			args: args{
+				ctx:  context.Background(),
+				kind: models.MetricKindCounter,
+				name: "testCounter1",
+			},
+			wantErr: false,
+		},
+		{
+			name: "get range Error",
+			args: args{
+				ctx:  context.Background(),
+				kind: models.MetricKindGauge,
+				name: "testGauge2222",
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := testDB.GetRange(tt.args.ctx, tt.args.kind, tt.args.name, time.Time{}, time.Now())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetRange() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPostgresStorage_PruneHistory(t *testing.T) {
+	if err := testDB.PruneHistory(context.Background(), time.Now()); err != nil {
+		t.Errorf("PruneHistory() error = %v", err)
+	}
+}
+
 func TestPostgresStorage_Close(t *testing.T) {
 	opts := Options{
 		Scheme:   "postgres",
@@ -400,7 +555,7 @@ func TestPostgresStorage_Close(t *testing.T) {
 		panic(err)
 	}
 	type fields struct {
-		Client *sql.DB
+		Client *pgxpool.Pool
 	}
 	tests := []struct {
 		name   string
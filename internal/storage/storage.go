@@ -1,18 +1,28 @@
 package storage
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
+	"iter"
 	"net/url"
 	"path"
+	"slices"
+	"strconv"
 	"time"
+
+	"github.com/sejo412/ya-metrics/internal/models"
 )
 
 const (
-	ctxTimeout                 = 10 * time.Second
-	defaultPostgresPort int    = 5432
-	MemoryScheme        string = "memory"     // memory storage scheme
-	PostgresSchemeLong  string = "postgresql" // long string for postgres scheme
-	PostgresSchemeShort string = "postgres"   // short string for postgres scheme
+	ctxTimeout                    = 10 * time.Second
+	defaultPostgresPort    int    = 5432
+	defaultCompactInterval        = 5 * time.Minute
+	MemoryScheme           string = "memory"     // memory storage scheme
+	PostgresSchemeLong     string = "postgresql" // long string for postgres scheme
+	PostgresSchemeShort    string = "postgres"   // short string for postgres scheme
+	FileScheme             string = "file"       // append-only log storage scheme
 )
 
 const (
@@ -38,8 +48,31 @@ type Options struct {
 	Database string
 	// SSLMode - settings for SSL.
 	SSLMode string
+	// Path - directory for file backend.
+	Path string
 	// Port - TCP port for connect to backend.
 	Port int
+	// CompactInterval - how often file backend compacts log into snapshot.
+	CompactInterval time.Duration
+	// MaxConnLifetime - how long connection of pool may be reused, 0 for default.
+	MaxConnLifetime time.Duration
+	// StatementTimeout - server side timeout of statements, 0 for no timeout.
+	StatementTimeout time.Duration
+	// ConnectRetryDelay - delay before first retry of connect, next delays grow linearly.
+	ConnectRetryDelay time.Duration
+	// ConnectRetries - how many times retry connect after first failed attempt.
+	ConnectRetries int
+	// MaxConns - max connections in pool, 0 for default.
+	MaxConns int32
+	// MinConns - min idle connections in pool, 0 for default.
+	MinConns int32
+}
+
+// UsesStoreFile reports whether storage with scheme keeps its state between restarts only in store file.
+// Another storages keep state themselves, so they mustn't be flushed and restored automatically:
+// loading store file into them adds counters twice.
+func UsesStoreFile(scheme string) bool {
+	return scheme == MemoryScheme
 }
 
 // ParseDSN parses DSN string to Options type.
@@ -54,11 +87,19 @@ func ParseDSN(dsn string) (opts Options, err error) {
 		return Options{
 			Scheme: MemoryScheme,
 		}, nil
+	case FileScheme:
+		return parseFileDSN(u)
 	case PostgresSchemeLong, PostgresSchemeShort:
 		port = defaultPostgresPort
 	default:
 		return opts, fmt.Errorf("unsupported database scheme: %s", u.Scheme)
 	}
+	if u.Port() != "" {
+		port, err = strconv.Atoi(u.Port())
+		if err != nil {
+			return opts, fmt.Errorf("invalid port: %s", u.Port())
+		}
+	}
 	password, _ := u.User.Password()
 	paramSslMode := u.Query().Get("sslmode")
 	var sslMode string
@@ -70,7 +111,7 @@ func ParseDSN(dsn string) (opts Options, err error) {
 	default:
 		return opts, fmt.Errorf("unsupported sslmode: %s", sslMode)
 	}
-	return Options{
+	opts = Options{
 		Scheme:   u.Scheme,
 		Host:     u.Hostname(),
 		Port:     port,
@@ -78,5 +119,179 @@ func ParseDSN(dsn string) (opts Options, err error) {
 		Password: password,
 		Database: path.Base(u.Path),
 		SSLMode:  sslMode,
+	}
+	if err = parsePoolParams(u.Query(), &opts); err != nil {
+		return Options{}, err
+	}
+	return opts, nil
+}
+
+// parsePoolParams parses pool settings from DSN query like
+// ?pool_max_conns=10&pool_min_conns=2&pool_max_conn_lifetime=1h&statement_timeout=5s
+// &connect_retries=3&connect_retry_delay=1s.
+func parsePoolParams(query url.Values, opts *Options) error {
+	if v := query.Get("connect_retries"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid connect_retries: %s", v)
+		}
+		opts.ConnectRetries = n
+	}
+	conns := []struct {
+		dst *int32
+		key string
+	}{
+		{key: "pool_max_conns", dst: &opts.MaxConns},
+		{key: "pool_min_conns", dst: &opts.MinConns},
+	}
+	for _, param := range conns {
+		if v := query.Get(param.key); v != "" {
+			n, err := strconv.ParseInt(v, 10, 32)
+			if err != nil || n < 0 {
+				return fmt.Errorf("invalid %s: %s", param.key, v)
+			}
+			*param.dst = int32(n)
+		}
+	}
+	durations := []struct {
+		dst *time.Duration
+		key string
+	}{
+		{key: "pool_max_conn_lifetime", dst: &opts.MaxConnLifetime},
+		{key: "statement_timeout", dst: &opts.StatementTimeout},
+		{key: "connect_retry_delay", dst: &opts.ConnectRetryDelay},
+	}
+	for _, param := range durations {
+		if v := query.Get(param.key); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d < 0 {
+				return fmt.Errorf("invalid %s: %s", param.key, v)
+			}
+			*param.dst = d
+		}
+	}
+	if opts.MaxConns > 0 && opts.MinConns > opts.MaxConns {
+		return fmt.Errorf("pool_min_conns %d is greater than pool_max_conns %d", opts.MinConns, opts.MaxConns)
+	}
+	return nil
+}
+
+// parseFileDSN parses DSN like file:///var/lib/metrics?compact_interval=1m.
+func parseFileDSN(u *url.URL) (opts Options, err error) {
+	dir := u.Host + u.Path
+	if u.Opaque != "" {
+		dir = u.Opaque
+	}
+	if dir == "" {
+		return opts, fmt.Errorf("empty path for %s scheme", FileScheme)
+	}
+	compactInterval := defaultCompactInterval
+	if param := u.Query().Get("compact_interval"); param != "" {
+		compactInterval, err = time.ParseDuration(param)
+		if err != nil || compactInterval <= 0 {
+			return opts, fmt.Errorf("invalid compact_interval: %s", param)
+		}
+	}
+	return Options{
+		Scheme:          FileScheme,
+		Path:            dir,
+		CompactInterval: compactInterval,
 	}, nil
 }
+
+// isValidKind returns true if storages support metric kind.
+func isValidKind(kind string) bool {
+	switch kind {
+	case models.MetricKindGauge, models.MetricKindCounter, models.MetricKindHistogram, models.MetricKindSummary:
+		return true
+	default:
+		return false
+	}
+}
+
+// validateMetric returns error if storages don't support metric kind or histogram or summary of metric is invalid.
+func validateMetric(metric models.Metric) error {
+	switch metric.Kind {
+	case models.MetricKindHistogram:
+		return metric.Histogram.Validate()
+	case models.MetricKindSummary:
+		return metric.Summary.Validate()
+	}
+	if !isValidKind(metric.Kind) {
+		return fmt.Errorf("%w: metric kind '%s'", models.ErrNotSupported, metric.Kind)
+	}
+	return nil
+}
+
+// validateCondition returns error if metric can't be updated by condition: only gauges are updated conditionally.
+func validateCondition(metric models.Metric, cond models.Condition) error {
+	if err := validateMetric(metric); err != nil {
+		return err
+	}
+	if metric.Kind != models.MetricKindGauge {
+		return fmt.Errorf("%w: conditional update of %s", models.ErrNotSupported, metric.Kind)
+	}
+	return cond.Validate()
+}
+
+// newSample returns sample of metric value stored at ts.
+// Histogram and summary are sampled as count and sum of observations.
+func newSample(metric models.Metric, ts time.Time) models.Sample {
+	switch metric.Kind {
+	case models.MetricKindHistogram:
+		return models.Sample{Timestamp: ts, Delta: int64(metric.Histogram.Count()), Value: metric.Histogram.Sum}
+	case models.MetricKindSummary:
+		return models.Sample{Timestamp: ts, Delta: int64(metric.Summary.Count()), Value: metric.Summary.Sum}
+	}
+	return models.Sample{Timestamp: ts, Delta: metric.Delta, Value: metric.Value}
+}
+
+// encodeMetrics writes metrics of all tenants to destination as newline-delimited MetricV2 JSON.
+func encodeMetrics(dst io.Writer, metrics iter.Seq2[models.Metric, error]) error {
+	encoder := json.NewEncoder(dst)
+	for metric, err := range metrics {
+		if err != nil {
+			return err
+		}
+		m, err := models.ConvertV1ToV2(&metric)
+		if err != nil {
+			return err
+		}
+		m.Tenant = metric.Tenant
+		if err = encoder.Encode(m); err != nil {
+			return fmt.Errorf("error encode metric %s: %w", metric.Name, err)
+		}
+	}
+	return nil
+}
+
+// withTenant returns copy of metrics moved to tenant, so caller can't write metrics of other tenant.
+func withTenant(metrics []models.Metric, tenant string) []models.Metric {
+	res := slices.Clone(metrics)
+	for i := range res {
+		res[i].Tenant = tenant
+	}
+	return res
+}
+
+// decodeMetrics reads newline-delimited MetricV2 JSON from source, metrics keep their tenants.
+func decodeMetrics(src io.Reader) ([]models.Metric, error) {
+	metrics := make([]models.Metric, 0)
+	scanner := bufio.NewScanner(src)
+	for scanner.Scan() {
+		m := models.MetricV2{}
+		if err := json.Unmarshal(scanner.Bytes(), &m); err != nil {
+			return nil, fmt.Errorf("error unmarshal metric %s: %w", scanner.Text(), err)
+		}
+		res, err := models.ConvertV2ToV1(&m)
+		if err != nil {
+			return nil, err
+		}
+		res.Tenant = m.Tenant
+		metrics = append(metrics, *res)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error read metrics: %w", err)
+	}
+	return metrics, nil
+}
@@ -0,0 +1,2 @@
+// Package storagetest implements conformance test suite for config.Storage implementations.
+package storagetest
@@ -0,0 +1,1264 @@
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"iter"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/models"
+	"github.com/sejo412/ya-metrics/internal/storage"
+)
+
+// Prefix - all metrics created by suite have names with this prefix.
+const Prefix = "testSuite"
+
+// Settings for concurrency test.
+const (
+	concurrentWriters = 8
+	concurrentUpserts = 50
+)
+
+// NewStorage returns opened and initialized storage without metrics named with Prefix.
+// It is called for every test of suite, storage is not closed by suite.
+type NewStorage func(t *testing.T) config.Storage
+
+// Run runs all tests of suite against storage returned by newStorage.
+func Run(t *testing.T, newStorage NewStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, newStorage NewStorage)
+	}{
+		{name: "upsert gauge replaces value", fn: testUpsertGauge},
+		{name: "upsert counter accumulates value", fn: testUpsertCounter},
+		{name: "mass upsert accumulates repeated counters", fn: testMassUpsert},
+		{name: "mass upsert is atomic", fn: testMassUpsertAtomic},
+		{name: "get honours kind", fn: testGetKind},
+		{name: "get not found", fn: testGetNotFound},
+		{name: "unsupported kind", fn: testUnsupportedKind},
+		{name: "all returns every metric", fn: testGetAll},
+		{name: "concurrent upserts", fn: testConcurrentUpserts},
+		{name: "flush and load round trip", fn: testFlushLoad},
+		{name: "load invalid source", fn: testLoadInvalid},
+		{name: "history honours kind", fn: testGetRange},
+		{name: "delete honours kind", fn: testDelete},
+		{name: "delete by prefix", fn: testDeleteByPrefix},
+		{name: "reset counter", fn: testResetCounter},
+		{name: "labels identify metric", fn: testLabels},
+		{name: "select by label matchers", fn: testSelect},
+		{name: "delete and reset all label sets", fn: testDeleteLabels},
+		{name: "flush and load labels", fn: testFlushLoadLabels},
+		{name: "delete stale metrics", fn: testDeleteStale},
+		{name: "all fresh hides stale metrics", fn: testGetFresh},
+		{name: "unit and description kept when omitted", fn: testUnitDescription},
+		{name: "metadata tracks update time and source", fn: testMetadata},
+		{name: "upsert histogram merges buckets", fn: testUpsertHistogram},
+		{name: "histogram history and round trip", fn: testHistogramRoundTrip},
+		{name: "upsert summary merges sketches", fn: testUpsertSummary},
+		{name: "summary history and round trip", fn: testSummaryRoundTrip},
+		{name: "list pages and filters", fn: testList},
+		{name: "iterate over all metrics", fn: testAll},
+		{name: "conditional update of gauge", fn: testUpsertIf},
+		{name: "concurrent conditional updates", fn: testConcurrentUpsertIf},
+		{name: "concurrent conditional inserts", fn: testConcurrentUpsertIfNew},
+		{name: "tenants are isolated", fn: testTenants},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage)
+		})
+	}
+}
+
+// NewPersistentStorage returns storage like NewStorage and function which closes it
+// and returns storage opened again from the same persistent state.
+type NewPersistentStorage func(t *testing.T) (st config.Storage, reopen func() config.Storage)
+
+// compacter is storage which compacts its persistent state on request.
+type compacter interface {
+	Compact() error
+}
+
+// RunPersistent runs tests of suite for storages which keep metrics between restarts.
+func RunPersistent(t *testing.T, newStorage NewPersistentStorage) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, newStorage NewPersistentStorage)
+	}{
+		{name: "metadata survives compaction and reopen", fn: testMetadataReopen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStorage)
+		})
+	}
+}
+
+func name(i int) string {
+	return Prefix + strconv.Itoa(i)
+}
+
+func gauge(i int, value float64) models.Metric {
+	return models.Metric{Kind: models.MetricKindGauge, Name: name(i), Value: value}
+}
+
+func counter(i int, delta int64) models.Metric {
+	return models.Metric{Kind: models.MetricKindCounter, Name: name(i), Delta: delta}
+}
+
+// histogram returns histogram metric with bounds of buckets and counts including +Inf bucket.
+func histogram(i int, sum float64, bounds []float64, counts ...uint64) models.Metric {
+	return models.Metric{Kind: models.MetricKindHistogram, Name: name(i),
+		Histogram: &models.Histogram{Bounds: bounds, Counts: counts, Sum: sum}}
+}
+
+// summary returns summary metric with sketch of observed values.
+func summary(i int, values ...float64) models.Metric {
+	s := &models.Summary{Accuracy: models.DefaultSummaryAccuracy}
+	for _, v := range values {
+		s.Observe(v)
+	}
+	return models.Metric{Kind: models.MetricKindSummary, Name: name(i), Summary: s}
+}
+
+// withLabels returns metric with labels specified as name, value pairs.
+func withLabels(m models.Metric, kv ...string) models.Metric {
+	m.Labels = make(models.Labels, len(kv)/2)
+	for i := 0; i+1 < len(kv); i += 2 {
+		m.Labels[kv[i]] = kv[i+1]
+	}
+	return m
+}
+
+// inTenant returns metric of tenant as storage returns it.
+func inTenant(m models.Metric, tenant string) models.Metric {
+	m.Tenant = tenant
+	return m
+}
+
+func matcher(t *testing.T, s string) models.LabelMatcher {
+	t.Helper()
+	m, err := models.ParseLabelMatcher(s)
+	if err != nil {
+		t.Fatalf("ParseLabelMatcher(%s) error = %v", s, err)
+	}
+	return m
+}
+
+// own returns sorted metrics created by suite.
+func own(t *testing.T, st config.Storage) []models.Metric {
+	t.Helper()
+	return ownOf(t, st.All(context.Background()))
+}
+
+// ownOf returns sorted metrics created by suite from iterator.
+func ownOf(t *testing.T, all iter.Seq2[models.Metric, error]) []models.Metric {
+	t.Helper()
+	res := make([]models.Metric, 0)
+	for m, err := range all {
+		if err != nil {
+			t.Fatalf("iterate metrics error = %v", err)
+		}
+		if strings.HasPrefix(m.Name, Prefix) {
+			res = append(res, m)
+		}
+	}
+	slices.SortFunc(res, models.CompareMetrics)
+	return res
+}
+
+func upsert(t *testing.T, st config.Storage, metrics ...models.Metric) {
+	t.Helper()
+	for _, m := range metrics {
+		if err := st.Upsert(context.Background(), m); err != nil {
+			t.Fatalf("Upsert(%v) error = %v", m, err)
+		}
+	}
+}
+
+func assertGet(t *testing.T, st config.Storage, want models.Metric) {
+	t.Helper()
+	got, err := st.Get(context.Background(), want.Kind, want.Name)
+	if err != nil {
+		t.Errorf("Get(%s, %s) error = %v", want.Kind, want.Name, err)
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Get(%s, %s) got = %v, want %v", want.Kind, want.Name, got, want)
+	}
+}
+
+func testUpsertGauge(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, gauge(1, 1.5), gauge(1, -2.25))
+	assertGet(t, st, gauge(1, -2.25))
+}
+
+func testUpsertCounter(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, counter(1, 2), counter(1, 3), counter(1, -1))
+	assertGet(t, st, counter(1, 4))
+}
+
+func testMassUpsert(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	err := st.MassUpsert(context.Background(), []models.Metric{
+		counter(1, 1),
+		gauge(2, 1.1),
+		counter(1, 2),
+		gauge(2, 2.2),
+		counter(1, 3),
+	})
+	if err != nil {
+		t.Fatalf("MassUpsert() error = %v", err)
+	}
+	assertGet(t, st, counter(1, 6))
+	assertGet(t, st, gauge(2, 2.2))
+}
+
+func testMassUpsertAtomic(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, counter(1, 1))
+	err := st.MassUpsert(context.Background(), []models.Metric{
+		gauge(2, 2.5),
+		counter(1, 2),
+		{Kind: "preved", Name: name(3)},
+	})
+	if err == nil {
+		t.Fatalf("MassUpsert() of unsupported kind error = nil")
+	}
+	assertGet(t, st, counter(1, 1))
+	if _, err = st.Get(context.Background(), models.MetricKindGauge, name(2)); !errors.Is(err, models.ErrHTTPNotFound) {
+		t.Errorf("Get() of metric from failed batch error = %v, want %v", err, models.ErrHTTPNotFound)
+	}
+}
+
+func testGetKind(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, gauge(1, 1.5), counter(1, 2), counter(1, 3))
+	assertGet(t, st, gauge(1, 1.5))
+	assertGet(t, st, counter(1, 5))
+}
+
+func testGetNotFound(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, gauge(1, 1.5))
+	for _, m := range []models.Metric{gauge(2, 0), counter(1, 0)} {
+		if _, err := st.Get(context.Background(), m.Kind, m.Name); !errors.Is(err, models.ErrHTTPNotFound) {
+			t.Errorf("Get(%s, %s) error = %v, want %v", m.Kind, m.Name, err, models.ErrHTTPNotFound)
+		}
+	}
+}
+
+func testUnsupportedKind(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	if _, err := st.Get(ctx, "preved", name(1)); !errors.Is(err, models.ErrNotSupported) {
+		t.Errorf("Get() error = %v, want %v", err, models.ErrNotSupported)
+	}
+	err := st.Upsert(ctx, models.Metric{Kind: "preved", Name: name(1), Value: 1})
+	if !errors.Is(err, models.ErrNotSupported) {
+		t.Errorf("Upsert() error = %v, want %v", err, models.ErrNotSupported)
+	}
+	_, err = st.GetRange(ctx, "preved", name(1), time.Time{}, time.Now())
+	if !errors.Is(err, models.ErrNotSupported) {
+		t.Errorf("GetRange() error = %v, want %v", err, models.ErrNotSupported)
+	}
+}
+
+func testGetAll(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, gauge(2, 2.5), gauge(1, 1.5), counter(1, 1))
+	want := []models.Metric{counter(1, 1), gauge(1, 1.5), gauge(2, 2.5)}
+	if got := own(t, st); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+}
+
+func testConcurrentUpserts(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*concurrentWriters*concurrentUpserts)
+	for w := 0; w < concurrentWriters; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < concurrentUpserts; i++ {
+				if err := st.Upsert(context.Background(), counter(1, 1)); err != nil {
+					errs <- err
+				}
+				if err := st.MassUpsert(context.Background(), []models.Metric{gauge(w, 1)}); err != nil {
+					errs <- err
+				}
+				// batches with the same metrics in different order must not deadlock
+				batch := []models.Metric{counter(2, 1), counter(3, 1)}
+				if w%2 == 1 {
+					slices.Reverse(batch)
+				}
+				if err := st.MassUpsert(context.Background(), batch); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent upsert error = %v", err)
+	}
+	total := int64(concurrentWriters * concurrentUpserts)
+	assertGet(t, st, counter(1, total))
+	assertGet(t, st, counter(2, total))
+	assertGet(t, st, counter(3, total))
+}
+
+func testFlushLoad(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	want := []models.Metric{counter(1, 5), gauge(1, 1.5), gauge(2, 0.001)}
+	upsert(t, st, want...)
+	buf := new(bytes.Buffer)
+	if err := st.Flush(context.Background(), buf); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	// keep only own metrics, storage may contain other data
+	src := new(bytes.Buffer)
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.Contains(line, `"id":"`+Prefix) {
+			src.WriteString(line)
+		}
+	}
+
+	// load into empty storage
+	restored := newStorage(t)
+	if err := restored.Load(context.Background(), bytes.NewReader(src.Bytes())); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := own(t, restored); !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() got = %v, want %v", got, want)
+	}
+	// load again accumulates counters
+	if err := restored.Load(context.Background(), bytes.NewReader(src.Bytes())); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	assertGet(t, restored, counter(1, 10))
+	assertGet(t, restored, gauge(1, 1.5))
+}
+
+func testLoadInvalid(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	if err := st.Load(context.Background(), strings.NewReader("zzz")); err == nil {
+		t.Errorf("Load() of invalid source error = nil")
+	}
+}
+
+func testGetRange(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	from := time.Now().Add(-time.Hour)
+	upsert(t, st, counter(1, 2), gauge(1, 1.5), counter(1, 3))
+	to := time.Now().Add(time.Hour)
+	tests := []struct {
+		kind string
+		want []models.Metric
+	}{
+		{kind: models.MetricKindCounter, want: []models.Metric{counter(1, 2), counter(1, 5)}},
+		{kind: models.MetricKindGauge, want: []models.Metric{gauge(1, 1.5)}},
+	}
+	for _, tt := range tests {
+		samples, err := st.GetRange(ctx, tt.kind, name(1), from, to)
+		if err != nil {
+			t.Errorf("GetRange(%s) error = %v", tt.kind, err)
+			continue
+		}
+		got := make([]models.Metric, 0, len(samples))
+		for _, sample := range samples {
+			got = append(got, models.Metric{Kind: tt.kind, Name: name(1), Delta: sample.Delta, Value: sample.Value})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetRange(%s) got = %v, want %v", tt.kind, got, tt.want)
+		}
+	}
+	if _, err := st.GetRange(ctx, models.MetricKindGauge, name(2), from, to); !errors.Is(err,
+		models.ErrHTTPNotFound) {
+		t.Errorf("GetRange() error = %v, want %v", err, models.ErrHTTPNotFound)
+	}
+}
+
+func testDelete(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	upsert(t, st, gauge(1, 1.5), counter(1, 2))
+	if err := st.Delete(ctx, models.MetricKindCounter, name(1)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, err := st.Get(ctx, models.MetricKindCounter, name(1)); !errors.Is(err, models.ErrHTTPNotFound) {
+		t.Errorf("Get() of deleted metric error = %v, want %v", err, models.ErrHTTPNotFound)
+	}
+	if _, err := st.GetRange(ctx, models.MetricKindCounter, name(1), time.Time{},
+		time.Now().Add(time.Hour)); !errors.Is(err, models.ErrHTTPNotFound) {
+		t.Errorf("GetRange() of deleted metric error = %v, want %v", err, models.ErrHTTPNotFound)
+	}
+	assertGet(t, st, gauge(1, 1.5))
+	if err := st.Delete(ctx, models.MetricKindCounter, name(1)); !errors.Is(err, models.ErrHTTPNotFound) {
+		t.Errorf("Delete() of deleted metric error = %v, want %v", err, models.ErrHTTPNotFound)
+	}
+	if err := st.Delete(ctx, "preved", name(1)); !errors.Is(err, models.ErrNotSupported) {
+		t.Errorf("Delete() error = %v, want %v", err, models.ErrNotSupported)
+	}
+	// deleted counter starts from scratch
+	upsert(t, st, counter(1, 3))
+	assertGet(t, st, counter(1, 3))
+}
+
+func testDeleteByPrefix(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, gauge(1, 1.5), counter(1, 2), gauge(10, 1), gauge(2, 2.5))
+	deleted, err := st.DeleteByPrefix(context.Background(), name(1))
+	if err != nil {
+		t.Fatalf("DeleteByPrefix() error = %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("DeleteByPrefix() got = %d, want %d", deleted, 3)
+	}
+	want := []models.Metric{gauge(2, 2.5)}
+	if got := own(t, st); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+	deleted, err = st.DeleteByPrefix(context.Background(), name(1))
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteByPrefix() again got = %d, error = %v, want 0", deleted, err)
+	}
+}
+
+func testResetCounter(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	upsert(t, st, counter(1, 5), gauge(2, 1.5))
+	if err := st.ResetCounter(ctx, name(1)); err != nil {
+		t.Fatalf("ResetCounter() error = %v", err)
+	}
+	assertGet(t, st, counter(1, 0))
+	upsert(t, st, counter(1, 2))
+	assertGet(t, st, counter(1, 2))
+	for _, n := range []string{name(2), name(3)} {
+		if err := st.ResetCounter(ctx, n); !errors.Is(err, models.ErrHTTPNotFound) {
+			t.Errorf("ResetCounter(%s) error = %v, want %v", n, err, models.ErrHTTPNotFound)
+		}
+	}
+}
+
+func testLabels(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st,
+		counter(1, 1),
+		withLabels(counter(1, 2), "host", "a"),
+		withLabels(counter(1, 3), "host", "b"),
+		withLabels(counter(1, 4), "host", "a"),
+		withLabels(counter(1, 5), "host", "a", "service", "api"),
+	)
+	assertGet(t, st, counter(1, 1))
+	want := []models.Metric{
+		counter(1, 1),
+		withLabels(counter(1, 6), "host", "a"),
+		withLabels(counter(1, 5), "host", "a", "service", "api"),
+		withLabels(counter(1, 3), "host", "b"),
+	}
+	if got := own(t, st); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+	// empty labels are the same as no labels
+	upsert(t, st, withLabels(counter(1, 1)))
+	assertGet(t, st, counter(1, 2))
+}
+
+func testSelect(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st,
+		gauge(1, 1),
+		withLabels(gauge(1, 2), "host", "web1", "dc", "eu"),
+		withLabels(gauge(1, 3), "host", "web2", "dc", "us"),
+		withLabels(counter(1, 4), "host", "web1", "dc", "eu"),
+		withLabels(gauge(2, 5), "host", "web1", "dc", "eu"),
+	)
+	tests := []struct {
+		name     string
+		matchers []string
+		want     []models.Metric
+	}{
+		{
+			name: "without matchers",
+			want: []models.Metric{
+				gauge(1, 1),
+				withLabels(gauge(1, 2), "host", "web1", "dc", "eu"),
+				withLabels(gauge(1, 3), "host", "web2", "dc", "us"),
+			},
+		},
+		{
+			name:     "equal",
+			matchers: []string{"host=web1"},
+			want:     []models.Metric{withLabels(gauge(1, 2), "host", "web1", "dc", "eu")},
+		},
+		{
+			name:     "missing label",
+			matchers: []string{"host="},
+			want:     []models.Metric{gauge(1, 1)},
+		},
+		{
+			name:     "regexp and not equal",
+			matchers: []string{"host=~web.*", "dc!=eu"},
+			want:     []models.Metric{withLabels(gauge(1, 3), "host", "web2", "dc", "us")},
+		},
+		{
+			name:     "nothing matches",
+			matchers: []string{"host!~.*"},
+			want:     []models.Metric{},
+		},
+	}
+	for _, tt := range tests {
+		matchers := make([]models.LabelMatcher, 0, len(tt.matchers))
+		for _, m := range tt.matchers {
+			matchers = append(matchers, matcher(t, m))
+		}
+		got, err := st.Select(context.Background(), models.MetricKindGauge, name(1), matchers...)
+		if err != nil {
+			t.Errorf("Select(%s) error = %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Select(%s) got = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if _, err := st.Select(context.Background(), "preved", name(1)); !errors.Is(err, models.ErrNotSupported) {
+		t.Errorf("Select() error = %v, want %v", err, models.ErrNotSupported)
+	}
+}
+
+func testDeleteLabels(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	upsert(t, st,
+		withLabels(gauge(1, 1), "host", "a"),
+		withLabels(gauge(1, 2), "host", "b"),
+		withLabels(counter(1, 3), "host", "a"),
+		withLabels(counter(1, 4), "host", "b"),
+	)
+	if err := st.ResetCounter(ctx, name(1)); err != nil {
+		t.Fatalf("ResetCounter() error = %v", err)
+	}
+	if err := st.Delete(ctx, models.MetricKindGauge, name(1)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	want := []models.Metric{
+		withLabels(counter(1, 0), "host", "a"),
+		withLabels(counter(1, 0), "host", "b"),
+	}
+	if got := own(t, st); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+}
+
+func testFlushLoadLabels(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	want := []models.Metric{
+		counter(1, 1),
+		withLabels(counter(1, 2), "host", "a", "service", "api"),
+	}
+	upsert(t, st, want...)
+	buf := new(bytes.Buffer)
+	if err := st.Flush(context.Background(), buf); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	src := new(bytes.Buffer)
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.Contains(line, `"id":"`+Prefix) {
+			src.WriteString(line)
+		}
+	}
+	restored := newStorage(t)
+	if err := restored.Load(context.Background(), src); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := own(t, restored); !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() got = %v, want %v", got, want)
+	}
+}
+
+func testDeleteStale(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	upsert(t, st, gauge(1, 1.5), counter(1, 2), withLabels(gauge(1, 2.5), "host", "web1"), gauge(2, 3.5))
+	policy := storage.TTLPolicy{Rules: []storage.TTLRule{{Pattern: name(1), TTL: time.Hour}}}
+	deleted, err := st.DeleteStale(ctx, policy, time.Now())
+	if err != nil || deleted != 0 {
+		t.Errorf("DeleteStale() of fresh metrics got = %d, error = %v, want 0", deleted, err)
+	}
+	deleted, err = st.DeleteStale(ctx, policy, time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatalf("DeleteStale() error = %v", err)
+	}
+	if deleted != 3 {
+		t.Errorf("DeleteStale() got = %d, want %d", deleted, 3)
+	}
+	want := []models.Metric{gauge(2, 3.5)}
+	if got := own(t, st); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+	// deleted counter starts from scratch
+	upsert(t, st, counter(1, 3))
+	assertGet(t, st, counter(1, 3))
+}
+
+func testGetFresh(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	upsert(t, st, gauge(1, 1.5), gauge(2, 2.5))
+	policy := storage.TTLPolicy{Rules: []storage.TTLRule{{Pattern: name(1), TTL: time.Hour}}}
+	tests := []struct {
+		now  time.Time
+		name string
+		want []models.Metric
+	}{
+		{name: "all fresh", now: time.Now(), want: []models.Metric{gauge(1, 1.5), gauge(2, 2.5)}},
+		{name: "one stale", now: time.Now().Add(2 * time.Hour), want: []models.Metric{gauge(2, 2.5)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ownOf(t, st.AllFresh(ctx, policy, tt.now)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AllFresh() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+	// stale metric is kept in storage
+	assertGet(t, st, gauge(1, 1.5))
+}
+
+func testUnitDescription(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	described := gauge(1, 1.5)
+	described.Unit = "bytes"
+	described.Description = "heap size"
+	upsert(t, st, described, gauge(1, 2.5))
+	described.Value = 2.5
+	assertGet(t, st, described)
+	relabelled := counter(2, 1)
+	relabelled.Unit = "requests"
+	upsert(t, st, relabelled)
+	relabelled.Unit = "calls"
+	relabelled.Description = "served calls"
+	if err := st.MassUpsert(context.Background(), []models.Metric{counter(2, 1), relabelled}); err != nil {
+		t.Fatalf("MassUpsert() error = %v", err)
+	}
+	relabelled.Delta = 3
+	assertGet(t, st, relabelled)
+}
+
+func testMetadata(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	before := time.Now().Add(-time.Second)
+	ctx := models.WithSource(context.Background(), "10.0.0.1")
+	if err := st.Upsert(ctx, gauge(1, 1)); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	meta, err := st.GetMetadata(context.Background(), gauge(1, 0))
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+	if meta.Source != "10.0.0.1" || meta.UpdatedAt.Before(before) || meta.Version != 1 {
+		t.Errorf("GetMetadata() got = %v, want source 10.0.0.1 version 1 updated after %v", meta, before)
+	}
+	if err = st.Upsert(context.Background(), gauge(1, 2)); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if meta, err = st.GetMetadata(context.Background(), gauge(1, 0)); err != nil || meta.Source != "" ||
+		meta.Version != 2 {
+		t.Errorf("GetMetadata() got = %v, error = %v, want empty source and version 2", meta, err)
+	}
+	for _, m := range []models.Metric{counter(1, 0), gauge(2, 0), withLabels(gauge(1, 0), "host", "a")} {
+		if _, err = st.GetMetadata(context.Background(), m); !errors.Is(err, models.ErrHTTPNotFound) {
+			t.Errorf("GetMetadata(%v) error = %v, want %v", m, err, models.ErrHTTPNotFound)
+		}
+	}
+}
+
+func testMetadataReopen(t *testing.T, newStorage NewPersistentStorage) {
+	st, reopen := newStorage(t)
+	ctx := models.WithSource(context.Background(), "10.0.0.1")
+	for _, m := range []models.Metric{gauge(1, 1), gauge(1, 2), counter(1, 3)} {
+		if err := st.Upsert(ctx, m); err != nil {
+			t.Fatalf("Upsert() error = %v", err)
+		}
+	}
+	// metrics updated before compaction are restored from snapshot, updated after it from log
+	if c, ok := st.(compacter); ok {
+		if err := c.Compact(); err != nil {
+			t.Fatalf("Compact() error = %v", err)
+		}
+	}
+	if err := st.Upsert(context.Background(), counter(2, 4)); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	keys := []models.Metric{gauge(1, 0), counter(1, 0), counter(2, 0)}
+	want := make([]models.Metadata, len(keys))
+	for i, m := range keys {
+		meta, err := st.GetMetadata(context.Background(), m)
+		if err != nil {
+			t.Fatalf("GetMetadata(%v) error = %v", m, err)
+		}
+		want[i] = meta
+	}
+
+	st = reopen()
+	for i, m := range keys {
+		got, err := st.GetMetadata(context.Background(), m)
+		if err != nil {
+			t.Errorf("GetMetadata(%v) after reopen error = %v", m, err)
+			continue
+		}
+		if !got.UpdatedAt.Equal(want[i].UpdatedAt) || got.Source != want[i].Source || got.Version != want[i].Version {
+			t.Errorf("GetMetadata(%v) after reopen got = %v, want %v", m, got, want[i])
+		}
+	}
+	assertGet(t, st, gauge(1, 2))
+	assertGet(t, st, counter(1, 3))
+	assertGet(t, st, counter(2, 4))
+}
+
+func testUpsertHistogram(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	bounds := []float64{1, 10}
+	upsert(t, st, histogram(1, 0.5, bounds, 1, 0, 0), histogram(1, 25, bounds, 0, 2, 1))
+	assertGet(t, st, histogram(1, 25.5, bounds, 1, 2, 1))
+	err := st.MassUpsert(context.Background(), []models.Metric{
+		histogram(1, 1, bounds, 1, 0, 0),
+		histogram(1, 2, bounds, 0, 1, 0),
+	})
+	if err != nil {
+		t.Fatalf("MassUpsert() error = %v", err)
+	}
+	assertGet(t, st, histogram(1, 28.5, bounds, 2, 3, 1))
+	// other bounds replace buckets
+	upsert(t, st, histogram(1, 4, []float64{5}, 1, 0))
+	assertGet(t, st, histogram(1, 4, []float64{5}, 1, 0))
+	for _, m := range []models.Metric{
+		histogram(1, 1, []float64{5}, 1),
+		histogram(1, 1, []float64{5, 1}, 1, 0, 0),
+		{Kind: models.MetricKindHistogram, Name: name(1)},
+	} {
+		if err = st.Upsert(context.Background(), m); !errors.Is(err, models.ErrInvalidHistogram) {
+			t.Errorf("Upsert(%v) error = %v, want %v", m, err, models.ErrInvalidHistogram)
+		}
+	}
+	assertGet(t, st, histogram(1, 4, []float64{5}, 1, 0))
+}
+
+func testHistogramRoundTrip(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	from := time.Now().Add(-time.Minute)
+	bounds := []float64{0.001, 0.01}
+	upsert(t, st, histogram(1, 0.5, bounds, 1, 1, 1), histogram(1, 0.25, bounds, 0, 0, 1), gauge(1, 1))
+	samples, err := st.GetRange(context.Background(), models.MetricKindHistogram, name(1), from,
+		time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetRange() error = %v", err)
+	}
+	got := make([]models.Sample, len(samples))
+	for i, sample := range samples {
+		got[i] = models.Sample{Delta: sample.Delta, Value: sample.Value}
+	}
+	if want := []models.Sample{{Delta: 3, Value: 0.5}, {Delta: 4, Value: 0.75}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRange() got = %v, want %v", got, want)
+	}
+
+	want := []models.Metric{gauge(1, 1), histogram(1, 0.75, bounds, 1, 1, 2)}
+	if got := own(t, st); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+	buf := new(bytes.Buffer)
+	if err = st.Flush(context.Background(), buf); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	src := new(bytes.Buffer)
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.Contains(line, `"id":"`+Prefix) {
+			src.WriteString(line)
+		}
+	}
+	restored := newStorage(t)
+	if err = restored.Load(context.Background(), bytes.NewReader(src.Bytes())); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := own(t, restored); !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() got = %v, want %v", got, want)
+	}
+}
+
+func testUpsertSummary(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, summary(1, 1, 2, 3), summary(1, -1, 0, 10))
+	assertGet(t, st, summary(1, 1, 2, 3, -1, 0, 10))
+	err := st.MassUpsert(context.Background(), []models.Metric{summary(1, 0.5), summary(1, 0.25)})
+	if err != nil {
+		t.Fatalf("MassUpsert() error = %v", err)
+	}
+	assertGet(t, st, summary(1, 1, 2, 3, -1, 0, 10, 0.5, 0.25))
+	// other accuracy replaces sketch
+	replaced := summary(1)
+	replaced.Summary = &models.Summary{Positive: map[int32]uint64{2: 1}, Accuracy: 0.5, Sum: 4, Min: 4, Max: 4}
+	upsert(t, st, replaced)
+	assertGet(t, st, replaced)
+	for _, m := range []models.Metric{
+		{Kind: models.MetricKindSummary, Name: name(1)},
+		{Kind: models.MetricKindSummary, Name: name(1), Summary: &models.Summary{}},
+		{Kind: models.MetricKindSummary, Name: name(1), Summary: &models.Summary{Accuracy: 0.01, Min: 1}},
+	} {
+		if err = st.Upsert(context.Background(), m); !errors.Is(err, models.ErrInvalidSummary) {
+			t.Errorf("Upsert(%v) error = %v, want %v", m, err, models.ErrInvalidSummary)
+		}
+	}
+	assertGet(t, st, replaced)
+}
+
+func testSummaryRoundTrip(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	from := time.Now().Add(-time.Minute)
+	upsert(t, st, summary(1, 0.5, 0.25), summary(1, -2), gauge(1, 1))
+	samples, err := st.GetRange(context.Background(), models.MetricKindSummary, name(1), from,
+		time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("GetRange() error = %v", err)
+	}
+	got := make([]models.Sample, len(samples))
+	for i, sample := range samples {
+		got[i] = models.Sample{Delta: sample.Delta, Value: sample.Value}
+	}
+	if want := []models.Sample{{Delta: 2, Value: 0.75}, {Delta: 3, Value: -1.25}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetRange() got = %v, want %v", got, want)
+	}
+
+	want := []models.Metric{gauge(1, 1), summary(1, 0.5, 0.25, -2)}
+	if got := own(t, st); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+	buf := new(bytes.Buffer)
+	if err = st.Flush(context.Background(), buf); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	src := new(bytes.Buffer)
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.Contains(line, `"id":"`+Prefix) {
+			src.WriteString(line)
+		}
+	}
+	restored := newStorage(t)
+	if err = restored.Load(context.Background(), bytes.NewReader(src.Bytes())); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := own(t, restored); !reflect.DeepEqual(got, want) {
+		t.Errorf("Load() got = %v, want %v", got, want)
+	}
+}
+
+func testList(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, gauge(1, 1), counter(1, 1), withLabels(gauge(1, 3), "host", "b"),
+		withLabels(gauge(1, 2), "host", "a"), gauge(2, 1), gauge(10, 1), counter(3, 1), summary(2, 1))
+	// names are sorted bytewise, so testSuite10 goes before testSuite2
+	all := []models.Metric{
+		counter(1, 1), gauge(1, 1), withLabels(gauge(1, 2), "host", "a"), withLabels(gauge(1, 3), "host", "b"),
+		gauge(10, 1), gauge(2, 1), summary(2, 1), counter(3, 1),
+	}
+	list := func(kind, prefix, regex, cursor string, limit int) models.ListPage {
+		t.Helper()
+		query, err := models.NewListQuery(kind, prefix, regex, cursor, limit)
+		if err != nil {
+			t.Fatalf("NewListQuery() error = %v", err)
+		}
+		page, err := st.List(context.Background(), query)
+		if err != nil {
+			t.Fatalf("List() error = %v", err)
+		}
+		return page
+	}
+
+	got := make([]models.Metric, 0)
+	pages := 0
+	for cursor := ""; pages == 0 || cursor != ""; pages++ {
+		page := list("", Prefix, "", cursor, 3)
+		got = append(got, page.Metrics...)
+		cursor = page.Next
+	}
+	if pages != 3 || !reflect.DeepEqual(got, all) {
+		t.Errorf("List() got %d pages = %v, want 3 pages = %v", pages, got, all)
+	}
+
+	filters := []struct {
+		name   string
+		kind   string
+		prefix string
+		regex  string
+		want   []models.Metric
+	}{
+		{name: "kind", kind: models.MetricKindGauge, prefix: Prefix, want: []models.Metric{all[1], all[2], all[3],
+			all[4], all[5]}},
+		{name: "prefix", prefix: name(1), want: all[:5]},
+		{name: "regex matches whole name", prefix: Prefix, regex: Prefix + "[23]", want: all[5:]},
+		{name: "nothing", kind: models.MetricKindHistogram, prefix: Prefix},
+	}
+	for _, tt := range filters {
+		page := list(tt.kind, tt.prefix, tt.regex, "", 0)
+		if len(page.Metrics) != len(tt.want) || len(tt.want) > 0 && !reflect.DeepEqual(page.Metrics, tt.want) ||
+			page.Next != "" {
+			t.Errorf("List() by %s got = %v, next = %q, want %v", tt.name, page.Metrics, page.Next, tt.want)
+		}
+	}
+
+	// metrics added before cursor don't shift next page
+	first := list("", Prefix, "", "", 3)
+	upsert(t, st, gauge(0, 1))
+	if got := list("", Prefix, "", first.Next, 1).Metrics; !reflect.DeepEqual(got, all[3:4]) {
+		t.Errorf("List() after cursor got = %v, want %v", got, all[3:4])
+	}
+}
+
+func testAll(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	upsert(t, st, gauge(2, 2.5), gauge(1, 1.5), counter(1, 1))
+	collect := func(all iter.Seq2[models.Metric, error]) []models.Metric {
+		t.Helper()
+		res := make([]models.Metric, 0)
+		for m, err := range all {
+			if err != nil {
+				t.Fatalf("All() error = %v", err)
+			}
+			if strings.HasPrefix(m.Name, Prefix) {
+				res = append(res, m)
+			}
+			// storage may be modified while iterating
+			upsert(t, st, gauge(1, 1.5))
+		}
+		slices.SortFunc(res, models.CompareMetrics)
+		return res
+	}
+	if got, want := collect(st.All(ctx)), own(t, st); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() got = %v, want %v", got, want)
+	}
+	policy := storage.TTLPolicy{Rules: []storage.TTLRule{{Pattern: Prefix + "*", TTL: time.Hour}}}
+	if got := collect(st.AllFresh(ctx, policy, time.Now().Add(2*time.Hour))); len(got) != 0 {
+		t.Errorf("AllFresh() got = %v, want none", got)
+	}
+	count := 0
+	for range st.All(ctx) {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("All() yielded %d metrics after break", count)
+	}
+}
+
+func testUpsertIf(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	ctx := context.Background()
+	version := func(v int64) *int64 {
+		return &v
+	}
+	tests := []struct {
+		metric      models.Metric
+		want        models.Metric
+		cond        models.Condition
+		name        string
+		wantErr     error
+		wantVersion int64
+	}{
+		{
+			name:    "version of not existing gauge",
+			metric:  gauge(1, 1),
+			cond:    models.Condition{Version: version(1)},
+			wantErr: models.ErrConflict,
+		},
+		{
+			name:        "create gauge",
+			metric:      gauge(1, 1),
+			cond:        models.Condition{Version: version(0)},
+			want:        gauge(1, 1),
+			wantVersion: 1,
+		},
+		{
+			name:    "create existing gauge",
+			metric:  gauge(1, 2),
+			cond:    models.Condition{Version: version(0)},
+			want:    gauge(1, 1),
+			wantErr: models.ErrConflict,
+		},
+		{
+			name:        "version matches",
+			metric:      gauge(1, 3),
+			cond:        models.Condition{Version: version(1)},
+			want:        gauge(1, 3),
+			wantVersion: 2,
+		},
+		{
+			name:    "stale version",
+			metric:  gauge(1, 4),
+			cond:    models.Condition{Version: version(1)},
+			want:    gauge(1, 3),
+			wantErr: models.ErrConflict,
+		},
+		{
+			name:    "max with less value",
+			metric:  gauge(1, 2),
+			cond:    models.Condition{Mode: models.UpdateModeMax},
+			want:    gauge(1, 3),
+			wantErr: models.ErrConflict,
+		},
+		{
+			name:    "max with equal value",
+			metric:  gauge(1, 3),
+			cond:    models.Condition{Mode: models.UpdateModeMax},
+			want:    gauge(1, 3),
+			wantErr: models.ErrConflict,
+		},
+		{
+			name:        "max with greater value",
+			metric:      gauge(1, 5),
+			cond:        models.Condition{Mode: models.UpdateModeMax},
+			want:        gauge(1, 5),
+			wantVersion: 3,
+		},
+		{
+			name:        "min with less value and version",
+			metric:      gauge(1, -1),
+			cond:        models.Condition{Version: version(3), Mode: models.UpdateModeMin},
+			want:        gauge(1, -1),
+			wantVersion: 4,
+		},
+		{
+			name:    "min with greater value",
+			metric:  gauge(1, 0),
+			cond:    models.Condition{Mode: models.UpdateModeMin},
+			want:    gauge(1, -1),
+			wantErr: models.ErrConflict,
+		},
+		{
+			name:        "max creates gauge",
+			metric:      gauge(2, -10),
+			cond:        models.Condition{Mode: models.UpdateModeMax},
+			want:        gauge(2, -10),
+			wantVersion: 1,
+		},
+		{
+			name:    "unknown mode",
+			metric:  gauge(1, 10),
+			cond:    models.Condition{Mode: "preved"},
+			want:    gauge(1, -1),
+			wantErr: models.ErrInvalidCondition,
+		},
+		{
+			name:    "counter",
+			metric:  counter(1, 1),
+			cond:    models.Condition{Version: version(0)},
+			wantErr: models.ErrNotSupported,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := st.UpsertIf(ctx, tt.metric, tt.cond)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpsertIf() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.wantVersion {
+				t.Errorf("UpsertIf() version = %d, want %d", got, tt.wantVersion)
+			}
+			if tt.want.Name != "" {
+				assertGet(t, st, tt.want)
+			}
+		})
+	}
+	// unconditional update increments version too
+	upsert(t, st, gauge(1, 7))
+	if got, err := st.UpsertIf(ctx, gauge(1, 8), models.Condition{Version: version(5)}); err != nil || got != 6 {
+		t.Errorf("UpsertIf() after upsert version = %d, error = %v, want 6", got, err)
+	}
+	// gauge with labels has own version
+	labeled := withLabels(gauge(1, 1), "host", "a")
+	if got, err := st.UpsertIf(ctx, labeled, models.Condition{Version: version(0)}); err != nil || got != 1 {
+		t.Errorf("UpsertIf() of labels version = %d, error = %v, want 1", got, err)
+	}
+	if meta, err := st.GetMetadata(ctx, labeled); err != nil || meta.Version != 1 {
+		t.Errorf("GetMetadata() got = %v, error = %v, want version 1", meta, err)
+	}
+}
+
+func testConcurrentUpsertIf(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	upsert(t, st, gauge(1, 0))
+	var wg sync.WaitGroup
+	results := make(chan error, concurrentWriters)
+	for w := 0; w < concurrentWriters; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// all writers expect the same version, so only one of them succeeds
+			version := int64(1)
+			_, err := st.UpsertIf(context.Background(), gauge(1, float64(w)),
+				models.Condition{Version: &version})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+	succeeded := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, models.ErrConflict):
+			t.Errorf("UpsertIf() error = %v, want %v", err, models.ErrConflict)
+		}
+	}
+	if succeeded != 1 {
+		t.Errorf("UpsertIf() succeeded %d times, want once", succeeded)
+	}
+}
+
+func testConcurrentUpsertIfNew(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	var wg sync.WaitGroup
+	results := make(chan error, concurrentWriters)
+	for w := 0; w < concurrentWriters; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// all writers create the same gauge, so storage may only reject concurrent inserts as conflicts
+			_, err := st.UpsertIf(context.Background(), gauge(1, float64(w)),
+				models.Condition{Mode: models.UpdateModeMax})
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+	succeeded := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, models.ErrConflict):
+			t.Errorf("UpsertIf() error = %v, want nil or %v", err, models.ErrConflict)
+		}
+	}
+	if succeeded == 0 {
+		t.Error("UpsertIf() never succeeded")
+	}
+}
+
+func testTenants(t *testing.T, newStorage NewStorage) {
+	st := newStorage(t)
+	team1 := models.WithTenant(context.Background(), "team1")
+	team2 := models.WithTenant(context.Background(), "team2")
+	for _, ctx := range []context.Context{team1, team2} {
+		if err := st.MassUpsert(ctx, []models.Metric{counter(1, 1), gauge(1, 1.5)}); err != nil {
+			t.Fatalf("MassUpsert() error = %v", err)
+		}
+	}
+	if err := st.Upsert(team1, counter(1, 2)); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	upsert(t, st, gauge(2, 2.5))
+
+	// metric with tenant set by caller is written to tenant of context
+	if err := st.Upsert(context.Background(), inTenant(gauge(3, 3.5), "team1")); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if got, want := own(t, st), []models.Metric{gauge(2, 2.5), gauge(3, 3.5)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("All() of default tenant got = %v, want %v", got, want)
+	}
+	ownOf := func(ctx context.Context) []models.Metric {
+		t.Helper()
+		res := make([]models.Metric, 0)
+		for m, err := range st.All(ctx) {
+			if err != nil {
+				t.Fatalf("All() error = %v", err)
+			}
+			if strings.HasPrefix(m.Name, Prefix) {
+				res = append(res, m)
+			}
+		}
+		slices.SortFunc(res, models.CompareMetrics)
+		return res
+	}
+	want := []models.Metric{inTenant(counter(1, 3), "team1"), inTenant(gauge(1, 1.5), "team1")}
+	if got := ownOf(team1); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() of team1 got = %v, want %v", got, want)
+	}
+	got, err := st.Get(team2, models.MetricKindCounter, name(1))
+	if err != nil || !reflect.DeepEqual(got, inTenant(counter(1, 1), "team2")) {
+		t.Errorf("Get() of team2 got = %v, %v, want %v", got, err, inTenant(counter(1, 1), "team2"))
+	}
+	if _, err = st.Get(context.Background(), models.MetricKindCounter, name(1)); !errors.Is(err,
+		models.ErrHTTPNotFound) {
+		t.Errorf("Get() of other tenant metric error = %v, want %v", err, models.ErrHTTPNotFound)
+	}
+	query, err := models.NewListQuery("", Prefix, "", "", 10)
+	if err != nil {
+		t.Fatalf("NewListQuery() error = %v", err)
+	}
+	page, err := st.List(team1, query)
+	if err != nil || !reflect.DeepEqual(page.Metrics, want) {
+		t.Errorf("List() of team1 got = %v, %v, want %v", page.Metrics, err, want)
+	}
+
+	// modifications of one tenant don't touch the other one
+	if err = st.ResetCounter(team2, name(1)); err != nil {
+		t.Fatalf("ResetCounter() error = %v", err)
+	}
+	if err = st.Delete(team1, models.MetricKindGauge, name(1)); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err = st.Delete(context.Background(), models.MetricKindGauge, name(1)); !errors.Is(err,
+		models.ErrHTTPNotFound) {
+		t.Errorf("Delete() of other tenant metric error = %v, want %v", err, models.ErrHTTPNotFound)
+	}
+	want = []models.Metric{inTenant(counter(1, 0), "team2"), inTenant(gauge(1, 1.5), "team2")}
+	if got := ownOf(team2); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() of team2 got = %v, want %v", got, want)
+	}
+	if deleted, err := st.DeleteByPrefix(team2, Prefix); err != nil || deleted != 2 {
+		t.Errorf("DeleteByPrefix() of team2 got = %d, %v, want 2", deleted, err)
+	}
+	want = []models.Metric{inTenant(counter(1, 3), "team1")}
+	if got := ownOf(team1); !reflect.DeepEqual(got, want) {
+		t.Errorf("All() of team1 got = %v, want %v", got, want)
+	}
+
+	// flush keeps tenants of metrics
+	buf := new(bytes.Buffer)
+	if err = st.Flush(context.Background(), buf); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	src := new(bytes.Buffer)
+	for _, line := range strings.SplitAfter(buf.String(), "\n") {
+		if strings.Contains(line, `"id":"`+Prefix) {
+			src.WriteString(line)
+		}
+	}
+	restored := newStorage(t)
+	if err = restored.Load(context.Background(), src); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	got, err = restored.Get(team1, models.MetricKindCounter, name(1))
+	if err != nil || !reflect.DeepEqual(got, inTenant(counter(1, 3), "team1")) {
+		t.Errorf("Get() of restored team1 got = %v, %v, want %v", got, err, inTenant(counter(1, 3), "team1"))
+	}
+	if got, want := own(t, restored), []models.Metric{gauge(2, 2.5), gauge(3, 3.5)}; !reflect.DeepEqual(got, want) {
+		t.Errorf("All() of restored default tenant got = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,53 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sejo412/ya-metrics/internal/config"
+	"github.com/sejo412/ya-metrics/internal/storage"
+	"github.com/sejo412/ya-metrics/internal/storage/storagetest"
+)
+
+func TestMemoryStorage_Suite(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) config.Storage {
+		return storage.NewMemoryStorage()
+	})
+}
+
+func TestFileStorage_Suite(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) config.Storage {
+		s := storage.NewFileStorage()
+		opts := storage.Options{
+			Scheme: storage.FileScheme,
+			Path:   t.TempDir(),
+		}
+		if err := s.Open(context.Background(), opts); err != nil {
+			t.Fatalf("Open() error = %v", err)
+		}
+		t.Cleanup(s.Close)
+		return s
+	})
+}
+
+func TestFileStorage_PersistentSuite(t *testing.T) {
+	storagetest.RunPersistent(t, func(t *testing.T) (config.Storage, func() config.Storage) {
+		opts := storage.Options{
+			Scheme: storage.FileScheme,
+			Path:   t.TempDir(),
+		}
+		open := func() *storage.FileStorage {
+			s := storage.NewFileStorage()
+			if err := s.Open(context.Background(), opts); err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			t.Cleanup(s.Close)
+			return s
+		}
+		s := open()
+		return s, func() config.Storage {
+			s.Close()
+			return open()
+		}
+	})
+}
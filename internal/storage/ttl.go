@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"fmt"
+	"path"
+	"time"
+)
+
+// TTLRule sets TTL for metrics with name matching pattern.
+type TTLRule struct {
+	// Pattern - metric name pattern in path.Match syntax, for example "agent1_*".
+	Pattern string
+	// TTL - how long metric lives without updates, 0 for forever.
+	TTL time.Duration
+}
+
+// TTLPolicy defines how long metrics live without updates. Zero policy keeps metrics forever.
+type TTLPolicy struct {
+	// Rules - TTL for metric name patterns, first matching rule wins.
+	Rules []TTLRule
+	// TTL - TTL for metrics not matching any rule, 0 for forever.
+	TTL time.Duration
+}
+
+// NewTTLRule returns rule after checking pattern.
+func NewTTLRule(pattern string, ttl time.Duration) (TTLRule, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return TTLRule{}, fmt.Errorf("invalid ttl pattern %q: %w", pattern, err)
+	}
+	if ttl < 0 {
+		return TTLRule{}, fmt.Errorf("negative ttl for pattern %q", pattern)
+	}
+	return TTLRule{Pattern: pattern, TTL: ttl}, nil
+}
+
+// TTLOf returns TTL of metric name, 0 for forever.
+func (p TTLPolicy) TTLOf(name string) time.Duration {
+	for _, rule := range p.Rules {
+		// patterns are checked in NewTTLRule
+		if ok, _ := path.Match(rule.Pattern, name); ok {
+			return rule.TTL
+		}
+	}
+	return p.TTL
+}
+
+// IsStale returns true if metric updated at updated is expired at now.
+func (p TTLPolicy) IsStale(name string, updated, now time.Time) bool {
+	ttl := p.TTLOf(name)
+	return ttl > 0 && now.Sub(updated) > ttl
+}
+
+// MinTTL returns the shortest TTL of policy, 0 if metrics live forever.
+// Metrics updated within MinTTL are never stale.
+func (p TTLPolicy) MinTTL() time.Duration {
+	ttl := p.TTL
+	for _, rule := range p.Rules {
+		if rule.TTL > 0 && (ttl == 0 || rule.TTL < ttl) {
+			ttl = rule.TTL
+		}
+	}
+	return ttl
+}
+
+// Enabled returns true if some metrics may expire.
+func (p TTLPolicy) Enabled() bool {
+	return p.MinTTL() > 0
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTTLPolicy_IsStale(t *testing.T) {
+	now := time.Now()
+	policy := TTLPolicy{
+		Rules: []TTLRule{
+			{Pattern: "agent1_*", TTL: time.Minute},
+			{Pattern: "agent*", TTL: 0},
+		},
+		TTL: time.Hour,
+	}
+	tests := []struct {
+		updated time.Time
+		name    string
+		metric  string
+		want    bool
+	}{
+		{name: "first matching rule", metric: "agent1_Alloc", updated: now.Add(-2 * time.Minute), want: true},
+		{name: "fresh by rule", metric: "agent1_Alloc", updated: now.Add(-30 * time.Second), want: false},
+		{name: "forever by rule", metric: "agent2_Alloc", updated: now.Add(-48 * time.Hour), want: false},
+		{name: "default ttl", metric: "Alloc", updated: now.Add(-2 * time.Hour), want: true},
+		{name: "fresh by default ttl", metric: "Alloc", updated: now.Add(-time.Minute), want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.IsStale(tt.metric, tt.updated, now); got != tt.want {
+				t.Errorf("IsStale() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTTLPolicy_MinTTL(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy TTLPolicy
+		want   time.Duration
+	}{
+		{name: "zero policy", policy: TTLPolicy{}, want: 0},
+		{name: "default only", policy: TTLPolicy{TTL: time.Hour}, want: time.Hour},
+		{
+			name:   "rule shorter than default",
+			policy: TTLPolicy{TTL: time.Hour, Rules: []TTLRule{{Pattern: "a*", TTL: time.Minute}}},
+			want:   time.Minute,
+		},
+		{
+			name:   "rules without default",
+			policy: TTLPolicy{Rules: []TTLRule{{Pattern: "a*", TTL: 0}, {Pattern: "b*", TTL: time.Second}}},
+			want:   time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.MinTTL(); got != tt.want {
+				t.Errorf("MinTTL() = %v, want %v", got, tt.want)
+			}
+			if got := tt.policy.Enabled(); got != (tt.want > 0) {
+				t.Errorf("Enabled() = %v, want %v", got, tt.want > 0)
+			}
+		})
+	}
+}
+
+func TestNewTTLRule(t *testing.T) {
+	if _, err := NewTTLRule("agent[", time.Minute); err == nil {
+		t.Errorf("NewTTLRule() with bad pattern error = nil")
+	}
+	if _, err := NewTTLRule("agent*", -time.Minute); err == nil {
+		t.Errorf("NewTTLRule() with negative ttl error = nil")
+	}
+	if _, err := NewTTLRule("agent*", time.Minute); err != nil {
+		t.Errorf("NewTTLRule() error = %v", err)
+	}
+}
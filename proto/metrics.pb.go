@@ -10,6 +10,7 @@ import (
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
 	emptypb "google.golang.org/protobuf/types/known/emptypb"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -25,9 +26,11 @@ const (
 type MType int32
 
 const (
-	MType_UNKNOWN MType = 0
-	MType_COUNTER MType = 1
-	MType_GAUGE   MType = 2
+	MType_UNKNOWN   MType = 0
+	MType_COUNTER   MType = 1
+	MType_GAUGE     MType = 2
+	MType_HISTOGRAM MType = 3
+	MType_SUMMARY   MType = 4
 )
 
 // Enum value maps for MType.
@@ -36,11 +39,15 @@ var (
 		0: "UNKNOWN",
 		1: "COUNTER",
 		2: "GAUGE",
+		3: "HISTOGRAM",
+		4: "SUMMARY",
 	}
 	MType_value = map[string]int32{
-		"UNKNOWN": 0,
-		"COUNTER": 1,
-		"GAUGE":   2,
+		"UNKNOWN":   0,
+		"COUNTER":   1,
+		"GAUGE":     2,
+		"HISTOGRAM": 3,
+		"SUMMARY":   4,
 	}
 )
 
@@ -71,19 +78,234 @@ func (MType) EnumDescriptor() ([]byte, []int) {
 	return file_proto_metrics_proto_rawDescGZIP(), []int{0}
 }
 
+type UpdateMode int32
+
+const (
+	UpdateMode_SET UpdateMode = 0
+	UpdateMode_MAX UpdateMode = 1
+	UpdateMode_MIN UpdateMode = 2
+)
+
+// Enum value maps for UpdateMode.
+var (
+	UpdateMode_name = map[int32]string{
+		0: "SET",
+		1: "MAX",
+		2: "MIN",
+	}
+	UpdateMode_value = map[string]int32{
+		"SET": 0,
+		"MAX": 1,
+		"MIN": 2,
+	}
+)
+
+func (x UpdateMode) Enum() *UpdateMode {
+	p := new(UpdateMode)
+	*p = x
+	return p
+}
+
+func (x UpdateMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (UpdateMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_proto_metrics_proto_enumTypes[1].Descriptor()
+}
+
+func (UpdateMode) Type() protoreflect.EnumType {
+	return &file_proto_metrics_proto_enumTypes[1]
+}
+
+func (x UpdateMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use UpdateMode.Descriptor instead.
+func (UpdateMode) EnumDescriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{1}
+}
+
+type Histogram struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Bounds        []float64              `protobuf:"fixed64,1,rep,packed,name=bounds" json:"bounds,omitempty"`
+	Counts        []uint64               `protobuf:"varint,2,rep,packed,name=counts" json:"counts,omitempty"`
+	Sum           *float64               `protobuf:"fixed64,3,opt,name=sum" json:"sum,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Histogram) Reset() {
+	*x = Histogram{}
+	mi := &file_proto_metrics_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Histogram) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Histogram) ProtoMessage() {}
+
+func (x *Histogram) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Histogram.ProtoReflect.Descriptor instead.
+func (*Histogram) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Histogram) GetBounds() []float64 {
+	if x != nil {
+		return x.Bounds
+	}
+	return nil
+}
+
+func (x *Histogram) GetCounts() []uint64 {
+	if x != nil {
+		return x.Counts
+	}
+	return nil
+}
+
+func (x *Histogram) GetSum() float64 {
+	if x != nil && x.Sum != nil {
+		return *x.Sum
+	}
+	return 0
+}
+
+type Summary struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Accuracy *float64               `protobuf:"fixed64,1,opt,name=accuracy" json:"accuracy,omitempty"`
+	Positive map[int32]uint64       `protobuf:"bytes,2,rep,name=positive" json:"positive,omitempty" protobuf_key:"zigzag32,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Negative map[int32]uint64       `protobuf:"bytes,3,rep,name=negative" json:"negative,omitempty" protobuf_key:"zigzag32,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	Zero     *uint64                `protobuf:"varint,4,opt,name=zero" json:"zero,omitempty"`
+	Sum      *float64               `protobuf:"fixed64,5,opt,name=sum" json:"sum,omitempty"`
+	Min      *float64               `protobuf:"fixed64,6,opt,name=min" json:"min,omitempty"`
+	Max      *float64               `protobuf:"fixed64,7,opt,name=max" json:"max,omitempty"`
+	// quantiles estimated by sketch, ignored in requests
+	Quantiles     map[string]float64 `protobuf:"bytes,8,rep,name=quantiles" json:"quantiles,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"fixed64,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_proto_metrics_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Summary) GetAccuracy() float64 {
+	if x != nil && x.Accuracy != nil {
+		return *x.Accuracy
+	}
+	return 0
+}
+
+func (x *Summary) GetPositive() map[int32]uint64 {
+	if x != nil {
+		return x.Positive
+	}
+	return nil
+}
+
+func (x *Summary) GetNegative() map[int32]uint64 {
+	if x != nil {
+		return x.Negative
+	}
+	return nil
+}
+
+func (x *Summary) GetZero() uint64 {
+	if x != nil && x.Zero != nil {
+		return *x.Zero
+	}
+	return 0
+}
+
+func (x *Summary) GetSum() float64 {
+	if x != nil && x.Sum != nil {
+		return *x.Sum
+	}
+	return 0
+}
+
+func (x *Summary) GetMin() float64 {
+	if x != nil && x.Min != nil {
+		return *x.Min
+	}
+	return 0
+}
+
+func (x *Summary) GetMax() float64 {
+	if x != nil && x.Max != nil {
+		return *x.Max
+	}
+	return 0
+}
+
+func (x *Summary) GetQuantiles() map[string]float64 {
+	if x != nil {
+		return x.Quantiles
+	}
+	return nil
+}
+
 type Metric struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            *string                `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 	Type          *MType                 `protobuf:"varint,2,opt,name=type,enum=metrics.MType" json:"type,omitempty"`
 	Delta         *int64                 `protobuf:"varint,3,opt,name=delta" json:"delta,omitempty"`
 	Value         *float64               `protobuf:"fixed64,4,opt,name=value" json:"value,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,5,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Unit          *string                `protobuf:"bytes,6,opt,name=unit" json:"unit,omitempty"`
+	Description   *string                `protobuf:"bytes,7,opt,name=description" json:"description,omitempty"`
+	Histogram     *Histogram             `protobuf:"bytes,8,opt,name=histogram" json:"histogram,omitempty"`
+	Summary       *Summary               `protobuf:"bytes,9,opt,name=summary" json:"summary,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *Metric) Reset() {
 	*x = Metric{}
-	mi := &file_proto_metrics_proto_msgTypes[0]
+	mi := &file_proto_metrics_proto_msgTypes[2]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -95,7 +317,7 @@ func (x *Metric) String() string {
 func (*Metric) ProtoMessage() {}
 
 func (x *Metric) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[0]
+	mi := &file_proto_metrics_proto_msgTypes[2]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -108,7 +330,7 @@ func (x *Metric) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Metric.ProtoReflect.Descriptor instead.
 func (*Metric) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{0}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{2}
 }
 
 func (x *Metric) GetId() string {
@@ -139,6 +361,101 @@ func (x *Metric) GetValue() float64 {
 	return 0
 }
 
+func (x *Metric) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+func (x *Metric) GetUnit() string {
+	if x != nil && x.Unit != nil {
+		return *x.Unit
+	}
+	return ""
+}
+
+func (x *Metric) GetDescription() string {
+	if x != nil && x.Description != nil {
+		return *x.Description
+	}
+	return ""
+}
+
+func (x *Metric) GetHistogram() *Histogram {
+	if x != nil {
+		return x.Histogram
+	}
+	return nil
+}
+
+func (x *Metric) GetSummary() *Summary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+type MetricMetadata struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=updated_at,json=updatedAt" json:"updated_at,omitempty"`
+	Source        *string                `protobuf:"bytes,2,opt,name=source" json:"source,omitempty"`
+	Version       *int64                 `protobuf:"varint,3,opt,name=version" json:"version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MetricMetadata) Reset() {
+	*x = MetricMetadata{}
+	mi := &file_proto_metrics_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MetricMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MetricMetadata) ProtoMessage() {}
+
+func (x *MetricMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MetricMetadata.ProtoReflect.Descriptor instead.
+func (*MetricMetadata) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *MetricMetadata) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+func (x *MetricMetadata) GetSource() string {
+	if x != nil && x.Source != nil {
+		return *x.Source
+	}
+	return ""
+}
+
+func (x *MetricMetadata) GetVersion() int64 {
+	if x != nil && x.Version != nil {
+		return *x.Version
+	}
+	return 0
+}
+
 type SendMetricsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Metrics       []*Metric              `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
@@ -148,7 +465,7 @@ type SendMetricsRequest struct {
 
 func (x *SendMetricsRequest) Reset() {
 	*x = SendMetricsRequest{}
-	mi := &file_proto_metrics_proto_msgTypes[1]
+	mi := &file_proto_metrics_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -160,7 +477,7 @@ func (x *SendMetricsRequest) String() string {
 func (*SendMetricsRequest) ProtoMessage() {}
 
 func (x *SendMetricsRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[1]
+	mi := &file_proto_metrics_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -173,7 +490,7 @@ func (x *SendMetricsRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SendMetricsRequest.ProtoReflect.Descriptor instead.
 func (*SendMetricsRequest) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{1}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *SendMetricsRequest) GetMetrics() []*Metric {
@@ -192,7 +509,7 @@ type SendMetricsResponse struct {
 
 func (x *SendMetricsResponse) Reset() {
 	*x = SendMetricsResponse{}
-	mi := &file_proto_metrics_proto_msgTypes[2]
+	mi := &file_proto_metrics_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -204,7 +521,7 @@ func (x *SendMetricsResponse) String() string {
 func (*SendMetricsResponse) ProtoMessage() {}
 
 func (x *SendMetricsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[2]
+	mi := &file_proto_metrics_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -217,7 +534,7 @@ func (x *SendMetricsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SendMetricsResponse.ProtoReflect.Descriptor instead.
 func (*SendMetricsResponse) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{2}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *SendMetricsResponse) GetError() string {
@@ -231,13 +548,14 @@ type GetMetricRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            *string                `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 	Kind          *MType                 `protobuf:"varint,2,opt,name=kind,enum=metrics.MType" json:"kind,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,3,rep,name=labels" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetMetricRequest) Reset() {
 	*x = GetMetricRequest{}
-	mi := &file_proto_metrics_proto_msgTypes[3]
+	mi := &file_proto_metrics_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -249,7 +567,7 @@ func (x *GetMetricRequest) String() string {
 func (*GetMetricRequest) ProtoMessage() {}
 
 func (x *GetMetricRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[3]
+	mi := &file_proto_metrics_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -262,7 +580,7 @@ func (x *GetMetricRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMetricRequest.ProtoReflect.Descriptor instead.
 func (*GetMetricRequest) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{3}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *GetMetricRequest) GetId() string {
@@ -279,17 +597,25 @@ func (x *GetMetricRequest) GetKind() MType {
 	return MType_UNKNOWN
 }
 
+func (x *GetMetricRequest) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
 type GetMetricResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Metric        *Metric                `protobuf:"bytes,1,opt,name=metric" json:"metric,omitempty"`
 	Error         *string                `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	Metadata      *MetricMetadata        `protobuf:"bytes,3,opt,name=metadata" json:"metadata,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *GetMetricResponse) Reset() {
 	*x = GetMetricResponse{}
-	mi := &file_proto_metrics_proto_msgTypes[4]
+	mi := &file_proto_metrics_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -301,7 +627,7 @@ func (x *GetMetricResponse) String() string {
 func (*GetMetricResponse) ProtoMessage() {}
 
 func (x *GetMetricResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[4]
+	mi := &file_proto_metrics_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -314,7 +640,7 @@ func (x *GetMetricResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMetricResponse.ProtoReflect.Descriptor instead.
 func (*GetMetricResponse) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{4}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *GetMetricResponse) GetMetric() *Metric {
@@ -331,6 +657,13 @@ func (x *GetMetricResponse) GetError() string {
 	return ""
 }
 
+func (x *GetMetricResponse) GetMetadata() *MetricMetadata {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
 type GetMetricsResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Metrics       []*Metric              `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
@@ -341,7 +674,7 @@ type GetMetricsResponse struct {
 
 func (x *GetMetricsResponse) Reset() {
 	*x = GetMetricsResponse{}
-	mi := &file_proto_metrics_proto_msgTypes[5]
+	mi := &file_proto_metrics_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -353,7 +686,7 @@ func (x *GetMetricsResponse) String() string {
 func (*GetMetricsResponse) ProtoMessage() {}
 
 func (x *GetMetricsResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[5]
+	mi := &file_proto_metrics_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -366,7 +699,7 @@ func (x *GetMetricsResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use GetMetricsResponse.ProtoReflect.Descriptor instead.
 func (*GetMetricsResponse) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{5}
+	return file_proto_metrics_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *GetMetricsResponse) GetMetrics() []*Metric {
@@ -383,28 +716,33 @@ func (x *GetMetricsResponse) GetError() string {
 	return ""
 }
 
-type PingStorageResponse struct {
+// kind UNKNOWN means any kind, empty cursor means first page
+type QueryMetricsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Ok            *bool                  `protobuf:"varint,1,opt,name=ok" json:"ok,omitempty"`
+	Kind          *MType                 `protobuf:"varint,1,opt,name=kind,enum=metrics.MType" json:"kind,omitempty"`
+	Prefix        *string                `protobuf:"bytes,2,opt,name=prefix" json:"prefix,omitempty"`
+	Regex         *string                `protobuf:"bytes,3,opt,name=regex" json:"regex,omitempty"`
+	Limit         *int32                 `protobuf:"varint,4,opt,name=limit" json:"limit,omitempty"`
+	Cursor        *string                `protobuf:"bytes,5,opt,name=cursor" json:"cursor,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *PingStorageResponse) Reset() {
-	*x = PingStorageResponse{}
-	mi := &file_proto_metrics_proto_msgTypes[6]
+func (x *QueryMetricsRequest) Reset() {
+	*x = QueryMetricsRequest{}
+	mi := &file_proto_metrics_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *PingStorageResponse) String() string {
+func (x *QueryMetricsRequest) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*PingStorageResponse) ProtoMessage() {}
+func (*QueryMetricsRequest) ProtoMessage() {}
 
-func (x *PingStorageResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_proto_metrics_proto_msgTypes[6]
+func (x *QueryMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -415,52 +753,710 @@ func (x *PingStorageResponse) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use PingStorageResponse.ProtoReflect.Descriptor instead.
-func (*PingStorageResponse) Descriptor() ([]byte, []int) {
-	return file_proto_metrics_proto_rawDescGZIP(), []int{6}
+// Deprecated: Use QueryMetricsRequest.ProtoReflect.Descriptor instead.
+func (*QueryMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{9}
 }
 
-func (x *PingStorageResponse) GetOk() bool {
-	if x != nil && x.Ok != nil {
-		return *x.Ok
+func (x *QueryMetricsRequest) GetKind() MType {
+	if x != nil && x.Kind != nil {
+		return *x.Kind
 	}
-	return false
+	return MType_UNKNOWN
 }
 
-var File_proto_metrics_proto protoreflect.FileDescriptor
-
-const file_proto_metrics_proto_rawDesc = "" +
+func (x *QueryMetricsRequest) GetPrefix() string {
+	if x != nil && x.Prefix != nil {
+		return *x.Prefix
+	}
+	return ""
+}
+
+func (x *QueryMetricsRequest) GetRegex() string {
+	if x != nil && x.Regex != nil {
+		return *x.Regex
+	}
+	return ""
+}
+
+func (x *QueryMetricsRequest) GetLimit() int32 {
+	if x != nil && x.Limit != nil {
+		return *x.Limit
+	}
+	return 0
+}
+
+func (x *QueryMetricsRequest) GetCursor() string {
+	if x != nil && x.Cursor != nil {
+		return *x.Cursor
+	}
+	return ""
+}
+
+type QueryMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metrics       []*Metric              `protobuf:"bytes,1,rep,name=metrics" json:"metrics,omitempty"`
+	NextCursor    *string                `protobuf:"bytes,2,opt,name=next_cursor,json=nextCursor" json:"next_cursor,omitempty"`
+	Error         *string                `protobuf:"bytes,3,opt,name=error" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *QueryMetricsResponse) Reset() {
+	*x = QueryMetricsResponse{}
+	mi := &file_proto_metrics_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *QueryMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*QueryMetricsResponse) ProtoMessage() {}
+
+func (x *QueryMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use QueryMetricsResponse.ProtoReflect.Descriptor instead.
+func (*QueryMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *QueryMetricsResponse) GetMetrics() []*Metric {
+	if x != nil {
+		return x.Metrics
+	}
+	return nil
+}
+
+func (x *QueryMetricsResponse) GetNextCursor() string {
+	if x != nil && x.NextCursor != nil {
+		return *x.NextCursor
+	}
+	return ""
+}
+
+func (x *QueryMetricsResponse) GetError() string {
+	if x != nil && x.Error != nil {
+		return *x.Error
+	}
+	return ""
+}
+
+// one metric per message, metrics go in unspecified order
+type ListMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metric        *Metric                `protobuf:"bytes,1,opt,name=metric" json:"metric,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListMetricsResponse) Reset() {
+	*x = ListMetricsResponse{}
+	mi := &file_proto_metrics_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListMetricsResponse) ProtoMessage() {}
+
+func (x *ListMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListMetricsResponse.ProtoReflect.Descriptor instead.
+func (*ListMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ListMetricsResponse) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+// gauge is updated only if stored one has if_version (0 if it doesn't exist) and satisfies mode
+type UpdateMetricRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Metric        *Metric                `protobuf:"bytes,1,opt,name=metric" json:"metric,omitempty"`
+	IfVersion     *int64                 `protobuf:"varint,2,opt,name=if_version,json=ifVersion" json:"if_version,omitempty"`
+	Mode          *UpdateMode            `protobuf:"varint,3,opt,name=mode,enum=metrics.UpdateMode" json:"mode,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateMetricRequest) Reset() {
+	*x = UpdateMetricRequest{}
+	mi := &file_proto_metrics_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateMetricRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateMetricRequest) ProtoMessage() {}
+
+func (x *UpdateMetricRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateMetricRequest.ProtoReflect.Descriptor instead.
+func (*UpdateMetricRequest) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *UpdateMetricRequest) GetMetric() *Metric {
+	if x != nil {
+		return x.Metric
+	}
+	return nil
+}
+
+func (x *UpdateMetricRequest) GetIfVersion() int64 {
+	if x != nil && x.IfVersion != nil {
+		return *x.IfVersion
+	}
+	return 0
+}
+
+func (x *UpdateMetricRequest) GetMode() UpdateMode {
+	if x != nil && x.Mode != nil {
+		return *x.Mode
+	}
+	return UpdateMode_SET
+}
+
+type UpdateMetricResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Version       *int64                 `protobuf:"varint,1,opt,name=version" json:"version,omitempty"`
+	Error         *string                `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateMetricResponse) Reset() {
+	*x = UpdateMetricResponse{}
+	mi := &file_proto_metrics_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateMetricResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateMetricResponse) ProtoMessage() {}
+
+func (x *UpdateMetricResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateMetricResponse.ProtoReflect.Descriptor instead.
+func (*UpdateMetricResponse) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *UpdateMetricResponse) GetVersion() int64 {
+	if x != nil && x.Version != nil {
+		return *x.Version
+	}
+	return 0
+}
+
+func (x *UpdateMetricResponse) GetError() string {
+	if x != nil && x.Error != nil {
+		return *x.Error
+	}
+	return ""
+}
+
+type DeleteMetricRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            *string                `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	Kind          *MType                 `protobuf:"varint,2,opt,name=kind,enum=metrics.MType" json:"kind,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMetricRequest) Reset() {
+	*x = DeleteMetricRequest{}
+	mi := &file_proto_metrics_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMetricRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMetricRequest) ProtoMessage() {}
+
+func (x *DeleteMetricRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMetricRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMetricRequest) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *DeleteMetricRequest) GetId() string {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return ""
+}
+
+func (x *DeleteMetricRequest) GetKind() MType {
+	if x != nil && x.Kind != nil {
+		return *x.Kind
+	}
+	return MType_UNKNOWN
+}
+
+type DeleteMetricResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Error         *string                `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMetricResponse) Reset() {
+	*x = DeleteMetricResponse{}
+	mi := &file_proto_metrics_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMetricResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMetricResponse) ProtoMessage() {}
+
+func (x *DeleteMetricResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMetricResponse.ProtoReflect.Descriptor instead.
+func (*DeleteMetricResponse) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *DeleteMetricResponse) GetError() string {
+	if x != nil && x.Error != nil {
+		return *x.Error
+	}
+	return ""
+}
+
+type DeleteMetricsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prefix        *string                `protobuf:"bytes,1,opt,name=prefix" json:"prefix,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMetricsRequest) Reset() {
+	*x = DeleteMetricsRequest{}
+	mi := &file_proto_metrics_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMetricsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMetricsRequest) ProtoMessage() {}
+
+func (x *DeleteMetricsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMetricsRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMetricsRequest) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DeleteMetricsRequest) GetPrefix() string {
+	if x != nil && x.Prefix != nil {
+		return *x.Prefix
+	}
+	return ""
+}
+
+type DeleteMetricsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Deleted       *int64                 `protobuf:"varint,1,opt,name=deleted" json:"deleted,omitempty"`
+	Error         *string                `protobuf:"bytes,2,opt,name=error" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMetricsResponse) Reset() {
+	*x = DeleteMetricsResponse{}
+	mi := &file_proto_metrics_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMetricsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMetricsResponse) ProtoMessage() {}
+
+func (x *DeleteMetricsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMetricsResponse.ProtoReflect.Descriptor instead.
+func (*DeleteMetricsResponse) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *DeleteMetricsResponse) GetDeleted() int64 {
+	if x != nil && x.Deleted != nil {
+		return *x.Deleted
+	}
+	return 0
+}
+
+func (x *DeleteMetricsResponse) GetError() string {
+	if x != nil && x.Error != nil {
+		return *x.Error
+	}
+	return ""
+}
+
+type ResetCounterRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            *string                `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetCounterRequest) Reset() {
+	*x = ResetCounterRequest{}
+	mi := &file_proto_metrics_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetCounterRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetCounterRequest) ProtoMessage() {}
+
+func (x *ResetCounterRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetCounterRequest.ProtoReflect.Descriptor instead.
+func (*ResetCounterRequest) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *ResetCounterRequest) GetId() string {
+	if x != nil && x.Id != nil {
+		return *x.Id
+	}
+	return ""
+}
+
+type ResetCounterResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Error         *string                `protobuf:"bytes,1,opt,name=error" json:"error,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResetCounterResponse) Reset() {
+	*x = ResetCounterResponse{}
+	mi := &file_proto_metrics_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResetCounterResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResetCounterResponse) ProtoMessage() {}
+
+func (x *ResetCounterResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResetCounterResponse.ProtoReflect.Descriptor instead.
+func (*ResetCounterResponse) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *ResetCounterResponse) GetError() string {
+	if x != nil && x.Error != nil {
+		return *x.Error
+	}
+	return ""
+}
+
+type PingStorageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Ok            *bool                  `protobuf:"varint,1,opt,name=ok" json:"ok,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PingStorageResponse) Reset() {
+	*x = PingStorageResponse{}
+	mi := &file_proto_metrics_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PingStorageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PingStorageResponse) ProtoMessage() {}
+
+func (x *PingStorageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_metrics_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PingStorageResponse.ProtoReflect.Descriptor instead.
+func (*PingStorageResponse) Descriptor() ([]byte, []int) {
+	return file_proto_metrics_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *PingStorageResponse) GetOk() bool {
+	if x != nil && x.Ok != nil {
+		return *x.Ok
+	}
+	return false
+}
+
+var File_proto_metrics_proto protoreflect.FileDescriptor
+
+const file_proto_metrics_proto_rawDesc = "" +
 	"\n" +
-	"\x13proto/metrics.proto\x12\ametrics\x1a\x1bgoogle/protobuf/empty.proto\"h\n" +
+	"\x13proto/metrics.proto\x12\ametrics\x1a\x1bgoogle/protobuf/empty.proto\x1a\x1fgoogle/protobuf/timestamp.proto\"M\n" +
+	"\tHistogram\x12\x16\n" +
+	"\x06bounds\x18\x01 \x03(\x01R\x06bounds\x12\x16\n" +
+	"\x06counts\x18\x02 \x03(\x04R\x06counts\x12\x10\n" +
+	"\x03sum\x18\x03 \x01(\x01R\x03sum\"\xde\x03\n" +
+	"\aSummary\x12\x1a\n" +
+	"\baccuracy\x18\x01 \x01(\x01R\baccuracy\x12:\n" +
+	"\bpositive\x18\x02 \x03(\v2\x1e.metrics.Summary.PositiveEntryR\bpositive\x12:\n" +
+	"\bnegative\x18\x03 \x03(\v2\x1e.metrics.Summary.NegativeEntryR\bnegative\x12\x12\n" +
+	"\x04zero\x18\x04 \x01(\x04R\x04zero\x12\x10\n" +
+	"\x03sum\x18\x05 \x01(\x01R\x03sum\x12\x10\n" +
+	"\x03min\x18\x06 \x01(\x01R\x03min\x12\x10\n" +
+	"\x03max\x18\a \x01(\x01R\x03max\x12=\n" +
+	"\tquantiles\x18\b \x03(\v2\x1f.metrics.Summary.QuantilesEntryR\tquantiles\x1a;\n" +
+	"\rPositiveEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x11R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a;\n" +
+	"\rNegativeEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\x11R\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\x1a<\n" +
+	"\x0eQuantilesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x01R\x05value:\x028\x01\"\xec\x02\n" +
 	"\x06Metric\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\"\n" +
 	"\x04type\x18\x02 \x01(\x0e2\x0e.metrics.MTypeR\x04type\x12\x14\n" +
 	"\x05delta\x18\x03 \x01(\x03R\x05delta\x12\x14\n" +
-	"\x05value\x18\x04 \x01(\x01R\x05value\"?\n" +
+	"\x05value\x18\x04 \x01(\x01R\x05value\x123\n" +
+	"\x06labels\x18\x05 \x03(\v2\x1b.metrics.Metric.LabelsEntryR\x06labels\x12\x12\n" +
+	"\x04unit\x18\x06 \x01(\tR\x04unit\x12 \n" +
+	"\vdescription\x18\a \x01(\tR\vdescription\x120\n" +
+	"\thistogram\x18\b \x01(\v2\x12.metrics.HistogramR\thistogram\x12*\n" +
+	"\asummary\x18\t \x01(\v2\x10.metrics.SummaryR\asummary\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"}\n" +
+	"\x0eMetricMetadata\x129\n" +
+	"\n" +
+	"updated_at\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\x12\x16\n" +
+	"\x06source\x18\x02 \x01(\tR\x06source\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x03R\aversion\"?\n" +
 	"\x12SendMetricsRequest\x12)\n" +
 	"\ametrics\x18\x01 \x03(\v2\x0f.metrics.MetricR\ametrics\"+\n" +
 	"\x13SendMetricsResponse\x12\x14\n" +
-	"\x05error\x18\x01 \x01(\tR\x05error\"F\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"\xc0\x01\n" +
 	"\x10GetMetricRequest\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\"\n" +
-	"\x04kind\x18\x02 \x01(\x0e2\x0e.metrics.MTypeR\x04kind\"R\n" +
+	"\x04kind\x18\x02 \x01(\x0e2\x0e.metrics.MTypeR\x04kind\x12=\n" +
+	"\x06labels\x18\x03 \x03(\v2%.metrics.GetMetricRequest.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x87\x01\n" +
 	"\x11GetMetricResponse\x12'\n" +
 	"\x06metric\x18\x01 \x01(\v2\x0f.metrics.MetricR\x06metric\x12\x14\n" +
-	"\x05error\x18\x02 \x01(\tR\x05error\"U\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\x123\n" +
+	"\bmetadata\x18\x03 \x01(\v2\x17.metrics.MetricMetadataR\bmetadata\"U\n" +
 	"\x12GetMetricsResponse\x12)\n" +
 	"\ametrics\x18\x01 \x03(\v2\x0f.metrics.MetricR\ametrics\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"\x95\x01\n" +
+	"\x13QueryMetricsRequest\x12\"\n" +
+	"\x04kind\x18\x01 \x01(\x0e2\x0e.metrics.MTypeR\x04kind\x12\x16\n" +
+	"\x06prefix\x18\x02 \x01(\tR\x06prefix\x12\x14\n" +
+	"\x05regex\x18\x03 \x01(\tR\x05regex\x12\x14\n" +
+	"\x05limit\x18\x04 \x01(\x05R\x05limit\x12\x16\n" +
+	"\x06cursor\x18\x05 \x01(\tR\x06cursor\"x\n" +
+	"\x14QueryMetricsResponse\x12)\n" +
+	"\ametrics\x18\x01 \x03(\v2\x0f.metrics.MetricR\ametrics\x12\x1f\n" +
+	"\vnext_cursor\x18\x02 \x01(\tR\n" +
+	"nextCursor\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\">\n" +
+	"\x13ListMetricsResponse\x12'\n" +
+	"\x06metric\x18\x01 \x01(\v2\x0f.metrics.MetricR\x06metric\"\x86\x01\n" +
+	"\x13UpdateMetricRequest\x12'\n" +
+	"\x06metric\x18\x01 \x01(\v2\x0f.metrics.MetricR\x06metric\x12\x1d\n" +
+	"\n" +
+	"if_version\x18\x02 \x01(\x03R\tifVersion\x12'\n" +
+	"\x04mode\x18\x03 \x01(\x0e2\x13.metrics.UpdateModeR\x04mode\"F\n" +
+	"\x14UpdateMetricResponse\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\x03R\aversion\x12\x14\n" +
+	"\x05error\x18\x02 \x01(\tR\x05error\"I\n" +
+	"\x13DeleteMetricRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\"\n" +
+	"\x04kind\x18\x02 \x01(\x0e2\x0e.metrics.MTypeR\x04kind\",\n" +
+	"\x14DeleteMetricResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\".\n" +
+	"\x14DeleteMetricsRequest\x12\x16\n" +
+	"\x06prefix\x18\x01 \x01(\tR\x06prefix\"G\n" +
+	"\x15DeleteMetricsResponse\x12\x18\n" +
+	"\adeleted\x18\x01 \x01(\x03R\adeleted\x12\x14\n" +
 	"\x05error\x18\x02 \x01(\tR\x05error\"%\n" +
+	"\x13ResetCounterRequest\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\",\n" +
+	"\x14ResetCounterResponse\x12\x14\n" +
+	"\x05error\x18\x01 \x01(\tR\x05error\"%\n" +
 	"\x13PingStorageResponse\x12\x0e\n" +
-	"\x02ok\x18\x01 \x01(\bR\x02ok*,\n" +
+	"\x02ok\x18\x01 \x01(\bR\x02ok*H\n" +
 	"\x05MType\x12\v\n" +
 	"\aUNKNOWN\x10\x00\x12\v\n" +
 	"\aCOUNTER\x10\x01\x12\t\n" +
-	"\x05GAUGE\x10\x022\x9f\x02\n" +
+	"\x05GAUGE\x10\x02\x12\r\n" +
+	"\tHISTOGRAM\x10\x03\x12\v\n" +
+	"\aSUMMARY\x10\x04*'\n" +
+	"\n" +
+	"UpdateMode\x12\a\n" +
+	"\x03SET\x10\x00\x12\a\n" +
+	"\x03MAX\x10\x01\x12\a\n" +
+	"\x03MIN\x10\x022\xea\x05\n" +
 	"\aMetrics\x12H\n" +
-	"\vSendMetrics\x12\x1b.metrics.SendMetricsRequest\x1a\x1c.metrics.SendMetricsResponse\x12B\n" +
+	"\vSendMetrics\x12\x1b.metrics.SendMetricsRequest\x1a\x1c.metrics.SendMetricsResponse\x12K\n" +
+	"\fUpdateMetric\x12\x1c.metrics.UpdateMetricRequest\x1a\x1d.metrics.UpdateMetricResponse\x12B\n" +
 	"\tGetMetric\x12\x19.metrics.GetMetricRequest\x1a\x1a.metrics.GetMetricResponse\x12A\n" +
 	"\n" +
-	"GetMetrics\x12\x16.google.protobuf.Empty\x1a\x1b.metrics.GetMetricsResponse\x12C\n" +
+	"GetMetrics\x12\x16.google.protobuf.Empty\x1a\x1b.metrics.GetMetricsResponse\x12K\n" +
+	"\fQueryMetrics\x12\x1c.metrics.QueryMetricsRequest\x1a\x1d.metrics.QueryMetricsResponse\x12E\n" +
+	"\vListMetrics\x12\x16.google.protobuf.Empty\x1a\x1c.metrics.ListMetricsResponse0\x01\x12K\n" +
+	"\fDeleteMetric\x12\x1c.metrics.DeleteMetricRequest\x1a\x1d.metrics.DeleteMetricResponse\x12N\n" +
+	"\rDeleteMetrics\x12\x1d.metrics.DeleteMetricsRequest\x1a\x1e.metrics.DeleteMetricsResponse\x12K\n" +
+	"\fResetCounter\x12\x1c.metrics.ResetCounterRequest\x1a\x1d.metrics.ResetCounterResponse\x12C\n" +
 	"\vPingStorage\x12\x16.google.protobuf.Empty\x1a\x1c.metrics.PingStorageResponseB\x12Z\x10ya-metrics/protob\beditionsp\xe8\a"
 
 var (
@@ -475,38 +1471,86 @@ func file_proto_metrics_proto_rawDescGZIP() []byte {
 	return file_proto_metrics_proto_rawDescData
 }
 
-var file_proto_metrics_proto_enumTypes = make([]protoimpl.EnumInfo, 1)
-var file_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_proto_metrics_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_proto_metrics_proto_msgTypes = make([]protoimpl.MessageInfo, 26)
 var file_proto_metrics_proto_goTypes = []any{
-	(MType)(0),                  // 0: metrics.MType
-	(*Metric)(nil),              // 1: metrics.Metric
-	(*SendMetricsRequest)(nil),  // 2: metrics.SendMetricsRequest
-	(*SendMetricsResponse)(nil), // 3: metrics.SendMetricsResponse
-	(*GetMetricRequest)(nil),    // 4: metrics.GetMetricRequest
-	(*GetMetricResponse)(nil),   // 5: metrics.GetMetricResponse
-	(*GetMetricsResponse)(nil),  // 6: metrics.GetMetricsResponse
-	(*PingStorageResponse)(nil), // 7: metrics.PingStorageResponse
-	(*emptypb.Empty)(nil),       // 8: google.protobuf.Empty
+	(MType)(0),                    // 0: metrics.MType
+	(UpdateMode)(0),               // 1: metrics.UpdateMode
+	(*Histogram)(nil),             // 2: metrics.Histogram
+	(*Summary)(nil),               // 3: metrics.Summary
+	(*Metric)(nil),                // 4: metrics.Metric
+	(*MetricMetadata)(nil),        // 5: metrics.MetricMetadata
+	(*SendMetricsRequest)(nil),    // 6: metrics.SendMetricsRequest
+	(*SendMetricsResponse)(nil),   // 7: metrics.SendMetricsResponse
+	(*GetMetricRequest)(nil),      // 8: metrics.GetMetricRequest
+	(*GetMetricResponse)(nil),     // 9: metrics.GetMetricResponse
+	(*GetMetricsResponse)(nil),    // 10: metrics.GetMetricsResponse
+	(*QueryMetricsRequest)(nil),   // 11: metrics.QueryMetricsRequest
+	(*QueryMetricsResponse)(nil),  // 12: metrics.QueryMetricsResponse
+	(*ListMetricsResponse)(nil),   // 13: metrics.ListMetricsResponse
+	(*UpdateMetricRequest)(nil),   // 14: metrics.UpdateMetricRequest
+	(*UpdateMetricResponse)(nil),  // 15: metrics.UpdateMetricResponse
+	(*DeleteMetricRequest)(nil),   // 16: metrics.DeleteMetricRequest
+	(*DeleteMetricResponse)(nil),  // 17: metrics.DeleteMetricResponse
+	(*DeleteMetricsRequest)(nil),  // 18: metrics.DeleteMetricsRequest
+	(*DeleteMetricsResponse)(nil), // 19: metrics.DeleteMetricsResponse
+	(*ResetCounterRequest)(nil),   // 20: metrics.ResetCounterRequest
+	(*ResetCounterResponse)(nil),  // 21: metrics.ResetCounterResponse
+	(*PingStorageResponse)(nil),   // 22: metrics.PingStorageResponse
+	nil,                           // 23: metrics.Summary.PositiveEntry
+	nil,                           // 24: metrics.Summary.NegativeEntry
+	nil,                           // 25: metrics.Summary.QuantilesEntry
+	nil,                           // 26: metrics.Metric.LabelsEntry
+	nil,                           // 27: metrics.GetMetricRequest.LabelsEntry
+	(*timestamppb.Timestamp)(nil), // 28: google.protobuf.Timestamp
+	(*emptypb.Empty)(nil),         // 29: google.protobuf.Empty
 }
 var file_proto_metrics_proto_depIdxs = []int32{
-	0, // 0: metrics.Metric.type:type_name -> metrics.MType
-	1, // 1: metrics.SendMetricsRequest.metrics:type_name -> metrics.Metric
-	0, // 2: metrics.GetMetricRequest.kind:type_name -> metrics.MType
-	1, // 3: metrics.GetMetricResponse.metric:type_name -> metrics.Metric
-	1, // 4: metrics.GetMetricsResponse.metrics:type_name -> metrics.Metric
-	2, // 5: metrics.Metrics.SendMetrics:input_type -> metrics.SendMetricsRequest
-	4, // 6: metrics.Metrics.GetMetric:input_type -> metrics.GetMetricRequest
-	8, // 7: metrics.Metrics.GetMetrics:input_type -> google.protobuf.Empty
-	8, // 8: metrics.Metrics.PingStorage:input_type -> google.protobuf.Empty
-	3, // 9: metrics.Metrics.SendMetrics:output_type -> metrics.SendMetricsResponse
-	5, // 10: metrics.Metrics.GetMetric:output_type -> metrics.GetMetricResponse
-	6, // 11: metrics.Metrics.GetMetrics:output_type -> metrics.GetMetricsResponse
-	7, // 12: metrics.Metrics.PingStorage:output_type -> metrics.PingStorageResponse
-	9, // [9:13] is the sub-list for method output_type
-	5, // [5:9] is the sub-list for method input_type
-	5, // [5:5] is the sub-list for extension type_name
-	5, // [5:5] is the sub-list for extension extendee
-	0, // [0:5] is the sub-list for field type_name
+	23, // 0: metrics.Summary.positive:type_name -> metrics.Summary.PositiveEntry
+	24, // 1: metrics.Summary.negative:type_name -> metrics.Summary.NegativeEntry
+	25, // 2: metrics.Summary.quantiles:type_name -> metrics.Summary.QuantilesEntry
+	0,  // 3: metrics.Metric.type:type_name -> metrics.MType
+	26, // 4: metrics.Metric.labels:type_name -> metrics.Metric.LabelsEntry
+	2,  // 5: metrics.Metric.histogram:type_name -> metrics.Histogram
+	3,  // 6: metrics.Metric.summary:type_name -> metrics.Summary
+	28, // 7: metrics.MetricMetadata.updated_at:type_name -> google.protobuf.Timestamp
+	4,  // 8: metrics.SendMetricsRequest.metrics:type_name -> metrics.Metric
+	0,  // 9: metrics.GetMetricRequest.kind:type_name -> metrics.MType
+	27, // 10: metrics.GetMetricRequest.labels:type_name -> metrics.GetMetricRequest.LabelsEntry
+	4,  // 11: metrics.GetMetricResponse.metric:type_name -> metrics.Metric
+	5,  // 12: metrics.GetMetricResponse.metadata:type_name -> metrics.MetricMetadata
+	4,  // 13: metrics.GetMetricsResponse.metrics:type_name -> metrics.Metric
+	0,  // 14: metrics.QueryMetricsRequest.kind:type_name -> metrics.MType
+	4,  // 15: metrics.QueryMetricsResponse.metrics:type_name -> metrics.Metric
+	4,  // 16: metrics.ListMetricsResponse.metric:type_name -> metrics.Metric
+	4,  // 17: metrics.UpdateMetricRequest.metric:type_name -> metrics.Metric
+	1,  // 18: metrics.UpdateMetricRequest.mode:type_name -> metrics.UpdateMode
+	0,  // 19: metrics.DeleteMetricRequest.kind:type_name -> metrics.MType
+	6,  // 20: metrics.Metrics.SendMetrics:input_type -> metrics.SendMetricsRequest
+	14, // 21: metrics.Metrics.UpdateMetric:input_type -> metrics.UpdateMetricRequest
+	8,  // 22: metrics.Metrics.GetMetric:input_type -> metrics.GetMetricRequest
+	29, // 23: metrics.Metrics.GetMetrics:input_type -> google.protobuf.Empty
+	11, // 24: metrics.Metrics.QueryMetrics:input_type -> metrics.QueryMetricsRequest
+	29, // 25: metrics.Metrics.ListMetrics:input_type -> google.protobuf.Empty
+	16, // 26: metrics.Metrics.DeleteMetric:input_type -> metrics.DeleteMetricRequest
+	18, // 27: metrics.Metrics.DeleteMetrics:input_type -> metrics.DeleteMetricsRequest
+	20, // 28: metrics.Metrics.ResetCounter:input_type -> metrics.ResetCounterRequest
+	29, // 29: metrics.Metrics.PingStorage:input_type -> google.protobuf.Empty
+	7,  // 30: metrics.Metrics.SendMetrics:output_type -> metrics.SendMetricsResponse
+	15, // 31: metrics.Metrics.UpdateMetric:output_type -> metrics.UpdateMetricResponse
+	9,  // 32: metrics.Metrics.GetMetric:output_type -> metrics.GetMetricResponse
+	10, // 33: metrics.Metrics.GetMetrics:output_type -> metrics.GetMetricsResponse
+	12, // 34: metrics.Metrics.QueryMetrics:output_type -> metrics.QueryMetricsResponse
+	13, // 35: metrics.Metrics.ListMetrics:output_type -> metrics.ListMetricsResponse
+	17, // 36: metrics.Metrics.DeleteMetric:output_type -> metrics.DeleteMetricResponse
+	19, // 37: metrics.Metrics.DeleteMetrics:output_type -> metrics.DeleteMetricsResponse
+	21, // 38: metrics.Metrics.ResetCounter:output_type -> metrics.ResetCounterResponse
+	22, // 39: metrics.Metrics.PingStorage:output_type -> metrics.PingStorageResponse
+	30, // [30:40] is the sub-list for method output_type
+	20, // [20:30] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
 }
 
 func init() { file_proto_metrics_proto_init() }
@@ -519,8 +1563,8 @@ func file_proto_metrics_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_metrics_proto_rawDesc), len(file_proto_metrics_proto_rawDesc)),
-			NumEnums:      1,
-			NumMessages:   7,
+			NumEnums:      2,
+			NumMessages:   26,
 			NumExtensions: 0,
 			NumServices:   1,
 		},